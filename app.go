@@ -2,14 +2,25 @@
 package main
 
 import (
+	"Loom/pkg/api"
+	"Loom/pkg/autoresponder"
+	"Loom/pkg/backend"
 	"Loom/pkg/core"
 	"Loom/pkg/db"
+	"Loom/pkg/drafts"
+	"Loom/pkg/media"
 	"Loom/pkg/models"
+	"Loom/pkg/notifications"
 	"Loom/pkg/providers"
+	"Loom/pkg/readstate"
+	"Loom/pkg/scheduler"
+	"Loom/pkg/templates"
+	"Loom/pkg/webhooks"
 	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
@@ -22,6 +33,7 @@ import (
 	"path/filepath"
 	"regexp"
 	goruntime "runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -38,9 +50,13 @@ type App struct {
 	ctx             context.Context
 	provider        core.Provider // Use the interface
 	providerManager *core.ProviderManager
-	eventChan       <-chan core.ProviderEvent
 	eventCancel     context.CancelFunc
 	systemTray      *menu.Menu
+	notifier        *notifications.Engine
+	responder       *autoresponder.Responder
+	scheduler       *scheduler.Scheduler
+	webhooks        *webhooks.Dispatcher
+	apiServer       *api.Server
 }
 
 // NewApp creates a new App application struct
@@ -48,36 +64,6 @@ func NewApp() *App {
 	return &App{}
 }
 
-// cleanupSelfReceipts removes receipts where the user is the sender of the message
-// This cleans up incorrectly stored receipts from previous versions
-func cleanupSelfReceipts() {
-	if db.DB == nil {
-		return
-	}
-
-	// Find all receipts where user_id matches the sender_id of the message
-	var receiptsToDelete []models.MessageReceipt
-	err := db.DB.
-		Joins("JOIN messages ON messages.id = message_receipts.message_id").
-		Where("message_receipts.user_id = messages.sender_id").
-		Find(&receiptsToDelete).Error
-
-	if err != nil {
-		log.Printf("Warning: Failed to find self receipts to clean up: %v", err)
-		return
-	}
-
-	if len(receiptsToDelete) > 0 {
-		log.Printf("Found %d self receipts to clean up", len(receiptsToDelete))
-		err = db.DB.Delete(&receiptsToDelete).Error
-		if err != nil {
-			log.Printf("Warning: Failed to delete self receipts: %v", err)
-		} else {
-			log.Printf("Successfully cleaned up %d self receipts", len(receiptsToDelete))
-		}
-	}
-}
-
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
@@ -89,178 +75,43 @@ func (a *App) startup(ctx context.Context) {
 	}
 
 	// Clean up incorrectly stored self receipts
-	cleanupSelfReceipts()
+	backend.CleanupSelfReceipts()
+
+	// Initialize the notification rules engine
+	a.notifier = notifications.NewEngine(a.emitNotification)
 
 	// Initialize provider manager
 	a.providerManager = core.NewProviderManager()
 	fmt.Printf("App.startup: ProviderManager initialized\n")
 
-	// Register available providers
-	a.providerManager.RegisterProvider("mock", core.ProviderInfo{
-		ID:          "mock",
-		Name:        "Mock",
-		Description: "Mock provider for development and testing",
-		ConfigSchema: map[string]interface{}{
-			"type":       "object",
-			"properties": map[string]interface{}{},
-		},
-	}, func() core.Provider {
-		return providers.NewMockProvider()
-	})
-
-	a.providerManager.RegisterProvider("whatsapp", core.ProviderInfo{
-		ID:          "whatsapp",
-		Name:        "WhatsApp",
-		Description: "WhatsApp messaging provider",
-		ConfigSchema: map[string]interface{}{
-			"type":       "object",
-			"properties": map[string]interface{}{},
-		},
-	}, func() core.Provider {
-		return providers.NewWhatsAppProvider()
-	})
-
-	a.providerManager.RegisterProvider("slack", core.ProviderInfo{
-		ID:          "slack",
-		Name:        "Slack",
-		Description: "Slack messaging provider",
-		ConfigSchema: map[string]interface{}{
-			"type": "object",
-			"properties": map[string]interface{}{
-				"token": map[string]interface{}{
-					"type":        "string",
-					"title":       "Auth Token",
-					"description": "Bot (xoxb-), User (xoxp-), or Client (xoxc-) Token",
-				},
-				"d_cookie": map[string]interface{}{
-					"type":        "string",
-					"title":       "d Cookie (Optional)",
-					"description": "Required for Client Tokens (xoxc). Enter the 'd' cookie value (starts with xoxd-).",
-				},
-			},
-			"required": []string{"token"},
-		},
-	}, func() core.Provider {
-		return providers.NewSlackProvider()
-	})
-
-	// Load and restore providers from database
-	configs, err := a.providerManager.LoadProviderConfigs()
-	if err != nil {
-		fmt.Printf("App.startup: Warning: Failed to load provider configs: %v\n", err)
-		configs = []models.ProviderConfiguration{}
-	}
-	fmt.Printf("App.startup: Loaded %d provider configs from database\n", len(configs))
-	if len(configs) == 0 {
-		fmt.Printf("App.startup: No provider configs found in database, skipping restoration\n")
-	}
+	// Start the away-mode auto-responder (it listens to all provider instances)
+	a.responder = autoresponder.NewResponder(a.providerManager)
+	go a.responder.Run(ctx)
 
-	// Restore providers from database
-	var activeProvider core.Provider
-	restoredCount := 0
-	for _, config := range configs {
-		// Capture config for goroutine
-		providerConfig := config
-		fmt.Printf("App.startup: Attempting to restore provider %s (InstanceID: %s, InstanceName: %s, IsActive: %v)\n",
-			providerConfig.ProviderID, providerConfig.InstanceID, providerConfig.InstanceName, providerConfig.IsActive)
-
-		fmt.Printf("App.startup: About to call RestoreProvider for %s (instanceID: %s)\n", providerConfig.ProviderID, providerConfig.InstanceID)
-		provider, err := a.providerManager.RestoreProvider(providerConfig)
-		if err != nil {
-			fmt.Printf("App.startup: ERROR - Failed to restore provider %s: %v\n", providerConfig.ProviderID, err)
-			continue
-		}
-		fmt.Printf("App.startup: Successfully restored provider %s (instanceID: %s) from database, provider is nil: %v\n",
-			providerConfig.ProviderID, providerConfig.InstanceID, provider == nil)
-		restoredCount++
+	// Start sending the messages of the local scheduler
+	a.scheduler = scheduler.NewScheduler(a.providerManager)
+	go a.scheduler.Run(ctx)
 
-		// Store the instanceID for later use
-		instanceID := providerConfig.InstanceID
-		if instanceID == "" {
-			instanceID = fmt.Sprintf("%s-1", providerConfig.ProviderID)
-		}
+	// Start forwarding provider events to the configured webhooks
+	a.webhooks = webhooks.NewDispatcher(a.providerManager)
+	go a.webhooks.Run(ctx)
 
-		// Only connect the provider if it's already authenticated
-		// Providers that need authentication (like WhatsApp) should only be connected
-		// when the user explicitly requests it via the UI
-		isAuth := provider.IsAuthenticated()
-		fmt.Printf("App.startup: Provider %s (instanceID: %s) IsAuthenticated: %v\n", providerConfig.ProviderID, instanceID, isAuth)
-		if isAuth {
-			if err := provider.Connect(); err != nil {
-				log.Printf("Warning: Failed to connect provider %s: %v", providerConfig.ProviderID, err)
-				continue
-			}
-			log.Printf("Provider %s (instanceID: %s) connected successfully", providerConfig.ProviderID, instanceID)
-		} else {
-			log.Printf("Provider %s (instanceID: %s) is not authenticated yet, skipping auto-connect. User must configure it first.", providerConfig.ProviderID, instanceID)
-			// Don't set as active if not authenticated
-			if providerConfig.IsActive {
-				log.Printf("Warning: Provider %s is marked as active but not authenticated, clearing active status", providerConfig.ProviderID)
-				// Clear active status in database
-				if db.DB != nil {
-					db.DB.Model(&models.ProviderConfiguration{}).Where("instance_id = ?", instanceID).Update("is_active", false)
-				}
-				providerConfig.IsActive = false
-			}
-			// Continue to next provider, but the provider is still in pm.providers from RestoreProvider
-			continue
+	// Start the local API if requested through the environment
+	if addr := os.Getenv("LOOM_API_ADDR"); addr != "" {
+		if _, err := a.EnableLocalAPI(addr); err != nil {
+			log.Printf("Warning: Failed to start local API: %v", err)
 		}
+	}
 
-		// Set as active provider if marked as active (BEFORE sync to ensure events are captured)
-		if providerConfig.IsActive {
-			activeProvider = provider
-			a.provider = provider
-			// Also set the active instance ID in the provider manager
-			instanceID := providerConfig.InstanceID
-			if instanceID == "" {
-				instanceID = fmt.Sprintf("%s-1", providerConfig.ProviderID)
-			}
-			if err := a.providerManager.SetActiveProvider(instanceID); err != nil {
-				log.Printf("Warning: Failed to set active provider instance %s: %v", instanceID, err)
-			} else {
-				fmt.Printf("App.startup: Set provider %s (instanceID: %s) as active provider\n", providerConfig.ProviderID, instanceID)
-			}
-
-			// Sync missed messages on startup
-			// Do this AFTER setting as active provider so events are captured
-			// Always sync on startup to ensure we have the latest messages and show the sync status
-			go func(p core.Provider, instID string) {
-				// Wait longer to ensure event listener is started and ready
-				// startEventListener is called after startup() completes, so we need to wait
-				time.Sleep(2 * time.Second)
-
-				var since time.Time
-				if providerConfig.LastSyncAt != nil {
-					// Use last sync time, but ensure we sync at least the last 24 hours
-					lastSync := *providerConfig.LastSyncAt
-					oneDayAgo := time.Now().Add(-24 * time.Hour)
-					if lastSync.Before(oneDayAgo) {
-						since = oneDayAgo
-					} else {
-						since = lastSync
-					}
-					fmt.Printf("App.startup: Syncing provider instance %s since last sync: %s\n", instID, since.Format("2006-01-02 15:04:05"))
-				} else {
-					// First time sync - sync last 1 year to get all conversations
-					since = time.Now().Add(-365 * 24 * time.Hour) // 1 year ago
-					fmt.Printf("App.startup: First time sync for provider instance %s, syncing since %s\n", instID, since.Format("2006-01-02 15:04:05"))
-				}
+	// Register available providers
+	backend.RegisterProviders(a.providerManager)
 
-				if err := p.SyncHistory(since); err != nil {
-					log.Printf("Warning: Failed to sync history for provider instance %s: %v", instID, err)
-				} else {
-					// Update last sync time
-					now := time.Now()
-					if db.DB != nil {
-						db.DB.Model(&models.ProviderConfiguration{}).Where("instance_id = ?", instID).Update("last_sync_at", now)
-					}
-				}
-			}(provider, instanceID)
-		}
+	// Restore providers from database, syncing the active one once the event listener is ready
+	// (startEventListener is called after startup() completes)
+	activeProvider, _ := backend.RestoreProviders(a.providerManager, backend.RestoreOptions{SyncDelay: 2 * time.Second})
+	if activeProvider != nil {
+		a.provider = activeProvider
 	}
-
-	fmt.Printf("App.startup: Finished restoring providers. Restored %d/%d providers. Active provider: %v\n",
-		restoredCount, len(configs), activeProvider != nil)
 	fmt.Printf("App.startup: a.provider is nil: %v\n", a.provider == nil)
 
 	// Log current state of pm.providers
@@ -353,286 +204,278 @@ func (a *App) startEventListener(ctx context.Context) {
 	eventCtx, cancel := context.WithCancel(ctx)
 	a.eventCancel = cancel
 
-	// Start listening to provider events and send them to the frontend
-	eventChan, err := a.provider.StreamEvents()
-	if err != nil {
-		log.Printf("Failed to get event stream: %v", err)
-		cancel()
-		return
-	}
-
-	a.eventChan = eventChan
-
+	// Every instance updates the stored state, the unread counters and the notifications through
+	// the recorder, which never drops events; only the events of the active instance are shown.
+	recorder := backend.NewRecorder(a.providerManager, a.notifier)
+	recorder.SetUnreadHandler(a.emitUnreadUpdate)
+	recorder.SetEventHandler(a.emitProviderEvent)
 	go func() {
-		for {
-			select {
-			case event, ok := <-eventChan:
-				if !ok {
-					log.Printf("Event channel closed")
-					return
-				}
-				switch e := event.(type) {
-				case core.MessageEvent:
-					log.Printf("App: Received MessageEvent for conversation %s, message ID: %s", e.Message.ProtocolConvID, e.Message.ProtocolMsgID)
-					// Convert avatar path to base64 data URL if present
-					if e.Message.SenderAvatarURL != "" {
-						avatarURL := a.GetAvatar(e.Message.SenderAvatarURL)
-						if avatarURL != "" {
-							e.Message.SenderAvatarURL = avatarURL
-						}
-					}
-					// Serialize the message to JSON
-					msgJSON, err := json.Marshal(e.Message)
-					if err != nil {
-						log.Printf("Failed to marshal message: %v", err)
-						continue
-					}
-					// Emit the event to the frontend using the app context
-					log.Printf("App: Emitting new-message event to frontend for message %s", e.Message.ProtocolMsgID)
-					previewLen := 100
-					if len(msgJSON) < previewLen {
-						previewLen = len(msgJSON)
-					}
-					log.Printf("App: Message JSON length: %d bytes, first %d chars: %s", len(msgJSON), previewLen, string(msgJSON[:previewLen]))
-					if a.ctx != nil {
-						runtime.EventsEmit(a.ctx, "new-message", string(msgJSON))
-						log.Printf("App: Event emitted (no error returned)")
-					} else {
-						log.Printf("App: ERROR - a.ctx is nil, cannot emit event")
-					}
-
-				case core.ReactionEvent:
-					log.Printf("App: Received ReactionEvent: conversation=%s, message=%s, user=%s, emoji=%s, added=%v", e.ConversationID, e.MessageID, e.UserID, e.Emoji, e.Added)
+		recorder.Run(eventCtx)
+		log.Printf("Event listener stopped")
+	}()
+}
 
-					// Clean emoji if it's from Slack (contains skin-tone modifier)
-					// This ensures reactions are stored consistently regardless of source
-					cleanedEmoji := e.Emoji
-					if strings.Contains(e.Emoji, ":skin-tone-") {
-						// Use regex to remove skin-tone modifiers (same logic as Slack provider)
-						re := regexp.MustCompile(`:skin-tone-[2-6]:`)
-						cleanedEmoji = re.ReplaceAllString(e.Emoji, "")
-					}
+// emitProviderEvent sends a recorded event of the active provider instance to the frontend.
+func (a *App) emitProviderEvent(instanceEvent core.InstanceEvent) {
+	if instanceEvent.InstanceID != a.providerManager.GetActiveInstanceID() {
+		return
+	}
+	switch e := instanceEvent.Event.(type) {
+	case core.MessageEvent:
+		log.Printf("App: Received MessageEvent for conversation %s, message ID: %s", e.Message.ProtocolConvID, e.Message.ProtocolMsgID)
+		// Convert avatar path to base64 data URL if present
+		if e.Message.SenderAvatarURL != "" {
+			avatarURL := a.GetAvatar(e.Message.SenderAvatarURL)
+			if avatarURL != "" {
+				e.Message.SenderAvatarURL = avatarURL
+			}
+		}
+		// Serialize the message to JSON
+		msgJSON, err := json.Marshal(e.Message)
+		if err != nil {
+			log.Printf("Failed to marshal message: %v", err)
+			return
+		}
+		// Emit the event to the frontend using the app context
+		log.Printf("App: Emitting new-message event to frontend for message %s", e.Message.ProtocolMsgID)
+		previewLen := 100
+		if len(msgJSON) < previewLen {
+			previewLen = len(msgJSON)
+		}
+		log.Printf("App: Message JSON length: %d bytes, first %d chars: %s", len(msgJSON), previewLen, string(msgJSON[:previewLen]))
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, "new-message", string(msgJSON))
+			log.Printf("App: Event emitted (no error returned)")
+		} else {
+			log.Printf("App: ERROR - a.ctx is nil, cannot emit event")
+		}
 
-					// Save reaction to database
-					if db.DB != nil {
-						// Find the message by protocol message ID
-						var message models.Message
-						if err := db.DB.Where("protocol_msg_id = ? AND protocol_conv_id = ?", e.MessageID, e.ConversationID).First(&message).Error; err == nil {
-							if e.Added {
-								// Check if reaction already exists (using cleaned emoji for comparison)
-								var existingReaction models.Reaction
-								reactionExists := db.DB.Where("message_id = ? AND user_id = ? AND emoji = ?", message.ID, e.UserID, cleanedEmoji).First(&existingReaction).Error == nil
-
-								if !reactionExists {
-									// Create new reaction with cleaned emoji
-									reaction := models.Reaction{
-										MessageID: message.ID,
-										UserID:    e.UserID,
-										Emoji:     cleanedEmoji,
-										CreatedAt: time.Unix(e.Timestamp, 0),
-										UpdatedAt: time.Unix(e.Timestamp, 0),
-									}
-									if err := db.DB.Create(&reaction).Error; err != nil {
-										log.Printf("App: Failed to save reaction to database: %v", err)
-									} else {
-										log.Printf("App: Saved reaction to database for message %s, user %s, emoji %s (cleaned from %s)", e.MessageID, e.UserID, cleanedEmoji, e.Emoji)
-									}
-								} else {
-									log.Printf("App: Reaction already exists in database for message %s, user %s, emoji %s", e.MessageID, e.UserID, cleanedEmoji)
-								}
-							} else {
-								// Remove reaction (using cleaned emoji for comparison)
-								var existingReaction models.Reaction
-								if err := db.DB.Where("message_id = ? AND user_id = ? AND emoji = ?", message.ID, e.UserID, cleanedEmoji).First(&existingReaction).Error; err == nil {
-									if err := db.DB.Delete(&existingReaction).Error; err != nil {
-										log.Printf("App: Failed to delete reaction from database: %v", err)
-									} else {
-										log.Printf("App: Deleted reaction from database for message %s, user %s, emoji %s", e.MessageID, e.UserID, cleanedEmoji)
-									}
-								} else {
-									log.Printf("App: Reaction not found in database for deletion: message %s, user %s, emoji %s", e.MessageID, e.UserID, cleanedEmoji)
-								}
-							}
-						} else {
-							log.Printf("App: Message not found in database for reaction: conversation %s, message %s (this is OK if message hasn't been loaded yet)", e.ConversationID, e.MessageID)
-						}
-					}
+	case core.ReactionEvent:
+		// Always emit the event to the frontend, even if message wasn't found in database
+		// The frontend will handle updating the UI when the message is loaded
+		reactionJSON, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("App: Failed to marshal reaction: %v", err)
+			return
+		}
+		// Emit the event to the frontend
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, "reaction", string(reactionJSON))
+			log.Printf("App: Emitted reaction event to frontend: conversation=%s, message=%s, emoji=%s", e.ConversationID, e.MessageID, e.Emoji)
+		}
 
-					// Update the event with cleaned emoji before emitting to frontend
-					e.Emoji = cleanedEmoji
+	case core.TypingEvent:
+		// Serialize the typing indicator to JSON
+		typingJSON, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("Failed to marshal typing indicator: %v", err)
+			return
+		}
+		// Emit the event to the frontend
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, "typing", string(typingJSON))
+		}
 
-					// Always emit the event to the frontend, even if message wasn't found in database
-					// The frontend will handle updating the UI when the message is loaded
-					reactionJSON, err := json.Marshal(e)
-					if err != nil {
-						log.Printf("App: Failed to marshal reaction: %v", err)
-						continue
-					}
-					// Emit the event to the frontend
-					if a.ctx != nil {
-						runtime.EventsEmit(a.ctx, "reaction", string(reactionJSON))
-						log.Printf("App: Emitted reaction event to frontend: conversation=%s, message=%s, emoji=%s", e.ConversationID, e.MessageID, e.Emoji)
-					}
+	case core.ContactStatusEvent:
+		// Serve cached custom status emojis as data URLs, the frontend cannot load local files
+		if e.StatusEmojiURL != "" && !strings.HasPrefix(e.StatusEmojiURL, "http") {
+			e.StatusEmojiURL = a.GetAvatar(e.StatusEmojiURL)
+		}
+		// Serialize the contact status to JSON
+		statusJSON, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("Failed to marshal contact status: %v", err)
+			return
+		}
+		// Emit the event to the frontend
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, "contact-status", string(statusJSON))
+			// If this is a refresh event, also invalidate the contacts query
+			if e.UserID == "refresh" && (e.Status == "sync_complete" || e.Status == "message_received") {
+				runtime.EventsEmit(a.ctx, "contacts-refresh", "{}")
+			}
+		}
 
-				case core.TypingEvent:
-					// Serialize the typing indicator to JSON
-					typingJSON, err := json.Marshal(e)
-					if err != nil {
-						log.Printf("Failed to marshal typing indicator: %v", err)
-						continue
-					}
-					// Emit the event to the frontend
-					if a.ctx != nil {
-						runtime.EventsEmit(a.ctx, "typing", string(typingJSON))
-					}
+	case core.PresenceEvent:
+		// Serialize the presence event to JSON
+		presenceJSON, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("Failed to marshal presence event: %v", err)
+			return
+		}
+		// Emit the event to the frontend
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, "presence", string(presenceJSON))
+			log.Printf("App: Emitted presence event to frontend: user=%s, online=%v", e.UserID, e.IsOnline)
+		}
 
-				case core.ContactStatusEvent:
-					// Serialize the contact status to JSON
-					statusJSON, err := json.Marshal(e)
-					if err != nil {
-						log.Printf("Failed to marshal contact status: %v", err)
-						continue
-					}
-					// Emit the event to the frontend
-					if a.ctx != nil {
-						runtime.EventsEmit(a.ctx, "contact-status", string(statusJSON))
-						// If this is a refresh event, also invalidate the contacts query
-						if e.UserID == "refresh" && (e.Status == "sync_complete" || e.Status == "message_received") {
-							runtime.EventsEmit(a.ctx, "contacts-refresh", "{}")
-						}
-					}
+	case core.GroupChangeEvent:
+		// Serialize the group change to JSON
+		groupChangeJSON, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("Failed to marshal group change: %v", err)
+			return
+		}
+		// Emit the event to the frontend
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, "group-change", string(groupChangeJSON))
+		}
 
-				case core.PresenceEvent:
-					// Serialize the presence event to JSON
-					presenceJSON, err := json.Marshal(e)
-					if err != nil {
-						log.Printf("Failed to marshal presence event: %v", err)
-						continue
-					}
-					// Emit the event to the frontend
-					if a.ctx != nil {
-						runtime.EventsEmit(a.ctx, "presence", string(presenceJSON))
-						log.Printf("App: Emitted presence event to frontend: user=%s, online=%v", e.UserID, e.IsOnline)
-					}
+	case core.ReceiptEvent:
+		// Serialize the receipt to JSON
+		receiptJSON, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("Failed to marshal receipt: %v", err)
+			return
+		}
+		// Emit the event to the frontend
+		log.Printf("App: Received ReceiptEvent for conversation %s, message %s, type: %s", e.ConversationID, e.MessageID, e.ReceiptType)
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, "receipt", string(receiptJSON))
+			log.Printf("App: Emitted receipt event to frontend")
+		}
 
-				case core.GroupChangeEvent:
-					// Serialize the group change to JSON
-					groupChangeJSON, err := json.Marshal(e)
-					if err != nil {
-						log.Printf("Failed to marshal group change: %v", err)
-						continue
-					}
-					// Emit the event to the frontend
-					if a.ctx != nil {
-						runtime.EventsEmit(a.ctx, "group-change", string(groupChangeJSON))
-					}
+	case core.RetryReceiptEvent:
+		// Serialize the retry receipt to JSON
+		retryReceiptJSON, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("Failed to marshal retry receipt: %v", err)
+			return
+		}
+		// Emit the event to the frontend
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, "retry-receipt", string(retryReceiptJSON))
+		}
+	case core.SyncStatusEvent:
+		// Serialize the sync status to JSON
+		syncStatusJSON, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("Failed to marshal sync status: %v", err)
+			return
+		}
+		// Emit the event to the frontend
+		log.Printf("App: Received SyncStatusEvent: status=%s, message=%s, progress=%d\n", e.Status, e.Message, e.Progress)
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, "sync-status", string(syncStatusJSON))
+			log.Printf("App: Emitted sync-status event to frontend: %s\n", string(syncStatusJSON))
+		} else {
+			log.Printf("App: WARNING - ctx is nil, cannot emit sync-status event\n")
+		}
 
-				case core.ReceiptEvent:
-					// Save receipt to database
-					if db.DB != nil {
-						// Find the message by protocol message ID
-						var message models.Message
-						if err := db.DB.Where("protocol_msg_id = ? AND protocol_conv_id = ?", e.MessageID, e.ConversationID).First(&message).Error; err == nil {
-							// Don't save receipts from the message sender (we don't count ourselves)
-							if e.UserID == message.SenderID {
-								log.Printf("App: Skipping receipt from sender themselves for message %s, user %s", e.MessageID, e.UserID)
-							} else {
-								// Check if receipt already exists
-								var existingReceipt models.MessageReceipt
-								receiptExists := db.DB.Where("message_id = ? AND user_id = ? AND receipt_type = ?", message.ID, e.UserID, string(e.ReceiptType)).First(&existingReceipt).Error == nil
-
-								if !receiptExists {
-									// Create new receipt
-									receipt := models.MessageReceipt{
-										MessageID:   message.ID,
-										UserID:      e.UserID,
-										ReceiptType: string(e.ReceiptType),
-										Timestamp:   time.Unix(e.Timestamp, 0),
-									}
-									if err := db.DB.Create(&receipt).Error; err != nil {
-										log.Printf("Failed to save receipt to database: %v", err)
-									} else {
-										log.Printf("App: Saved receipt to database for message %s, user %s, type %s", e.MessageID, e.UserID, e.ReceiptType)
-									}
-								} else {
-									// Update existing receipt timestamp if newer
-									receiptTimestamp := time.Unix(e.Timestamp, 0)
-									if receiptTimestamp.After(existingReceipt.Timestamp) {
-										existingReceipt.Timestamp = receiptTimestamp
-										if err := db.DB.Save(&existingReceipt).Error; err != nil {
-											log.Printf("Failed to update receipt in database: %v", err)
-										} else {
-											log.Printf("App: Updated receipt in database for message %s, user %s, type %s", e.MessageID, e.UserID, e.ReceiptType)
-										}
-									}
-								}
-							}
-						} else {
-							log.Printf("App: Message not found for receipt: conversation %s, message %s", e.ConversationID, e.MessageID)
-						}
-					}
+	case core.ThreadUpdateEvent:
+		// Serialize the thread update to JSON
+		threadJSON, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("Failed to marshal thread update: %v", err)
+			return
+		}
+		// Emit the event to the frontend
+		log.Printf("App: Received ThreadUpdateEvent: conversation=%s, parent=%s, replies=%d", e.ConversationID, e.ParentMessageID, e.ReplyCount)
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, "thread-update", string(threadJSON))
+		}
 
-					// Serialize the receipt to JSON
-					receiptJSON, err := json.Marshal(e)
-					if err != nil {
-						log.Printf("Failed to marshal receipt: %v", err)
-						continue
-					}
-					// Emit the event to the frontend
-					log.Printf("App: Received ReceiptEvent for conversation %s, message %s, type: %s", e.ConversationID, e.MessageID, e.ReceiptType)
-					if a.ctx != nil {
-						runtime.EventsEmit(a.ctx, "receipt", string(receiptJSON))
-						log.Printf("App: Emitted receipt event to frontend")
-					}
+	case core.UploadProgressEvent:
+		// Serialize the upload progress to JSON
+		progressJSON, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("Failed to marshal upload progress: %v", err)
+			return
+		}
+		// Emit the event to the frontend
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, "upload-progress", string(progressJSON))
+		}
 
-				case core.RetryReceiptEvent:
-					// Serialize the retry receipt to JSON
-					retryReceiptJSON, err := json.Marshal(e)
-					if err != nil {
-						log.Printf("Failed to marshal retry receipt: %v", err)
-						continue
-					}
-					// Emit the event to the frontend
-					if a.ctx != nil {
-						runtime.EventsEmit(a.ctx, "retry-receipt", string(retryReceiptJSON))
-					}
-				case core.SyncStatusEvent:
-					// Serialize the sync status to JSON
-					syncStatusJSON, err := json.Marshal(e)
-					if err != nil {
-						log.Printf("Failed to marshal sync status: %v", err)
-						continue
-					}
-					// Emit the event to the frontend
-					log.Printf("App: Received SyncStatusEvent: status=%s, message=%s, progress=%d\n", e.Status, e.Message, e.Progress)
-					if a.ctx != nil {
-						runtime.EventsEmit(a.ctx, "sync-status", string(syncStatusJSON))
-						log.Printf("App: Emitted sync-status event to frontend: %s\n", string(syncStatusJSON))
-					} else {
-						log.Printf("App: WARNING - ctx is nil, cannot emit sync-status event\n")
-					}
-				}
-			case <-eventCtx.Done():
-				log.Printf("Event listener stopped")
-				return
-			}
+	case core.AttachmentUpdateEvent:
+		// Serialize the updated attachments to JSON
+		attachmentJSON, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("Failed to marshal attachment update: %v", err)
+			return
 		}
-	}()
+		// Emit the event to the frontend
+		log.Printf("App: Received AttachmentUpdateEvent: conversation=%s, message=%s, attachments=%d", e.ConversationID, e.MessageID, len(e.Attachments))
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, "attachment-update", string(attachmentJSON))
+		}
+	}
 }
 
 // domReady is called when the frontend is ready.
 func (a *App) domReady(ctx context.Context) {
 	// Start listening to provider events
 	a.startEventListener(ctx)
+
+	// Restore the badge from the persisted unread counters
+	if totals, err := readstate.GetTotals(""); err == nil && totals.UnreadMessages > 0 {
+		if err := a.UpdateSystemTrayBadge(totals.UnreadMessages); err != nil {
+			log.Printf("App: Failed to restore system tray badge: %v", err)
+		}
+	}
 }
 
 // shutdown is called at application closure.
 func (a *App) shutdown(_ context.Context) {
+	a.DisableLocalAPI()
 	if a.provider != nil {
 		a.provider.Disconnect()
 	}
 }
 
+// LocalAPIInfo describes how to reach the local API.
+type LocalAPIInfo struct {
+	Enabled bool   `json:"enabled"`
+	Address string `json:"address,omitempty"`
+	Token   string `json:"token,omitempty"`
+}
+
+// EnableLocalAPI starts the loopback-only HTTP API on addr (default 127.0.0.1:7878).
+func (a *App) EnableLocalAPI(addr string) (*LocalAPIInfo, error) {
+	if a.apiServer != nil {
+		return a.GetLocalAPIInfo(), nil
+	}
+
+	token, err := api.LoadOrCreateToken()
+	if err != nil {
+		return nil, err
+	}
+	server, err := api.NewServer(a.providerManager, addr, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := server.Start(); err != nil {
+		return nil, err
+	}
+	a.apiServer = server
+	return &LocalAPIInfo{Enabled: true, Address: server.Addr(), Token: token}, nil
+}
+
+// DisableLocalAPI stops the local API if it is running.
+func (a *App) DisableLocalAPI() {
+	if a.apiServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.apiServer.Shutdown(ctx); err != nil {
+		log.Printf("Warning: Failed to stop local API: %v", err)
+	}
+	a.apiServer = nil
+}
+
+// GetLocalAPIInfo returns the address and token of the local API.
+func (a *App) GetLocalAPIInfo() *LocalAPIInfo {
+	if a.apiServer == nil {
+		return &LocalAPIInfo{Enabled: false}
+	}
+	token, err := api.LoadOrCreateToken()
+	if err != nil {
+		log.Printf("Warning: Failed to read local API token: %v", err)
+	}
+	return &LocalAPIInfo{Enabled: true, Address: a.apiServer.Addr(), Token: token}
+}
+
 // --- Methods Exposed to the Frontend ---
 
 // GetMetaContacts returns a list of unified contacts.
@@ -1219,7 +1062,14 @@ func (a *App) GetParticipantNames(participantIDs []string) (map[string]string, e
 
 // SendMessage sends a text message.
 func (a *App) SendMessage(conversationID string, text string) (*models.Message, error) {
-	return a.provider.SendMessage(conversationID, text, nil, nil)
+	if a.provider == nil {
+		return nil, fmt.Errorf("no active provider")
+	}
+	msg, err := a.provider.SendMessage(conversationID, text, nil, nil)
+	if err == nil {
+		a.afterSend(conversationID)
+	}
+	return msg, err
 }
 
 // SendReply sends a text message as a reply to another message.
@@ -1227,7 +1077,11 @@ func (a *App) SendReply(conversationID string, text string, quotedMessageID stri
 	if a.provider == nil {
 		return nil, fmt.Errorf("no active provider")
 	}
-	return a.provider.SendReply(conversationID, text, quotedMessageID)
+	msg, err := a.provider.SendReply(conversationID, text, quotedMessageID)
+	if err == nil {
+		a.afterSend(conversationID)
+	}
+	return msg, err
 }
 
 // SendFile sends a file to a conversation.
@@ -1245,6 +1099,11 @@ func (a *App) SendFile(conversationID string, fileData string, fileName string,
 		return nil, fmt.Errorf("failed to decode file data: %w", err)
 	}
 
+	// The browser leaves the type empty or generic for files it doesn't know
+	if mimeType == "" || mimeType == media.DefaultMIMEType {
+		mimeType = media.DetectMIME(data, fileName)
+	}
+
 	// Create attachment
 	attachment := &core.Attachment{
 		FileName: fileName,
@@ -1253,7 +1112,11 @@ func (a *App) SendFile(conversationID string, fileData string, fileName string,
 		Data:     data,
 	}
 
-	return a.provider.SendFile(conversationID, attachment, nil)
+	msg, err := a.provider.SendFile(conversationID, attachment, nil)
+	if err == nil {
+		a.afterSend(conversationID)
+	}
+	return msg, err
 }
 
 // EditMessage edits an existing message.
@@ -1305,27 +1168,8 @@ func (a *App) SendFileFromPath(conversationID string, filePath string) (*models.
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	// Determine MIME type from file extension
-	mimeType := "application/octet-stream"
-	ext := filepath.Ext(filePath)
-	switch strings.ToLower(ext) {
-	case ".jpg", ".jpeg":
-		mimeType = "image/jpeg"
-	case ".png":
-		mimeType = "image/png"
-	case ".gif":
-		mimeType = "image/gif"
-	case ".webp":
-		mimeType = "image/webp"
-	case ".mp4":
-		mimeType = "video/mp4"
-	case ".mp3":
-		mimeType = "audio/mpeg"
-	case ".pdf":
-		mimeType = "application/pdf"
-	case ".xls", ".xlsx":
-		mimeType = "application/vnd.ms-excel"
-	}
+	// Determine MIME type from the file content, falling back to its extension
+	mimeType := media.DetectMIME(data, filePath)
 
 	// Get filename from path
 	fileName := filepath.Base(filePath)
@@ -1338,14 +1182,42 @@ func (a *App) SendFileFromPath(conversationID string, filePath string) (*models.
 		Data:     data,
 	}
 
-	return a.provider.SendFile(conversationID, attachment, nil)
+	msg, err := a.provider.SendFile(conversationID, attachment, nil)
+	if err == nil {
+		a.afterSend(conversationID)
+	}
+	return msg, err
 }
 
 // GetThreads returns all messages in a thread for a given parent message ID.
 func (a *App) GetThreads(parentMessageID string) ([]models.Message, error) {
+	if a.provider == nil {
+		return nil, fmt.Errorf("no active provider")
+	}
 	return a.provider.GetThreads(parentMessageID)
 }
 
+// SendThreadReply sends a text message in a thread.
+// If alsoSendToChannel is true, the reply is also shown in the conversation (Slack only).
+func (a *App) SendThreadReply(conversationID string, threadID string, text string, alsoSendToChannel bool) (*models.Message, error) {
+	if a.provider == nil {
+		return nil, fmt.Errorf("no active provider")
+	}
+	var msg *models.Message
+	var err error
+	if replier, ok := a.provider.(core.ThreadReplier); ok {
+		msg, err = replier.SendThreadReply(conversationID, threadID, text, alsoSendToChannel)
+	} else if alsoSendToChannel {
+		return nil, fmt.Errorf("this provider cannot send thread replies to the conversation")
+	} else {
+		msg, err = a.provider.SendMessage(conversationID, text, nil, &threadID)
+	}
+	if err == nil {
+		a.afterSend(conversationID)
+	}
+	return msg, err
+}
+
 // AddReaction adds a reaction (emoji) to a message.
 func (a *App) AddReaction(conversationID string, messageID string, emoji string) error {
 	if a.provider == nil {
@@ -1373,85 +1245,426 @@ func (a *App) MarkMessageAsRead(conversationID string, messageID string) error {
 		return err
 	}
 	// Only log errors, not every successful call to reduce log noise
+
+	marker, changed, err := readstate.MarkRead(a.providerManager.GetActiveInstanceID(), conversationID, messageID)
+	if err != nil {
+		log.Printf("App: Failed to update read marker for conversation %s: %v", conversationID, err)
+	} else if changed {
+		a.emitUnreadUpdate(marker)
+	}
 	return nil
 }
 
-// MarkMessageAsPlayed sends a played receipt for a specific voice message.
-func (a *App) MarkMessageAsPlayed(conversationID string, messageID string) error {
-	log.Printf("App: MarkMessageAsPlayed called for conversation %s, message %s", conversationID, messageID)
+// MarkConversationAsRead marks every message of a conversation as read.
+// A read receipt is sent for the latest incoming message known locally.
+func (a *App) MarkConversationAsRead(conversationID string) error {
 	if a.provider == nil {
 		return fmt.Errorf("no active provider")
 	}
-	err := a.provider.MarkMessageAsPlayed(conversationID, messageID)
+
+	marker, err := readstate.MarkConversationAsRead(a.provider, a.providerManager.GetActiveInstanceID(), conversationID)
 	if err != nil {
-		log.Printf("App: Failed to mark message as played: %v", err)
+		log.Printf("App: Failed to mark conversation %s as read: %v", conversationID, err)
 		return err
 	}
-	log.Printf("App: Successfully marked message %s as played in conversation %s", messageID, conversationID)
+	a.emitUnreadUpdate(marker)
 	return nil
 }
 
-// GetAvatar returns the avatar image as a base64 data URL.
-
-// GetAttachmentData reads an attachment file and returns it as a base64 data URL.
-func (a *App) GetAttachmentData(filePath string) (string, error) {
-	if filePath == "" {
-		return "", fmt.Errorf("file path is empty")
+// SupportsReadMarkers reports whether reading a conversation in Loom also marks it as read
+// on the service of the active provider (not the case for Slack bot tokens).
+func (a *App) SupportsReadMarkers() bool {
+	if a.provider == nil {
+		return false
 	}
+	return core.SupportsReadMarkers(a.provider)
+}
 
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return "", fmt.Errorf("file does not exist: %s", filePath)
+// GetReadMarkers returns the read markers of the active provider instance.
+func (a *App) GetReadMarkers() ([]models.ReadMarker, error) {
+	if a.providerManager == nil {
+		return []models.ReadMarker{}, nil
 	}
+	return readstate.List(a.providerManager.GetActiveInstanceID())
+}
 
-	// Read the file
-	file, err := os.Open(filePath)
+// GetUnreadTotals returns the unread totals across all provider instances.
+func (a *App) GetUnreadTotals() (readstate.Totals, error) {
+	return readstate.GetTotals("")
+}
+
+// emitUnreadUpdate pushes a changed read marker and the new totals to the frontend,
+// and refreshes the system tray badge.
+func (a *App) emitUnreadUpdate(marker *models.ReadMarker) {
+	totals, err := readstate.GetTotals("")
 	if err != nil {
-		return "", fmt.Errorf("failed to open file: %w", err)
+		log.Printf("App: Failed to compute unread totals: %v", err)
+		return
 	}
-	defer file.Close()
 
-	// Read file content
-	data, err := io.ReadAll(file)
+	payload := map[string]interface{}{
+		"marker": marker,
+		"totals": totals,
+	}
+	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
-		return "", fmt.Errorf("failed to read file: %w", err)
+		log.Printf("Failed to marshal unread update: %v", err)
+		return
 	}
-
-	// Determine MIME type from file extension
-	mimeType := "application/octet-stream"
-	ext := filepath.Ext(filePath)
-	switch ext {
-	case ".jpg", ".jpeg":
-		mimeType = "image/jpeg"
-	case ".png":
-		mimeType = "image/png"
-	case ".gif":
-		mimeType = "image/gif"
-	case ".webp":
-		mimeType = "image/webp"
-	case ".mp4":
-		mimeType = "video/mp4"
-	case ".mp3":
-		mimeType = "audio/mpeg"
-	case ".pdf":
-		mimeType = "application/pdf"
-	case ".xls", ".xlsx":
-		mimeType = "application/vnd.ms-excel"
-	case ".ogg":
-		mimeType = "audio/ogg"
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, "unread-update", string(payloadJSON))
 	}
 
-	// Encode to base64
-	base64Data := base64.StdEncoding.EncodeToString(data)
-	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64Data), nil
+	if err := a.UpdateSystemTrayBadge(totals.UnreadMessages); err != nil {
+		log.Printf("App: Failed to update system tray badge: %v", err)
+	}
 }
 
-// GetSlackEmojiURL returns the URL for a Slack emoji given the provider instance ID and emoji name
-// emojiName can be with or without colons (e.g., ":calendar:" or "calendar")
-func (a *App) GetSlackEmojiURL(providerInstanceID string, emojiName string) string {
-	if a.providerManager == nil || providerInstanceID == "" {
-		fmt.Printf("[App.GetSlackEmojiURL] ERROR: providerManager is nil or providerInstanceID is empty (providerInstanceID: %s)\n", providerInstanceID)
-		return ""
+// MarkMessageAsPlayed sends a played receipt for a specific voice message.
+func (a *App) MarkMessageAsPlayed(conversationID string, messageID string) error {
+	log.Printf("App: MarkMessageAsPlayed called for conversation %s, message %s", conversationID, messageID)
+	if a.provider == nil {
+		return fmt.Errorf("no active provider")
+	}
+	err := a.provider.MarkMessageAsPlayed(conversationID, messageID)
+	if err != nil {
+		log.Printf("App: Failed to mark message as played: %v", err)
+		return err
+	}
+	log.Printf("App: Successfully marked message %s as played in conversation %s", messageID, conversationID)
+	return nil
+}
+
+// myStatusManager returns the active provider if it can manage the status of the account.
+func (a *App) myStatusManager() (core.MyStatusManager, error) {
+	if a.provider == nil {
+		return nil, fmt.Errorf("no active provider")
+	}
+	manager, ok := a.provider.(core.MyStatusManager)
+	if !ok {
+		return nil, fmt.Errorf("this provider cannot change the status of the account")
+	}
+	return manager, nil
+}
+
+// GetMyStatusFeatures returns the parts of the status the active provider can set
+// (nil when it cannot set the status at all).
+func (a *App) GetMyStatusFeatures() *core.MyStatusFeatures {
+	manager, err := a.myStatusManager()
+	if err != nil {
+		return nil
+	}
+	features := manager.MyStatusFeatures()
+	return &features
+}
+
+// GetMyStatus returns the status of the account on the active provider.
+func (a *App) GetMyStatus() (*core.MyStatus, error) {
+	manager, err := a.myStatusManager()
+	if err != nil {
+		return nil, err
+	}
+	return manager.GetMyStatus()
+}
+
+// SetMyStatusText sets the status text of the account (Slack custom status, WhatsApp about).
+// An empty text and emoji clear the status; expiration is nil for a status without end.
+func (a *App) SetMyStatusText(text string, emoji string, expiration *time.Time) error {
+	manager, err := a.myStatusManager()
+	if err != nil {
+		return err
+	}
+	if err := manager.SetMyStatusText(text, emoji, expiration); err != nil {
+		log.Printf("App: Failed to set status text: %v", err)
+		return err
+	}
+	return nil
+}
+
+// SetMyPresence sets the presence of the account: "available" or "away".
+func (a *App) SetMyPresence(presence string) error {
+	manager, err := a.myStatusManager()
+	if err != nil {
+		return err
+	}
+	if err := manager.SetMyPresence(core.PresenceState(presence)); err != nil {
+		log.Printf("App: Failed to set presence %s: %v", presence, err)
+		return err
+	}
+	return nil
+}
+
+// SetDoNotDisturb pauses the notifications of the account for the given number of minutes;
+// 0 ends do not disturb.
+func (a *App) SetDoNotDisturb(minutes int) error {
+	manager, err := a.myStatusManager()
+	if err != nil {
+		return err
+	}
+	if err := manager.SetDoNotDisturb(time.Duration(minutes) * time.Minute); err != nil {
+		log.Printf("App: Failed to set do not disturb: %v", err)
+		return err
+	}
+	return nil
+}
+
+// channelDirectory returns the channel directory of the active provider.
+func (a *App) channelDirectory() (core.ChannelDirectory, error) {
+	if a.provider == nil {
+		return nil, fmt.Errorf("no active provider")
+	}
+	directory, ok := a.provider.(core.ChannelDirectory)
+	if !ok {
+		return nil, fmt.Errorf("this provider has no channel directory")
+	}
+	return directory, nil
+}
+
+// HasChannelDirectory reports whether the public channels of the active provider can be browsed and joined.
+func (a *App) HasChannelDirectory() bool {
+	_, err := a.channelDirectory()
+	return err == nil
+}
+
+// BrowseChannels returns a page of the public channels whose name, topic or purpose contains query.
+// cursor is "" for the first page, then the nextCursor of the previous page.
+func (a *App) BrowseChannels(query string, cursor string, limit int, includeArchived bool) (*core.ChannelDirectoryPage, error) {
+	directory, err := a.channelDirectory()
+	if err != nil {
+		return nil, err
+	}
+	return directory.BrowseChannels(query, cursor, limit, includeArchived)
+}
+
+// JoinChannel joins a public channel of the directory and refreshes the contact list.
+func (a *App) JoinChannel(channelID string) (*models.Conversation, error) {
+	directory, err := a.channelDirectory()
+	if err != nil {
+		return nil, err
+	}
+	conversation, err := directory.JoinChannel(channelID)
+	if err != nil {
+		log.Printf("App: Failed to join channel %s: %v", channelID, err)
+		return nil, err
+	}
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, "contacts-refresh", "{}")
+	}
+	return conversation, nil
+}
+
+// LeaveGroup leaves a group or channel of the active provider and refreshes the contact list.
+func (a *App) LeaveGroup(conversationID string) error {
+	if a.provider == nil {
+		return fmt.Errorf("no active provider")
+	}
+	if err := a.provider.LeaveGroup(conversationID); err != nil {
+		log.Printf("App: Failed to leave %s: %v", conversationID, err)
+		return err
+	}
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, "contacts-refresh", "{}")
+	}
+	return nil
+}
+
+// SetChannelArchived archives or unarchives a channel, where the workspace permits it.
+func (a *App) SetChannelArchived(channelID string, archived bool) error {
+	directory, err := a.channelDirectory()
+	if err != nil {
+		return err
+	}
+	if archived {
+		err = directory.ArchiveChannel(channelID)
+	} else {
+		err = directory.UnarchiveChannel(channelID)
+	}
+	if err != nil {
+		log.Printf("App: Failed to set channel %s archived=%v: %v", channelID, archived, err)
+		return err
+	}
+	return nil
+}
+
+// ScheduleMessage schedules a message in a conversation of the active provider instance,
+// in a thread if threadID is not nil. Slack messages are scheduled on Slack and sent even
+// when Loom is closed; the others are sent by Loom at postAt, or when it next runs.
+func (a *App) ScheduleMessage(conversationID string, text string, threadID *string, postAt time.Time) (*core.ScheduledMessage, error) {
+	if a.provider == nil {
+		return nil, fmt.Errorf("no active provider")
+	}
+	scheduled, err := a.scheduler.Schedule(a.providerManager.GetActiveInstanceID(), conversationID, text, threadID, postAt)
+	if err != nil {
+		log.Printf("App: Failed to schedule message in %s: %v", conversationID, err)
+		return nil, err
+	}
+	return scheduled, nil
+}
+
+// GetScheduledMessages returns the pending scheduled messages of the active provider instance
+// in a conversation, or in all conversations if conversationID is empty.
+func (a *App) GetScheduledMessages(conversationID string) ([]core.ScheduledMessage, error) {
+	if a.provider == nil {
+		return nil, fmt.Errorf("no active provider")
+	}
+	return a.scheduler.List(a.providerManager.GetActiveInstanceID(), conversationID)
+}
+
+// CancelScheduledMessage cancels a scheduled message of the active provider instance before it is sent.
+func (a *App) CancelScheduledMessage(conversationID string, scheduledID string) error {
+	if a.provider == nil {
+		return fmt.Errorf("no active provider")
+	}
+	if err := a.scheduler.Cancel(a.providerManager.GetActiveInstanceID(), conversationID, scheduledID); err != nil {
+		log.Printf("App: Failed to cancel scheduled message %s: %v", scheduledID, err)
+		return err
+	}
+	return nil
+}
+
+// SearchMessages searches the messages of the active provider instance. The first page holds
+// the matching messages stored locally, merged with the hits of the service when the provider
+// can search remotely (Slack); the next pages only hold remote hits. Remote hits already stored
+// locally carry their database ID. Files are only searched remotely.
+func (a *App) SearchMessages(query core.SearchQuery) (*core.SearchResults, error) {
+	if a.provider == nil {
+		return nil, fmt.Errorf("no active provider")
+	}
+
+	results := &core.SearchResults{Hits: []core.SearchHit{}, Total: -1}
+	if searcher, ok := a.provider.(core.RemoteSearcher); ok {
+		remote, err := searcher.SearchMessages(query)
+		if err != nil {
+			log.Printf("App: Remote search failed, using local results only: %v", err)
+			if query.Cursor != "" || query.Files {
+				return nil, err
+			}
+		} else {
+			results = remote
+		}
+	}
+	if query.Cursor != "" || query.Files || strings.TrimSpace(query.Text) == "" {
+		return results, nil
+	}
+
+	local, err := a.localSearchHits(query)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]core.SearchHit, len(local))
+	for _, hit := range local {
+		stored[hit.Message.ProtocolConvID+"|"+hit.Message.ProtocolMsgID] = hit
+	}
+	for i := range results.Hits {
+		key := results.Hits[i].Message.ProtocolConvID + "|" + results.Hits[i].Message.ProtocolMsgID
+		if hit, ok := stored[key]; ok {
+			results.Hits[i].Message.ID = hit.Message.ID
+			delete(stored, key)
+		}
+	}
+	for _, hit := range local {
+		if _, ok := stored[hit.Message.ProtocolConvID+"|"+hit.Message.ProtocolMsgID]; ok {
+			results.Hits = append(results.Hits, hit)
+		}
+	}
+	sort.SliceStable(results.Hits, func(i, j int) bool {
+		return results.Hits[i].Message.Timestamp.After(results.Hits[j].Message.Timestamp)
+	})
+	return results, nil
+}
+
+// localSearchHits returns the stored messages of the active provider instance matching query.
+func (a *App) localSearchHits(query core.SearchQuery) ([]core.SearchHit, error) {
+	q := api.MessageQuery{
+		InstanceID:     a.providerManager.GetActiveInstanceID(),
+		ConversationID: query.ConversationID,
+		Text:           strings.TrimSpace(query.Text),
+		Limit:          query.Limit,
+	}
+	if query.Since != nil {
+		q.Since = *query.Since
+	}
+	messages, err := api.QueryMessages(q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search local messages: %w", err)
+	}
+
+	type conversationInfo struct {
+		name    string
+		isGroup bool
+	}
+	conversations := make(map[string]conversationInfo)
+	var hits []core.SearchHit
+	for _, message := range messages {
+		if query.SenderID != "" && message.SenderID != query.SenderID {
+			continue
+		}
+		if query.Until != nil && !message.Timestamp.Before(*query.Until) {
+			continue
+		}
+		info, cached := conversations[message.ProtocolConvID]
+		if !cached {
+			var account models.LinkedAccount
+			if err := db.DB.Where("user_id = ?", message.ProtocolConvID).First(&account).Error; err == nil {
+				info.name = account.Username
+			}
+			var conversation models.Conversation
+			if err := db.DB.Where("protocol_conv_id = ?", message.ProtocolConvID).First(&conversation).Error; err == nil {
+				info.isGroup = conversation.IsGroup
+			}
+			conversations[message.ProtocolConvID] = info
+		}
+		hits = append(hits, core.SearchHit{
+			Message:          message,
+			ConversationName: info.name,
+			IsGroup:          info.isGroup,
+			Highlights:       []string{q.Text},
+		})
+	}
+	return hits, nil
+}
+
+// GetAvatar returns the avatar image as a base64 data URL.
+
+// GetAttachmentData reads an attachment file and returns it as a base64 data URL.
+func (a *App) GetAttachmentData(filePath string) (string, error) {
+	if filePath == "" {
+		return "", fmt.Errorf("file path is empty")
+	}
+
+	// Check if file exists
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return "", fmt.Errorf("file does not exist: %s", filePath)
+	}
+
+	// Read the file
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	// Read file content
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	// Determine MIME type from the file content, falling back to its extension
+	mimeType := media.DetectMIME(data, filePath)
+
+	// Encode to base64
+	base64Data := base64.StdEncoding.EncodeToString(data)
+	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64Data), nil
+}
+
+// GetSlackEmojiURL returns the URL for a Slack emoji given the provider instance ID and emoji name
+// emojiName can be with or without colons (e.g., ":calendar:" or "calendar")
+// Cached emoji images are returned as data URLs.
+func (a *App) GetSlackEmojiURL(providerInstanceID string, emojiName string) string {
+	if a.providerManager == nil || providerInstanceID == "" {
+		fmt.Printf("[App.GetSlackEmojiURL] ERROR: providerManager is nil or providerInstanceID is empty (providerInstanceID: %s)\n", providerInstanceID)
+		return ""
 	}
 
 	provider, err := a.providerManager.GetProvider(providerInstanceID)
@@ -1460,6 +1673,17 @@ func (a *App) GetSlackEmojiURL(providerInstanceID string, emojiName string) stri
 		return ""
 	}
 
+	// Prefer the cached image, served as a data URL
+	if emojiProvider, ok := provider.(interface {
+		GetEmojiPath(string) string
+	}); ok {
+		if imagePath := emojiProvider.GetEmojiPath(emojiName); imagePath != "" {
+			if dataURL := a.GetAvatar(imagePath); dataURL != "" {
+				return dataURL
+			}
+		}
+	}
+
 	// Check if provider has GetEmojiURL method using type assertion
 	if slackProvider, ok := provider.(interface {
 		GetEmojiURL(string) string
@@ -1500,18 +1724,11 @@ func (a *App) GetAvatar(filePath string) string {
 		return ""
 	}
 
-	// Determine MIME type based on file extension
-	mimeType := "image/jpeg"
-	if len(filePath) > 4 {
-		ext := filePath[len(filePath)-4:]
-		switch ext {
-		case ".png":
-			mimeType = "image/png"
-		case ".gif":
-			mimeType = "image/gif"
-		case ".webp":
-			mimeType = "image/webp"
-		}
+	// Determine MIME type from the image content, falling back to its extension
+	mimeType := media.DetectMIME(data, filePath)
+	if media.Classify(mimeType, nil) != media.TypeImage {
+		// Avatars downloaded without an extension are JPEG in practice
+		mimeType = "image/jpeg"
 	}
 
 	// Encode to base64
@@ -1541,6 +1758,150 @@ func (a *App) CreateGroup(groupName string, participantIDs []string) (*models.Co
 	return a.provider.CreateGroup(groupName, participantIDs)
 }
 
+// UpdateGroupName renames a group conversation of the active provider.
+func (a *App) UpdateGroupName(conversationID string, newName string) error {
+	if a.provider == nil {
+		return fmt.Errorf("no active provider")
+	}
+	if err := a.provider.UpdateGroupName(conversationID, newName); err != nil {
+		log.Printf("App: Failed to rename group %s: %v", conversationID, err)
+		return err
+	}
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, "contacts-refresh", "{}")
+	}
+	return nil
+}
+
+// UpdateGroupParticipants adds ("add"), removes ("remove"), promotes ("promote") or demotes
+// ("demote") participants of a group of the active provider. The participants the change
+// failed for are returned with the reasons, the change being applied to the others.
+func (a *App) UpdateGroupParticipants(conversationID string, action string, participantIDs []string) ([]core.ParticipantFailure, error) {
+	if a.provider == nil {
+		return nil, fmt.Errorf("no active provider")
+	}
+	var err error
+	switch action {
+	case "add":
+		err = a.provider.AddGroupParticipants(conversationID, participantIDs)
+	case "remove":
+		err = a.provider.RemoveGroupParticipants(conversationID, participantIDs)
+	case "promote":
+		err = a.provider.PromoteGroupAdmins(conversationID, participantIDs)
+	case "demote":
+		err = a.provider.DemoteGroupAdmins(conversationID, participantIDs)
+	default:
+		return nil, fmt.Errorf("unknown participant change %q", action)
+	}
+
+	var changeErr *core.ParticipantChangeError
+	if errors.As(err, &changeErr) {
+		log.Printf("App: %s in group %s failed for %d of %d participants", action, conversationID, len(changeErr.Failures), len(participantIDs))
+		if len(changeErr.Failures) < len(participantIDs) {
+			// Partially applied: the failures are the result
+			return changeErr.Failures, nil
+		}
+		return changeErr.Failures, err
+	}
+	if err != nil {
+		log.Printf("App: Failed to %s participants of group %s: %v", action, conversationID, err)
+		return nil, err
+	}
+	return []core.ParticipantFailure{}, nil
+}
+
+// CreateGroupInviteLink returns the invite link of a group of the active provider.
+func (a *App) CreateGroupInviteLink(conversationID string) (string, error) {
+	if a.provider == nil {
+		return "", fmt.Errorf("no active provider")
+	}
+	link, err := a.provider.CreateGroupInviteLink(conversationID)
+	if err != nil {
+		log.Printf("App: Failed to get the invite link of group %s: %v", conversationID, err)
+		return "", err
+	}
+	return link, nil
+}
+
+// RevokeGroupInviteLink revokes the invite link of a group of the active provider and returns
+// the link replacing it.
+func (a *App) RevokeGroupInviteLink(conversationID string) (string, error) {
+	if a.provider == nil {
+		return "", fmt.Errorf("no active provider")
+	}
+	if err := a.provider.RevokeGroupInviteLink(conversationID); err != nil {
+		log.Printf("App: Failed to revoke the invite link of group %s: %v", conversationID, err)
+		return "", err
+	}
+	return a.provider.CreateGroupInviteLink(conversationID)
+}
+
+// groupInvitePreviewer returns the invitation previews of the active provider.
+func (a *App) groupInvitePreviewer() (core.GroupInvitePreviewer, error) {
+	if a.provider == nil {
+		return nil, fmt.Errorf("no active provider")
+	}
+	previewer, ok := a.provider.(core.GroupInvitePreviewer)
+	if !ok {
+		return nil, fmt.Errorf("this provider cannot preview group invitations")
+	}
+	return previewer, nil
+}
+
+// PreviewGroupInviteLink returns the group of an invite link before joining it.
+func (a *App) PreviewGroupInviteLink(inviteLink string) (*core.GroupInvitePreview, error) {
+	previewer, err := a.groupInvitePreviewer()
+	if err != nil {
+		return nil, err
+	}
+	return previewer.PreviewGroupInviteLink(inviteLink)
+}
+
+// PreviewGroupInviteMessage returns the group of an invitation message received in a conversation before joining it.
+func (a *App) PreviewGroupInviteMessage(conversationID string, inviteMessageID string) (*core.GroupInvitePreview, error) {
+	previewer, err := a.groupInvitePreviewer()
+	if err != nil {
+		return nil, err
+	}
+	return previewer.PreviewGroupInviteMessage(conversationID, inviteMessageID)
+}
+
+// JoinGroupByInviteLink joins the group of an invite link and refreshes the contact list.
+// When an admin must approve the request, the error says so.
+func (a *App) JoinGroupByInviteLink(inviteLink string) (*models.Conversation, error) {
+	if a.provider == nil {
+		return nil, fmt.Errorf("no active provider")
+	}
+	conversation, err := a.provider.JoinGroupByInviteLink(inviteLink)
+	return a.joinedGroup(conversation, err)
+}
+
+// JoinGroupByInviteMessage joins the group of an invitation message received in a conversation and refreshes the contact list.
+func (a *App) JoinGroupByInviteMessage(conversationID string, inviteMessageID string) (*models.Conversation, error) {
+	if a.provider == nil {
+		return nil, fmt.Errorf("no active provider")
+	}
+	conversation, err := a.provider.JoinGroupByInviteMessage(conversationID, inviteMessageID)
+	return a.joinedGroup(conversation, err)
+}
+
+// joinedGroup logs the result of joining a group and refreshes the contact list on success.
+func (a *App) joinedGroup(conversation *models.Conversation, err error) (*models.Conversation, error) {
+	if errors.Is(err, core.ErrJoinRequestPending) {
+		log.Printf("App: Join request sent: %v", err)
+		return nil, core.ErrJoinRequestPending
+	}
+	if err != nil {
+		log.Printf("App: Failed to join group: %v", err)
+		return nil, err
+	}
+	log.Printf("App: Joined group %s", conversation.ProtocolConvID)
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, "contacts-refresh", "{}")
+	}
+	return conversation, nil
+}
+
 // GetConfiguredProviders returns a list of configured providers.
 func (a *App) GetConfiguredProviders() ([]core.ProviderInfo, error) {
 	fmt.Printf("App.GetConfiguredProviders: called\n")
@@ -1598,17 +1959,93 @@ func (a *App) CreateProvider(providerID string, config core.ProviderConfig, inst
 			}
 		}
 	}
-
-	// Update last sync time when creating a new provider
-	if db.DB != nil {
-		var providerConfig models.ProviderConfiguration
-		if err := db.DB.Where("instance_id = ?", instanceID).First(&providerConfig).Error; err == nil {
-			now := time.Now()
-			db.DB.Model(&providerConfig).Update("last_sync_at", now)
-		}
+
+	// Update last sync time when creating a new provider
+	if db.DB != nil {
+		var providerConfig models.ProviderConfiguration
+		if err := db.DB.Where("instance_id = ?", instanceID).First(&providerConfig).Error; err == nil {
+			now := time.Now()
+			db.DB.Model(&providerConfig).Update("last_sync_at", now)
+		}
+	}
+
+	return instanceID, nil
+}
+
+// DiscoverSlackWorkspaces lists the Slack workspaces a client token (xoxc-) and its d cookie
+// give access to, for the user to pick the ones to attach with AttachSlackWorkspaces.
+func (a *App) DiscoverSlackWorkspaces(token string, dCookie string) ([]providers.SlackWorkspace, error) {
+	log.Printf("DiscoverSlackWorkspaces: Looking for the workspaces of a client session")
+	workspaces, err := providers.DiscoverSlackWorkspaces(token, dCookie)
+	if err != nil {
+		log.Printf("DiscoverSlackWorkspaces: ERROR - %v", err)
+		return nil, err
+	}
+	return workspaces, nil
+}
+
+// AttachSlackWorkspaces creates one Slack instance per selected workspace (team ID) of a client
+// session. The instances share the d cookie, stored once. Workspaces already attached are
+// skipped. It returns the IDs of the created instances.
+func (a *App) AttachSlackWorkspaces(token string, dCookie string, teamIDs []string) ([]string, error) {
+	if len(teamIDs) == 0 {
+		return nil, fmt.Errorf("no workspace selected")
+	}
+	workspaces, err := providers.DiscoverSlackWorkspaces(token, dCookie)
+	if err != nil {
+		log.Printf("AttachSlackWorkspaces: ERROR - %v", err)
+		return nil, err
+	}
+	byTeam := make(map[string]providers.SlackWorkspace, len(workspaces))
+	for _, workspace := range workspaces {
+		byTeam[workspace.TeamID] = workspace
+	}
+	for _, teamID := range teamIDs {
+		if _, ok := byTeam[teamID]; !ok {
+			return nil, fmt.Errorf("the session has no access to the workspace %s", teamID)
+		}
+	}
+
+	sessionID, err := providers.SaveSlackSession(dCookie)
+	if err != nil {
+		log.Printf("AttachSlackWorkspaces: ERROR - %v", err)
+		return nil, err
+	}
+
+	instanceIDs := []string{}
+	var failures []string
+	for _, teamID := range teamIDs {
+		workspace := byTeam[teamID]
+		if workspace.Attached {
+			log.Printf("AttachSlackWorkspaces: Workspace %s (%s) is already attached", workspace.Name, teamID)
+			continue
+		}
+		instanceID, err := a.CreateProvider("slack", providers.SlackWorkspaceConfig(workspace, sessionID), workspace.Name, "")
+		if err != nil {
+			log.Printf("AttachSlackWorkspaces: ERROR - failed to attach workspace %s (%s): %v", workspace.Name, teamID, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", workspace.Name, err))
+			if instanceID != "" {
+				// The instance was saved but its credentials were refused
+				if err := a.providerManager.RemoveProvider(instanceID); err != nil {
+					log.Printf("AttachSlackWorkspaces: WARNING - failed to remove instance %s: %v", instanceID, err)
+				}
+			}
+			continue
+		}
+		log.Printf("AttachSlackWorkspaces: Attached workspace %s (%s) as %s", workspace.Name, teamID, instanceID)
+		instanceIDs = append(instanceIDs, instanceID)
+	}
+	if len(instanceIDs) == 0 {
+		providers.PruneSlackSessions()
+	}
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, "contacts-refresh", "{}")
 	}
 
-	return instanceID, nil
+	if len(failures) > 0 {
+		return instanceIDs, fmt.Errorf("failed to attach %d workspace(s): %s", len(failures), strings.Join(failures, "; "))
+	}
+	return instanceIDs, nil
 }
 
 // GetProviderQRCode returns the latest QR code for a provider instance (if applicable).
@@ -1733,6 +2170,11 @@ func (a *App) RemoveProvider(instanceID string) error {
 		log.Printf("Not deleting provider config directory: %d other instance(s) still exist", remainingInstances)
 	}
 
+	// Forget the Slack client sessions (d cookies) no instance uses anymore
+	if providerID == "slack" {
+		providers.PruneSlackSessions()
+	}
+
 	// If this was the active provider, clear it and switch to MockProvider if available
 	if a.provider != nil {
 		currentProvider, _ := a.providerManager.GetActiveProvider()
@@ -1879,6 +2321,501 @@ func (a *App) GetContactAliases() (map[string]string, error) {
 	return aliasMap, nil
 }
 
+// SaveDraft stores the message being composed in a conversation of the active provider instance.
+// attachments are local file paths. Saving an empty draft clears it.
+func (a *App) SaveDraft(conversationID string, text string, quotedMessageID string, attachments []string) (*models.Draft, error) {
+	if db.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if strings.TrimSpace(text) == "" && quotedMessageID == "" && len(attachments) == 0 {
+		return nil, a.ClearDraft(conversationID)
+	}
+
+	if attachments == nil {
+		attachments = []string{}
+	}
+	attachmentsJSON, err := json.Marshal(attachments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode draft attachments: %w", err)
+	}
+
+	instanceID := a.activeInstanceID()
+	var draft models.Draft
+	result := db.DB.Where("provider_instance_id = ? AND protocol_conv_id = ?", instanceID, conversationID).First(&draft)
+	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+		return nil, result.Error
+	}
+
+	draft.ProviderInstanceID = instanceID
+	draft.ProtocolConvID = conversationID
+	draft.Text = text
+	draft.QuotedMessageID = quotedMessageID
+	draft.Attachments = string(attachmentsJSON)
+	if err := db.DB.Save(&draft).Error; err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// GetDraft returns the draft of a conversation of the active provider instance, or nil if there is none.
+func (a *App) GetDraft(conversationID string) (*models.Draft, error) {
+	if db.DB == nil {
+		return nil, nil
+	}
+
+	var draft models.Draft
+	err := db.DB.Where("provider_instance_id = ? AND protocol_conv_id = ?", a.activeInstanceID(), conversationID).First(&draft).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// ClearDraft removes the draft of a conversation of the active provider instance.
+func (a *App) ClearDraft(conversationID string) error {
+	return drafts.Clear(a.activeInstanceID(), conversationID)
+}
+
+// GetDrafts returns the drafts of all provider instances, most recently edited first.
+func (a *App) GetDrafts() ([]models.Draft, error) {
+	if db.DB == nil {
+		return []models.Draft{}, nil
+	}
+
+	var drafts []models.Draft
+	if err := db.DB.Order("updated_at desc").Find(&drafts).Error; err != nil {
+		return nil, err
+	}
+	return drafts, nil
+}
+
+// afterSend runs the shared follow-ups of a message sent in a conversation of the active instance.
+func (a *App) afterSend(conversationID string) {
+	drafts.AfterSend(a.activeInstanceID(), conversationID)
+}
+
+// activeInstanceID returns the instance ID of the active provider, or "" if none.
+func (a *App) activeInstanceID() string {
+	if a.providerManager == nil {
+		return ""
+	}
+	return a.providerManager.GetActiveInstanceID()
+}
+
+// GetTemplates returns the message templates available for the active provider instance.
+func (a *App) GetTemplates() ([]models.MessageTemplate, error) {
+	if db.DB == nil {
+		return []models.MessageTemplate{}, nil
+	}
+
+	var list []models.MessageTemplate
+	err := db.DB.Where("provider_instance_id = ? OR provider_instance_id = ?", "", a.activeInstanceID()).
+		Order("name asc").Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// SaveTemplate creates a message template, or updates it if template.ID is set.
+func (a *App) SaveTemplate(template models.MessageTemplate) (*models.MessageTemplate, error) {
+	if db.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	template.Name = strings.TrimSpace(template.Name)
+	if template.Name == "" {
+		return nil, fmt.Errorf("template name is required")
+	}
+	if strings.TrimSpace(template.Body) == "" {
+		return nil, fmt.Errorf("template body is required")
+	}
+
+	if template.ID != 0 {
+		var existing models.MessageTemplate
+		if err := db.DB.First(&existing, template.ID).Error; err != nil {
+			return nil, fmt.Errorf("template %d not found: %w", template.ID, err)
+		}
+		template.CreatedAt = existing.CreatedAt
+	}
+	if err := db.DB.Save(&template).Error; err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// DeleteTemplate removes a message template.
+func (a *App) DeleteTemplate(templateID uint) error {
+	if db.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	return db.DB.Delete(&models.MessageTemplate{}, templateID).Error
+}
+
+// GetTemplatePlaceholders returns the placeholders of a template, so the frontend
+// can ask the user for the custom prompts before rendering.
+func (a *App) GetTemplatePlaceholders(templateID uint) ([]templates.Placeholder, error) {
+	template, err := a.loadTemplate(templateID)
+	if err != nil {
+		return nil, err
+	}
+	return templates.Placeholders(template.Body), nil
+}
+
+// RenderTemplate renders a template for a conversation, formatted for the active provider.
+// values holds the answers to the template's custom prompts, keyed by prompt label.
+func (a *App) RenderTemplate(templateID uint, conversationID string, values map[string]string) (string, error) {
+	template, err := a.loadTemplate(templateID)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, providerID := a.templateContext(conversationID, values)
+	text, err := templates.Render(template.Body, ctx)
+	if err != nil {
+		return "", err
+	}
+	return templates.Format(text, providerID), nil
+}
+
+// SendTemplate renders a template for a conversation and sends it.
+func (a *App) SendTemplate(templateID uint, conversationID string, values map[string]string) (*models.Message, error) {
+	text, err := a.RenderTemplate(templateID, conversationID, values)
+	if err != nil {
+		return nil, err
+	}
+	return a.SendMessage(conversationID, text)
+}
+
+// loadTemplate reads a message template from the database.
+func (a *App) loadTemplate(templateID uint) (*models.MessageTemplate, error) {
+	if db.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	var template models.MessageTemplate
+	if err := db.DB.First(&template, templateID).Error; err != nil {
+		return nil, fmt.Errorf("template %d not found: %w", templateID, err)
+	}
+	return &template, nil
+}
+
+// templateContext gathers the values template placeholders resolve to for a conversation
+// of the active provider instance, and returns the provider ID used for formatting.
+func (a *App) templateContext(conversationID string, values map[string]string) (templates.Context, string) {
+	ctx := templates.Context{Now: time.Now(), Values: values}
+	instanceID := a.activeInstanceID()
+	providerID := ""
+
+	if selfIdentifier, ok := a.provider.(core.SelfIdentifier); ok {
+		ctx.MeName = selfIdentifier.GetSelfDisplayName()
+	}
+	if db.DB == nil {
+		return ctx, providerID
+	}
+
+	var config models.ProviderConfiguration
+	if err := db.DB.Where("instance_id = ?", instanceID).First(&config).Error; err == nil {
+		providerID = config.ProviderID
+		ctx.ProviderName = config.InstanceName
+	}
+
+	isGroup := false
+	var conv models.Conversation
+	if err := db.DB.Where("protocol_conv_id = ?", conversationID).First(&conv).Error; err == nil {
+		isGroup = conv.IsGroup
+		ctx.ConversationName = conv.GroupName
+	}
+
+	// Conversations with a contact use the contact's user ID as conversation ID
+	name := ""
+	var alias models.ContactAlias
+	if err := db.DB.Where("user_id = ?", conversationID).First(&alias).Error; err == nil {
+		name = alias.Alias
+	}
+	if name == "" {
+		var account models.LinkedAccount
+		if err := db.DB.Where("user_id = ?", conversationID).First(&account).Error; err == nil {
+			name = account.Username
+			var metaContact models.MetaContact
+			if err := db.DB.First(&metaContact, account.MetaContactID).Error; err == nil && metaContact.DisplayName != "" {
+				name = metaContact.DisplayName
+			}
+		}
+	}
+
+	if ctx.ConversationName == "" {
+		ctx.ConversationName = name
+	}
+	if !isGroup {
+		ctx.ContactName = name
+	}
+	return ctx, providerID
+}
+
+// GetAutoReplyRule returns the auto-responder rule of a provider instance (a disabled default if none is saved).
+func (a *App) GetAutoReplyRule(instanceID string) (*models.AutoReplyRule, error) {
+	if db.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	var rule models.AutoReplyRule
+	err := db.DB.Where("provider_instance_id = ?", instanceID).First(&rule).Error
+	if err == gorm.ErrRecordNotFound {
+		return &models.AutoReplyRule{ProviderInstanceID: instanceID, CooldownHours: autoresponder.DefaultCooldownHours}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// SaveAutoReplyRule creates or updates the auto-responder rule of a provider instance.
+func (a *App) SaveAutoReplyRule(rule models.AutoReplyRule) (*models.AutoReplyRule, error) {
+	if db.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if rule.ProviderInstanceID == "" {
+		return nil, fmt.Errorf("provider instance is required")
+	}
+	if rule.Enabled && strings.TrimSpace(rule.Message) == "" {
+		return nil, fmt.Errorf("auto-reply message is required")
+	}
+	if rule.StartAt != nil && rule.EndAt != nil && !rule.EndAt.After(*rule.StartAt) {
+		return nil, fmt.Errorf("end time must be after start time")
+	}
+	if rule.CooldownHours <= 0 {
+		rule.CooldownHours = autoresponder.DefaultCooldownHours
+	}
+
+	// One rule per instance: reuse the existing row
+	var existing models.AutoReplyRule
+	if err := db.DB.Where("provider_instance_id = ?", rule.ProviderInstanceID).First(&existing).Error; err == nil {
+		rule.ID = existing.ID
+		rule.CreatedAt = existing.CreatedAt
+	}
+	if err := db.DB.Save(&rule).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// SetAutoReplyStopped stops (or resumes) the auto-responder in a conversation of the active provider instance.
+func (a *App) SetAutoReplyStopped(conversationID string, stopped bool) error {
+	if db.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	instanceID := a.activeInstanceID()
+
+	if !stopped {
+		return db.DB.Where("provider_instance_id = ? AND protocol_conv_id = ?", instanceID, conversationID).
+			Delete(&models.AutoReplyExclusion{}).Error
+	}
+	exclusion := models.AutoReplyExclusion{ProviderInstanceID: instanceID, ProtocolConvID: conversationID}
+	return db.DB.Where(exclusion).FirstOrCreate(&exclusion).Error
+}
+
+// GetAutoReplyExclusions returns the conversations where the auto-responder is stopped.
+func (a *App) GetAutoReplyExclusions(instanceID string) ([]models.AutoReplyExclusion, error) {
+	if db.DB == nil {
+		return []models.AutoReplyExclusion{}, nil
+	}
+
+	var exclusions []models.AutoReplyExclusion
+	if err := db.DB.Where("provider_instance_id = ?", instanceID).Find(&exclusions).Error; err != nil {
+		return nil, err
+	}
+	return exclusions, nil
+}
+
+// GetAutoReplyLog returns the latest auto-responder activity of a provider instance ("" = all instances).
+func (a *App) GetAutoReplyLog(instanceID string, limit int) ([]models.AutoReplyLog, error) {
+	if db.DB == nil {
+		return []models.AutoReplyLog{}, nil
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := db.DB.Order("created_at desc").Limit(limit)
+	if instanceID != "" {
+		query = query.Where("provider_instance_id = ?", instanceID)
+	}
+	var entries []models.AutoReplyLog
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetWebhooks returns all configured webhooks.
+func (a *App) GetWebhooks() ([]models.Webhook, error) {
+	if db.DB == nil {
+		return []models.Webhook{}, nil
+	}
+
+	var list []models.Webhook
+	if err := db.DB.Order("id asc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// SaveWebhook creates a webhook, or updates it if webhook.ID is set.
+func (a *App) SaveWebhook(webhook models.Webhook) (*models.Webhook, error) {
+	if db.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if err := webhooks.ValidateWebhook(webhook); err != nil {
+		return nil, err
+	}
+
+	if webhook.ID != 0 {
+		var existing models.Webhook
+		if err := db.DB.First(&existing, webhook.ID).Error; err != nil {
+			return nil, fmt.Errorf("webhook %d not found: %w", webhook.ID, err)
+		}
+		webhook.CreatedAt = existing.CreatedAt
+	}
+	if err := db.DB.Save(&webhook).Error; err != nil {
+		return nil, err
+	}
+
+	a.reloadWebhooks()
+	return &webhook, nil
+}
+
+// DeleteWebhook removes a webhook and its dead letters.
+func (a *App) DeleteWebhook(webhookID uint) error {
+	if db.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if err := db.DB.Where("webhook_id = ?", webhookID).Delete(&models.WebhookDeadLetter{}).Error; err != nil {
+		return err
+	}
+	if err := db.DB.Delete(&models.Webhook{}, webhookID).Error; err != nil {
+		return err
+	}
+
+	a.reloadWebhooks()
+	return nil
+}
+
+// GetWebhookDeadLetters returns the failed deliveries of a webhook (0 = all webhooks), newest first.
+func (a *App) GetWebhookDeadLetters(webhookID uint) ([]models.WebhookDeadLetter, error) {
+	if db.DB == nil {
+		return []models.WebhookDeadLetter{}, nil
+	}
+
+	query := db.DB.Order("created_at desc")
+	if webhookID != 0 {
+		query = query.Where("webhook_id = ?", webhookID)
+	}
+	var letters []models.WebhookDeadLetter
+	if err := query.Find(&letters).Error; err != nil {
+		return nil, err
+	}
+	return letters, nil
+}
+
+// RetryWebhookDeadLetter queues a failed delivery again.
+func (a *App) RetryWebhookDeadLetter(deadLetterID uint) error {
+	if a.webhooks == nil {
+		return fmt.Errorf("webhook dispatcher not initialized")
+	}
+	return a.webhooks.Redeliver(deadLetterID)
+}
+
+// DeleteWebhookDeadLetter discards a failed delivery.
+func (a *App) DeleteWebhookDeadLetter(deadLetterID uint) error {
+	if db.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	return db.DB.Delete(&models.WebhookDeadLetter{}, deadLetterID).Error
+}
+
+// reloadWebhooks refreshes the webhooks used by the dispatcher after a change.
+func (a *App) reloadWebhooks() {
+	if a.webhooks == nil {
+		return
+	}
+	if err := a.webhooks.Reload(); err != nil {
+		log.Printf("Warning: Failed to reload webhooks: %v", err)
+	}
+}
+
+// emitNotification sends a notification produced by the rules engine to the frontend.
+func (a *App) emitNotification(n notifications.Notification) {
+	notificationJSON, err := json.Marshal(n)
+	if err != nil {
+		log.Printf("Failed to marshal notification: %v", err)
+		return
+	}
+	log.Printf("App: Emitting notification for conversation %s (priority=%s, reason=%s, count=%d)", n.ConversationID, n.Priority, n.Reason, n.Count)
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, "notification", string(notificationJSON))
+	}
+}
+
+// GetNotificationRules returns all notification rules.
+func (a *App) GetNotificationRules() ([]models.NotificationRule, error) {
+	if db.DB == nil {
+		return []models.NotificationRule{}, nil
+	}
+
+	var rules []models.NotificationRule
+	if err := db.DB.Order("id asc").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// SaveNotificationRule creates a notification rule, or updates it if rule.ID is set.
+func (a *App) SaveNotificationRule(rule models.NotificationRule) (*models.NotificationRule, error) {
+	if db.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if err := notifications.ValidateRule(rule); err != nil {
+		return nil, err
+	}
+
+	if rule.ID != 0 {
+		var existing models.NotificationRule
+		if err := db.DB.First(&existing, rule.ID).Error; err != nil {
+			return nil, fmt.Errorf("notification rule %d not found: %w", rule.ID, err)
+		}
+		rule.CreatedAt = existing.CreatedAt
+	}
+	if err := db.DB.Save(&rule).Error; err != nil {
+		return nil, err
+	}
+
+	if a.notifier != nil {
+		if err := a.notifier.Reload(); err != nil {
+			log.Printf("Warning: Failed to reload notification rules: %v", err)
+		}
+	}
+	return &rule, nil
+}
+
+// DeleteNotificationRule removes a notification rule.
+func (a *App) DeleteNotificationRule(ruleID uint) error {
+	if db.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if err := db.DB.Delete(&models.NotificationRule{}, ruleID).Error; err != nil {
+		return err
+	}
+
+	if a.notifier != nil {
+		if err := a.notifier.Reload(); err != nil {
+			log.Printf("Warning: Failed to reload notification rules: %v", err)
+		}
+	}
+	return nil
+}
+
 // ClipboardFile represents a file retrieved from the system clipboard
 type ClipboardFile struct {
 	Filename string `json:"filename"`
@@ -1959,27 +2896,8 @@ func (a *App) GetClipboardFile() (*ClipboardFile, error) {
 		return nil, fmt.Errorf("failed to get file stats: %w", err)
 	}
 
-	// Determine MIME type from file extension
-	mimeType := "application/octet-stream"
-	ext := filepath.Ext(filePath)
-	switch strings.ToLower(ext) {
-	case ".jpg", ".jpeg":
-		mimeType = "image/jpeg"
-	case ".png":
-		mimeType = "image/png"
-	case ".gif":
-		mimeType = "image/gif"
-	case ".webp":
-		mimeType = "image/webp"
-	case ".mp4":
-		mimeType = "video/mp4"
-	case ".mp3":
-		mimeType = "audio/mpeg"
-	case ".pdf":
-		mimeType = "application/pdf"
-	case ".xls", ".xlsx":
-		mimeType = "application/vnd.ms-excel"
-	}
+	// Determine MIME type from the file content, falling back to its extension
+	mimeType := media.DetectMIME(data, filePath)
 
 	// Prepare response
 	return &ClipboardFile{
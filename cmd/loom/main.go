@@ -0,0 +1,500 @@
+// Command loom is a command-line client for scripting Loom.
+//
+// It talks to the local API of a running Loom app or loomd daemon when one is reachable,
+// and otherwise reads the Loom database directly (connecting a provider only to send).
+//
+//	loom [global flags] <command> [flags]
+//
+//	providers list
+//	contacts list [--instance slack-1]
+//	conversations list [--instance slack-1] [--unread]
+//	history <conversation> [--since 7d] [--limit 100]
+//	send <conversation> ["text"] [--file x.pdf] [--reply <message>] [--thread <thread> [--broadcast]]
+//	tail [--conversation <id>] [-n 20] [--follow]
+//	search "query" [--conversation <id>] [--since 30d]
+//
+// Every command accepts --json to print machine-readable output.
+package main
+
+import (
+	"Loom/pkg/api"
+	"Loom/pkg/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// out is the real standard output; os.Stdout is redirected so that the logs
+// printed by the Loom packages do not mix with the command output.
+var out io.Writer = os.Stdout
+
+const usage = `Usage: loom [global flags] <command> [flags]
+
+Commands:
+  providers list                      List the configured provider instances
+  contacts list                       List the contacts of an instance
+  conversations list                  List conversations with their unread counters
+  history <conversation>              Print the stored messages of a conversation
+  send <conversation> ["text"]        Send a message and/or a file (--file)
+  tail                                Print the latest messages (--follow to wait for new ones)
+  search "query"                      Search the stored messages
+
+Global flags:
+`
+
+func main() {
+	global := flag.NewFlagSet("loom", flag.ExitOnError)
+	apiAddr := global.String("api", os.Getenv("LOOM_API_ADDR"), "address of the local API (default "+api.DefaultAddr+")")
+	token := global.String("token", os.Getenv("LOOM_API_TOKEN"), "API token (default: read from the Loom config directory)")
+	local := global.Bool("local", false, "use the database and providers directly, even if the API is reachable")
+	verbose := global.Bool("verbose", false, "print the logs of the Loom packages on stderr")
+	global.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		global.PrintDefaults()
+	}
+	global.Parse(os.Args[1:])
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+		if devNull, err := os.Open(os.DevNull); err == nil {
+			os.Stdout = devNull
+		}
+	} else {
+		os.Stdout = os.Stderr
+	}
+
+	args := global.Args()
+	if len(args) == 0 {
+		global.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s, err := openStore(ctx, *apiAddr, *token, *local)
+	if err != nil {
+		fatal(err)
+	}
+	defer s.Close()
+
+	if err := run(ctx, s, args); err != nil {
+		fatal(err)
+	}
+}
+
+// openStore uses the API when it answers, and the database otherwise.
+func openStore(ctx context.Context, addr, token string, local bool) (store, error) {
+	if !local {
+		if token == "" {
+			token, _ = api.LoadToken()
+		}
+		if token != "" {
+			client := api.NewClient(addr, token)
+			pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			err := client.Ping(pingCtx)
+			cancel()
+			if err == nil {
+				return &remoteStore{client: client}, nil
+			}
+			if addr != "" {
+				// An explicit address must be reachable
+				return nil, fmt.Errorf("local API at %s is not reachable: %w", addr, err)
+			}
+		}
+	}
+	return newLocalStore()
+}
+
+// run executes a command.
+func run(ctx context.Context, s store, args []string) error {
+	command, args := args[0], args[1:]
+	// "providers list" and "providers" are equivalent
+	if len(args) > 0 && args[0] == "list" && (command == "providers" || command == "contacts" || command == "conversations") {
+		args = args[1:]
+	}
+
+	switch command {
+	case "providers":
+		return runProviders(ctx, s, args)
+	case "contacts":
+		return runContacts(ctx, s, args)
+	case "conversations":
+		return runConversations(ctx, s, args)
+	case "history":
+		return runHistory(ctx, s, args)
+	case "send":
+		return runSend(ctx, s, args)
+	case "tail":
+		return runTail(ctx, s, args)
+	case "search":
+		return runSearch(ctx, s, args)
+	default:
+		return fmt.Errorf("unknown command %q (run loom -h for help)", command)
+	}
+}
+
+// runProviders lists the configured provider instances.
+func runProviders(ctx context.Context, s store, args []string) error {
+	fs := flag.NewFlagSet("providers", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	providers, err := s.Providers(ctx)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(providers)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE\tPROVIDER\tNAME\tACTIVE")
+	for _, p := range providers {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.InstanceID, p.ID, p.InstanceName, yesNo(p.IsActive))
+	}
+	return w.Flush()
+}
+
+// runContacts lists the contacts of an instance.
+func runContacts(ctx context.Context, s store, args []string) error {
+	fs := flag.NewFlagSet("contacts", flag.ExitOnError)
+	instanceID := fs.String("instance", "", "provider instance (default: all)")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	contacts, err := s.Contacts(ctx, *instanceID)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(contacts)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tINSTANCE\tSTATUS")
+	for _, c := range contacts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.UserID, c.Username, c.ProviderInstanceID, c.Status)
+	}
+	return w.Flush()
+}
+
+// runConversations lists conversations with their unread counters.
+func runConversations(ctx context.Context, s store, args []string) error {
+	fs := flag.NewFlagSet("conversations", flag.ExitOnError)
+	instanceID := fs.String("instance", "", "provider instance (default: the active one)")
+	unreadOnly := fs.Bool("unread", false, "only conversations with unread messages")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	conversations, err := s.Conversations(ctx, *instanceID)
+	if err != nil {
+		return err
+	}
+	if *unreadOnly {
+		filtered := conversations[:0]
+		for _, c := range conversations {
+			if c.UnreadCount > 0 {
+				filtered = append(filtered, c)
+			}
+		}
+		conversations = filtered
+	}
+	if *asJSON {
+		return printJSON(conversations)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tUNREAD\tMENTIONS\tLAST MESSAGE")
+	for _, c := range conversations {
+		last := ""
+		if c.LastMessageAt != nil {
+			last = c.LastMessageAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", c.ID, c.Name, c.UnreadCount, c.MentionCount, last)
+	}
+	return w.Flush()
+}
+
+// runHistory prints the stored messages of a conversation.
+func runHistory(ctx context.Context, s store, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	instanceID := fs.String("instance", "", "provider instance (default: all)")
+	since := fs.String("since", "", "only messages newer than this (e.g. 7d, 12h, 2026-01-31)")
+	limit := fs.Int("limit", 100, "maximum number of messages")
+	asJSON := fs.Bool("json", false, "print JSON")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: loom history <conversation> [--since 7d] [--limit 100] [--json]")
+	}
+
+	q := api.MessageQuery{InstanceID: *instanceID, ConversationID: positional[0], Limit: *limit}
+	if q.Since, err = parseSince(*since); err != nil {
+		return err
+	}
+	messages, err := s.Messages(ctx, q)
+	if err != nil {
+		return err
+	}
+	return printMessages(messages, *asJSON)
+}
+
+// runSend sends a text message and/or a file.
+func runSend(ctx context.Context, s store, args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	instanceID := fs.String("instance", "", "provider instance (default: the active one)")
+	filePath := fs.String("file", "", "file to attach")
+	replyTo := fs.String("reply", "", "ID of the message to reply to")
+	threadID := fs.String("thread", "", "ID of the thread to post in (Slack)")
+	broadcast := fs.Bool("broadcast", false, "also show the thread reply in the conversation (Slack, with --thread)")
+	asJSON := fs.Bool("json", false, "print the sent messages as JSON")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 1 || len(positional) > 2 {
+		return fmt.Errorf(`usage: loom send <conversation> ["text"] [--file path]`)
+	}
+
+	req := api.SendMessageRequest{QuotedMessageID: *replyTo}
+	if len(positional) == 2 {
+		req.Text = positional[1]
+	}
+	if req.Text == "-" {
+		// Read the text from stdin so it can be piped
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		req.Text = strings.TrimRight(string(data), "\n")
+	}
+	if *threadID != "" {
+		req.ThreadID = threadID
+		req.AlsoSendToChannel = *broadcast
+	} else if *broadcast {
+		return fmt.Errorf("--broadcast requires --thread")
+	}
+	if req.Text == "" && *filePath == "" {
+		return fmt.Errorf("nothing to send: pass a text or --file")
+	}
+
+	sent, err := s.Send(ctx, *instanceID, positional[0], req, *filePath)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(sent)
+	}
+	for _, msg := range sent {
+		if msg != nil {
+			fmt.Fprintf(out, "Sent %s\n", msg.ProtocolMsgID)
+		}
+	}
+	return nil
+}
+
+// runTail prints the latest messages and optionally waits for new ones.
+func runTail(ctx context.Context, s store, args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	instanceID := fs.String("instance", "", "provider instance (default: all)")
+	conversationID := fs.String("conversation", "", "only this conversation")
+	count := fs.Int("n", 20, "number of messages to print first")
+	follow := fs.Bool("follow", false, "keep printing new messages")
+	fs.BoolVar(follow, "f", false, "shorthand for --follow")
+	asJSON := fs.Bool("json", false, "print one JSON message per line")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	q := api.MessageQuery{InstanceID: *instanceID, ConversationID: *conversationID, Limit: *count}
+	if *count > 0 {
+		messages, err := s.Messages(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			if err := printMessage(msg, *asJSON); err != nil {
+				return err
+			}
+		}
+	}
+	if !*follow {
+		return nil
+	}
+
+	q.Limit = 0
+	return s.Follow(ctx, q, func(msg models.Message) {
+		if err := printMessage(msg, *asJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "loom: %v\n", err)
+		}
+	})
+}
+
+// runSearch searches the stored messages.
+func runSearch(ctx context.Context, s store, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	instanceID := fs.String("instance", "", "provider instance (default: all)")
+	conversationID := fs.String("conversation", "", "only this conversation")
+	since := fs.String("since", "", "only messages newer than this (e.g. 30d, 2026-01-31)")
+	limit := fs.Int("limit", 100, "maximum number of messages")
+	asJSON := fs.Bool("json", false, "print JSON")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || positional[0] == "" {
+		return fmt.Errorf(`usage: loom search "query" [--instance id] [--since 30d] [--json]`)
+	}
+
+	q := api.MessageQuery{InstanceID: *instanceID, ConversationID: *conversationID, Text: positional[0], Limit: *limit}
+	if q.Since, err = parseSince(*since); err != nil {
+		return err
+	}
+	messages, err := s.Messages(ctx, q)
+	if err != nil {
+		return err
+	}
+	return printMessages(messages, *asJSON)
+}
+
+// parseArgs parses flags placed before, between or after the positional arguments
+// and returns the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// parseSince parses a relative duration ("7d", "2w", "12h", "30m") or a date ("2026-01-31").
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	unit := value[len(value)-1]
+	if unit == 'd' || unit == 'w' {
+		n, err := strconv.Atoi(value[:len(value)-1])
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("invalid --since value %q", value)
+		}
+		days := n
+		if unit == 'w' {
+			days = n * 7
+		}
+		return time.Now().AddDate(0, 0, -days), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("invalid --since value %q", value)
+	}
+	return time.Now().Add(-d), nil
+}
+
+// printMessages prints messages as a JSON array or one per line.
+func printMessages(messages []models.Message, asJSON bool) error {
+	if asJSON {
+		if messages == nil {
+			messages = []models.Message{}
+		}
+		return printJSON(messages)
+	}
+	for _, msg := range messages {
+		if err := printMessage(msg, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printMessage prints a message as one JSON line or one text line.
+func printMessage(msg models.Message, asJSON bool) error {
+	if asJSON {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	}
+
+	sender := msg.SenderID
+	if msg.SenderName != "" {
+		sender = msg.SenderName
+	}
+	if msg.IsFromMe {
+		sender = "me"
+	}
+	body := strings.ReplaceAll(msg.Body, "\n", " ")
+	if body == "" && msg.Attachments != "" {
+		body = "[attachment]"
+	}
+	_, err := fmt.Fprintf(out, "%s  %s  %s: %s\n", msg.Timestamp.Local().Format("2006-01-02 15:04"), msg.ProtocolConvID, sender, body)
+	return err
+}
+
+// printJSON prints value as indented JSON.
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// yesNo formats a boolean for tables.
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+// containsFold reports whether substr is in s, ignoring case.
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// fatal prints an error and exits.
+func fatal(err error) {
+	if errors.Is(err, context.Canceled) {
+		os.Exit(130)
+	}
+	fmt.Fprintf(os.Stderr, "loom: %v\n", err)
+	os.Exit(1)
+}
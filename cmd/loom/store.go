@@ -0,0 +1,275 @@
+package main
+
+import (
+	"Loom/pkg/api"
+	"Loom/pkg/backend"
+	"Loom/pkg/core"
+	"Loom/pkg/db"
+	"Loom/pkg/drafts"
+	"Loom/pkg/media"
+	"Loom/pkg/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pollInterval is how often the local store checks the database for new messages when following.
+const pollInterval = 2 * time.Second
+
+// store is where the CLI reads and sends messages: the API of a running app or daemon,
+// or the database and providers directly.
+type store interface {
+	Providers(ctx context.Context) ([]api.ProviderSummary, error)
+	Contacts(ctx context.Context, instanceID string) ([]models.LinkedAccount, error)
+	Conversations(ctx context.Context, instanceID string) ([]api.ConversationSummary, error)
+	Messages(ctx context.Context, q api.MessageQuery) ([]models.Message, error)
+	Send(ctx context.Context, instanceID, conversationID string, req api.SendMessageRequest, filePath string) ([]*models.Message, error)
+	// Follow calls handle for every new message matching q until ctx is cancelled.
+	Follow(ctx context.Context, q api.MessageQuery, handle func(models.Message)) error
+	Close()
+}
+
+// remoteStore goes through the local API.
+type remoteStore struct {
+	client *api.Client
+}
+
+// Providers lists the configured provider instances.
+func (s *remoteStore) Providers(ctx context.Context) ([]api.ProviderSummary, error) {
+	return s.client.Providers(ctx)
+}
+
+// Contacts lists the contacts of an instance.
+func (s *remoteStore) Contacts(ctx context.Context, instanceID string) ([]models.LinkedAccount, error) {
+	return s.client.Contacts(ctx, instanceID)
+}
+
+// Conversations lists the conversations of an instance.
+func (s *remoteStore) Conversations(ctx context.Context, instanceID string) ([]api.ConversationSummary, error) {
+	return s.client.Conversations(ctx, instanceID)
+}
+
+// Messages searches the stored messages.
+func (s *remoteStore) Messages(ctx context.Context, q api.MessageQuery) ([]models.Message, error) {
+	return s.client.Messages(ctx, q)
+}
+
+// Send sends a text and/or a file.
+func (s *remoteStore) Send(ctx context.Context, instanceID, conversationID string, req api.SendMessageRequest, filePath string) ([]*models.Message, error) {
+	var sent []*models.Message
+	if filePath != "" {
+		msg, err := s.client.SendFile(ctx, instanceID, conversationID, filePath, req.ThreadID)
+		if err != nil {
+			return sent, err
+		}
+		sent = append(sent, msg)
+	}
+	if req.Text != "" {
+		msg, err := s.client.SendMessage(ctx, instanceID, conversationID, req)
+		if err != nil {
+			return sent, err
+		}
+		sent = append(sent, msg)
+	}
+	return sent, nil
+}
+
+// Follow streams new messages over the WebSocket endpoint.
+func (s *remoteStore) Follow(ctx context.Context, q api.MessageQuery, handle func(models.Message)) error {
+	return s.client.StreamEvents(ctx, q.InstanceID, func(event api.RawStreamEvent) {
+		if event.Type != string(core.EventTypeMessage) {
+			return
+		}
+		var messageEvent core.MessageEvent
+		if err := json.Unmarshal(event.Data, &messageEvent); err != nil {
+			return
+		}
+		if matchesQuery(messageEvent.Message, q) {
+			handle(messageEvent.Message)
+		}
+	})
+}
+
+// Close releases nothing: the API client holds no connection between calls.
+func (s *remoteStore) Close() {}
+
+// localStore reads the database directly and connects providers only to send messages.
+type localStore struct {
+	pm *core.ProviderManager
+}
+
+// newLocalStore opens the Loom database.
+func newLocalStore() (*localStore, error) {
+	if err := db.InitDatabase(); err != nil {
+		return nil, fmt.Errorf("failed to open the Loom database: %w", err)
+	}
+	pm := core.NewProviderManager()
+	backend.RegisterProviders(pm)
+	return &localStore{pm: pm}, nil
+}
+
+// Providers lists the provider instances saved in the database.
+func (s *localStore) Providers(ctx context.Context) ([]api.ProviderSummary, error) {
+	configs, err := s.pm.LoadProviderConfigs()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	for _, info := range s.pm.GetAvailableProviders() {
+		names[info.ID] = info.Name
+	}
+
+	summaries := make([]api.ProviderSummary, 0, len(configs))
+	for _, config := range configs {
+		summaries = append(summaries, api.ProviderSummary{
+			ID:           config.ProviderID,
+			InstanceID:   config.InstanceID,
+			InstanceName: config.InstanceName,
+			Name:         names[config.ProviderID],
+			IsActive:     config.IsActive,
+		})
+	}
+	return summaries, nil
+}
+
+// Contacts lists the contacts stored for an instance ("" = all instances).
+func (s *localStore) Contacts(ctx context.Context, instanceID string) ([]models.LinkedAccount, error) {
+	query := db.DB.Order("username asc")
+	if instanceID != "" {
+		query = query.Where("provider_instance_id = ?", instanceID)
+	}
+	var accounts []models.LinkedAccount
+	err := query.Find(&accounts).Error
+	return accounts, err
+}
+
+// Conversations lists the conversations of an instance ("" = the active instance).
+func (s *localStore) Conversations(ctx context.Context, instanceID string) ([]api.ConversationSummary, error) {
+	if instanceID == "" {
+		instanceID = s.activeInstanceID()
+	}
+	return api.ListConversations(instanceID)
+}
+
+// Messages searches the stored messages.
+func (s *localStore) Messages(ctx context.Context, q api.MessageQuery) ([]models.Message, error) {
+	return api.QueryMessages(q)
+}
+
+// Send connects the provider instance, sends a text and/or a file, then disconnects.
+func (s *localStore) Send(ctx context.Context, instanceID, conversationID string, req api.SendMessageRequest, filePath string) ([]*models.Message, error) {
+	provider, err := s.connect(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	defer provider.Disconnect()
+
+	var sent []*models.Message
+	if filePath != "" {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return sent, err
+		}
+		fileName := filepath.Base(filePath)
+		attachment := &core.Attachment{
+			FileName: fileName,
+			FileSize: len(data),
+			MimeType: media.DetectMIME(data, fileName),
+			Data:     data,
+		}
+		msg, err := provider.SendFile(conversationID, attachment, req.ThreadID)
+		if err != nil {
+			return sent, err
+		}
+		sent = append(sent, msg)
+	}
+	if req.Text != "" {
+		msg, err := api.SendText(provider, conversationID, req)
+		if err != nil {
+			return sent, err
+		}
+		sent = append(sent, msg)
+	}
+	drafts.AfterSend(instanceID, conversationID)
+	return sent, nil
+}
+
+// Follow polls the database for messages stored after the latest one.
+// Messages are only stored while the app or the daemon is running.
+func (s *localStore) Follow(ctx context.Context, q api.MessageQuery, handle func(models.Message)) error {
+	var last models.Message
+	if err := db.DB.Order("id desc").Limit(1).Find(&last).Error; err != nil {
+		return err
+	}
+	q.AfterID = last.ID
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			messages, err := api.QueryMessages(q)
+			if err != nil {
+				return err
+			}
+			for _, msg := range messages {
+				handle(msg)
+				q.AfterID = msg.ID
+			}
+		}
+	}
+}
+
+// Close releases nothing: providers are disconnected after each send.
+func (s *localStore) Close() {}
+
+// activeInstanceID returns the instance marked as active in the database.
+func (s *localStore) activeInstanceID() string {
+	var config models.ProviderConfiguration
+	if err := db.DB.Where("is_active = ?", true).First(&config).Error; err != nil {
+		return ""
+	}
+	return config.InstanceID
+}
+
+// connect restores and connects a provider instance ("" = the active instance).
+func (s *localStore) connect(instanceID string) (core.Provider, error) {
+	if instanceID == "" {
+		instanceID = s.activeInstanceID()
+	}
+	if instanceID == "" {
+		return nil, fmt.Errorf("no active provider, pass --instance")
+	}
+
+	var config models.ProviderConfiguration
+	if err := db.DB.Where("instance_id = ?", instanceID).First(&config).Error; err != nil {
+		return nil, fmt.Errorf("provider instance %s not found", instanceID)
+	}
+	provider, err := s.pm.RestoreProvider(config)
+	if err != nil {
+		return nil, err
+	}
+	if !provider.IsAuthenticated() {
+		return nil, fmt.Errorf("provider instance %s is not authenticated, configure it in the Loom app first", instanceID)
+	}
+	if err := provider.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect %s: %w", instanceID, err)
+	}
+	return provider, nil
+}
+
+// matchesQuery applies the filters of q to a message received from the event stream.
+func matchesQuery(msg models.Message, q api.MessageQuery) bool {
+	if q.ConversationID != "" && msg.ProtocolConvID != q.ConversationID {
+		return false
+	}
+	if q.Text != "" && !containsFold(msg.Body, q.Text) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+// Command loomd runs the Loom backend without the desktop window.
+// It restores the configured provider instances, keeps their sessions alive, syncs and
+// archives their events to the Loom database, sends the locally scheduled messages, and
+// optionally serves the local API.
+//
+// Providers must be configured (and WhatsApp paired) once from the desktop app;
+// loomd uses the same database and sessions.
+package main
+
+import (
+	"Loom/pkg/api"
+	"Loom/pkg/autoresponder"
+	"Loom/pkg/backend"
+	"Loom/pkg/core"
+	"Loom/pkg/db"
+	"Loom/pkg/notifications"
+	"Loom/pkg/scheduler"
+	"Loom/pkg/webhooks"
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	apiAddr := flag.String("api", os.Getenv("LOOM_API_ADDR"), "serve the local API on this loopback address (e.g. "+api.DefaultAddr+"); disabled if empty")
+	statusInterval := flag.Duration("status-interval", 5*time.Minute, "how often to log the status of the provider instances (0 disables)")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := db.InitDatabase(); err != nil {
+		log.Fatalf("loomd: Failed to initialize database: %v", err)
+	}
+	backend.CleanupSelfReceipts()
+
+	pm := core.NewProviderManager()
+	backend.RegisterProviders(pm)
+
+	// Start the event consumers before restoring providers
+	notifier := notifications.NewEngine(func(n notifications.Notification) {
+		log.Printf("loomd: Notification [%s] %s: %s", n.ProviderInstanceID, n.Title, n.Body)
+	})
+	recorder := backend.NewRecorder(pm, notifier)
+	go recorder.Run(ctx)
+	go autoresponder.NewResponder(pm).Run(ctx)
+	go webhooks.NewDispatcher(pm).Run(ctx)
+	go scheduler.NewScheduler(pm).Run(ctx)
+
+	_, activeInstanceID := backend.RestoreProviders(pm, backend.RestoreOptions{SyncAll: true, SyncDelay: 2 * time.Second})
+	log.Printf("loomd: Providers restored (active instance: %q)", activeInstanceID)
+
+	var apiServer *api.Server
+	if *apiAddr != "" {
+		token, err := api.LoadOrCreateToken()
+		if err != nil {
+			log.Fatalf("loomd: Failed to load API token: %v", err)
+		}
+		apiServer, err = api.NewServer(pm, *apiAddr, token)
+		if err != nil {
+			log.Fatalf("loomd: Failed to create API server: %v", err)
+		}
+		apiServer.SetStatsSource(recorder.Stats)
+		if err := apiServer.Start(); err != nil {
+			log.Fatalf("loomd: Failed to start API server: %v", err)
+		}
+	}
+
+	logStatus(pm, recorder)
+	var ticker <-chan time.Time
+	if *statusInterval > 0 {
+		t := time.NewTicker(*statusInterval)
+		defer t.Stop()
+		ticker = t.C
+	}
+
+	for {
+		select {
+		case <-ticker:
+			logStatus(pm, recorder)
+		case <-ctx.Done():
+			log.Printf("loomd: Shutting down")
+			if apiServer != nil {
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				if err := apiServer.Shutdown(shutdownCtx); err != nil {
+					log.Printf("loomd: Failed to stop API server: %v", err)
+				}
+				cancel()
+			}
+			for _, info := range pm.GetConfiguredProviders() {
+				if provider, err := pm.GetProvider(info.InstanceID); err == nil {
+					if err := provider.Disconnect(); err != nil {
+						log.Printf("loomd: Failed to disconnect %s: %v", info.InstanceID, err)
+					}
+				}
+			}
+			return
+		}
+	}
+}
+
+// logStatus logs the connection state and event counters of every provider instance.
+func logStatus(pm *core.ProviderManager, recorder *backend.Recorder) {
+	stats := recorder.Stats()
+	configured := pm.GetConfiguredProviders()
+	log.Printf("loomd: Status: %d provider instance(s)", len(configured))
+	for _, info := range configured {
+		authenticated, connected := false, false
+		if provider, err := pm.GetProvider(info.InstanceID); err == nil {
+			authenticated = provider.IsAuthenticated()
+			connected = core.IsConnected(provider)
+		}
+		s := stats[info.InstanceID]
+		lastEvent := "never"
+		if !s.LastEventAt.IsZero() {
+			lastEvent = s.LastEventAt.Format(time.RFC3339)
+		}
+		lastSync := "never"
+		if !s.LastSyncAt.IsZero() {
+			lastSync = s.LastSyncAt.Format(time.RFC3339)
+		}
+		log.Printf("loomd:   %s (%s): authenticated=%v connected=%v active=%v messages=%d reactions=%d receipts=%d persisted=%d failed=%d lastEvent=%s lastSync=%s",
+			info.InstanceID, info.InstanceName, authenticated, connected, info.IsActive, s.Messages, s.Reactions, s.Receipts, s.Persisted, s.Failed, lastEvent, lastSync)
+	}
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"Loom/pkg/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Client calls the local API of a running Loom app or daemon.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// RawStreamEvent is a StreamEvent as received by a client, with its payload left undecoded.
+type RawStreamEvent struct {
+	Event      string          `json:"event"`
+	Type       string          `json:"type"`
+	InstanceID string          `json:"instanceId"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// NewClient creates a client for the API listening on addr (DefaultAddr if empty).
+func NewClient(addr string, token string) *Client {
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	return &Client{
+		baseURL:    "http://" + addr + "/api/v1",
+		token:      token,
+		httpClient: &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// Ping checks that the API is reachable and accepts the token.
+func (c *Client) Ping(ctx context.Context) error {
+	var providers []ProviderSummary
+	return c.do(ctx, http.MethodGet, "/providers", nil, nil, "", &providers)
+}
+
+// Providers lists the configured provider instances.
+func (c *Client) Providers(ctx context.Context) ([]ProviderSummary, error) {
+	var providers []ProviderSummary
+	err := c.do(ctx, http.MethodGet, "/providers", nil, nil, "", &providers)
+	return providers, err
+}
+
+// Status returns the connection state and recorded event counters of the configured instances.
+func (c *Client) Status(ctx context.Context) ([]InstanceStatus, error) {
+	var statuses []InstanceStatus
+	err := c.do(ctx, http.MethodGet, "/status", nil, nil, "", &statuses)
+	return statuses, err
+}
+
+// Contacts lists the contacts of an instance ("" = all instances).
+func (c *Client) Contacts(ctx context.Context, instanceID string) ([]models.LinkedAccount, error) {
+	var contacts []models.LinkedAccount
+	err := c.do(ctx, http.MethodGet, "/contacts", instanceParams(instanceID), nil, "", &contacts)
+	return contacts, err
+}
+
+// Conversations lists the conversations of an instance ("" = the active instance).
+func (c *Client) Conversations(ctx context.Context, instanceID string) ([]ConversationSummary, error) {
+	var conversations []ConversationSummary
+	err := c.do(ctx, http.MethodGet, "/conversations", instanceParams(instanceID), nil, "", &conversations)
+	return conversations, err
+}
+
+// Messages searches the stored messages.
+func (c *Client) Messages(ctx context.Context, q MessageQuery) ([]models.Message, error) {
+	params := instanceParams(q.InstanceID)
+	if q.ConversationID != "" {
+		params.Set("conversation", q.ConversationID)
+	}
+	if q.Text != "" {
+		params.Set("q", q.Text)
+	}
+	if !q.Since.IsZero() {
+		params.Set("since", q.Since.Format(time.RFC3339Nano))
+	}
+	if q.AfterID > 0 {
+		params.Set("after", strconv.FormatUint(uint64(q.AfterID), 10))
+	}
+	if q.Limit > 0 {
+		params.Set("limit", strconv.Itoa(q.Limit))
+	}
+
+	var messages []models.Message
+	err := c.do(ctx, http.MethodGet, "/messages", params, nil, "", &messages)
+	return messages, err
+}
+
+// SendMessage sends a text message to a conversation of an instance ("" = the active instance).
+func (c *Client) SendMessage(ctx context.Context, instanceID, conversationID string, req SendMessageRequest) (*models.Message, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var msg models.Message
+	path := "/conversations/" + url.PathEscape(conversationID) + "/messages"
+	if err := c.do(ctx, http.MethodPost, path, instanceParams(instanceID), bytes.NewReader(body), "application/json", &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// SendFile uploads a local file to a conversation of an instance ("" = the active instance).
+func (c *Client) SendFile(ctx context.Context, instanceID, conversationID, filePath string, threadID *string) (*models.Message, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filepath.Base(filePath))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return nil, err
+	}
+	if threadID != nil {
+		if err := writer.WriteField("threadId", *threadID); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg models.Message
+	path := "/conversations/" + url.PathEscape(conversationID) + "/files"
+	if err := c.do(ctx, http.MethodPost, path, instanceParams(instanceID), &body, writer.FormDataContentType(), &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// StreamEvents calls handle for every event of an instance ("" = all instances)
+// until ctx is cancelled or the connection is closed.
+func (c *Client) StreamEvents(ctx context.Context, instanceID string, handle func(RawStreamEvent)) error {
+	eventsURL, err := url.Parse(c.baseURL + "/events")
+	if err != nil {
+		return err
+	}
+	eventsURL.Scheme = "ws"
+	eventsURL.RawQuery = instanceParams(instanceID).Encode()
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.token)
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, eventsURL.String(), header)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the event stream: %w", err)
+	}
+	defer conn.Close()
+
+	// Unblock ReadJSON when the context is cancelled
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	for {
+		var event RawStreamEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		handle(event)
+	}
+}
+
+// do performs a request and decodes the JSON response into result.
+func (c *Client) do(ctx context.Context, method, path string, params url.Values, body io.Reader, contentType string, result interface{}) error {
+	target := c.baseURL + path
+	if len(params) > 0 {
+		target += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err == nil && apiErr.Error != "" {
+			return fmt.Errorf("API error (%d): %s", resp.StatusCode, apiErr.Error)
+		}
+		return fmt.Errorf("API error: %s", resp.Status)
+	}
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// instanceParams returns the query parameters selecting an instance.
+func instanceParams(instanceID string) url.Values {
+	params := url.Values{}
+	if instanceID != "" {
+		params.Set("instance", instanceID)
+	}
+	return params
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// pingInterval is how often the WebSocket connection is pinged to detect dead clients.
+	pingInterval = 30 * time.Second
+	// writeTimeout bounds a single WebSocket write.
+	writeTimeout = 10 * time.Second
+)
+
+// upgrader accepts WebSocket connections from non-browser clients and loopback pages only.
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		parsed, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		if parsed.Hostname() == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(parsed.Hostname())
+		return ip != nil && ip.IsLoopback()
+	},
+}
+
+// handleEvents streams provider events over a WebSocket.
+// The optional "instance" parameter restricts the stream to one provider instance.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	instanceFilter := r.URL.Query().Get("instance")
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already wrote the HTTP error
+		fmt.Printf("API: WebSocket upgrade failed: %v\n", err)
+		return
+	}
+	defer conn.Close()
+	if !s.addStream(conn) {
+		return
+	}
+	defer s.removeStream(conn)
+
+	events, unsubscribe := s.providerManager.SubscribeEvents()
+	defer unsubscribe()
+
+	// Read loop: only used to notice when the client goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	fmt.Printf("API: WebSocket client connected from %s\n", r.RemoteAddr)
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			fmt.Printf("API: WebSocket client %s disconnected\n", r.RemoteAddr)
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				return
+			}
+		case instanceEvent, ok := <-events:
+			if !ok {
+				return
+			}
+			if instanceFilter != "" && instanceEvent.InstanceID != instanceFilter {
+				continue
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			err := conn.WriteJSON(StreamEvent{
+				Event:      FrontendEventName(instanceEvent.Event.Type()),
+				Type:       instanceEvent.Event.Type(),
+				InstanceID: instanceEvent.InstanceID,
+				Data:       instanceEvent.Event,
+			})
+			if err != nil {
+				fmt.Printf("API: WebSocket write to %s failed: %v\n", r.RemoteAddr, err)
+				return
+			}
+		}
+	}
+}
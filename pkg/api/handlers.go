@@ -0,0 +1,460 @@
+package api
+
+import (
+	"Loom/pkg/core"
+	"Loom/pkg/db"
+	"Loom/pkg/drafts"
+	"Loom/pkg/media"
+	"Loom/pkg/models"
+	"Loom/pkg/notifications"
+	"Loom/pkg/readstate"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultHistoryLimit is the page size of the history endpoint.
+	defaultHistoryLimit = 50
+	// maxHistoryLimit caps the page size of the history endpoint.
+	maxHistoryLimit = 500
+	// maxUploadSize caps the size of files sent through the API.
+	maxUploadSize = 100 << 20
+)
+
+// routes builds the HTTP router.
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/providers", s.handleProviders)
+	mux.HandleFunc("GET /api/v1/status", s.handleStatus)
+	mux.HandleFunc("GET /api/v1/contacts", s.handleContacts)
+	mux.HandleFunc("GET /api/v1/conversations", s.handleConversations)
+	mux.HandleFunc("GET /api/v1/messages", s.handleMessages)
+	mux.HandleFunc("GET /api/v1/conversations/{id}/messages", s.handleHistory)
+	mux.HandleFunc("POST /api/v1/conversations/{id}/messages", s.handleSendMessage)
+	mux.HandleFunc("GET /api/v1/conversations/{id}/messages/{messageId}/thread", s.handleThread)
+	mux.HandleFunc("POST /api/v1/conversations/{id}/files", s.handleSendFile)
+	mux.HandleFunc("POST /api/v1/conversations/{id}/messages/{messageId}/reactions", s.handleAddReaction)
+	mux.HandleFunc("DELETE /api/v1/conversations/{id}/messages/{messageId}/reactions/{emoji}", s.handleRemoveReaction)
+	mux.HandleFunc("POST /api/v1/conversations/{id}/read", s.handleMarkRead)
+	mux.HandleFunc("GET /api/v1/events", s.handleEvents)
+	return s.withAuth(mux)
+}
+
+// resolveProvider returns the provider instance selected by the "instance" query parameter,
+// or the active provider when it is absent.
+func (s *Server) resolveProvider(r *http.Request) (string, core.Provider, error) {
+	instanceID := r.URL.Query().Get("instance")
+	if instanceID == "" {
+		instanceID = s.providerManager.GetActiveInstanceID()
+	}
+	if instanceID == "" {
+		return "", nil, fmt.Errorf("no active provider, pass the instance parameter")
+	}
+	provider, err := s.providerManager.GetProvider(instanceID)
+	if err != nil {
+		return "", nil, err
+	}
+	return instanceID, provider, nil
+}
+
+// handleProviders lists the configured provider instances.
+func (s *Server) handleProviders(w http.ResponseWriter, r *http.Request) {
+	configured := s.providerManager.GetConfiguredProviders()
+	summaries := make([]ProviderSummary, 0, len(configured))
+	for _, info := range configured {
+		summary := ProviderSummary{
+			ID:           info.ID,
+			InstanceID:   info.InstanceID,
+			InstanceName: info.InstanceName,
+			Name:         info.Name,
+			IsActive:     info.IsActive,
+		}
+		if provider, err := s.providerManager.GetProvider(info.InstanceID); err == nil && provider != nil {
+			readMarkers := core.SupportsReadMarkers(provider)
+			summary.ReadMarkers = &readMarkers
+		}
+		summaries = append(summaries, summary)
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+// handleStatus reports the connection state and recorded event counters of every configured instance.
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	var stats map[string]core.InstanceStats
+	if s.statsSource != nil {
+		stats = s.statsSource()
+	}
+
+	configured := s.providerManager.GetConfiguredProviders()
+	statuses := make([]InstanceStatus, 0, len(configured))
+	for _, info := range configured {
+		status := InstanceStatus{
+			InstanceID:   info.InstanceID,
+			InstanceName: info.InstanceName,
+			ID:           info.ID,
+			IsActive:     info.IsActive,
+		}
+		if provider, err := s.providerManager.GetProvider(info.InstanceID); err == nil && provider != nil {
+			status.Loaded = true
+			status.Authenticated = provider.IsAuthenticated()
+			status.Connected = core.IsConnected(provider)
+		}
+		if stats != nil {
+			instanceStats := stats[info.InstanceID]
+			status.Stats = &instanceStats
+		}
+		statuses = append(statuses, status)
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// handleContacts lists the contacts stored for a provider instance ("instance" parameter, all if absent).
+func (s *Server) handleContacts(w http.ResponseWriter, r *http.Request) {
+	if db.DB == nil {
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("database not initialized"))
+		return
+	}
+
+	query := db.DB.Order("username asc")
+	if instanceID := r.URL.Query().Get("instance"); instanceID != "" {
+		query = query.Where("provider_instance_id = ?", instanceID)
+	}
+	var accounts []models.LinkedAccount
+	if err := query.Find(&accounts).Error; err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, accounts)
+}
+
+// handleConversations lists the conversations of a provider instance with their unread state,
+// most recent first.
+func (s *Server) handleConversations(w http.ResponseWriter, r *http.Request) {
+	instanceID := r.URL.Query().Get("instance")
+	if instanceID == "" {
+		instanceID = s.providerManager.GetActiveInstanceID()
+	}
+	conversations, err := ListConversations(instanceID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, conversations)
+}
+
+// handleHistory returns a page of messages older than the "before" parameter (RFC 3339).
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	_, provider, err := s.resolveProvider(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", value))
+			return
+		}
+		if limit > maxHistoryLimit {
+			limit = maxHistoryLimit
+		}
+	}
+
+	var before *time.Time
+	if value := r.URL.Query().Get("before"); value != "" {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid before timestamp: %w", err))
+			return
+		}
+		before = &t
+	}
+
+	messages, err := provider.GetConversationHistory(r.PathValue("id"), limit, before)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	if messages == nil {
+		messages = []models.Message{}
+	}
+	writeJSON(w, http.StatusOK, messages)
+}
+
+// handleThread returns the replies of the thread started by a message.
+func (s *Server) handleThread(w http.ResponseWriter, r *http.Request) {
+	_, provider, err := s.resolveProvider(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	replies, err := core.GetConversationThread(provider, r.PathValue("id"), r.PathValue("messageId"))
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	if replies == nil {
+		replies = []models.Message{}
+	}
+	writeJSON(w, http.StatusOK, replies)
+}
+
+// handleSendMessage sends a text message, optionally as a reply or in a thread.
+func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request) {
+	instanceID, provider, err := s.resolveProvider(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req SendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+		return
+	}
+	if req.Text == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("text is required"))
+		return
+	}
+
+	msg, err := SendText(provider, r.PathValue("id"), req)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	drafts.AfterSend(instanceID, r.PathValue("id"))
+	writeJSON(w, http.StatusCreated, msg)
+}
+
+// SendText sends the text of req as a plain message, a reply or a thread reply.
+func SendText(provider core.Provider, conversationID string, req SendMessageRequest) (*models.Message, error) {
+	if req.QuotedMessageID != "" {
+		return provider.SendReply(conversationID, req.Text, req.QuotedMessageID)
+	}
+	if req.ThreadID != nil && req.AlsoSendToChannel {
+		replier, ok := provider.(core.ThreadReplier)
+		if !ok {
+			return nil, fmt.Errorf("this provider cannot send thread replies to the conversation")
+		}
+		return replier.SendThreadReply(conversationID, *req.ThreadID, req.Text, true)
+	}
+	return provider.SendMessage(conversationID, req.Text, nil, req.ThreadID)
+}
+
+// handleSendFile sends the "file" part of a multipart form; "threadId" is optional.
+func (s *Server) handleSendFile(w http.ResponseWriter, r *http.Request) {
+	instanceID, provider, err := s.resolveProvider(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("missing file part: %w", err))
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("failed to read file: %w", err))
+		return
+	}
+
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "" || mimeType == media.DefaultMIMEType {
+		mimeType = media.DetectMIME(data, header.Filename)
+	}
+	attachment := &core.Attachment{
+		FileName: header.Filename,
+		FileSize: len(data),
+		MimeType: mimeType,
+		Data:     data,
+	}
+
+	var threadID *string
+	if value := r.FormValue("threadId"); value != "" {
+		threadID = &value
+	}
+
+	msg, err := provider.SendFile(r.PathValue("id"), attachment, threadID)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	drafts.AfterSend(instanceID, r.PathValue("id"))
+	writeJSON(w, http.StatusCreated, msg)
+}
+
+// handleAddReaction adds a reaction to a message.
+func (s *Server) handleAddReaction(w http.ResponseWriter, r *http.Request) {
+	_, provider, err := s.resolveProvider(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req ReactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Emoji == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("an emoji is required"))
+		return
+	}
+
+	if err := provider.AddReaction(r.PathValue("id"), r.PathValue("messageId"), req.Emoji); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRemoveReaction removes a reaction from a message.
+func (s *Server) handleRemoveReaction(w http.ResponseWriter, r *http.Request) {
+	_, provider, err := s.resolveProvider(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := provider.RemoveReaction(r.PathValue("id"), r.PathValue("messageId"), r.PathValue("emoji")); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMarkRead marks a message, or the whole conversation, as read.
+func (s *Server) handleMarkRead(w http.ResponseWriter, r *http.Request) {
+	instanceID, provider, err := s.resolveProvider(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req MarkReadRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+			return
+		}
+	}
+
+	conversationID := r.PathValue("id")
+	var marker *models.ReadMarker
+	if req.MessageID != "" {
+		if err := provider.MarkMessageAsRead(conversationID, req.MessageID); err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		marker, _, err = readstate.MarkRead(instanceID, conversationID, req.MessageID)
+	} else {
+		marker, err = readstate.MarkConversationAsRead(provider, instanceID, conversationID)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, marker)
+}
+
+// ListConversations returns the conversations of a provider instance ("" = all instances)
+// with their unread state, most recent first.
+func ListConversations(instanceID string) ([]ConversationSummary, error) {
+	if db.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	// Conversations are listed through the accounts (users, groups, channels) of the instance
+	query := db.DB.Model(&models.LinkedAccount{})
+	if instanceID != "" {
+		query = query.Where("provider_instance_id = ?", instanceID)
+	}
+	var accounts []models.LinkedAccount
+	if err := query.Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+
+	markers, err := readstate.List(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	markerByConv := make(map[string]models.ReadMarker, len(markers))
+	for _, marker := range markers {
+		markerByConv[marker.ProviderInstanceID+"|"+marker.ProtocolConvID] = marker
+	}
+
+	var groupIDs []string
+	db.DB.Model(&models.Conversation{}).Where("is_group = ?", true).Pluck("protocol_conv_id", &groupIDs)
+	groups := make(map[string]bool, len(groupIDs))
+	for _, id := range groupIDs {
+		groups[id] = true
+	}
+
+	aliases := make(map[string]string)
+	var aliasRows []models.ContactAlias
+	if err := db.DB.Find(&aliasRows).Error; err == nil {
+		for _, alias := range aliasRows {
+			aliases[alias.UserID] = alias.Alias
+		}
+	}
+
+	lastMessages := lastMessageTimes()
+
+	summaries := make([]ConversationSummary, 0, len(accounts))
+	for _, account := range accounts {
+		summary := ConversationSummary{
+			ID:         account.UserID,
+			InstanceID: account.ProviderInstanceID,
+			Name:       account.Username,
+			IsGroup:    groups[account.UserID] || notifications.LooksLikeGroup(account.UserID),
+		}
+		if alias, ok := aliases[account.UserID]; ok {
+			summary.Name = alias
+		}
+		if marker, ok := markerByConv[account.ProviderInstanceID+"|"+account.UserID]; ok {
+			summary.UnreadCount = marker.UnreadCount
+			summary.MentionCount = marker.MentionCount
+		}
+		if last, ok := lastMessages[account.UserID]; ok {
+			lastCopy := last
+			summary.LastMessageAt = &lastCopy
+		}
+		summaries = append(summaries, summary)
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		a, b := summaries[i].LastMessageAt, summaries[j].LastMessageAt
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return a.After(*b)
+	})
+	return summaries, nil
+}
+
+// lastMessageTimes returns the timestamp of the latest stored message of each conversation.
+func lastMessageTimes() map[string]time.Time {
+	var rows []struct {
+		ProtocolConvID string
+		Timestamp      time.Time
+	}
+	// Latest message per conversation, without relying on the SQL type of MAX(timestamp)
+	db.DB.Model(&models.Message{}).
+		Select("protocol_conv_id, timestamp").
+		Where("timestamp = (SELECT MAX(m2.timestamp) FROM messages m2 WHERE m2.protocol_conv_id = messages.protocol_conv_id AND m2.deleted_at IS NULL)").
+		Scan(&rows)
+
+	times := make(map[string]time.Time, len(rows))
+	for _, row := range rows {
+		times[row.ProtocolConvID] = row.Timestamp
+	}
+	return times
+}
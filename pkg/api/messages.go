@@ -0,0 +1,117 @@
+package api
+
+import (
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultQueryLimit is the number of messages returned by QueryMessages when no limit is given.
+const defaultQueryLimit = 100
+
+// MessageQuery selects stored messages. Zero fields do not filter.
+type MessageQuery struct {
+	InstanceID     string    // Only conversations of this provider instance
+	ConversationID string    // Only this protocol conversation
+	Text           string    // Case-insensitive substring of the body
+	Since          time.Time // Only messages sent at or after this time
+	AfterID        uint      // Only messages stored after this database ID (for polling)
+	Limit          int       // Maximum number of messages (defaultQueryLimit if 0)
+}
+
+// QueryMessages returns the stored messages matching q in chronological order.
+// Without AfterID the most recent matching messages are returned; with AfterID, the
+// oldest ones stored after it, so that repeated calls see every new message once.
+func QueryMessages(q MessageQuery) ([]models.Message, error) {
+	if db.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+
+	query := db.DB.Model(&models.Message{})
+	if q.InstanceID != "" {
+		// Messages are linked to an instance through the accounts (users, groups, channels) of the instance
+		query = query.Where("protocol_conv_id IN (?)",
+			db.DB.Model(&models.LinkedAccount{}).Select("user_id").Where("provider_instance_id = ?", q.InstanceID))
+	}
+	if q.ConversationID != "" {
+		query = query.Where("protocol_conv_id = ?", q.ConversationID)
+	}
+	if q.Text != "" {
+		query = query.Where("body LIKE ?", "%"+q.Text+"%")
+	}
+	if !q.Since.IsZero() {
+		query = query.Where("timestamp >= ?", q.Since)
+	}
+
+	var messages []models.Message
+	if q.AfterID > 0 {
+		err := query.Where("id > ?", q.AfterID).Order("id asc").Limit(limit).Find(&messages).Error
+		return messages, err
+	}
+
+	if err := query.Order("timestamp desc").Limit(limit).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	// Oldest first, like GetConversationHistory
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// handleMessages searches the stored messages of all conversations.
+// Parameters: instance, conversation, q (text), since (RFC 3339), after (message ID) and limit.
+func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q := MessageQuery{
+		InstanceID:     params.Get("instance"),
+		ConversationID: params.Get("conversation"),
+		Text:           params.Get("q"),
+	}
+
+	if value := params.Get("since"); value != "" {
+		since, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid since timestamp: %w", err))
+			return
+		}
+		q.Since = since
+	}
+	if value := params.Get("after"); value != "" {
+		afterID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid after ID %q", value))
+			return
+		}
+		q.AfterID = uint(afterID)
+	}
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", value))
+			return
+		}
+		if limit > maxHistoryLimit {
+			limit = maxHistoryLimit
+		}
+		q.Limit = limit
+	}
+
+	messages, err := QueryMessages(q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if messages == nil {
+		messages = []models.Message{}
+	}
+	writeJSON(w, http.StatusOK, messages)
+}
@@ -0,0 +1,282 @@
+// Package api exposes the Loom backend over a loopback-only HTTP API so that scripts
+// and local tools can use the same providers and database as the desktop app.
+//
+// Every request must carry the API token, either as "Authorization: Bearer <token>"
+// or, for WebSocket clients that cannot set headers, as a "token" query parameter.
+// Routes are versioned under /api/v1; the "instance" query parameter selects the
+// provider instance and defaults to the active one.
+package api
+
+import (
+	"Loom/pkg/core"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultAddr is the address the API listens on when none is configured.
+const DefaultAddr = "127.0.0.1:7878"
+
+// tokenFileName is the file, in the Loom config directory, holding the API token.
+const tokenFileName = "api_token"
+
+// Server is the local HTTP API.
+type Server struct {
+	providerManager *core.ProviderManager
+	addr            string
+	token           string
+	httpServer      *http.Server
+	mu              sync.Mutex
+	listener        net.Listener
+	statsSource     func() map[string]core.InstanceStats // Recorded event counters per instance (nil if not recorded)
+
+	// WebSocket event streams: their connections are hijacked, so http.Server.Shutdown does not close them
+	streams     map[*websocket.Conn]struct{}
+	streamsDone sync.WaitGroup // Running stream handlers
+	closing     bool           // Set by Shutdown: new streams are refused
+	streamsMu   sync.Mutex     // Mutex for streams and closing
+}
+
+// NewServer creates an API server for providerManager listening on addr (DefaultAddr if empty).
+// addr must be a loopback address: the API gives full access to the user's accounts.
+func NewServer(providerManager *core.ProviderManager, addr string, token string) (*Server, error) {
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	if err := checkLoopback(addr); err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, fmt.Errorf("an API token is required")
+	}
+
+	s := &Server{
+		providerManager: providerManager,
+		addr:            addr,
+		token:           token,
+		streams:         make(map[*websocket.Conn]struct{}),
+	}
+	s.httpServer = &http.Server{
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s, nil
+}
+
+// SetStatsSource sets the function returning the recorded event counters of every instance,
+// reported by the status route. It must be called before Start.
+func (s *Server) SetStatsSource(source func() map[string]core.InstanceStats) {
+	s.statsSource = source
+}
+
+// Addr returns the address the server listens on (the actual port once started).
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.addr
+}
+
+// Start begins serving requests in the background.
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return fmt.Errorf("API server already started")
+	}
+
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+	s.listener = listener
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("API: ERROR - server stopped: %v\n", err)
+		}
+	}()
+	fmt.Printf("API: Listening on http://%s\n", listener.Addr().String())
+	return nil
+}
+
+// Shutdown stops the server, waiting for in-flight requests until ctx expires.
+// The WebSocket event streams are closed and their handlers waited for too.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.httpServer.Shutdown(ctx)
+
+	s.streamsMu.Lock()
+	s.closing = true
+	for conn := range s.streams {
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+			time.Now().Add(writeTimeout))
+		conn.Close()
+	}
+	s.streamsMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.streamsDone.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	return err
+}
+
+// addStream registers the connection of an event stream, closed by Shutdown.
+// It returns false once the server is shutting down.
+func (s *Server) addStream(conn *websocket.Conn) bool {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	if s.closing {
+		return false
+	}
+	s.streams[conn] = struct{}{}
+	s.streamsDone.Add(1)
+	return true
+}
+
+// removeStream unregisters the connection of an event stream whose handler returns.
+func (s *Server) removeStream(conn *websocket.Conn) {
+	s.streamsMu.Lock()
+	delete(s.streams, conn)
+	s.streamsMu.Unlock()
+	s.streamsDone.Done()
+}
+
+// LoadOrCreateToken returns the API token stored in the Loom config directory,
+// generating and saving a new one on first use.
+func LoadOrCreateToken() (string, error) {
+	path, err := tokenPath()
+	if err != nil {
+		return "", err
+	}
+
+	if data, err := os.ReadFile(path); err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate API token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return "", fmt.Errorf("could not create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to save API token: %w", err)
+	}
+	return token, nil
+}
+
+// LoadToken returns the API token stored in the Loom config directory, without creating it.
+func LoadToken() (string, error) {
+	path, err := tokenPath()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read API token: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("API token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// tokenPath returns the path of the API token file.
+func tokenPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get user config dir: %w", err)
+	}
+	return filepath.Join(configDir, "Loom", tokenFileName), nil
+}
+
+// checkLoopback ensures addr only listens on the loopback interface.
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid API address %q: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("API address %q is not a loopback address", addr)
+	}
+	return nil
+}
+
+// withAuth rejects requests that do not come from the loopback interface or lack the token.
+func (s *Server) withAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			writeError(w, http.StatusForbidden, fmt.Errorf("only loopback clients are allowed"))
+			return
+		}
+
+		// Refuse requests addressed to another host name (DNS rebinding from a web page)
+		if hostName, _, err := net.SplitHostPort(r.Host); err == nil {
+			if ip := net.ParseIP(hostName); hostName != "localhost" && (ip == nil || !ip.IsLoopback()) {
+				writeError(w, http.StatusForbidden, fmt.Errorf("invalid host %q", r.Host))
+				return
+			}
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid or missing API token"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON writes value as a JSON response.
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		fmt.Printf("API: WARNING - failed to write response: %v\n", err)
+	}
+}
+
+// writeError writes an error as a JSON response.
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
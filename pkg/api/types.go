@@ -0,0 +1,96 @@
+package api
+
+import (
+	"Loom/pkg/core"
+	"time"
+)
+
+// ErrorResponse is returned with every non-2xx status.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// ProviderSummary describes a configured provider instance (without its credentials).
+type ProviderSummary struct {
+	ID           string `json:"id"`           // Provider type (e.g., "whatsapp")
+	InstanceID   string `json:"instanceId"`   // Instance identifier (e.g., "whatsapp-1")
+	InstanceName string `json:"instanceName"` // Display name of the instance
+	Name         string `json:"name"`         // Display name of the provider type
+	IsActive     bool   `json:"isActive"`
+	ReadMarkers  *bool  `json:"readMarkers,omitempty"` // Whether read markers are synced with the service (unknown if not connected)
+}
+
+// InstanceStatus is the connection state and recorded event counters of a provider instance.
+type InstanceStatus struct {
+	InstanceID    string              `json:"instanceId"`
+	InstanceName  string              `json:"instanceName"`
+	ID            string              `json:"id"` // Provider type (e.g., "whatsapp")
+	IsActive      bool                `json:"isActive"`
+	Loaded        bool                `json:"loaded"` // Whether the instance was restored in this process
+	Authenticated bool                `json:"authenticated"`
+	Connected     bool                `json:"connected"`
+	Stats         *core.InstanceStats `json:"stats,omitempty"` // Recorded events (absent if the process does not record them)
+}
+
+// ConversationSummary is a conversation of a provider instance with its unread state.
+type ConversationSummary struct {
+	ID            string     `json:"id"` // Protocol conversation ID
+	InstanceID    string     `json:"instanceId"`
+	Name          string     `json:"name"`
+	IsGroup       bool       `json:"isGroup"`
+	UnreadCount   int        `json:"unreadCount"`
+	MentionCount  int        `json:"mentionCount"`
+	LastMessageAt *time.Time `json:"lastMessageAt,omitempty"`
+}
+
+// SendMessageRequest is the body of POST /conversations/{id}/messages.
+type SendMessageRequest struct {
+	Text              string  `json:"text"`
+	QuotedMessageID   string  `json:"quotedMessageId,omitempty"`   // Reply to this message
+	ThreadID          *string `json:"threadId,omitempty"`          // Post in this thread (Slack)
+	AlsoSendToChannel bool    `json:"alsoSendToChannel,omitempty"` // Also show the thread reply in the conversation (Slack)
+}
+
+// ReactionRequest is the body of POST /conversations/{id}/messages/{messageId}/reactions.
+type ReactionRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+// MarkReadRequest is the body of POST /conversations/{id}/read.
+// Without a message ID the whole conversation is marked as read.
+type MarkReadRequest struct {
+	MessageID string `json:"messageId,omitempty"`
+}
+
+// StreamEvent is a provider event sent over the WebSocket endpoint.
+type StreamEvent struct {
+	Event      string             `json:"event"` // Name of the matching frontend event (e.g., "new-message")
+	Type       core.EventType     `json:"type"`
+	InstanceID string             `json:"instanceId"`
+	Data       core.ProviderEvent `json:"data"`
+}
+
+// frontendEventNames maps provider event types to the event names emitted to the frontend.
+var frontendEventNames = map[core.EventType]string{
+	core.EventTypeMessage:          "new-message",
+	core.EventTypeReaction:         "reaction",
+	core.EventTypeTyping:           "typing",
+	core.EventTypeContactStatus:    "contact-status",
+	core.EventTypePresence:         "presence",
+	core.EventTypeGroupChange:      "group-change",
+	core.EventTypeReceipt:          "receipt",
+	core.EventTypeRetryReceipt:     "retry-receipt",
+	core.EventTypeSyncStatus:       "sync-status",
+	core.EventTypeReadMarker:       "read-marker",
+	core.EventTypeThreadUpdate:     "thread-update",
+	core.EventTypeUploadProgress:   "upload-progress",
+	core.EventTypeAttachmentUpdate: "attachment-update",
+}
+
+// FrontendEventName returns the frontend event name for an event type.
+func FrontendEventName(eventType core.EventType) string {
+	if name, ok := frontendEventNames[eventType]; ok {
+		return name
+	}
+	return string(eventType)
+}
@@ -0,0 +1,181 @@
+// Package autoresponder answers incoming messages automatically while the user is away.
+// It listens to the events of every provider instance and replies through the instance
+// that received the message, according to the AutoReplyRule of that instance.
+package autoresponder
+
+import (
+	"Loom/pkg/core"
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"Loom/pkg/notifications"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCooldownHours is used when a rule does not set a cooldown.
+const DefaultCooldownHours = 24
+
+// maxMessageAge is the age above which incoming messages are considered history
+// (replayed after a reconnection) and never answered.
+const maxMessageAge = 10 * time.Minute
+
+// Log statuses.
+const (
+	StatusSent   = "sent"
+	StatusFailed = "failed"
+)
+
+// Responder sends out-of-office replies to incoming messages.
+type Responder struct {
+	providerManager *core.ProviderManager
+	mu              sync.Mutex
+	inFlight        map[string]bool // Key: instanceID + "|" + senderID, replies being sent
+}
+
+// NewResponder creates an auto-responder replying through the providers of providerManager.
+func NewResponder(providerManager *core.ProviderManager) *Responder {
+	return &Responder{
+		providerManager: providerManager,
+		inFlight:        make(map[string]bool),
+	}
+}
+
+// Run processes the message events of all provider instances until ctx is cancelled.
+func (r *Responder) Run(ctx context.Context) {
+	events, unsubscribe := r.providerManager.SubscribeEvents()
+	defer unsubscribe()
+
+	fmt.Printf("AutoResponder: Listening to provider events\n")
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Printf("AutoResponder: Stopped\n")
+			return
+		case instanceEvent, ok := <-events:
+			if !ok {
+				return
+			}
+			if e, isMessage := instanceEvent.Event.(core.MessageEvent); isMessage {
+				r.HandleMessage(instanceEvent.InstanceID, e.Message)
+			}
+		}
+	}
+}
+
+// IsActive reports whether a rule is enabled and now falls in its away period.
+func IsActive(rule models.AutoReplyRule, now time.Time) bool {
+	if !rule.Enabled || strings.TrimSpace(rule.Message) == "" {
+		return false
+	}
+	if rule.StartAt != nil && now.Before(*rule.StartAt) {
+		return false
+	}
+	if rule.EndAt != nil && !now.Before(*rule.EndAt) {
+		return false
+	}
+	return true
+}
+
+// HandleMessage replies to an incoming message if the rule of its instance asks for it.
+func (r *Responder) HandleMessage(instanceID string, msg models.Message) {
+	if db.DB == nil || msg.IsFromMe || msg.IsStatusMessage || msg.IsDeleted || msg.IsEdited {
+		return
+	}
+	if time.Since(msg.Timestamp) > maxMessageAge {
+		return
+	}
+
+	var rule models.AutoReplyRule
+	if err := db.DB.Where("provider_instance_id = ?", instanceID).First(&rule).Error; err != nil {
+		return
+	}
+	now := time.Now()
+	if !IsActive(rule, now) {
+		return
+	}
+
+	var excluded int64
+	db.DB.Model(&models.AutoReplyExclusion{}).
+		Where("provider_instance_id = ? AND protocol_conv_id = ?", instanceID, msg.ProtocolConvID).
+		Count(&excluded)
+	if excluded > 0 {
+		return
+	}
+
+	provider, err := r.providerManager.GetProvider(instanceID)
+	if err != nil {
+		fmt.Printf("AutoResponder: Provider instance %s not found: %v\n", instanceID, err)
+		return
+	}
+
+	selfUserID := ""
+	if selfIdentifier, ok := provider.(core.SelfIdentifier); ok {
+		selfUserID = selfIdentifier.GetSelfUserID()
+	}
+	if msg.SenderID == "" || msg.SenderID == selfUserID {
+		return
+	}
+
+	// Groups are only answered when I'm mentioned personally (not through @here/@channel)
+	isGroup := notifications.LooksLikeGroup(msg.ProtocolConvID)
+	var conv models.Conversation
+	if err := db.DB.Where("protocol_conv_id = ?", msg.ProtocolConvID).First(&conv).Error; err == nil {
+		isGroup = conv.IsGroup
+	}
+	if isGroup && !notifications.MessageMentionsUser(msg, selfUserID, false) {
+		return
+	}
+
+	key := instanceID + "|" + msg.SenderID
+	r.mu.Lock()
+	if r.inFlight[key] {
+		r.mu.Unlock()
+		return
+	}
+	r.inFlight[key] = true
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.inFlight, key)
+		r.mu.Unlock()
+	}()
+
+	cooldown := rule.CooldownHours
+	if cooldown <= 0 {
+		cooldown = DefaultCooldownHours
+	}
+	var recent int64
+	db.DB.Model(&models.AutoReplyLog{}).
+		Where("provider_instance_id = ? AND sender_id = ? AND status = ? AND created_at > ?",
+			instanceID, msg.SenderID, StatusSent, now.Add(-time.Duration(cooldown)*time.Hour)).
+		Count(&recent)
+	if recent > 0 {
+		return
+	}
+
+	fmt.Printf("AutoResponder: Replying to %s in conversation %s (instance %s)\n", msg.SenderID, msg.ProtocolConvID, instanceID)
+	entry := models.AutoReplyLog{
+		ProviderInstanceID: instanceID,
+		ProtocolConvID:     msg.ProtocolConvID,
+		SenderID:           msg.SenderID,
+		TriggerMessageID:   msg.ProtocolMsgID,
+		Status:             StatusSent,
+	}
+
+	// Keep Slack thread replies in their thread
+	reply, err := provider.SendMessage(msg.ProtocolConvID, rule.Message, nil, msg.ThreadID)
+	if err != nil {
+		fmt.Printf("AutoResponder: ERROR - Failed to send auto-reply to %s: %v\n", msg.SenderID, err)
+		entry.Status = StatusFailed
+		entry.Error = err.Error()
+	} else if reply != nil {
+		entry.ReplyMessageID = reply.ProtocolMsgID
+	}
+
+	if err := db.DB.Create(&entry).Error; err != nil {
+		fmt.Printf("AutoResponder: WARNING - Failed to save activity log: %v\n", err)
+	}
+}
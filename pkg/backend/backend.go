@@ -0,0 +1,103 @@
+// Package backend holds the parts of Loom that do not depend on the desktop window:
+// provider registration, restoration and sync on startup, and persistence of provider events.
+// It is shared by the Wails app and by the headless loomd daemon.
+package backend
+
+import (
+	"Loom/pkg/core"
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"Loom/pkg/providers"
+	"log"
+)
+
+// RegisterProviders registers the factories of all built-in providers.
+func RegisterProviders(pm *core.ProviderManager) {
+	pm.RegisterProvider("mock", core.ProviderInfo{
+		ID:          "mock",
+		Name:        "Mock",
+		Description: "Mock provider for development and testing",
+		ConfigSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+	}, func() core.Provider {
+		return providers.NewMockProvider()
+	})
+
+	pm.RegisterProvider("whatsapp", core.ProviderInfo{
+		ID:          "whatsapp",
+		Name:        "WhatsApp",
+		Description: "WhatsApp messaging provider",
+		ConfigSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+	}, func() core.Provider {
+		return providers.NewWhatsAppProvider()
+	})
+
+	pm.RegisterProvider("slack", core.ProviderInfo{
+		ID:          "slack",
+		Name:        "Slack",
+		Description: "Slack messaging provider",
+		ConfigSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"token": map[string]interface{}{
+					"type":        "string",
+					"title":       "Auth Token",
+					"description": "Bot (xoxb-), User (xoxp-), or Client (xoxc-) Token",
+				},
+				"d_cookie": map[string]interface{}{
+					"type":        "string",
+					"title":       "d Cookie (Optional)",
+					"description": "Required for Client Tokens (xoxc). Enter the 'd' cookie value (starts with xoxd-).",
+				},
+				"team_id": map[string]interface{}{
+					"type":        "string",
+					"title":       "Workspace ID (Optional)",
+					"description": "Team ID (T...) of the workspace, for Enterprise Grid tokens valid in several workspaces of the org.",
+				},
+				"app_token": map[string]interface{}{
+					"type":        "string",
+					"title":       "App-Level Token (Optional)",
+					"description": "App-level token (xapp-) with connections:write, enables Socket Mode. Without it, real-time events use RTM.",
+				},
+			},
+			"required": []string{"token"},
+		},
+	}, func() core.Provider {
+		return providers.NewSlackProvider()
+	})
+}
+
+// CleanupSelfReceipts removes receipts where the user is the sender of the message
+// This cleans up incorrectly stored receipts from previous versions
+func CleanupSelfReceipts() {
+	if db.DB == nil {
+		return
+	}
+
+	// Find all receipts where user_id matches the sender_id of the message
+	var receiptsToDelete []models.MessageReceipt
+	err := db.DB.
+		Joins("JOIN messages ON messages.id = message_receipts.message_id").
+		Where("message_receipts.user_id = messages.sender_id").
+		Find(&receiptsToDelete).Error
+
+	if err != nil {
+		log.Printf("Warning: Failed to find self receipts to clean up: %v", err)
+		return
+	}
+
+	if len(receiptsToDelete) > 0 {
+		log.Printf("Found %d self receipts to clean up", len(receiptsToDelete))
+		err = db.DB.Delete(&receiptsToDelete).Error
+		if err != nil {
+			log.Printf("Warning: Failed to delete self receipts: %v", err)
+		} else {
+			log.Printf("Successfully cleaned up %d self receipts", len(receiptsToDelete))
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package backend
+
+import (
+	"Loom/pkg/core"
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"log"
+	"regexp"
+	"time"
+)
+
+// skinToneRegex matches the skin-tone modifiers Slack appends to emoji names.
+var skinToneRegex = regexp.MustCompile(`:skin-tone-[2-6]:`)
+
+// CleanEmoji removes Slack skin-tone modifiers so reactions are stored consistently regardless of source.
+func CleanEmoji(emoji string) string {
+	return skinToneRegex.ReplaceAllString(emoji, "")
+}
+
+// PersistReaction saves or removes a reaction in the database.
+// It returns the event with its emoji cleaned, ready to be forwarded, and the database error if
+// the reaction could not be saved. A reaction to a message not stored yet is not an error.
+func PersistReaction(e core.ReactionEvent) (core.ReactionEvent, error) {
+	cleanedEmoji := CleanEmoji(e.Emoji)
+	var saveErr error
+
+	if db.DB != nil {
+		// Find the message by protocol message ID
+		var message models.Message
+		if err := db.DB.Where("protocol_msg_id = ? AND protocol_conv_id = ?", e.MessageID, e.ConversationID).First(&message).Error; err == nil {
+			if e.Added {
+				// Check if reaction already exists (using cleaned emoji for comparison)
+				var existingReaction models.Reaction
+				reactionExists := db.DB.Where("message_id = ? AND user_id = ? AND emoji = ?", message.ID, e.UserID, cleanedEmoji).First(&existingReaction).Error == nil
+
+				if !reactionExists {
+					reaction := models.Reaction{
+						MessageID: message.ID,
+						UserID:    e.UserID,
+						Emoji:     cleanedEmoji,
+						CreatedAt: time.Unix(e.Timestamp, 0),
+						UpdatedAt: time.Unix(e.Timestamp, 0),
+					}
+					if err := db.DB.Create(&reaction).Error; err != nil {
+						log.Printf("Backend: Failed to save reaction to database: %v", err)
+						saveErr = err
+					} else {
+						log.Printf("Backend: Saved reaction to database for message %s, user %s, emoji %s (cleaned from %s)", e.MessageID, e.UserID, cleanedEmoji, e.Emoji)
+					}
+				} else {
+					log.Printf("Backend: Reaction already exists in database for message %s, user %s, emoji %s", e.MessageID, e.UserID, cleanedEmoji)
+				}
+			} else {
+				// Remove reaction (using cleaned emoji for comparison)
+				var existingReaction models.Reaction
+				if err := db.DB.Where("message_id = ? AND user_id = ? AND emoji = ?", message.ID, e.UserID, cleanedEmoji).First(&existingReaction).Error; err == nil {
+					if err := db.DB.Delete(&existingReaction).Error; err != nil {
+						log.Printf("Backend: Failed to delete reaction from database: %v", err)
+						saveErr = err
+					} else {
+						log.Printf("Backend: Deleted reaction from database for message %s, user %s, emoji %s", e.MessageID, e.UserID, cleanedEmoji)
+					}
+				} else {
+					log.Printf("Backend: Reaction not found in database for deletion: message %s, user %s, emoji %s", e.MessageID, e.UserID, cleanedEmoji)
+				}
+			}
+		} else {
+			log.Printf("Backend: Message not found in database for reaction: conversation %s, message %s (this is OK if message hasn't been loaded yet)", e.ConversationID, e.MessageID)
+		}
+	}
+
+	e.Emoji = cleanedEmoji
+	return e, saveErr
+}
+
+// PersistReceipt saves a delivery/read receipt in the database.
+// Receipts from the sender of the message are ignored; a newer receipt of the same type updates the timestamp.
+// It returns the database error if the receipt could not be saved; a receipt of a message not
+// stored yet is not an error.
+func PersistReceipt(e core.ReceiptEvent) error {
+	if db.DB == nil {
+		return nil
+	}
+
+	var message models.Message
+	if err := db.DB.Where("protocol_msg_id = ? AND protocol_conv_id = ?", e.MessageID, e.ConversationID).First(&message).Error; err != nil {
+		log.Printf("Backend: Message not found for receipt: conversation %s, message %s", e.ConversationID, e.MessageID)
+		return nil
+	}
+
+	// Don't save receipts from the message sender (we don't count ourselves)
+	if e.UserID == message.SenderID {
+		log.Printf("Backend: Skipping receipt from sender themselves for message %s, user %s", e.MessageID, e.UserID)
+		return nil
+	}
+
+	receiptTimestamp := time.Unix(e.Timestamp, 0)
+	var existingReceipt models.MessageReceipt
+	if err := db.DB.Where("message_id = ? AND user_id = ? AND receipt_type = ?", message.ID, e.UserID, string(e.ReceiptType)).First(&existingReceipt).Error; err != nil {
+		receipt := models.MessageReceipt{
+			MessageID:   message.ID,
+			UserID:      e.UserID,
+			ReceiptType: string(e.ReceiptType),
+			Timestamp:   receiptTimestamp,
+		}
+		if err := db.DB.Create(&receipt).Error; err != nil {
+			log.Printf("Backend: Failed to save receipt to database: %v", err)
+			return err
+		}
+		log.Printf("Backend: Saved receipt to database for message %s, user %s, type %s", e.MessageID, e.UserID, e.ReceiptType)
+		return nil
+	}
+
+	// Update existing receipt timestamp if newer
+	if receiptTimestamp.After(existingReceipt.Timestamp) {
+		existingReceipt.Timestamp = receiptTimestamp
+		if err := db.DB.Save(&existingReceipt).Error; err != nil {
+			log.Printf("Backend: Failed to update receipt in database: %v", err)
+			return err
+		} else {
+			log.Printf("Backend: Updated receipt in database for message %s, user %s, type %s", e.MessageID, e.UserID, e.ReceiptType)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package backend
+
+import (
+	"Loom/pkg/core"
+	"Loom/pkg/models"
+	"Loom/pkg/notifications"
+	"Loom/pkg/readstate"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Recorder persists the events of every provider instance that providers do not store themselves
+// (reactions, receipts and read state) and evaluates notification rules for incoming messages.
+// It records every instance, whether or not it is the active one.
+type Recorder struct {
+	pm             *core.ProviderManager
+	notifier       *notifications.Engine
+	onEvent        func(core.InstanceEvent) // Called with every event once recorded (nil: none)
+	onUnreadChange func(*models.ReadMarker) // Called when the unread counters of a conversation change (nil: none)
+	mu             sync.Mutex
+	stats          map[string]*core.InstanceStats // Key: InstanceID
+}
+
+// NewRecorder creates a recorder for the instances of pm.
+// notifier may be nil to skip notification rules.
+func NewRecorder(pm *core.ProviderManager, notifier *notifications.Engine) *Recorder {
+	return &Recorder{
+		pm:       pm,
+		notifier: notifier,
+		stats:    make(map[string]*core.InstanceStats),
+	}
+}
+
+// SetEventHandler sets the function called with every event once it is recorded, e.g. to show
+// it in the frontend. Reactions are passed with their emoji cleaned. It must be called before Run.
+func (r *Recorder) SetEventHandler(handler func(core.InstanceEvent)) {
+	r.onEvent = handler
+}
+
+// SetUnreadHandler sets the function called when the unread counters of a conversation change.
+// It must be called before Run.
+func (r *Recorder) SetUnreadHandler(handler func(*models.ReadMarker)) {
+	r.onUnreadChange = handler
+}
+
+// Run records events until ctx is cancelled.
+// Events are never dropped: the providers wait while the recorder is behind.
+func (r *Recorder) Run(ctx context.Context) {
+	events, unsubscribe := r.pm.SubscribeEventsBlocking()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case instanceEvent, ok := <-events:
+			if !ok {
+				return
+			}
+			instanceEvent.Event = r.handle(instanceEvent)
+			if r.onEvent != nil {
+				r.onEvent(instanceEvent)
+			}
+		}
+	}
+}
+
+// Stats returns a copy of the per-instance counters.
+func (r *Recorder) Stats() map[string]core.InstanceStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := make(map[string]core.InstanceStats, len(r.stats))
+	for instanceID, s := range r.stats {
+		stats[instanceID] = *s
+	}
+	return stats
+}
+
+// handle persists one event and returns it as it should be forwarded.
+func (r *Recorder) handle(instanceEvent core.InstanceEvent) core.ProviderEvent {
+	instanceID := instanceEvent.InstanceID
+
+	switch e := instanceEvent.Event.(type) {
+	case core.MessageEvent:
+		r.count(instanceID, func(s *core.InstanceStats) { s.Messages++ })
+
+		selfUserID := ""
+		if provider, err := r.pm.GetProvider(instanceID); err == nil {
+			if selfIdentifier, ok := provider.(core.SelfIdentifier); ok {
+				selfUserID = selfIdentifier.GetSelfUserID()
+			}
+		}
+		// Group-wide mentions only occur in groups, so they are always checked
+		mentioned := notifications.MessageMentionsUser(e.Message, selfUserID, true)
+		marker, changed, err := readstate.RecordIncoming(instanceID, e.Message, mentioned)
+		if err != nil {
+			log.Printf("Recorder: Failed to update read marker for conversation %s: %v", e.Message.ProtocolConvID, err)
+		} else if changed {
+			r.unreadChanged(marker)
+		}
+		r.countResult(instanceID, err)
+		if r.notifier != nil {
+			r.notifier.HandleMessage(instanceID, selfUserID, e.Message)
+		}
+
+	case core.ReactionEvent:
+		r.count(instanceID, func(s *core.InstanceStats) { s.Reactions++ })
+		// Save the reaction with Slack skin-tone modifiers removed
+		reaction, err := PersistReaction(e)
+		r.countResult(instanceID, err)
+		return reaction
+
+	case core.ReceiptEvent:
+		r.count(instanceID, func(s *core.InstanceStats) { s.Receipts++ })
+		r.countResult(instanceID, PersistReceipt(e))
+
+	case core.ReadMarkerEvent:
+		var lastReadAt time.Time
+		if e.Timestamp > 0 {
+			lastReadAt = time.Unix(e.Timestamp, 0)
+		}
+		marker, changed, err := readstate.Reconcile(instanceID, e.ConversationID, e.LastReadMessageID, lastReadAt, e.UnreadCount)
+		if err != nil {
+			log.Printf("Recorder: Failed to reconcile read marker for conversation %s: %v", e.ConversationID, err)
+		} else if changed {
+			r.unreadChanged(marker)
+		}
+		r.countResult(instanceID, err)
+
+	case core.SyncStatusEvent:
+		if e.Status == core.SyncStatusCompleted {
+			r.count(instanceID, func(s *core.InstanceStats) { s.LastSyncAt = time.Now() })
+		}
+	}
+	return instanceEvent.Event
+}
+
+// unreadChanged reports a change of the unread counters of a conversation.
+func (r *Recorder) unreadChanged(marker *models.ReadMarker) {
+	if r.onUnreadChange != nil {
+		r.onUnreadChange(marker)
+	}
+}
+
+// countResult counts an event as persisted, or as failed if err is not nil.
+func (r *Recorder) countResult(instanceID string, err error) {
+	r.count(instanceID, func(s *core.InstanceStats) {
+		if err != nil {
+			s.Failed++
+		} else {
+			s.Persisted++
+		}
+	})
+}
+
+// count updates the counters of an instance.
+func (r *Recorder) count(instanceID string, update func(*core.InstanceStats)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.stats[instanceID]
+	if !ok {
+		s = &core.InstanceStats{}
+		r.stats[instanceID] = s
+	}
+	update(s)
+	s.LastEventAt = time.Now()
+}
@@ -0,0 +1,132 @@
+package backend
+
+import (
+	"Loom/pkg/core"
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"fmt"
+	"log"
+	"time"
+)
+
+// RestoreOptions controls how providers are brought back on startup.
+type RestoreOptions struct {
+	// SyncAll syncs the history of every connected instance instead of the active one only.
+	SyncAll bool
+	// SyncDelay is waited before syncing, so that event listeners started after
+	// RestoreProviders returns do not miss the synced messages.
+	SyncDelay time.Duration
+}
+
+// RestoreProviders restores the provider instances saved in the database, connects the
+// authenticated ones and syncs their missed history in the background.
+// It returns the active provider and its instance ID (nil and "" if none could be restored).
+func RestoreProviders(pm *core.ProviderManager, opts RestoreOptions) (core.Provider, string) {
+	configs, err := pm.LoadProviderConfigs()
+	if err != nil {
+		fmt.Printf("Backend.RestoreProviders: Warning: Failed to load provider configs: %v\n", err)
+		configs = []models.ProviderConfiguration{}
+	}
+	fmt.Printf("Backend.RestoreProviders: Loaded %d provider configs from database\n", len(configs))
+	if len(configs) == 0 {
+		fmt.Printf("Backend.RestoreProviders: No provider configs found in database, skipping restoration\n")
+	}
+
+	var activeProvider core.Provider
+	activeInstanceID := ""
+	restoredCount := 0
+	for _, config := range configs {
+		providerConfig := config
+		fmt.Printf("Backend.RestoreProviders: Attempting to restore provider %s (InstanceID: %s, InstanceName: %s, IsActive: %v)\n",
+			providerConfig.ProviderID, providerConfig.InstanceID, providerConfig.InstanceName, providerConfig.IsActive)
+
+		provider, err := pm.RestoreProvider(providerConfig)
+		if err != nil {
+			fmt.Printf("Backend.RestoreProviders: ERROR - Failed to restore provider %s: %v\n", providerConfig.ProviderID, err)
+			continue
+		}
+		restoredCount++
+
+		instanceID := providerConfig.InstanceID
+		if instanceID == "" {
+			instanceID = fmt.Sprintf("%s-1", providerConfig.ProviderID)
+		}
+
+		// Only connect the provider if it's already authenticated
+		// Providers that need authentication (like WhatsApp) should only be connected
+		// when the user explicitly requests it
+		isAuth := provider.IsAuthenticated()
+		fmt.Printf("Backend.RestoreProviders: Provider %s (instanceID: %s) IsAuthenticated: %v\n", providerConfig.ProviderID, instanceID, isAuth)
+		if !isAuth {
+			log.Printf("Provider %s (instanceID: %s) is not authenticated yet, skipping auto-connect. User must configure it first.", providerConfig.ProviderID, instanceID)
+			// Don't set as active if not authenticated
+			if providerConfig.IsActive {
+				log.Printf("Warning: Provider %s is marked as active but not authenticated, clearing active status", providerConfig.ProviderID)
+				if db.DB != nil {
+					db.DB.Model(&models.ProviderConfiguration{}).Where("instance_id = ?", instanceID).Update("is_active", false)
+				}
+			}
+			// The provider stays registered in the manager so it can be configured later
+			continue
+		}
+
+		if err := provider.Connect(); err != nil {
+			log.Printf("Warning: Failed to connect provider %s: %v", providerConfig.ProviderID, err)
+			continue
+		}
+		log.Printf("Provider %s (instanceID: %s) connected successfully", providerConfig.ProviderID, instanceID)
+
+		// Set as active provider if marked as active (BEFORE sync to ensure events are captured)
+		if providerConfig.IsActive {
+			activeProvider = provider
+			activeInstanceID = instanceID
+			if err := pm.SetActiveProvider(instanceID); err != nil {
+				log.Printf("Warning: Failed to set active provider instance %s: %v", instanceID, err)
+			} else {
+				fmt.Printf("Backend.RestoreProviders: Set provider %s (instanceID: %s) as active provider\n", providerConfig.ProviderID, instanceID)
+			}
+		}
+
+		// Sync missed messages on startup
+		if providerConfig.IsActive || opts.SyncAll {
+			go func(p core.Provider, instID string, lastSyncAt *time.Time) {
+				if opts.SyncDelay > 0 {
+					time.Sleep(opts.SyncDelay)
+				}
+				SyncProvider(p, instID, lastSyncAt)
+			}(provider, instanceID, providerConfig.LastSyncAt)
+		}
+	}
+
+	fmt.Printf("Backend.RestoreProviders: Finished restoring providers. Restored %d/%d providers. Active provider: %v\n",
+		restoredCount, len(configs), activeProvider != nil)
+	return activeProvider, activeInstanceID
+}
+
+// SyncProvider syncs the history of a provider instance since its last sync
+// (at most 24 hours back, or one year back on the first sync) and records the sync time.
+func SyncProvider(p core.Provider, instanceID string, lastSyncAt *time.Time) {
+	var since time.Time
+	if lastSyncAt != nil {
+		// Use last sync time, but ensure we sync at least the last 24 hours
+		oneDayAgo := time.Now().Add(-24 * time.Hour)
+		if lastSyncAt.Before(oneDayAgo) {
+			since = oneDayAgo
+		} else {
+			since = *lastSyncAt
+		}
+		fmt.Printf("Backend.SyncProvider: Syncing provider instance %s since last sync: %s\n", instanceID, since.Format("2006-01-02 15:04:05"))
+	} else {
+		// First time sync - sync last 1 year to get all conversations
+		since = time.Now().Add(-365 * 24 * time.Hour)
+		fmt.Printf("Backend.SyncProvider: First time sync for provider instance %s, syncing since %s\n", instanceID, since.Format("2006-01-02 15:04:05"))
+	}
+
+	if err := p.SyncHistory(since); err != nil {
+		log.Printf("Warning: Failed to sync history for provider instance %s: %v", instanceID, err)
+		return
+	}
+	if db.DB != nil {
+		db.DB.Model(&models.ProviderConfiguration{}).Where("instance_id = ?", instanceID).Update("last_sync_at", time.Now())
+	}
+}
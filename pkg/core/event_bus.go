@@ -0,0 +1,165 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultSubscriberBuffer is the channel size given to each event subscriber.
+const defaultSubscriberBuffer = 500
+
+// InstanceEvent is a provider event tagged with the provider instance that emitted it.
+type InstanceEvent struct {
+	InstanceID string        // Provider instance that emitted the event (e.g., "whatsapp-1")
+	Event      ProviderEvent // The event itself
+}
+
+// InstanceStats counts the events recorded for a provider instance.
+type InstanceStats struct {
+	Messages    int       `json:"messages"`
+	Reactions   int       `json:"reactions"`
+	Receipts    int       `json:"receipts"`
+	Persisted   int       `json:"persisted"`   // Events whose state was saved to the database
+	Failed      int       `json:"failed"`      // Events whose state could not be saved
+	LastEventAt time.Time `json:"lastEventAt"` // Zero if no event was recorded
+	LastSyncAt  time.Time `json:"lastSyncAt"`  // When the last synchronization completed (zero if none)
+}
+
+// EventBus fans out the events of every provider instance to any number of subscribers.
+// A provider's event channel can only be drained by one reader, so the bus is that reader
+// and consumers (frontend, auto-responder, ...) subscribe to the bus instead.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[int]*subscriber
+	nextID      int
+	sources     map[string]<-chan ProviderEvent // Key: InstanceID, channel currently pumped
+}
+
+// subscriber is a channel receiving the events of the bus.
+type subscriber struct {
+	ch       chan InstanceEvent
+	blocking bool          // Publish waits for room in ch instead of dropping the event
+	done     chan struct{} // Closed on unsubscribe, releases a Publish waiting on ch
+}
+
+// NewEventBus creates an empty event bus.
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[int]*subscriber),
+		sources:     make(map[string]<-chan ProviderEvent),
+	}
+}
+
+// Subscribe returns a channel receiving the events of all provider instances,
+// and a function to call to stop receiving them. Events are dropped while its buffer is full.
+func (b *EventBus) Subscribe() (<-chan InstanceEvent, func()) {
+	return b.subscribe(false)
+}
+
+// SubscribeBlocking is like Subscribe, but no event is ever dropped: while the buffer is full,
+// the events of the instances wait for the subscriber. It is meant for the consumers persisting
+// the events, which must read them until they unsubscribe.
+func (b *EventBus) SubscribeBlocking() (<-chan InstanceEvent, func()) {
+	return b.subscribe(true)
+}
+
+// subscribe registers a subscriber.
+func (b *EventBus) subscribe(blocking bool) (<-chan InstanceEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	sub := &subscriber{
+		ch:       make(chan InstanceEvent, defaultSubscriberBuffer),
+		blocking: blocking,
+		done:     make(chan struct{}),
+	}
+	b.subscribers[id] = sub
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			// Release a Publish waiting on the subscriber before taking the lock it holds
+			close(sub.done)
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.subscribers[id]; ok {
+				delete(b.subscribers, id)
+				close(sub.ch)
+			}
+		})
+	}
+	return sub.ch, unsubscribe
+}
+
+// Publish delivers an event to every subscriber.
+// Slow subscribers whose buffer is full miss the event rather than blocking the others,
+// except blocking subscribers, which are waited for.
+func (b *EventBus) Publish(instanceID string, event ProviderEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	instanceEvent := InstanceEvent{InstanceID: instanceID, Event: event}
+	for id, sub := range b.subscribers {
+		if sub.blocking {
+			select {
+			case sub.ch <- instanceEvent:
+			case <-sub.done:
+			}
+			continue
+		}
+		select {
+		case sub.ch <- instanceEvent:
+		default:
+			fmt.Printf("EventBus: WARNING - subscriber %d is full, dropping %s event from %s\n", id, event.Type(), instanceID)
+		}
+	}
+}
+
+// Attach starts forwarding the events of a provider instance to the bus.
+// Attaching the same provider twice is a no-op; attaching a new provider for an
+// existing instance ID replaces the previous source.
+func (b *EventBus) Attach(instanceID string, provider Provider) {
+	source, err := provider.StreamEvents()
+	if err != nil || source == nil {
+		fmt.Printf("EventBus: WARNING - cannot stream events of instance %s: %v\n", instanceID, err)
+		return
+	}
+
+	b.mu.Lock()
+	if current, ok := b.sources[instanceID]; ok && current == source {
+		b.mu.Unlock()
+		return
+	}
+	b.sources[instanceID] = source
+	b.mu.Unlock()
+
+	go func() {
+		for event := range source {
+			b.mu.RLock()
+			current := b.sources[instanceID] == source
+			b.mu.RUnlock()
+			if !current {
+				// The instance was replaced or removed
+				return
+			}
+			b.Publish(instanceID, event)
+		}
+		fmt.Printf("EventBus: Event stream of instance %s closed\n", instanceID)
+
+		b.mu.Lock()
+		if b.sources[instanceID] == source {
+			delete(b.sources, instanceID)
+		}
+		b.mu.Unlock()
+	}()
+}
+
+// Detach stops forwarding the events of a provider instance.
+func (b *EventBus) Detach(instanceID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.sources, instanceID)
+}
@@ -25,6 +25,14 @@ const (
 	EventTypeRetryReceipt EventType = "retry_receipt"
 	// EventTypeSyncStatus represents a synchronization status update event.
 	EventTypeSyncStatus EventType = "sync_status"
+	// EventTypeReadMarker represents a change of the read position of a conversation on the provider side.
+	EventTypeReadMarker EventType = "read_marker"
+	// EventTypeThreadUpdate represents new replies in a discussion thread.
+	EventTypeThreadUpdate EventType = "thread_update"
+	// EventTypeUploadProgress represents the progress of a file upload.
+	EventTypeUploadProgress EventType = "upload_progress"
+	// EventTypeAttachmentUpdate represents attachments of a message that finished downloading.
+	EventTypeAttachmentUpdate EventType = "attachment_update"
 )
 
 // ProviderEvent is the base interface for all provider events.
@@ -72,11 +80,12 @@ func (e TypingEvent) Type() EventType {
 
 // ContactStatusEvent represents a change in contact status (online/offline, last seen, etc.).
 type ContactStatusEvent struct {
-	UserID      string // Protocol user ID
-	Status      string // "online", "offline", "away", "busy", etc.
-	LastSeen    *int64 // Unix timestamp of last seen (nil if not available)
-	StatusEmoji string // Emoji associated with the status (e.g., ":calendar:", "📅")
-	StatusText  string // Status text (e.g., "en réunion", "in a meeting")
+	UserID         string // Protocol user ID
+	Status         string // "online", "offline", "away", "busy", etc.
+	LastSeen       *int64 // Unix timestamp of last seen (nil if not available)
+	StatusEmoji    string // Emoji associated with the status (e.g., ":calendar:", "📅")
+	StatusEmojiURL string // Image of a custom status emoji: cached file path or remote URL ("" for standard emojis)
+	StatusText     string // Status text (e.g., "en réunion", "in a meeting")
 }
 
 // Type returns the event type for ContactStatusEvent.
@@ -121,6 +130,8 @@ type GroupChangeEvent struct {
 	ConversationID string          // Protocol conversation ID
 	ChangeType     GroupChangeType // Type of change
 	GroupName      string          // Updated group name (if applicable)
+	Topic          string          // Updated topic (if applicable)
+	Description    string          // Updated description, the Slack channel purpose (if applicable)
 	ParticipantID  string          // User ID of the participant (if applicable)
 	Timestamp      int64           // Unix timestamp
 }
@@ -179,6 +190,8 @@ const (
 	SyncStatusFetchingHistory SyncStatusType = "fetching_history"
 	// SyncStatusFetchingAvatars indicates profile pictures are being loaded.
 	SyncStatusFetchingAvatars SyncStatusType = "fetching_avatars"
+	// SyncStatusThrottled indicates requests are delayed by the rate limits of the service.
+	SyncStatusThrottled SyncStatusType = "throttled"
 	// SyncStatusCompleted indicates synchronization is completed.
 	SyncStatusCompleted SyncStatusType = "completed"
 	// SyncStatusError indicates an error occurred during synchronization.
@@ -197,3 +210,61 @@ type SyncStatusEvent struct {
 func (e SyncStatusEvent) Type() EventType {
 	return EventTypeSyncStatus
 }
+
+// ReadMarkerEvent reports the read state of a conversation as known by the provider
+// (e.g. the conversation was read from another device).
+type ReadMarkerEvent struct {
+	ConversationID    string // Protocol conversation ID
+	LastReadMessageID string // Protocol ID of the last message read ("" if unknown)
+	UnreadCount       int    // Number of unread messages, -1 if unknown
+	Timestamp         int64  // Unix timestamp of the read position (0 if unknown)
+}
+
+// Type returns the event type for ReadMarkerEvent.
+func (e ReadMarkerEvent) Type() EventType {
+	return EventTypeReadMarker
+}
+
+// ThreadUpdateEvent reports that a discussion thread changed (e.g. a new reply arrived).
+type ThreadUpdateEvent struct {
+	ConversationID  string   // Protocol conversation ID
+	ParentMessageID string   // Protocol ID of the thread parent message
+	ReplyCount      int      // Number of replies in the thread
+	LatestReplyAt   int64    // Unix timestamp of the latest reply
+	ParticipantIDs  []string // User IDs who replied in the thread
+	LatestReplyID   string   // Protocol ID of the reply that triggered the update ("" if unknown)
+}
+
+// Type returns the event type for ThreadUpdateEvent.
+func (e ThreadUpdateEvent) Type() EventType {
+	return EventTypeThreadUpdate
+}
+
+// UploadProgressEvent represents the progress of a file being sent.
+type UploadProgressEvent struct {
+	ConversationID string // Protocol conversation ID
+	FileName       string // Name of the file being uploaded
+	BytesSent      int64  // Number of bytes uploaded so far
+	TotalBytes     int64  // Size of the file in bytes
+	Progress       int    // Progress percentage (0-100)
+	Done           bool   // true once the file has been shared in the conversation
+	Error          string // Error message if the upload failed
+}
+
+// Type returns the event type for UploadProgressEvent.
+func (e UploadProgressEvent) Type() EventType {
+	return EventTypeUploadProgress
+}
+
+// AttachmentUpdateEvent reports that the attachments of a stored message changed, e.g. once
+// files received with the message have been downloaded in the background.
+type AttachmentUpdateEvent struct {
+	ConversationID string              // Protocol conversation ID
+	MessageID      string              // Protocol message ID
+	Attachments    []models.Attachment // All the attachments of the message
+}
+
+// Type returns the event type for AttachmentUpdateEvent.
+func (e AttachmentUpdateEvent) Type() EventType {
+	return EventTypeAttachmentUpdate
+}
@@ -3,6 +3,9 @@ package core
 
 import (
 	"Loom/pkg/models"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -185,9 +188,10 @@ type Provider interface {
 	JoinGroupByInviteLink(inviteLink string) (*models.Conversation, error)
 
 	// JoinGroupByInviteMessage joins a group using an invite message.
+	// conversationID is the conversation the invite message was received in.
 	// inviteMessageID is the protocol-specific message ID of the invite message.
 	// Returns the conversation or an error.
-	JoinGroupByInviteMessage(inviteMessageID string) (*models.Conversation, error)
+	JoinGroupByInviteMessage(conversationID string, inviteMessageID string) (*models.Conversation, error)
 
 	// --- Receipts ---
 
@@ -251,3 +255,257 @@ type Provider interface {
 	// Returns the created message or an error.
 	SendStatusMessage(text string, file *Attachment) (*models.Message, error)
 }
+
+// SelfIdentifier is an optional interface for providers that can report who the logged-in
+// account is (e.g. to detect @-mentions of the user or fill in message templates).
+type SelfIdentifier interface {
+	// GetSelfUserID returns the user ID of the authenticated account, or an empty string if unknown.
+	GetSelfUserID() string
+	// GetSelfDisplayName returns the display name of the authenticated account, or an empty string if unknown.
+	GetSelfDisplayName() string
+}
+
+// ReadMarkerSupport is an optional interface for providers whose read markers depend on the
+// account (e.g. Slack bot tokens have no read state). Providers without it support read markers.
+type ReadMarkerSupport interface {
+	// SupportsReadMarkers reports whether marking messages as read is synced with the service.
+	SupportsReadMarkers() bool
+}
+
+// SupportsReadMarkers reports whether provider syncs read markers with its service.
+func SupportsReadMarkers(provider Provider) bool {
+	if support, ok := provider.(ReadMarkerSupport); ok {
+		return support.SupportsReadMarkers()
+	}
+	return true
+}
+
+// ConnectionReporter is an optional interface for providers that can tell whether their
+// connection to the service is currently up (a session can be authenticated but disconnected).
+type ConnectionReporter interface {
+	// IsConnected reports whether the provider is connected to its service.
+	IsConnected() bool
+}
+
+// IsConnected reports whether provider is connected to its service.
+// Providers without ConnectionReporter are considered connected while authenticated.
+func IsConnected(provider Provider) bool {
+	if reporter, ok := provider.(ConnectionReporter); ok {
+		return reporter.IsConnected()
+	}
+	return provider.IsAuthenticated()
+}
+
+// ConversationThreadLoader is an optional interface for providers whose message IDs are only
+// unique within a conversation (Slack timestamps), so a thread parent is found by both.
+type ConversationThreadLoader interface {
+	// GetConversationThread loads all replies of the thread started by parentMessageID in conversationID.
+	GetConversationThread(conversationID string, parentMessageID string) ([]models.Message, error)
+}
+
+// GetConversationThread loads the replies of a thread of a conversation, with the conversation
+// scoped lookup of the provider when it has one.
+func GetConversationThread(provider Provider, conversationID string, parentMessageID string) ([]models.Message, error) {
+	if loader, ok := provider.(ConversationThreadLoader); ok && conversationID != "" {
+		return loader.GetConversationThread(conversationID, parentMessageID)
+	}
+	return provider.GetThreads(parentMessageID)
+}
+
+// ThreadReplier is an optional interface for providers whose threads can be answered
+// with the reply also posted to the conversation (Slack's "also send to channel").
+type ThreadReplier interface {
+	// SendThreadReply posts text in the thread started by threadID.
+	// If alsoSendToChannel is true, the reply is also shown in the conversation itself.
+	SendThreadReply(conversationID string, threadID string, text string, alsoSendToChannel bool) (*models.Message, error)
+}
+
+// PresenceState is the presence the logged-in account shows to the other users.
+type PresenceState string
+
+const (
+	// PresenceAvailable shows the account as online (Slack "auto", WhatsApp "available").
+	PresenceAvailable PresenceState = "available"
+	// PresenceAway shows the account as away (Slack "away", WhatsApp "unavailable").
+	PresenceAway PresenceState = "away"
+)
+
+// MyStatus is the status of the logged-in account as the other users see it.
+type MyStatus struct {
+	Text       string        `json:"text"`                 // Custom status (Slack) or about text (WhatsApp)
+	Emoji      string        `json:"emoji,omitempty"`      // Status emoji, e.g. ":palm_tree:" (Slack)
+	Expiration *time.Time    `json:"expiration,omitempty"` // When the status is cleared (nil: never)
+	Presence   PresenceState `json:"presence,omitempty"`   // Presence of the account ("" if unknown)
+	DNDUntil   *time.Time    `json:"dndUntil,omitempty"`   // End of do not disturb (nil: off)
+}
+
+// MyStatusFeatures tells which parts of MyStatus a provider can set.
+type MyStatusFeatures struct {
+	Emoji        bool `json:"emoji"`
+	Expiration   bool `json:"expiration"`
+	DoNotDisturb bool `json:"doNotDisturb"`
+}
+
+// MyStatusManager is an optional interface for providers that can read and change the status
+// of the logged-in account (Slack custom status, presence and do not disturb; WhatsApp about
+// text and presence).
+type MyStatusManager interface {
+	// MyStatusFeatures returns the parts of the status the provider supports.
+	MyStatusFeatures() MyStatusFeatures
+	// GetMyStatus returns the current status of the account.
+	GetMyStatus() (*MyStatus, error)
+	// SetMyStatusText sets the status text. An empty text and emoji clear the status.
+	// emoji and expiration are ignored by providers that do not support them.
+	SetMyStatusText(text string, emoji string, expiration *time.Time) error
+	// SetMyPresence sets the presence of the account.
+	SetMyPresence(presence PresenceState) error
+	// SetDoNotDisturb pauses notifications for duration; a zero duration ends do not disturb.
+	// It returns an error when the provider does not support it.
+	SetDoNotDisturb(duration time.Duration) error
+}
+
+// DirectoryChannel is a public channel listed in the channel directory of a service.
+type DirectoryChannel struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Topic       string `json:"topic,omitempty"`
+	Purpose     string `json:"purpose,omitempty"`
+	MemberCount int    `json:"memberCount"`
+	IsMember    bool   `json:"isMember"`   // The logged-in account is a member of the channel
+	IsArchived  bool   `json:"isArchived"` // The channel is archived (read-only)
+}
+
+// ChannelDirectoryPage is a page of channel directory results.
+type ChannelDirectoryPage struct {
+	Channels   []DirectoryChannel `json:"channels"`
+	NextCursor string             `json:"nextCursor,omitempty"` // Cursor of the next page, empty on the last page
+}
+
+// ChannelDirectory is an optional interface for providers whose public channels can be
+// browsed and joined without an invitation (Slack). Channels are left with LeaveGroup.
+type ChannelDirectory interface {
+	// BrowseChannels returns a page of the public channels whose name, topic or purpose contains
+	// query (case-insensitive, all channels if query is empty). cursor is "" for the first page,
+	// then the NextCursor of the previous page. A page may hold fewer than limit channels before the end.
+	BrowseChannels(query string, cursor string, limit int, includeArchived bool) (*ChannelDirectoryPage, error)
+	// JoinChannel joins a public channel and returns its conversation.
+	JoinChannel(channelID string) (*models.Conversation, error)
+	// ArchiveChannel archives a channel, if the workspace permits it for the account.
+	ArchiveChannel(channelID string) error
+	// UnarchiveChannel restores an archived channel, if the workspace permits it for the account.
+	UnarchiveChannel(channelID string) error
+}
+
+// ErrNativeSchedulingUnavailable is returned by MessageScheduler.ScheduleMessage when the service
+// cannot schedule this message (e.g. too far in the future); the local scheduler is used instead.
+var ErrNativeSchedulingUnavailable = errors.New("the service cannot schedule this message")
+
+// ScheduledMessage is a message waiting to be sent at a given time.
+type ScheduledMessage struct {
+	ID             string    `json:"id"`             // Scheduled message ID on the service, or "local-<n>" for the local scheduler
+	ConversationID string    `json:"conversationId"` // Protocol conversation ID
+	Text           string    `json:"text"`
+	ThreadID       *string   `json:"threadId,omitempty"` // Thread the message is posted in (nil: the conversation)
+	PostAt         time.Time `json:"postAt"`
+	Native         bool      `json:"native"` // Scheduled on the servers of the service: sent even when Loom is closed
+}
+
+// MessageScheduler is an optional interface for providers that can schedule messages on the
+// servers of their service (Slack). Messages of the other providers, and those the service
+// cannot schedule, go to the local scheduler, which sends them while Loom is running.
+type MessageScheduler interface {
+	// ScheduleMessage schedules text to be posted at postAt, in a thread if threadID is not nil.
+	// It returns ErrNativeSchedulingUnavailable (wrapped) when the service cannot schedule it.
+	ScheduleMessage(conversationID string, text string, threadID *string, postAt time.Time) (*ScheduledMessage, error)
+	// GetScheduledMessages returns the messages scheduled on the service in a conversation,
+	// or in all conversations if conversationID is empty.
+	GetScheduledMessages(conversationID string) ([]ScheduledMessage, error)
+	// CancelScheduledMessage deletes a message scheduled on the service before it is sent.
+	CancelScheduledMessage(conversationID string, scheduledID string) error
+}
+
+// SearchQuery is a provider-neutral message search. Zero fields do not filter.
+type SearchQuery struct {
+	Text           string     `json:"text"`                     // Words to find, "quoted phrases" kept together
+	ConversationID string     `json:"conversationId,omitempty"` // Only this protocol conversation
+	SenderID       string     `json:"senderId,omitempty"`       // Only the messages of this user
+	Since          *time.Time `json:"since,omitempty"`          // Only messages sent at or after this time
+	Until          *time.Time `json:"until,omitempty"`          // Only messages sent before this time
+	Files          bool       `json:"files,omitempty"`          // Search the shared files instead of the messages
+	Cursor         string     `json:"cursor,omitempty"`         // "" for the first page, then the NextCursor of the previous page
+	Limit          int        `json:"limit,omitempty"`          // Hits per page (the provider default if 0)
+}
+
+// SearchHit is a message found by a search, with its conversation context.
+type SearchHit struct {
+	Message          models.Message   `json:"message"`                    // The message; ID is 0 when it is not stored locally
+	ConversationName string           `json:"conversationName,omitempty"` // Name of the channel or person of the conversation
+	IsGroup          bool             `json:"isGroup"`
+	Context          []models.Message `json:"context,omitempty"`    // Neighbouring messages of the conversation, oldest first
+	Highlights       []string         `json:"highlights,omitempty"` // Terms of the body matched by the query
+	Permalink        string           `json:"permalink,omitempty"`  // Link to the message on the service
+	Remote           bool             `json:"remote"`               // Found on the service rather than in the local database
+}
+
+// SearchResults is a page of search hits.
+type SearchResults struct {
+	Hits       []SearchHit `json:"hits"`
+	Total      int         `json:"total"`                // Total number of hits on the service (-1 if unknown)
+	NextCursor string      `json:"nextCursor,omitempty"` // Cursor of the next page, empty on the last page
+}
+
+// RemoteSearcher is an optional interface for providers whose service can search the whole
+// history of the account, beyond the messages Loom has synchronized (Slack search.messages).
+type RemoteSearcher interface {
+	// SearchMessages searches the messages, or the files when query.Files is set, on the service.
+	SearchMessages(query SearchQuery) (*SearchResults, error)
+}
+
+// ParticipantFailure is a participant a group change could not be applied to.
+type ParticipantFailure struct {
+	ParticipantID string `json:"participantId"`
+	Code          int    `json:"code,omitempty"` // Error code of the service (e.g. 403 on WhatsApp)
+	Reason        string `json:"reason"`
+}
+
+// ParticipantChangeError is returned by the group participant changes (AddGroupParticipants,
+// RemoveGroupParticipants, PromoteGroupAdmins, DemoteGroupAdmins) when the change failed for
+// some participants. The change was applied to the other participants.
+type ParticipantChangeError struct {
+	Action   string               `json:"action"` // "add", "remove", "promote" or "demote"
+	Failures []ParticipantFailure `json:"failures"`
+}
+
+// Error lists the participants the change failed for, with the reasons.
+func (e *ParticipantChangeError) Error() string {
+	reasons := make([]string, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		reasons = append(reasons, failure.ParticipantID+": "+failure.Reason)
+	}
+	return fmt.Sprintf("failed to %s %d participant(s): %s", e.Action, len(e.Failures), strings.Join(reasons, "; "))
+}
+
+// ErrJoinRequestPending is returned (wrapped) when joining a group sent a join request that a
+// group admin must approve before the account becomes a member.
+var ErrJoinRequestPending = errors.New("the join request is waiting for the approval of a group admin")
+
+// GroupInvitePreview describes the group of an invitation before joining it.
+type GroupInvitePreview struct {
+	GroupID          string `json:"groupId"` // Protocol conversation ID of the group
+	Name             string `json:"name"`
+	Topic            string `json:"topic,omitempty"` // Description of the group
+	ParticipantCount int    `json:"participantCount"`
+	PictureURL       string `json:"pictureUrl,omitempty"`
+	ApprovalRequired bool   `json:"approvalRequired"` // Joining sends a request an admin must approve
+	IsMember         bool   `json:"isMember"`         // The account is already a member of the group
+}
+
+// GroupInvitePreviewer is an optional interface for providers that can describe the group of
+// an invitation without joining it (WhatsApp). Invitations are joined with
+// JoinGroupByInviteLink and JoinGroupByInviteMessage.
+type GroupInvitePreviewer interface {
+	// PreviewGroupInviteLink returns the group of an invite link.
+	PreviewGroupInviteLink(inviteLink string) (*GroupInvitePreview, error)
+	// PreviewGroupInviteMessage returns the group of an invitation message received in conversationID.
+	PreviewGroupInviteMessage(conversationID string, inviteMessageID string) (*GroupInvitePreview, error)
+}
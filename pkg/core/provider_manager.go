@@ -33,7 +33,8 @@ type ProviderManager struct {
 	factories        map[string]ProviderFactory // Key: ProviderID (e.g., "whatsapp")
 	infos            map[string]ProviderInfo    // Key: ProviderID (e.g., "whatsapp")
 	mu               sync.RWMutex
-	activeInstanceID string // InstanceID of the currently active provider (e.g., "whatsapp-1")
+	activeInstanceID string    // InstanceID of the currently active provider (e.g., "whatsapp-1")
+	events           *EventBus // Fan-out of the events of all provider instances
 }
 
 // NewProviderManager creates a new provider manager.
@@ -42,6 +43,7 @@ func NewProviderManager() *ProviderManager {
 		providers: make(map[string]Provider),
 		factories: make(map[string]ProviderFactory),
 		infos:     make(map[string]ProviderInfo),
+		events:    NewEventBus(),
 	}
 }
 
@@ -183,6 +185,7 @@ func (pm *ProviderManager) CreateProvider(providerID string, config ProviderConf
 	}
 
 	pm.providers[instanceID] = provider
+	pm.events.Attach(instanceID, provider)
 
 	// Save configuration to database
 	if err := pm.saveProviderConfig(providerID, instanceID, instanceName, config, false); err != nil {
@@ -217,6 +220,7 @@ func (pm *ProviderManager) AddProvider(id string, provider Provider) {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 	pm.providers[id] = provider
+	pm.events.Attach(id, provider)
 }
 
 // GetProvider returns a provider by ID.
@@ -268,6 +272,26 @@ func (pm *ProviderManager) GetActiveProvider() (Provider, error) {
 	return provider, nil
 }
 
+// GetActiveInstanceID returns the instance ID of the currently active provider,
+// or an empty string if no provider is active.
+func (pm *ProviderManager) GetActiveInstanceID() string {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return pm.activeInstanceID
+}
+
+// SubscribeEvents returns a channel receiving the events of all provider instances,
+// tagged with their instance ID, and a function to call to unsubscribe.
+func (pm *ProviderManager) SubscribeEvents() (<-chan InstanceEvent, func()) {
+	return pm.events.Subscribe()
+}
+
+// SubscribeEventsBlocking is like SubscribeEvents, but never drops an event: providers wait
+// while the subscriber is behind. It is for the consumers that persist the events.
+func (pm *ProviderManager) SubscribeEventsBlocking() (<-chan InstanceEvent, func()) {
+	return pm.events.SubscribeBlocking()
+}
+
 // RemoveProvider removes a provider instance and deletes it from the database.
 func (pm *ProviderManager) RemoveProvider(instanceID string) error {
 	fmt.Printf("ProviderManager.RemoveProvider: Called with instanceID=%s\n", instanceID)
@@ -288,6 +312,7 @@ func (pm *ProviderManager) RemoveProvider(instanceID string) error {
 	}
 
 	delete(pm.providers, instanceID)
+	pm.events.Detach(instanceID)
 
 	// Delete provider configuration and all associated data from database
 	if db.DB != nil {
@@ -472,6 +497,7 @@ func (pm *ProviderManager) RestoreProvider(config models.ProviderConfiguration)
 
 	fmt.Printf("ProviderManager.RestoreProvider: adding provider to pm.providers map with instanceID %s\n", instanceID)
 	pm.providers[instanceID] = provider
+	pm.events.Attach(instanceID, provider)
 	fmt.Printf("ProviderManager.RestoreProvider: provider added to pm.providers (now %d providers: %v)\n",
 		len(pm.providers), getMapKeys(pm.providers))
 
@@ -42,6 +42,18 @@ func InitDatabase() error {
 		&models.ProviderConfiguration{},
 		&models.ContactAlias{},
 		&models.LIDMapping{},
+		&models.NotificationRule{},
+		&models.ReadMarker{},
+		&models.Draft{},
+		&models.MessageTemplate{},
+		&models.AutoReplyRule{},
+		&models.AutoReplyExclusion{},
+		&models.AutoReplyLog{},
+		&models.Webhook{},
+		&models.WebhookDeadLetter{},
+		&models.CustomEmoji{},
+		&models.ScheduledMessage{},
+		&models.SlackSession{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to auto-migrate database schema: %w", err)
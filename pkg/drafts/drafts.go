@@ -0,0 +1,26 @@
+// Package drafts keeps the unsent text of conversations and runs the follow-ups of a sent
+// message, whichever way it was sent (desktop app, local API or command line).
+package drafts
+
+import (
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"fmt"
+	"log"
+)
+
+// Clear removes the draft of a conversation of a provider instance.
+func Clear(instanceID, conversationID string) error {
+	if db.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	return db.DB.Where("provider_instance_id = ? AND protocol_conv_id = ?", instanceID, conversationID).Delete(&models.Draft{}).Error
+}
+
+// AfterSend must be called once a message was sent successfully in a conversation of a
+// provider instance: the draft of the conversation is cleared.
+func AfterSend(instanceID, conversationID string) {
+	if err := Clear(instanceID, conversationID); err != nil {
+		log.Printf("Drafts: Failed to clear draft for conversation %s of %s: %v", conversationID, instanceID, err)
+	}
+}
@@ -0,0 +1,102 @@
+package media
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/image/webp"
+)
+
+// Type is the kind of media an attachment carries.
+// The values match models.Attachment.Type so they can be stored directly.
+type Type string
+
+const (
+	// TypeImage is a still picture that can be displayed inline.
+	TypeImage Type = "image"
+	// TypeVideo is a video clip.
+	TypeVideo Type = "video"
+	// TypeAudio is an audio file (music, recording, ...).
+	TypeAudio Type = "audio"
+	// TypeVoice is a voice note (Ogg/Opus push-to-talk recording).
+	TypeVoice Type = "voice"
+	// TypeDocument is any other file, sent and displayed as a download.
+	TypeDocument Type = "document"
+	// TypeSticker is a WebP sticker (512x512 or animated).
+	TypeSticker Type = "sticker"
+)
+
+// stickerSize is the canvas size used by WhatsApp and Telegram stickers.
+const stickerSize = 512
+
+// Classify returns the media type for a file given its MIME type and, optionally, its content.
+// data is used to tell voice notes from other audio and stickers from other WebP images;
+// when it is nil the classification is based on the MIME type only.
+func Classify(mimeType string, data []byte) Type {
+	base := BaseMIME(mimeType)
+
+	switch {
+	case base == "image/webp":
+		if isSticker(data) {
+			return TypeSticker
+		}
+		return TypeImage
+	case base == "image/svg+xml":
+		// Vector images are not rendered as pictures by the messaging apps
+		return TypeDocument
+	case strings.HasPrefix(base, "image/"):
+		return TypeImage
+	case strings.HasPrefix(base, "video/"):
+		return TypeVideo
+	case strings.HasPrefix(base, "audio/"):
+		if isVoiceNote(mimeType, data) {
+			return TypeVoice
+		}
+		return TypeAudio
+	default:
+		return TypeDocument
+	}
+}
+
+// DetectFile sniffs the MIME type of a file and classifies it in one call.
+func DetectFile(data []byte, fileName string) (string, Type) {
+	mimeType := DetectMIME(data, fileName)
+	return mimeType, Classify(mimeType, data)
+}
+
+// isVoiceNote reports whether an audio file is an Ogg/Opus recording, the format used for voice notes.
+func isVoiceNote(mimeType string, data []byte) bool {
+	if BaseMIME(mimeType) != "audio/ogg" {
+		return false
+	}
+	if strings.Contains(strings.ToLower(mimeType), "opus") {
+		return true
+	}
+	// The Opus identification header lives in the first Ogg page
+	header := data
+	if len(header) > 128 {
+		header = header[:128]
+	}
+	return bytes.Contains(header, []byte("OpusHead"))
+}
+
+// isSticker reports whether a WebP image looks like a sticker: animated, or exactly 512x512.
+func isSticker(data []byte) bool {
+	if len(data) < 30 {
+		return false
+	}
+
+	// Extended format: flags and canvas size are in the VP8X chunk
+	if bytes.Equal(data[12:16], []byte("VP8X")) {
+		animated := data[20]&0x02 != 0
+		width := 1 + (int(data[24]) | int(data[25])<<8 | int(data[26])<<16)
+		height := 1 + (int(data[27]) | int(data[28])<<8 | int(data[29])<<16)
+		return animated || (width == stickerSize && height == stickerSize)
+	}
+
+	cfg, err := webp.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+	return cfg.Width == stickerSize && cfg.Height == stickerSize
+}
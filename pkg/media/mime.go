@@ -0,0 +1,257 @@
+// Package media provides MIME type detection and media-type classification for attachments.
+// It is the single place where Loom decides what a file is, so that the App bindings and the
+// providers agree on how a file should be displayed and sent.
+package media
+
+import (
+	"bytes"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMIMEType is returned when the content of a file cannot be identified.
+const DefaultMIMEType = "application/octet-stream"
+
+// signature describes a magic byte sequence found at a fixed offset in a file.
+type signature struct {
+	offset   int
+	magic    []byte
+	mimeType string
+}
+
+// signatures lists the magic byte sequences we recognise, checked in order.
+// Container formats (RIFF, ftyp, ZIP, OLE) are refined further in DetectMIME.
+var signatures = []signature{
+	{0, []byte{0xFF, 0xD8, 0xFF}, "image/jpeg"},
+	{0, []byte("\x89PNG\r\n\x1a\n"), "image/png"},
+	{0, []byte("GIF87a"), "image/gif"},
+	{0, []byte("GIF89a"), "image/gif"},
+	{0, []byte{0x49, 0x49, 0x2A, 0x00}, "image/tiff"},
+	{0, []byte{0x4D, 0x4D, 0x00, 0x2A}, "image/tiff"},
+	{0, []byte{0x1A, 0x45, 0xDF, 0xA3}, "video/webm"},
+	{0, []byte("OggS"), "audio/ogg"},
+	{0, []byte("ID3"), "audio/mpeg"},
+	{0, []byte{0xFF, 0xFB}, "audio/mpeg"},
+	{0, []byte{0xFF, 0xF3}, "audio/mpeg"},
+	{0, []byte{0xFF, 0xF2}, "audio/mpeg"},
+	{0, []byte{0xFF, 0xF1}, "audio/aac"},
+	{0, []byte{0xFF, 0xF9}, "audio/aac"},
+	{0, []byte("fLaC"), "audio/flac"},
+	{0, []byte("#!AMR"), "audio/amr"},
+	{0, []byte("%PDF-"), "application/pdf"},
+	{0, []byte("{\\rtf"), "application/rtf"},
+	{0, []byte("Rar!\x1a\x07"), "application/vnd.rar"},
+	{0, []byte{0x37, 0x7A, 0xBC, 0xAF, 0x27, 0x1C}, "application/x-7z-compressed"},
+	{0, []byte{0x1F, 0x8B}, "application/gzip"},
+	{0, []byte("PK\x03\x04"), "application/zip"},
+	{0, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, "application/x-ole-storage"},
+	{0, []byte("BEGIN:VCARD"), "text/vcard"},
+}
+
+// ftypBrands maps ISO base media file format brands (bytes 8-12) to MIME types.
+var ftypBrands = map[string]string{
+	"heic": "image/heic",
+	"heix": "image/heic",
+	"mif1": "image/heif",
+	"msf1": "image/heif",
+	"avif": "image/avif",
+	"qt  ": "video/quicktime",
+	"3gp4": "video/3gpp",
+	"3gp5": "video/3gpp",
+	"3gp6": "video/3gpp",
+	"3g2a": "video/3gpp2",
+	"M4A ": "audio/mp4",
+	"M4B ": "audio/mp4",
+	"M4V ": "video/x-m4v",
+}
+
+// extensionTypes maps lower-case file extensions to MIME types.
+// It is used as a fallback when the content cannot be sniffed, and to refine
+// generic containers such as ZIP (docx, xlsx, ...) or OLE (doc, xls, ...).
+var extensionTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+	".heic": "image/heic",
+	".heif": "image/heif",
+	".avif": "image/avif",
+	".svg":  "image/svg+xml",
+	".ico":  "image/x-icon",
+	".mp4":  "video/mp4",
+	".m4v":  "video/x-m4v",
+	".mov":  "video/quicktime",
+	".3gp":  "video/3gpp",
+	".webm": "video/webm",
+	".mkv":  "video/x-matroska",
+	".avi":  "video/x-msvideo",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/ogg",
+	".wav":  "audio/wav",
+	".flac": "audio/flac",
+	".amr":  "audio/amr",
+	".pdf":  "application/pdf",
+	".doc":  "application/msword",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xls":  "application/vnd.ms-excel",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".odp":  "application/vnd.oasis.opendocument.presentation",
+	".epub": "application/epub+zip",
+	".apk":  "application/vnd.android.package-archive",
+	".rtf":  "application/rtf",
+	".txt":  "text/plain",
+	".csv":  "text/csv",
+	".md":   "text/markdown",
+	".html": "text/html",
+	".htm":  "text/html",
+	".json": "application/json",
+	".xml":  "application/xml",
+	".vcf":  "text/vcard",
+	".zip":  "application/zip",
+	".rar":  "application/vnd.rar",
+	".7z":   "application/x-7z-compressed",
+	".gz":   "application/gzip",
+}
+
+// mimeExtensions maps MIME types to the preferred file extension when writing to the cache.
+// Types with several extensions in extensionTypes must be listed here to keep the choice stable.
+var mimeExtensions = map[string]string{
+	"image/jpeg":         ".jpg",
+	"image/tiff":         ".tif",
+	"image/x-icon":       ".ico",
+	"image/svg+xml":      ".svg",
+	"video/quicktime":    ".mov",
+	"video/x-matroska":   ".mkv",
+	"video/x-msvideo":    ".avi",
+	"audio/mpeg":         ".mp3",
+	"audio/mp4":          ".m4a",
+	"audio/ogg":          ".ogg",
+	"text/plain":         ".txt",
+	"text/html":          ".html",
+	"application/gzip":   ".gz",
+	"application/msword": ".doc",
+}
+
+// DetectMIME returns the MIME type of a file from its content, falling back to its name.
+// Magic bytes win over the extension, except for generic containers (ZIP, OLE) where the
+// extension is needed to tell e.g. an .xlsx from a .docx.
+// fileName may be a bare name or a full path; it may also be empty.
+func DetectMIME(data []byte, fileName string) string {
+	fromExt := MIMEFromExtension(fileName)
+	sniffed := sniff(data)
+
+	switch sniffed {
+	case "":
+		// Nothing recognised from the content
+	case "application/zip":
+		if isZipContainer(fromExt) {
+			return fromExt
+		}
+		return sniffed
+	case "application/x-ole-storage":
+		if fromExt != "" {
+			return fromExt
+		}
+		return DefaultMIMEType
+	default:
+		return sniffed
+	}
+
+	if fromExt != "" {
+		return fromExt
+	}
+
+	if len(data) > 0 {
+		// Let the standard library have a go (mostly useful for text)
+		return BaseMIME(http.DetectContentType(data))
+	}
+
+	return DefaultMIMEType
+}
+
+// MIMEFromExtension returns the MIME type registered for the extension of fileName,
+// or an empty string if the extension is unknown.
+func MIMEFromExtension(fileName string) string {
+	return extensionTypes[strings.ToLower(filepath.Ext(fileName))]
+}
+
+// ExtensionFromMIME returns a file extension (with leading dot) suitable for mimeType,
+// or ".bin" if the type is unknown. Parameters such as "; codecs=opus" are ignored.
+func ExtensionFromMIME(mimeType string) string {
+	base := BaseMIME(mimeType)
+	if ext, ok := mimeExtensions[base]; ok {
+		return ext
+	}
+	for ext, mt := range extensionTypes {
+		if mt == base {
+			return ext
+		}
+	}
+	return ".bin"
+}
+
+// BaseMIME strips parameters from a MIME type and lower-cases it
+// (e.g. "audio/ogg; codecs=opus" -> "audio/ogg").
+func BaseMIME(mimeType string) string {
+	if idx := strings.Index(mimeType, ";"); idx != -1 {
+		mimeType = mimeType[:idx]
+	}
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+// sniff identifies data from its magic bytes, returning an empty string if unknown.
+func sniff(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+
+	// RIFF containers: WebP, WAV, AVI
+	if len(data) >= 12 && bytes.Equal(data[0:4], []byte("RIFF")) {
+		switch string(data[8:12]) {
+		case "WEBP":
+			return "image/webp"
+		case "WAVE":
+			return "audio/wav"
+		case "AVI ":
+			return "video/x-msvideo"
+		}
+	}
+
+	// ISO base media files: MP4, MOV, HEIC, M4A, 3GP
+	if len(data) >= 12 && bytes.Equal(data[4:8], []byte("ftyp")) {
+		if mimeType, ok := ftypBrands[string(data[8:12])]; ok {
+			return mimeType
+		}
+		return "video/mp4"
+	}
+
+	for _, sig := range signatures {
+		end := sig.offset + len(sig.magic)
+		if len(data) >= end && bytes.Equal(data[sig.offset:end], sig.magic) {
+			return sig.mimeType
+		}
+	}
+
+	return ""
+}
+
+// isZipContainer reports whether mimeType is a format stored as a ZIP archive.
+func isZipContainer(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "application/vnd.openxmlformats-officedocument.") ||
+		strings.HasPrefix(mimeType, "application/vnd.oasis.opendocument.") ||
+		mimeType == "application/epub+zip" ||
+		mimeType == "application/vnd.android.package-archive"
+}
@@ -0,0 +1,103 @@
+package media
+
+import "testing"
+
+// webpVP8X builds the header of an extended WebP image with the given canvas size.
+func webpVP8X(width, height int, animated bool) []byte {
+	data := make([]byte, 30)
+	copy(data[0:4], "RIFF")
+	copy(data[8:12], "WEBP")
+	copy(data[12:16], "VP8X")
+	if animated {
+		data[20] = 0x02
+	}
+	w, h := width-1, height-1
+	data[24], data[25], data[26] = byte(w), byte(w>>8), byte(w>>16)
+	data[27], data[28], data[29] = byte(h), byte(h>>8), byte(h>>16)
+	return data
+}
+
+func TestDetectMIME(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		fileName string
+		want     string
+	}{
+		{"jpeg magic", []byte{0xFF, 0xD8, 0xFF, 0xE0}, "", "image/jpeg"},
+		{"png magic beats extension", []byte("\x89PNG\r\n\x1a\n...."), "photo.jpg", "image/png"},
+		{"pdf magic", []byte("%PDF-1.7"), "file.bin", "application/pdf"},
+		{"ogg magic", []byte("OggS\x00\x02"), "voice", "audio/ogg"},
+		{"webp riff", webpVP8X(100, 100, false), "", "image/webp"},
+		{"wav riff", []byte("RIFF\x00\x00\x00\x00WAVEfmt "), "", "audio/wav"},
+		{"mp4 ftyp", []byte("\x00\x00\x00\x18ftypisom"), "", "video/mp4"},
+		{"heic ftyp", []byte("\x00\x00\x00\x18ftypheic"), "", "image/heic"},
+		{"m4a ftyp", []byte("\x00\x00\x00\x18ftypM4A "), "", "audio/mp4"},
+		{"xlsx zip", []byte("PK\x03\x04...."), "Report.XLSX", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+		{"plain zip", []byte("PK\x03\x04...."), "archive.zip", "application/zip"},
+		{"zip with wrong extension", []byte("PK\x03\x04...."), "photo.jpg", "application/zip"},
+		{"xls ole", []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, "sheet.xls", "application/vnd.ms-excel"},
+		{"ole without extension", []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, "", DefaultMIMEType},
+		{"extension fallback", nil, "song.opus", "audio/ogg"},
+		{"extension fallback for unknown content", []byte{0x00, 0x01, 0x02}, "notes.md", "text/markdown"},
+		{"text content", []byte("hello world"), "", "text/plain"},
+		{"nothing known", nil, "file", DefaultMIMEType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectMIME(tt.data, tt.fileName); got != tt.want {
+				t.Errorf("DetectMIME(%q) = %q, want %q", tt.fileName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name     string
+		mimeType string
+		data     []byte
+		want     Type
+	}{
+		{"jpeg", "image/jpeg", nil, TypeImage},
+		{"svg is a document", "image/svg+xml", nil, TypeDocument},
+		{"video", "video/mp4", nil, TypeVideo},
+		{"mp3 is audio", "audio/mpeg", nil, TypeAudio},
+		{"opus codec parameter is voice", "audio/ogg; codecs=opus", nil, TypeVoice},
+		{"ogg with opus header is voice", "audio/ogg", []byte("OggS\x00\x02\x00\x00\x00\x00OpusHead"), TypeVoice},
+		{"ogg vorbis is audio", "audio/ogg", []byte("OggS\x00\x02\x00\x00\x00\x00\x01vorbis"), TypeAudio},
+		{"ogg without content is audio", "audio/ogg", nil, TypeAudio},
+		{"512x512 webp is a sticker", "image/webp", webpVP8X(512, 512, false), TypeSticker},
+		{"animated webp is a sticker", "image/webp", webpVP8X(200, 300, true), TypeSticker},
+		{"other webp is an image", "image/webp", webpVP8X(800, 600, false), TypeImage},
+		{"webp without content is an image", "image/webp", nil, TypeImage},
+		{"xlsx is a document", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", nil, TypeDocument},
+		{"empty is a document", "", nil, TypeDocument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.mimeType, tt.data); got != tt.want {
+				t.Errorf("Classify(%q) = %q, want %q", tt.mimeType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtensionFromMIME(t *testing.T) {
+	tests := []struct {
+		mimeType string
+		want     string
+	}{
+		{"image/jpeg", ".jpg"},
+		{"audio/ogg; codecs=opus", ".ogg"},
+		{"AUDIO/MPEG", ".mp3"},
+		{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", ".xlsx"},
+		{"application/pdf", ".pdf"},
+		{"application/x-unknown", ".bin"},
+	}
+	for _, tt := range tests {
+		if got := ExtensionFromMIME(tt.mimeType); got != tt.want {
+			t.Errorf("ExtensionFromMIME(%q) = %q, want %q", tt.mimeType, got, tt.want)
+		}
+	}
+}
@@ -70,9 +70,15 @@ type Message struct {
 	SenderName       string           `gorm:"-" json:"senderName,omitempty"`      // Human-readable sender name (not persisted yet)
 	SenderAvatarURL  string           `gorm:"-" json:"senderAvatarUrl,omitempty"` // Sender's avatar URL (not persisted yet)
 	Body             string           `json:"body"`
+	RichText         string           `json:"richText,omitempty"`    // JSON richtext.Document with the resolved mentions, channels and links
+	RichContent      string           `json:"richContent,omitempty"` // JSON richtext.Content of integration messages (blocks, attachments); Body is then its plain-text fallback
 	Timestamp        time.Time        `json:"timestamp"`
 	IsFromMe         bool             `json:"isFromMe"`
 	ThreadID         *string          `gorm:"index" json:"threadId,omitempty"`                 // Nullable, for replies
+	ThreadReplyCount int              `json:"threadReplyCount,omitempty"`                      // Number of replies (thread parents only)
+	ThreadLatestAt   *time.Time       `json:"threadLatestAt,omitempty"`                        // Timestamp of the latest reply (thread parents only)
+	ThreadUsers      string           `json:"threadUsers,omitempty"`                           // JSON array of the user IDs who replied (thread parents only)
+	ThreadBroadcast  bool             `json:"threadBroadcast"`                                 // Reply also sent to the conversation
 	QuotedMessageID  *string          `gorm:"index" json:"quotedMessageId,omitempty"`          // ID of the message being replied to
 	QuotedSenderID   *string          `json:"quotedSenderId,omitempty"`                        // Sender ID of the quoted message
 	QuotedSenderName string           `gorm:"-" json:"quotedSenderName,omitempty"`             // Sender name of the quoted message (not persisted)
@@ -92,6 +98,7 @@ type Message struct {
 	CallParticipants string           `json:"callParticipants,omitempty"`                      // JSON array of participant JIDs (from CallLogMessage)
 	CallOutcome      string           `json:"callOutcome,omitempty"`                           // Call outcome: "CONNECTED", "MISSED", "FAILED", etc. (from CallLogMessage)
 	CallIsVideo      bool             `json:"callIsVideo"`                                     // Whether the call was a video call (from CallLogMessage)
+	GroupInvite      string           `json:"groupInvite,omitempty"`                           // JSON GroupInvite of group invitation messages
 	DeletedAt        gorm.DeletedAt   `gorm:"index" json:"-"`
 }
 
@@ -144,6 +151,17 @@ type Attachment struct {
 	Duration  uint32 `json:"duration,omitempty"`  // Duration in seconds (for audio/video)
 }
 
+// GroupInvite is the invitation to join a group carried by a message (stored as JSON in Message.GroupInvite).
+type GroupInvite struct {
+	GroupID    string `json:"groupId"`             // Protocol conversation ID of the group
+	GroupName  string `json:"groupName"`           // Name of the group when the invitation was sent
+	InviterID  string `json:"inviterId"`           // User who sent the invitation
+	Code       string `json:"code"`                // Invitation code
+	Expiration int64  `json:"expiration"`          // Unix time after which the invitation cannot be used (0: never)
+	Caption    string `json:"caption,omitempty"`   // Text sent with the invitation
+	Thumbnail  string `json:"thumbnail,omitempty"` // Data URL of the group picture thumbnail
+}
+
 // ProviderConfiguration stores the configuration of a provider instance.
 type ProviderConfiguration struct {
 	ID           uint       `gorm:"primarykey" json:"id"`
@@ -153,8 +171,10 @@ type ProviderConfiguration struct {
 	ConfigJSON   string     `gorm:"type:text" json:"configJson"`      // JSON-encoded configuration
 	IsActive     bool       `json:"isActive"`                         // Whether this provider is currently active
 	LastSyncAt   *time.Time `json:"lastSyncAt,omitempty"`             // Last time messages were synced
-	CreatedAt    time.Time  `json:"createdAt"`
-	UpdatedAt    time.Time  `json:"updatedAt"`
+	// Last time the custom emoji list was fetched (Slack), nil if never
+	EmojisRefreshedAt *time.Time `json:"emojisRefreshedAt,omitempty"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	UpdatedAt         time.Time  `json:"updatedAt"`
 }
 
 // ContactAlias stores user-defined custom names for contacts.
@@ -165,3 +185,162 @@ type ContactAlias struct {
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
+
+// NotificationRule is a user-defined rule deciding which incoming messages raise a notification.
+// The Type field selects which of the other fields are relevant.
+type NotificationRule struct {
+	ID                 uint      `gorm:"primarykey" json:"id"`
+	Type               string    `gorm:"index;not null" json:"type"`      // "vip", "keyword", "regex", "quiet_hours", "default"
+	Enabled            bool      `json:"enabled"`                         // Disabled rules are kept but ignored
+	ProviderInstanceID string    `gorm:"index" json:"providerInstanceId"` // Restrict the rule to a provider instance ("" = all instances)
+	MetaContactID      uint      `json:"metaContactId,omitempty"`         // "vip": the MetaContact whose messages always notify
+	Pattern            string    `json:"pattern,omitempty"`               // "keyword"/"regex": text to look for in message bodies
+	Scope              string    `json:"scope,omitempty"`                 // "default": "direct" or "group"
+	Level              string    `json:"level,omitempty"`                 // "default": "all", "mentions" or "none"
+	StartTime          string    `json:"startTime,omitempty"`             // "quiet_hours": local start time ("22:00")
+	EndTime            string    `json:"endTime,omitempty"`               // "quiet_hours": local end time ("07:30"), may be on the next day
+	Days               string    `json:"days,omitempty"`                  // "quiet_hours": comma-separated weekdays (0 = Sunday), empty = every day
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+}
+
+// ReadMarker tracks the read position and unread counters of a conversation.
+type ReadMarker struct {
+	ID                 uint      `gorm:"primarykey" json:"id"`
+	ProviderInstanceID string    `gorm:"uniqueIndex:idx_read_marker_conv" json:"providerInstanceId"`
+	ProtocolConvID     string    `gorm:"uniqueIndex:idx_read_marker_conv" json:"protocolConvId"` // Conversation ID on the platform
+	LastReadMessageID  string    `json:"lastReadMessageId,omitempty"`                            // Protocol ID of the last message read
+	LastReadAt         time.Time `json:"lastReadAt"`                                             // Timestamp of the last message read
+	UnreadCount        int       `json:"unreadCount"`                                            // Incoming messages received after LastReadAt
+	MentionCount       int       `json:"mentionCount"`                                           // Unread messages mentioning the user
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+}
+
+// Draft is an unsent message being composed in a conversation.
+type Draft struct {
+	ID                 uint      `gorm:"primarykey" json:"id"`
+	ProviderInstanceID string    `gorm:"uniqueIndex:idx_draft_conv" json:"providerInstanceId"`
+	ProtocolConvID     string    `gorm:"uniqueIndex:idx_draft_conv" json:"protocolConvId"` // Conversation ID on the platform
+	Text               string    `json:"text"`
+	QuotedMessageID    string    `json:"quotedMessageId,omitempty"` // Protocol ID of the message being replied to
+	Attachments        string    `json:"attachments"`               // JSON []string of local file paths to attach
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+}
+
+// MessageTemplate is a reusable snippet with placeholders such as {{contact.firstName}}.
+type MessageTemplate struct {
+	ID                 uint      `gorm:"primarykey" json:"id"`
+	Name               string    `gorm:"uniqueIndex;not null" json:"name"`
+	Shortcut           string    `gorm:"index" json:"shortcut,omitempty"` // Optional text trigger in the composer (e.g. "/thanks")
+	Body               string    `gorm:"type:text" json:"body"`           // Markdown-like text (**bold**, _italic_, ~~strike~~, [text](url))
+	ProviderInstanceID string    `gorm:"index" json:"providerInstanceId"` // Restrict the template to a provider instance ("" = all instances)
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+}
+
+// AutoReplyRule configures the away-mode auto-responder of a provider instance.
+type AutoReplyRule struct {
+	ID                 uint       `gorm:"primarykey" json:"id"`
+	ProviderInstanceID string     `gorm:"uniqueIndex;not null" json:"providerInstanceId"`
+	Enabled            bool       `json:"enabled"`
+	Message            string     `gorm:"type:text" json:"message"` // Out-of-office text sent back
+	CooldownHours      int        `json:"cooldownHours"`            // Reply at most once per contact in this period
+	StartAt            *time.Time `json:"startAt,omitempty"`        // Away period start (nil = immediately)
+	EndAt              *time.Time `json:"endAt,omitempty"`          // Away period end (nil = until disabled)
+	CreatedAt          time.Time  `json:"createdAt"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+}
+
+// AutoReplyExclusion stops the auto-responder in a specific conversation.
+type AutoReplyExclusion struct {
+	ID                 uint      `gorm:"primarykey" json:"id"`
+	ProviderInstanceID string    `gorm:"uniqueIndex:idx_auto_reply_exclusion" json:"providerInstanceId"`
+	ProtocolConvID     string    `gorm:"uniqueIndex:idx_auto_reply_exclusion" json:"protocolConvId"`
+	CreatedAt          time.Time `json:"createdAt"`
+}
+
+// AutoReplyLog records what the auto-responder did with an incoming message.
+type AutoReplyLog struct {
+	ID                 uint      `gorm:"primarykey" json:"id"`
+	ProviderInstanceID string    `gorm:"index" json:"providerInstanceId"`
+	ProtocolConvID     string    `json:"protocolConvId"`
+	SenderID           string    `gorm:"index" json:"senderId"`    // Contact the reply was sent to
+	TriggerMessageID   string    `json:"triggerMessageId"`         // Incoming message that triggered the reply
+	ReplyMessageID     string    `json:"replyMessageId,omitempty"` // Protocol ID of the reply (when sent)
+	Status             string    `gorm:"index" json:"status"`      // "sent" or "failed"
+	Error              string    `json:"error,omitempty"`
+	CreatedAt          time.Time `gorm:"index" json:"createdAt"`
+}
+
+// Webhook is an HTTP endpoint receiving provider events as JSON.
+// Filters are comma-separated lists; an empty filter matches everything.
+type Webhook struct {
+	ID                 uint      `gorm:"primarykey" json:"id"`
+	Name               string    `json:"name"`
+	URL                string    `gorm:"not null" json:"url"`
+	Secret             string    `json:"secret,omitempty"` // HMAC-SHA256 key used to sign the payloads ("" = unsigned)
+	Enabled            bool      `json:"enabled"`
+	InstanceFilter     string    `json:"instanceFilter,omitempty"`     // Provider instance IDs (e.g. "whatsapp-1,slack-1")
+	ConversationFilter string    `json:"conversationFilter,omitempty"` // Protocol conversation IDs
+	EventTypes         string    `json:"eventTypes,omitempty"`         // Event types (e.g. "message,reaction"), "" = messages, reactions, receipts and group changes
+	MaxAttempts        int       `json:"maxAttempts"`                  // Deliveries tried before dead-lettering (0 = default)
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+}
+
+// WebhookDeadLetter is a webhook delivery that failed after all retries.
+type WebhookDeadLetter struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	WebhookID      uint      `gorm:"index" json:"webhookId"`
+	DeliveryID     string    `gorm:"index" json:"deliveryId"`
+	EventType      string    `json:"eventType"`
+	Payload        string    `gorm:"type:text" json:"payload"` // JSON body that could not be delivered
+	Attempts       int       `json:"attempts"`
+	LastStatusCode int       `json:"lastStatusCode,omitempty"` // HTTP status of the last attempt (0 = network error)
+	LastError      string    `json:"lastError"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// CustomEmoji is a custom emoji of a workspace, kept to render reactions and statuses offline.
+type CustomEmoji struct {
+	ID                 uint      `gorm:"primarykey" json:"id"`
+	ProviderInstanceID string    `gorm:"uniqueIndex:idx_custom_emoji_name" json:"providerInstanceId"`
+	Name               string    `gorm:"uniqueIndex:idx_custom_emoji_name" json:"name"` // Emoji name without colons
+	URL                string    `json:"url"`                                           // Image URL on the provider ("" for an alias of a standard emoji)
+	AliasOf            string    `json:"aliasOf,omitempty"`                             // Name of the aliased emoji ("alias:foo" on Slack)
+	LocalPath          string    `json:"localPath,omitempty"`                           // Cached image file ("" until downloaded)
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+}
+
+// ScheduledMessage is a message of the local scheduler, for providers that cannot schedule
+// messages on their service (see core.MessageScheduler). It is sent by Loom at PostAt, or
+// as soon as Loom runs again if it was closed at that time.
+type ScheduledMessage struct {
+	ID                 uint       `gorm:"primarykey" json:"id"`
+	ProviderInstanceID string     `gorm:"index" json:"providerInstanceId"`
+	ProtocolConvID     string     `json:"protocolConvId"` // Conversation ID on the platform
+	Text               string     `json:"text"`
+	ThreadID           *string    `json:"threadId,omitempty"` // Thread the message is posted in (nil: the conversation)
+	PostAt             time.Time  `gorm:"index" json:"postAt"`
+	Status             string     `gorm:"index" json:"status"`     // "pending", "sent" or "failed"
+	Attempts           int        `json:"attempts"`                // Failed sending attempts
+	Error              string     `json:"error,omitempty"`         // Why sending failed
+	SentMessageID      string     `json:"sentMessageId,omitempty"` // Protocol ID of the sent message
+	SentAt             *time.Time `json:"sentAt,omitempty"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+}
+
+// SlackSession holds the d cookie of a Slack client session. The instances of the workspaces
+// attached from one session refer to it (the "session_id" of their configuration) instead of
+// each storing a copy of the cookie, so that it is kept once and rotated in one place.
+type SlackSession struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	CookieHash string    `gorm:"uniqueIndex" json:"-"` // SHA-256 of the cookie, finds the session of a cookie again
+	DCookie    string    `json:"-"`                    // Never sent to the frontend
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
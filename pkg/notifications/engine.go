@@ -0,0 +1,329 @@
+package notifications
+
+import (
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"Loom/pkg/richtext"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCoalesceWindow is the minimum delay between two notifications for the same conversation.
+// Messages arriving within the window are merged into a single notification.
+const DefaultCoalesceWindow = 10 * time.Second
+
+// maxBodyLength is the maximum number of characters of the message body kept in a notification.
+const maxBodyLength = 200
+
+// Priority indicates how prominently a notification should be shown.
+type Priority string
+
+const (
+	// PriorityHigh is used for VIPs, mentions and watched keywords.
+	PriorityHigh Priority = "high"
+	// PriorityNormal is used for regular direct messages.
+	PriorityNormal Priority = "normal"
+	// PriorityLow is used for regular group messages.
+	PriorityLow Priority = "low"
+)
+
+// Reasons explaining why a notification was raised.
+const (
+	ReasonVIP           = "vip"
+	ReasonMention       = "mention"
+	ReasonKeyword       = "keyword"
+	ReasonDirectMessage = "direct_message"
+	ReasonGroupMessage  = "group_message"
+)
+
+// priorityRank orders priorities so that coalesced notifications keep the highest one.
+var priorityRank = map[Priority]int{
+	PriorityLow:    0,
+	PriorityNormal: 1,
+	PriorityHigh:   2,
+}
+
+// Notification is emitted to the frontend when a message deserves the user's attention.
+type Notification struct {
+	ProviderInstanceID string    `json:"providerInstanceId"`
+	ConversationID     string    `json:"conversationId"` // Protocol conversation ID
+	MessageID          string    `json:"messageId"`      // Protocol ID of the latest message
+	SenderID           string    `json:"senderId"`
+	Title              string    `json:"title"` // Sender name, with the group name for groups
+	Body               string    `json:"body"`  // Truncated body of the latest message
+	Priority           Priority  `json:"priority"`
+	Reason             string    `json:"reason"`           // One of the Reason* constants
+	Detail             string    `json:"detail,omitempty"` // Extra information (e.g. the matched keyword)
+	Count              int       `json:"count"`            // Number of messages merged into this notification
+	Timestamp          time.Time `json:"timestamp"`
+}
+
+// Engine evaluates incoming messages against the notification rules and emits
+// rate-limited, per-conversation coalesced notifications.
+type Engine struct {
+	mu       sync.Mutex
+	rules    *ruleSet
+	emit     func(Notification)
+	window   time.Duration
+	lastSent map[string]time.Time     // Key: instanceID + "|" + conversationID
+	pending  map[string]*Notification // Notifications waiting for the window to expire
+}
+
+// NewEngine creates a notification engine that calls emit for each notification.
+// Rules are loaded from the database immediately.
+func NewEngine(emit func(Notification)) *Engine {
+	e := &Engine{
+		rules:    &ruleSet{vips: make(map[uint]bool), defaults: make(map[string]string)},
+		emit:     emit,
+		window:   DefaultCoalesceWindow,
+		lastSent: make(map[string]time.Time),
+		pending:  make(map[string]*Notification),
+	}
+	if err := e.Reload(); err != nil {
+		fmt.Printf("Notifications: WARNING - failed to load rules: %v\n", err)
+	}
+	return e
+}
+
+// Reload re-reads the rules from the database. Call it after rules are changed.
+func (e *Engine) Reload() error {
+	rs, err := loadRuleSet()
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.rules = rs
+	e.mu.Unlock()
+	return nil
+}
+
+// HandleMessage evaluates a message and emits a notification if the rules allow it.
+// selfUserID is the protocol user ID of the logged-in account, used to detect mentions.
+func (e *Engine) HandleMessage(instanceID, selfUserID string, msg models.Message) {
+	n := e.Evaluate(instanceID, selfUserID, msg, time.Now())
+	if n == nil {
+		return
+	}
+
+	key := instanceID + "|" + n.ConversationID
+
+	e.mu.Lock()
+	if existing, ok := e.pending[key]; ok {
+		// Already waiting for the window to expire: merge into the pending notification
+		mergeNotification(existing, n)
+		e.mu.Unlock()
+		return
+	}
+
+	if last, ok := e.lastSent[key]; ok {
+		if wait := e.window - time.Since(last); wait > 0 {
+			e.pending[key] = n
+			time.AfterFunc(wait, func() { e.flush(key) })
+			e.mu.Unlock()
+			return
+		}
+	}
+
+	e.lastSent[key] = time.Now()
+	emit := e.emit
+	e.mu.Unlock()
+
+	if emit != nil {
+		emit(*n)
+	}
+}
+
+// flush emits the pending notification for a conversation once its window has expired.
+func (e *Engine) flush(key string) {
+	e.mu.Lock()
+	n, ok := e.pending[key]
+	if ok {
+		delete(e.pending, key)
+		e.lastSent[key] = time.Now()
+	}
+	emit := e.emit
+	e.mu.Unlock()
+
+	if ok && emit != nil {
+		emit(*n)
+	}
+}
+
+// Evaluate applies the rules to a message and returns the resulting notification,
+// or nil if the message should not notify. It does not apply rate limiting.
+func (e *Engine) Evaluate(instanceID, selfUserID string, msg models.Message, now time.Time) *Notification {
+	if msg.IsFromMe || msg.IsStatusMessage || msg.IsDeleted || msg.IsEdited {
+		return nil
+	}
+
+	e.mu.Lock()
+	rules := e.rules
+	e.mu.Unlock()
+
+	// Look up the conversation state (mute, group)
+	isGroup := LooksLikeGroup(msg.ProtocolConvID)
+	isMuted := false
+	groupName := ""
+	if db.DB != nil {
+		var conv models.Conversation
+		if err := db.DB.Where("protocol_conv_id = ?", msg.ProtocolConvID).First(&conv).Error; err == nil {
+			isGroup = conv.IsGroup
+			isMuted = conv.IsMuted
+			groupName = conv.GroupName
+		}
+	}
+
+	mentioned := MessageMentionsUser(msg, selfUserID, isGroup)
+
+	// Muted conversations only break through for mentions of me
+	if isMuted && !mentioned {
+		return nil
+	}
+
+	var priority Priority
+	var reason, detail string
+	switch {
+	case isVIP(rules, instanceID, msg.SenderID):
+		priority, reason = PriorityHigh, ReasonVIP
+	case mentioned:
+		priority, reason = PriorityHigh, ReasonMention
+	default:
+		if keyword, ok := rules.matchKeyword(instanceID, msg.Body); ok {
+			priority, reason, detail = PriorityHigh, ReasonKeyword, keyword
+			break
+		}
+		if rules.defaultLevel(instanceID, isGroup) != LevelAll {
+			return nil
+		}
+		if isGroup {
+			priority, reason = PriorityLow, ReasonGroupMessage
+		} else {
+			priority, reason = PriorityNormal, ReasonDirectMessage
+		}
+	}
+
+	// Quiet hours silence everything but VIPs
+	if reason != ReasonVIP && rules.inQuietHours(instanceID, now) {
+		return nil
+	}
+
+	title := msg.SenderName
+	if title == "" {
+		title = msg.SenderID
+	}
+	if isGroup && groupName != "" {
+		title = fmt.Sprintf("%s in %s", title, groupName)
+	}
+
+	return &Notification{
+		ProviderInstanceID: instanceID,
+		ConversationID:     msg.ProtocolConvID,
+		MessageID:          msg.ProtocolMsgID,
+		SenderID:           msg.SenderID,
+		Title:              title,
+		Body:               previewBody(msg),
+		Priority:           priority,
+		Reason:             reason,
+		Detail:             detail,
+		Count:              1,
+		Timestamp:          msg.Timestamp,
+	}
+}
+
+// mergeNotification folds a newer notification into a pending one for the same conversation.
+func mergeNotification(existing, newer *Notification) {
+	existing.Count += newer.Count
+	existing.MessageID = newer.MessageID
+	existing.SenderID = newer.SenderID
+	existing.Title = newer.Title
+	existing.Body = newer.Body
+	existing.Timestamp = newer.Timestamp
+	if priorityRank[newer.Priority] > priorityRank[existing.Priority] {
+		existing.Priority = newer.Priority
+		existing.Reason = newer.Reason
+		existing.Detail = newer.Detail
+	}
+}
+
+// isVIP reports whether the sender belongs to a MetaContact marked as VIP.
+func isVIP(rules *ruleSet, instanceID, senderID string) bool {
+	if len(rules.vips) == 0 || senderID == "" || db.DB == nil {
+		return false
+	}
+
+	var accounts []models.LinkedAccount
+	if err := db.DB.Where("user_id = ?", senderID).Find(&accounts).Error; err != nil {
+		return false
+	}
+	for _, account := range accounts {
+		if account.ProviderInstanceID != "" && account.ProviderInstanceID != instanceID {
+			continue
+		}
+		if rules.vips[account.MetaContactID] {
+			return true
+		}
+	}
+	return false
+}
+
+// MessageMentionsUser reports whether a message mentions the user. The resolved mentions of its
+// rich text are checked first, then the body (see MentionsUser).
+func MessageMentionsUser(msg models.Message, selfUserID string, isGroup bool) bool {
+	if msg.RichText != "" {
+		if doc, err := richtext.Decode(msg.RichText); err == nil {
+			if isGroup && doc.MentionsEveryone() {
+				return true
+			}
+			if selfUserID != "" && doc.MentionsUser(selfUserID) {
+				return true
+			}
+		}
+	}
+	return MentionsUser(msg.Body, selfUserID, isGroup)
+}
+
+// MentionsUser reports whether body mentions the user, either directly
+// ("@33612345678" on WhatsApp, "<@U024BE7LH>" on Slack) or through a group-wide mention.
+func MentionsUser(body, selfUserID string, isGroup bool) bool {
+	if isGroup && (strings.Contains(body, "<!here") || strings.Contains(body, "<!channel") || strings.Contains(body, "<!everyone")) {
+		return true
+	}
+	if selfUserID == "" {
+		return false
+	}
+
+	// Keep only the user part of IDs such as "33612345678:12@s.whatsapp.net"
+	user := selfUserID
+	if idx := strings.Index(user, "@"); idx != -1 {
+		user = user[:idx]
+	}
+	if idx := strings.Index(user, ":"); idx != -1 {
+		user = user[:idx]
+	}
+	return user != "" && strings.Contains(body, "@"+user)
+}
+
+// LooksLikeGroup guesses whether a conversation is a group from its protocol ID,
+// for conversations that are not stored in the database yet.
+func LooksLikeGroup(conversationID string) bool {
+	if strings.HasSuffix(conversationID, "@g.us") {
+		return true
+	}
+	// Slack channels (C...) and private groups (G...)
+	return len(conversationID) > 1 && (conversationID[0] == 'C' || conversationID[0] == 'G') && strings.ToUpper(conversationID) == conversationID
+}
+
+// previewBody returns a short text describing the message.
+func previewBody(msg models.Message) string {
+	body := strings.TrimSpace(msg.Body)
+	if body == "" && msg.Attachments != "" && msg.Attachments != "[]" {
+		return "📎 Attachment"
+	}
+	runes := []rune(body)
+	if len(runes) > maxBodyLength {
+		return string(runes[:maxBodyLength]) + "…"
+	}
+	return body
+}
@@ -0,0 +1,231 @@
+// Package notifications decides which incoming messages deserve the user's attention.
+// Each MessageEvent is evaluated against user-defined rules (VIPs, keywords, quiet hours,
+// group/DM defaults) and turned into a Notification with a priority and a reason.
+package notifications
+
+import (
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule types stored in models.NotificationRule.Type.
+const (
+	RuleTypeVIP        = "vip"
+	RuleTypeKeyword    = "keyword"
+	RuleTypeRegex      = "regex"
+	RuleTypeQuietHours = "quiet_hours"
+	RuleTypeDefault    = "default"
+)
+
+// Scopes and levels used by RuleTypeDefault rules.
+const (
+	ScopeDirect = "direct"
+	ScopeGroup  = "group"
+
+	LevelAll      = "all"
+	LevelMentions = "mentions"
+	LevelNone     = "none"
+)
+
+// quietHours is a parsed RuleTypeQuietHours rule.
+type quietHours struct {
+	instanceID string
+	start      int          // Minutes since midnight
+	end        int          // Minutes since midnight
+	days       map[int]bool // Weekdays the window starts on (empty = every day)
+}
+
+// keywordRule is a parsed RuleTypeKeyword or RuleTypeRegex rule.
+type keywordRule struct {
+	instanceID string
+	pattern    string
+	re         *regexp.Regexp // nil for plain keywords
+}
+
+// ruleSet is the compiled form of all enabled rules.
+type ruleSet struct {
+	vips       map[uint]bool
+	keywords   []keywordRule
+	quietHours []quietHours
+	defaults   map[string]string // Key: instanceID + "|" + scope, value: level
+}
+
+// ValidateRule checks that a rule is well-formed before it is saved.
+func ValidateRule(rule models.NotificationRule) error {
+	switch rule.Type {
+	case RuleTypeVIP:
+		if rule.MetaContactID == 0 {
+			return fmt.Errorf("vip rule requires a metaContactId")
+		}
+	case RuleTypeKeyword:
+		if strings.TrimSpace(rule.Pattern) == "" {
+			return fmt.Errorf("keyword rule requires a pattern")
+		}
+	case RuleTypeRegex:
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("invalid regex %q: %w", rule.Pattern, err)
+		}
+	case RuleTypeQuietHours:
+		if _, err := parseClock(rule.StartTime); err != nil {
+			return fmt.Errorf("invalid start time: %w", err)
+		}
+		if _, err := parseClock(rule.EndTime); err != nil {
+			return fmt.Errorf("invalid end time: %w", err)
+		}
+		if _, err := parseDays(rule.Days); err != nil {
+			return fmt.Errorf("invalid days: %w", err)
+		}
+	case RuleTypeDefault:
+		if rule.Scope != ScopeDirect && rule.Scope != ScopeGroup {
+			return fmt.Errorf("default rule scope must be %q or %q", ScopeDirect, ScopeGroup)
+		}
+		if rule.Level != LevelAll && rule.Level != LevelMentions && rule.Level != LevelNone {
+			return fmt.Errorf("default rule level must be %q, %q or %q", LevelAll, LevelMentions, LevelNone)
+		}
+	default:
+		return fmt.Errorf("unknown rule type: %s", rule.Type)
+	}
+	return nil
+}
+
+// loadRuleSet reads the enabled rules from the database and compiles them.
+// Invalid rules are skipped so that one bad row does not disable notifications.
+func loadRuleSet() (*ruleSet, error) {
+	rs := &ruleSet{
+		vips:     make(map[uint]bool),
+		defaults: make(map[string]string),
+	}
+	if db.DB == nil {
+		return rs, nil
+	}
+
+	var rules []models.NotificationRule
+	if err := db.DB.Where("enabled = ?", true).Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to load notification rules: %w", err)
+	}
+
+	for _, rule := range rules {
+		if err := ValidateRule(rule); err != nil {
+			fmt.Printf("Notifications: skipping invalid rule %d: %v\n", rule.ID, err)
+			continue
+		}
+		switch rule.Type {
+		case RuleTypeVIP:
+			rs.vips[rule.MetaContactID] = true
+		case RuleTypeKeyword:
+			rs.keywords = append(rs.keywords, keywordRule{instanceID: rule.ProviderInstanceID, pattern: rule.Pattern})
+		case RuleTypeRegex:
+			re := regexp.MustCompile(rule.Pattern)
+			rs.keywords = append(rs.keywords, keywordRule{instanceID: rule.ProviderInstanceID, pattern: rule.Pattern, re: re})
+		case RuleTypeQuietHours:
+			start, _ := parseClock(rule.StartTime)
+			end, _ := parseClock(rule.EndTime)
+			days, _ := parseDays(rule.Days)
+			rs.quietHours = append(rs.quietHours, quietHours{instanceID: rule.ProviderInstanceID, start: start, end: end, days: days})
+		case RuleTypeDefault:
+			rs.defaults[rule.ProviderInstanceID+"|"+rule.Scope] = rule.Level
+		}
+	}
+
+	return rs, nil
+}
+
+// defaultLevel returns the notification level for direct or group messages on an instance.
+// Instance-specific rules win over global ones; without any rule, DMs notify and groups only on mentions.
+func (rs *ruleSet) defaultLevel(instanceID string, isGroup bool) string {
+	scope := ScopeDirect
+	fallback := LevelAll
+	if isGroup {
+		scope = ScopeGroup
+		fallback = LevelMentions
+	}
+	if level, ok := rs.defaults[instanceID+"|"+scope]; ok {
+		return level
+	}
+	if level, ok := rs.defaults["|"+scope]; ok {
+		return level
+	}
+	return fallback
+}
+
+// matchKeyword returns the first keyword or regex rule matching body, if any.
+func (rs *ruleSet) matchKeyword(instanceID, body string) (string, bool) {
+	lowerBody := strings.ToLower(body)
+	for _, kw := range rs.keywords {
+		if kw.instanceID != "" && kw.instanceID != instanceID {
+			continue
+		}
+		if kw.re != nil {
+			if kw.re.MatchString(body) {
+				return kw.pattern, true
+			}
+		} else if strings.Contains(lowerBody, strings.ToLower(kw.pattern)) {
+			return kw.pattern, true
+		}
+	}
+	return "", false
+}
+
+// inQuietHours reports whether now falls in a quiet-hours window for the instance.
+func (rs *ruleSet) inQuietHours(instanceID string, now time.Time) bool {
+	minutes := now.Hour()*60 + now.Minute()
+	today := int(now.Weekday())
+	yesterday := (today + 6) % 7
+
+	for _, qh := range rs.quietHours {
+		if qh.instanceID != "" && qh.instanceID != instanceID {
+			continue
+		}
+		if qh.start <= qh.end {
+			// Same-day window (e.g. 12:00-14:00)
+			if minutes >= qh.start && minutes < qh.end && qh.appliesOn(today) {
+				return true
+			}
+			continue
+		}
+		// Overnight window (e.g. 22:00-07:00): the evening part belongs to today,
+		// the morning part to the window that started yesterday
+		if minutes >= qh.start && qh.appliesOn(today) {
+			return true
+		}
+		if minutes < qh.end && qh.appliesOn(yesterday) {
+			return true
+		}
+	}
+	return false
+}
+
+// appliesOn reports whether the window is active on the given weekday.
+func (qh quietHours) appliesOn(weekday int) bool {
+	return len(qh.days) == 0 || qh.days[weekday]
+}
+
+// parseClock parses "HH:MM" into minutes since midnight.
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseDays parses a comma-separated list of weekdays (0 = Sunday).
+func parseDays(value string) (map[int]bool, error) {
+	days := make(map[int]bool)
+	if strings.TrimSpace(value) == "" {
+		return days, nil
+	}
+	for _, part := range strings.Split(value, ",") {
+		day, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || day < 0 || day > 6 {
+			return nil, fmt.Errorf("invalid weekday %q", part)
+		}
+		days[day] = true
+	}
+	return days, nil
+}
@@ -4,6 +4,7 @@ package providers
 import (
 	"Loom/pkg/core"
 	"Loom/pkg/logging"
+	"Loom/pkg/media"
 	"Loom/pkg/models"
 	cryptoRand "crypto/rand"
 	"fmt"
@@ -198,7 +199,7 @@ func (m *MockProvider) SendMessage(conversationID string, text string, file *cor
 	}
 
 	if file != nil {
-		newMessage.Attachments = fmt.Sprintf(`[{"fileName":"%s","mimeType":"%s","fileSize":%d}]`, file.FileName, file.MimeType, file.FileSize)
+		newMessage.Attachments = fmt.Sprintf(`[{"type":"%s","fileName":"%s","mimeType":"%s","fileSize":%d}]`, media.Classify(file.MimeType, file.Data), file.FileName, file.MimeType, file.FileSize)
 	}
 
 	if _, ok := m.messages[conversationID]; ok {
@@ -309,7 +310,7 @@ func (m *MockProvider) SendFile(conversationID string, file *core.Attachment, th
 		Timestamp:      time.Now(),
 		IsFromMe:       true,
 		ThreadID:       threadID,
-		Attachments:    fmt.Sprintf(`[{"fileName":"%s","mimeType":"%s","fileSize":%d}]`, file.FileName, file.MimeType, file.FileSize),
+		Attachments:    fmt.Sprintf(`[{"type":"%s","fileName":"%s","mimeType":"%s","fileSize":%d}]`, media.Classify(file.MimeType, file.Data), file.FileName, file.MimeType, file.FileSize),
 	}
 
 	if _, ok := m.messages[conversationID]; ok {
@@ -898,8 +899,8 @@ func (m *MockProvider) JoinGroupByInviteLink(inviteLink string) (*models.Convers
 }
 
 // JoinGroupByInviteMessage joins a group using an invite message.
-func (m *MockProvider) JoinGroupByInviteMessage(inviteMessageID string) (*models.Conversation, error) {
-	m.log("MockProvider: Joining group via invite message: %s\n", inviteMessageID)
+func (m *MockProvider) JoinGroupByInviteMessage(conversationID string, inviteMessageID string) (*models.Conversation, error) {
+	m.log("MockProvider: Joining group via invite message: %s in %s\n", inviteMessageID, conversationID)
 
 	// Similar to JoinGroupByInviteLink
 	return m.JoinGroupByInviteLink(fmt.Sprintf("invite-from-msg-%s", inviteMessageID))
@@ -0,0 +1,281 @@
+package slack
+
+import (
+	"Loom/pkg/richtext"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// attachmentColors are the CSS colors of the named legacy attachment colors.
+var attachmentColors = map[string]string{
+	"good":    "#2eb886",
+	"warning": "#daa038",
+	"danger":  "#a30200",
+}
+
+// messageContent converts the Block Kit blocks and legacy attachments of a message.
+// Slack shows the layout blocks instead of the text of the message (its notification
+// fallback), while legacy attachments are shown below the text, so the text becomes the
+// first section of attachment-only content. Messages with only rich_text blocks (written in
+// a Slack client) and link previews have no content.
+func (p *SlackProvider) messageContent(msg slack.Msg, text richtext.Document) richtext.Content {
+	var content richtext.Content
+	if blocks, layout := p.convertBlocks(msg.Blocks); layout {
+		content.Blocks = blocks
+	}
+
+	for _, attachment := range msg.Attachments {
+		// Link previews are unfurls, not content
+		if attachment.FromURL != "" || attachment.OriginalURL != "" {
+			continue
+		}
+		content.Attachments = append(content.Attachments, p.convertAttachment(attachment))
+	}
+
+	if len(content.Blocks) == 0 && len(content.Attachments) > 0 && !text.IsEmpty() {
+		content.Blocks = []richtext.Block{{Type: richtext.BlockSection, Text: text}}
+	}
+	return content
+}
+
+// convertBlocks converts Block Kit blocks. It reports whether they contain layout blocks,
+// i.e. anything other than the rich_text blocks of a message written in a Slack client.
+func (p *SlackProvider) convertBlocks(blocks slack.Blocks) ([]richtext.Block, bool) {
+	var converted []richtext.Block
+	layout := false
+	for _, block := range blocks.BlockSet {
+		switch b := block.(type) {
+		case *slack.RichTextBlock:
+			var doc richtext.Document
+			for i, element := range b.Elements {
+				if i > 0 {
+					endLine(&doc)
+				}
+				appendRichTextElement(&doc, element)
+			}
+			p.resolveEntities(&doc)
+			converted = append(converted, richtext.Block{Type: richtext.BlockSection, Text: doc})
+			continue
+		case *slack.HeaderBlock:
+			converted = append(converted, richtext.Block{Type: richtext.BlockHeader, Text: p.textObject(b.Text)})
+		case *slack.SectionBlock:
+			section := richtext.Block{Type: richtext.BlockSection, Text: p.textObject(b.Text)}
+			for _, field := range b.Fields {
+				section.Fields = append(section.Fields, richtext.Field{Value: p.textObject(field), Short: true})
+			}
+			if b.Accessory != nil {
+				if image := b.Accessory.ImageElement; image != nil {
+					section.Images = append(section.Images, richtext.Image{URL: blockImageURL(image.ImageURL, image.SlackFile), AltText: image.AltText})
+				} else if action, ok := blockAction(accessoryElement(b.Accessory)); ok {
+					section.Actions = append(section.Actions, action)
+				}
+			}
+			converted = append(converted, section)
+		case *slack.ContextBlock:
+			context := richtext.Block{Type: richtext.BlockContext}
+			for _, element := range b.ContextElements.Elements {
+				switch e := element.(type) {
+				case *slack.TextBlockObject:
+					if !context.Text.IsEmpty() {
+						context.Text.AppendText(" ", richtext.Style{})
+					}
+					context.Text.Segments = append(context.Text.Segments, p.textObject(e).Segments...)
+				case *slack.ImageBlockElement:
+					context.Images = append(context.Images, richtext.Image{URL: blockImageURL(e.ImageURL, e.SlackFile), AltText: e.AltText})
+				}
+			}
+			converted = append(converted, context)
+		case *slack.DividerBlock:
+			converted = append(converted, richtext.Block{Type: richtext.BlockDivider})
+		case *slack.ImageBlock:
+			converted = append(converted, richtext.Block{
+				Type:   richtext.BlockImage,
+				Text:   p.textObject(b.Title),
+				Images: []richtext.Image{{URL: blockImageURL(b.ImageURL, b.SlackFile), AltText: b.AltText}},
+			})
+		case *slack.ActionBlock:
+			actions := richtext.Block{Type: richtext.BlockActions}
+			if b.Elements != nil {
+				for _, element := range b.Elements.ElementSet {
+					if action, ok := blockAction(element); ok {
+						actions.Actions = append(actions.Actions, action)
+					}
+				}
+			}
+			converted = append(converted, actions)
+		case *slack.MarkdownBlock:
+			var doc richtext.Document
+			doc.AppendText(b.Text, richtext.Style{})
+			converted = append(converted, richtext.Block{Type: richtext.BlockSection, Text: doc})
+		default:
+			// Input, file, call and video blocks have no message content to show
+			continue
+		}
+		layout = true
+	}
+	return converted, layout
+}
+
+// convertAttachment converts a legacy attachment. Its texts are mrkdwn when listed in
+// mrkdwn_in; links and mentions are formatted in any case.
+func (p *SlackProvider) convertAttachment(attachment slack.Attachment) richtext.Attachment {
+	formatted := func(field string) bool {
+		return slices.Contains(attachment.MarkdownIn, field)
+	}
+
+	converted := richtext.Attachment{
+		Color:      attachmentColor(attachment.Color),
+		Pretext:    p.attachmentText(attachment.Pretext, formatted("pretext")),
+		AuthorName: attachment.AuthorName,
+		AuthorLink: attachment.AuthorLink,
+		AuthorIcon: attachment.AuthorIcon,
+		Title:      mrkdwnUnescaper.Replace(attachment.Title),
+		TitleLink:  attachment.TitleLink,
+		Text:       p.attachmentText(attachment.Text, formatted("text")),
+		ImageURL:   attachment.ImageURL,
+		ThumbURL:   attachment.ThumbURL,
+		Footer:     mrkdwnUnescaper.Replace(attachment.Footer),
+		FooterIcon: attachment.FooterIcon,
+	}
+	if converted.Text.IsEmpty() && attachment.Title == "" && len(attachment.Fields) == 0 && len(attachment.Blocks.BlockSet) == 0 {
+		// Attachments without content only have their notification fallback
+		converted.Text = p.attachmentText(attachment.Fallback, false)
+	}
+	if ts, err := strconv.ParseFloat(attachment.Ts.String(), 64); err == nil {
+		converted.Timestamp = int64(ts)
+	}
+
+	for _, field := range attachment.Fields {
+		converted.Fields = append(converted.Fields, richtext.Field{
+			Title: field.Title,
+			Value: p.attachmentText(field.Value, formatted("fields")),
+			Short: field.Short,
+		})
+	}
+	converted.Blocks, _ = p.convertBlocks(attachment.Blocks)
+	for _, action := range attachment.Actions {
+		actionType := richtext.ActionButton
+		if action.Type == slack.ActionType("select") {
+			actionType = richtext.ActionMenu
+		}
+		converted.Actions = append(converted.Actions, richtext.Action{
+			Type:  actionType,
+			Text:  action.Text,
+			URL:   action.URL,
+			Value: action.Value,
+			Style: actionStyle(action.Style),
+		})
+	}
+	return converted
+}
+
+// attachmentText parses a text of a legacy attachment, with inline styles when formatted.
+func (p *SlackProvider) attachmentText(text string, formatted bool) richtext.Document {
+	var doc richtext.Document
+	if formatted {
+		doc = parseMrkdwn(text)
+	} else {
+		parseMrkdwnInline(&doc, text, richtext.Style{}, false)
+	}
+	p.resolveEntities(&doc)
+	return doc
+}
+
+// textObject converts a Block Kit text object: mrkdwn is parsed, plain_text kept as is.
+func (p *SlackProvider) textObject(text *slack.TextBlockObject) richtext.Document {
+	var doc richtext.Document
+	if text == nil {
+		return doc
+	}
+	if text.Type != slack.MarkdownType {
+		doc.AppendText(text.Text, richtext.Style{})
+		return doc
+	}
+	doc = parseMrkdwn(text.Text)
+	p.resolveEntities(&doc)
+	return doc
+}
+
+// accessoryElement returns the element of a section accessory other than an image.
+func accessoryElement(accessory *slack.Accessory) slack.BlockElement {
+	switch {
+	case accessory.ButtonElement != nil:
+		return accessory.ButtonElement
+	case accessory.OverflowElement != nil:
+		return accessory.OverflowElement
+	case accessory.DatePickerElement != nil:
+		return accessory.DatePickerElement
+	case accessory.SelectElement != nil:
+		return accessory.SelectElement
+	case accessory.MultiSelectElement != nil:
+		return accessory.MultiSelectElement
+	}
+	return nil
+}
+
+// blockAction converts the interactive elements shown in messages: buttons and menus.
+func blockAction(element slack.BlockElement) (richtext.Action, bool) {
+	switch e := element.(type) {
+	case *slack.ButtonBlockElement:
+		label := ""
+		if e.Text != nil {
+			label = e.Text.Text
+		}
+		return richtext.Action{Type: richtext.ActionButton, Text: label, URL: e.URL, Value: e.Value, Style: actionStyle(string(e.Style))}, true
+	case *slack.SelectBlockElement:
+		return richtext.Action{Type: richtext.ActionMenu, Text: placeholderText(e.Placeholder)}, true
+	case *slack.MultiSelectBlockElement:
+		return richtext.Action{Type: richtext.ActionMenu, Text: placeholderText(e.Placeholder)}, true
+	case *slack.DatePickerBlockElement:
+		text := placeholderText(e.Placeholder)
+		if e.InitialDate != "" {
+			text = e.InitialDate
+		}
+		return richtext.Action{Type: richtext.ActionMenu, Text: text}, true
+	case *slack.OverflowBlockElement:
+		return richtext.Action{Type: richtext.ActionMenu, Text: "…"}, true
+	}
+	return richtext.Action{}, false
+}
+
+// placeholderText returns the text of an optional placeholder.
+func placeholderText(placeholder *slack.TextBlockObject) string {
+	if placeholder == nil {
+		return ""
+	}
+	return placeholder.Text
+}
+
+// actionStyle keeps the "primary" and "danger" button styles ("default" is the absence of style).
+func actionStyle(style string) string {
+	if style == "primary" || style == "danger" {
+		return style
+	}
+	return ""
+}
+
+// blockImageURL returns the URL of an image given by URL or as a Slack file.
+func blockImageURL(imageURL string, file *slack.SlackFileObject) string {
+	if imageURL == "" && file != nil {
+		return file.URL
+	}
+	return imageURL
+}
+
+// attachmentColor returns the CSS color of a legacy attachment color: a named color
+// ("good", "warning", "danger") or a hex code with or without "#".
+func attachmentColor(color string) string {
+	if color == "" {
+		return ""
+	}
+	if named, ok := attachmentColors[color]; ok {
+		return named
+	}
+	if !strings.HasPrefix(color, "#") {
+		return "#" + color
+	}
+	return color
+}
@@ -0,0 +1,219 @@
+package slack
+
+import (
+	"Loom/pkg/core"
+	"Loom/pkg/models"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	// directoryPageSize is the number of channels fetched per conversations.list call of the directory.
+	directoryPageSize = 200
+	// directoryMaxCalls is the number of conversations.list calls a directory search makes
+	// to fill a page before returning what it found (conversations.list is tier 2).
+	directoryMaxCalls = 3
+	// defaultDirectoryLimit is the page size used when BrowseChannels is called without a limit.
+	defaultDirectoryLimit = 50
+)
+
+// BrowseChannels returns a page of the public channels of the workspace, including those
+// the user is not a member of. Slack has no channel search, so conversations.list is paged
+// and filtered here: a page holds the matches of up to directoryMaxCalls Slack pages, which
+// may be fewer than limit (or a few more) before the end of the directory.
+func (p *SlackProvider) BrowseChannels(query string, cursor string, limit int, includeArchived bool) (*core.ChannelDirectoryPage, error) {
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("slack client not initialized")
+	}
+	if limit <= 0 {
+		limit = defaultDirectoryLimit
+	}
+	query = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(query), "#")))
+
+	page := &core.ChannelDirectoryPage{Channels: []core.DirectoryChannel{}}
+	for calls := 0; calls < directoryMaxCalls && len(page.Channels) < limit; calls++ {
+		var channels []slack.Channel
+		var nextCursor string
+		err := p.callAPI("conversations.list", priorityInteractive, func() error {
+			var err error
+			channels, nextCursor, err = client.GetConversations(&slack.GetConversationsParameters{
+				Types:           []string{"public_channel"},
+				Limit:           directoryPageSize,
+				Cursor:          cursor,
+				ExcludeArchived: !includeArchived,
+				TeamID:          p.scopeTeamID(),
+			})
+			return err
+		})
+		if err != nil {
+			p.log("SlackProvider.BrowseChannels: ERROR - failed to list channels: %v\n", err)
+			return nil, fmt.Errorf("failed to list channels: %w", err)
+		}
+		p.cacheChannelNames(channels)
+
+		for _, channel := range channels {
+			if query != "" && !channelMatches(channel, query) {
+				continue
+			}
+			page.Channels = append(page.Channels, core.DirectoryChannel{
+				ID:          channel.ID,
+				Name:        channel.Name,
+				Topic:       channel.Topic.Value,
+				Purpose:     channel.Purpose.Value,
+				MemberCount: channel.NumMembers,
+				IsMember:    channel.IsMember,
+				IsArchived:  channel.IsArchived,
+			})
+		}
+
+		cursor = nextCursor
+		if cursor == "" {
+			break
+		}
+	}
+	page.NextCursor = cursor
+
+	p.log("SlackProvider.BrowseChannels: %d channels matching %q (more: %v)\n", len(page.Channels), query, page.NextCursor != "")
+	return page, nil
+}
+
+// channelMatches reports whether the name, topic or purpose of a channel contains query (lowercase).
+func channelMatches(channel slack.Channel, query string) bool {
+	return strings.Contains(strings.ToLower(channel.Name), query) ||
+		strings.Contains(strings.ToLower(channel.Topic.Value), query) ||
+		strings.Contains(strings.ToLower(channel.Purpose.Value), query)
+}
+
+// JoinChannel joins a public channel. Joining a channel the user is already in succeeds.
+func (p *SlackProvider) JoinChannel(channelID string) (*models.Conversation, error) {
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("slack client not initialized")
+	}
+
+	var channel *slack.Channel
+	err := p.callAPI("conversations.join", priorityInteractive, func() error {
+		var err error
+		channel, _, _, err = client.JoinConversation(channelID)
+		return err
+	})
+	if err != nil {
+		p.log("SlackProvider.JoinChannel: ERROR - failed to join %s: %v\n", channelID, err)
+		return nil, channelActionError("join", err)
+	}
+	if channel == nil {
+		p.log("SlackProvider.JoinChannel: ERROR - conversations.join returned no channel for %s\n", channelID)
+		return nil, fmt.Errorf("failed to join channel %s: no channel returned by Slack", channelID)
+	}
+	p.cacheChannelNames([]slack.Channel{*channel})
+	p.log("SlackProvider.JoinChannel: joined #%s (%s)\n", channel.Name, channel.ID)
+
+	return &models.Conversation{
+		ProtocolConvID: channel.ID,
+		GroupName:      channel.Name,
+		IsGroup:        true,
+	}, nil
+}
+
+// ArchiveChannel archives a channel. Archiving an archived channel succeeds.
+func (p *SlackProvider) ArchiveChannel(channelID string) error {
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+
+	if client == nil {
+		return fmt.Errorf("slack client not initialized")
+	}
+
+	err := p.callAPI("conversations.archive", priorityInteractive, func() error {
+		return client.ArchiveConversation(channelID)
+	})
+	if err != nil && !strings.Contains(err.Error(), "already_archived") {
+		p.log("SlackProvider.ArchiveChannel: ERROR - failed to archive %s: %v\n", channelID, err)
+		return channelActionError("archive", err)
+	}
+	p.log("SlackProvider.ArchiveChannel: archived %s\n", channelID)
+	return nil
+}
+
+// UnarchiveChannel restores an archived channel. Unarchiving an active channel succeeds.
+func (p *SlackProvider) UnarchiveChannel(channelID string) error {
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+
+	if client == nil {
+		return fmt.Errorf("slack client not initialized")
+	}
+
+	err := p.callAPI("conversations.unarchive", priorityInteractive, func() error {
+		return client.UnArchiveConversation(channelID)
+	})
+	if err != nil && !strings.Contains(err.Error(), "not_archived") {
+		p.log("SlackProvider.UnarchiveChannel: ERROR - failed to unarchive %s: %v\n", channelID, err)
+		return channelActionError("unarchive", err)
+	}
+	p.log("SlackProvider.UnarchiveChannel: unarchived %s\n", channelID)
+	return nil
+}
+
+// channelActionError explains the Slack errors of the channel actions the user may not be permitted.
+func channelActionError(action string, err error) error {
+	switch code := err.Error(); {
+	case strings.Contains(code, "restricted_action"):
+		return fmt.Errorf("the workspace does not allow you to %s this channel", action)
+	case strings.Contains(code, "cant_archive_general"):
+		return fmt.Errorf("the general channel of the workspace cannot be archived")
+	case strings.Contains(code, "is_archived"):
+		return fmt.Errorf("the channel is archived")
+	case strings.Contains(code, "method_not_supported_for_channel_type"):
+		return fmt.Errorf("only public channels can be joined")
+	case strings.Contains(code, "missing_scope"):
+		return fmt.Errorf("the Slack token is missing the permission to %s channels: %w", action, err)
+	}
+	return fmt.Errorf("failed to %s channel: %w", action, err)
+}
+
+// handleRealtimeChannelInfo emits the topic, purpose or name change announced by a
+// channel_topic, channel_purpose or channel_name message (group_* for private channels).
+func (p *SlackProvider) handleRealtimeChannelInfo(channelID, subType string, message *slack.Msg) {
+	if message == nil {
+		return
+	}
+	event := core.GroupChangeEvent{
+		ConversationID: channelID,
+		ChangeType:     core.GroupChangeUpdated,
+		ParticipantID:  message.User,
+		Timestamp:      time.Now().Unix(),
+	}
+	if message.Timestamp != "" {
+		event.Timestamp = parseSlackTimestamp(message.Timestamp).Unix()
+	}
+
+	switch subType {
+	case slack.MsgSubTypeChannelTopic, slack.MsgSubTypeGroupTopic:
+		event.Topic = message.Topic
+	case slack.MsgSubTypeChannelPurpose, slack.MsgSubTypeGroupPurpose:
+		event.Description = message.Purpose
+	case slack.MsgSubTypeChannelName, slack.MsgSubTypeGroupName:
+		event.GroupName = message.Name
+		p.channelNamesMu.Lock()
+		p.channelNames[channelID] = message.Name
+		p.channelNamesMu.Unlock()
+	default:
+		return
+	}
+
+	p.log("SlackProvider.handleRealtimeChannelInfo: %s in %s\n", subType, channelID)
+	p.emitEvent(event)
+}
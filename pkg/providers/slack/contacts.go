@@ -13,20 +13,38 @@ import (
 // GetContacts returns the list of contacts for this protocol.
 // This includes both individual users and group conversations (channels).
 func (p *SlackProvider) GetContacts() ([]models.LinkedAccount, error) {
+	// The client is copied rather than locked: the calls may wait for the rate limits
 	p.mu.RLock()
-	defer p.mu.RUnlock()
+	client := p.client
+	p.mu.RUnlock()
 
-	if p.client == nil {
+	if client == nil {
 		return nil, fmt.Errorf("slack client not initialized")
 	}
 
 	var contacts []models.LinkedAccount
+	teamID, _ := p.workspaceTeam()
 
 	// Get individual users
-	users, err := p.client.GetUsers()
+	users, err := p.listUsers(client, priorityBackground)
 	if err != nil {
 		p.log("SlackProvider.GetContacts: WARNING - failed to get users: %v\n", err)
 	} else {
+		// Keep the users for mentions in message text
+		p.userCacheMu.Lock()
+		listed := make(map[string]bool, len(users))
+		for i := range users {
+			p.userCache[users[i].ID] = &users[i]
+			listed[users[i].ID] = true
+		}
+		// Users of other orgs met in shared channels are not listed by users.list
+		for userID, user := range p.userCache {
+			if !listed[userID] && p.isExternalUser(user) {
+				users = append(users, *user)
+			}
+		}
+		p.userCacheMu.Unlock()
+
 		for _, user := range users {
 			if user.Deleted || user.IsBot {
 				continue
@@ -78,6 +96,11 @@ func (p *SlackProvider) GetContacts() ([]models.LinkedAccount, error) {
 				}
 			}
 
+			// The team of the user: users of shared channels may belong to other orgs
+			for key, value := range p.userTeamExtra(&user) {
+				extraData[key] = value
+			}
+
 			// Store status emoji and text in Extra field for potential future use
 			if statusEmoji != "" {
 				extraData["statusEmoji"] = statusEmoji
@@ -130,10 +153,17 @@ func (p *SlackProvider) GetContacts() ([]models.LinkedAccount, error) {
 	}
 
 	// Get group conversations (channels)
-	channels, nextCursor, err := p.client.GetConversations(&slack.GetConversationsParameters{
-		Types:           []string{"public_channel", "private_channel"},
-		Limit:           1000, // Get up to 1000 channels
-		ExcludeArchived: true,
+	var channels []slack.Channel
+	var nextCursor string
+	err = p.callAPI("conversations.list", priorityBackground, func() error {
+		var err error
+		channels, nextCursor, err = client.GetConversations(&slack.GetConversationsParameters{
+			Types:           []string{"public_channel", "private_channel"},
+			Limit:           1000, // Get up to 1000 channels
+			ExcludeArchived: true,
+			TeamID:          p.scopeTeamID(),
+		})
+		return err
 	})
 	if err != nil {
 		p.log("SlackProvider.GetContacts: WARNING - failed to get channels: %v\n", err)
@@ -141,11 +171,18 @@ func (p *SlackProvider) GetContacts() ([]models.LinkedAccount, error) {
 		// Handle pagination if needed
 		allChannels := channels
 		for nextCursor != "" {
-			moreChannels, cursor, err := p.client.GetConversations(&slack.GetConversationsParameters{
-				Types:           []string{"public_channel", "private_channel"},
-				Limit:           1000,
-				Cursor:          nextCursor,
-				ExcludeArchived: true,
+			var moreChannels []slack.Channel
+			var cursor string
+			err := p.callAPI("conversations.list", priorityBackground, func() error {
+				var err error
+				moreChannels, cursor, err = client.GetConversations(&slack.GetConversationsParameters{
+					Types:           []string{"public_channel", "private_channel"},
+					Limit:           1000,
+					Cursor:          nextCursor,
+					ExcludeArchived: true,
+					TeamID:          p.scopeTeamID(),
+				})
+				return err
 			})
 			if err != nil {
 				p.log("SlackProvider.GetContacts: WARNING - failed to paginate channels: %v\n", err)
@@ -155,6 +192,9 @@ func (p *SlackProvider) GetContacts() ([]models.LinkedAccount, error) {
 			nextCursor = cursor
 		}
 
+		p.cacheChannelNames(allChannels)
+		p.cacheChannelTeams(allChannels)
+
 		for _, channel := range allChannels {
 			// Channels are group conversations in Slack
 			// We already have the channel name from GetConversations, so we don't need to fetch detailed info
@@ -164,6 +204,7 @@ func (p *SlackProvider) GetContacts() ([]models.LinkedAccount, error) {
 				Username: channel.Name,
 				Status:   "offline", // Channels don't have online status
 				Protocol: "slack",
+				Extra:    channelTeamExtra(channel, teamID),
 			})
 		}
 		p.log("SlackProvider.GetContacts: Retrieved %d channels\n", len(allChannels))
@@ -176,14 +217,15 @@ func (p *SlackProvider) GetContacts() ([]models.LinkedAccount, error) {
 // GetContactName returns the display name for a Slack user ID.
 func (p *SlackProvider) GetContactName(contactID string) (string, error) {
 	p.mu.RLock()
-	defer p.mu.RUnlock()
+	client := p.client
+	p.mu.RUnlock()
 
-	if p.client == nil {
+	if client == nil {
 		return "", fmt.Errorf("slack client not initialized")
 	}
 
 	// Get user info from Slack API
-	user, err := p.client.GetUserInfo(contactID)
+	user, err := p.lookupUser(client, contactID, priorityBackground)
 	if err != nil {
 		return "", fmt.Errorf("failed to get user info: %w", err)
 	}
@@ -199,3 +241,107 @@ func (p *SlackProvider) GetContactName(contactID string) (string, error) {
 
 	return displayName, nil
 }
+
+// lookupUser returns a user from the user cache, fetching it with users.info on a miss.
+// Users of other orgs met in externally shared channels that users.info refuses are built
+// from their profile (users.profile.get), which Slack Connect members can read.
+func (p *SlackProvider) lookupUser(client *slack.Client, userID string, priority requestPriority) (*slack.User, error) {
+	p.userCacheMu.RLock()
+	user, cached := p.userCache[userID]
+	p.userCacheMu.RUnlock()
+	if cached {
+		return user, nil
+	}
+
+	err := p.callAPI("users.info", priority, func() error {
+		var err error
+		user, err = client.GetUserInfo(userID)
+		return err
+	})
+	if err != nil && strings.Contains(err.Error(), "user_not_found") {
+		var profile *slack.UserProfile
+		profileErr := p.callAPI("users.profile.get", priority, func() error {
+			var err error
+			profile, err = client.GetUserProfile(&slack.GetUserProfileParameters{UserID: userID})
+			return err
+		})
+		if profileErr == nil && profile != nil {
+			user = &slack.User{
+				ID:       userID,
+				TeamID:   profile.Team,
+				Name:     profile.DisplayName,
+				RealName: profile.RealName,
+				Profile:  *profile,
+			}
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p.userCacheMu.Lock()
+	p.userCache[userID] = user
+	p.userCacheMu.Unlock()
+	if p.isExternalUser(user) {
+		p.log("SlackProvider.lookupUser: %s belongs to the external team %s\n", userID, user.TeamID)
+	}
+	return user, nil
+}
+
+// isExternalUser reports whether a user belongs to another org than the workspace: a
+// Slack Connect member of an externally shared channel. Users of the other workspaces of
+// the same Enterprise Grid org are not external.
+func (p *SlackProvider) isExternalUser(user *slack.User) bool {
+	teamID, enterpriseID := p.workspaceTeam()
+	if user == nil || user.TeamID == "" || teamID == "" || user.TeamID == teamID {
+		return false
+	}
+	return enterpriseID == "" || user.Enterprise.EnterpriseID != enterpriseID
+}
+
+// userTeamExtra returns the team data of a user stored in LinkedAccount.Extra: the team of
+// the user, its Enterprise Grid org, and whether the user belongs to another org.
+func (p *SlackProvider) userTeamExtra(user *slack.User) map[string]interface{} {
+	extra := make(map[string]interface{})
+	teamID, _ := p.workspaceTeam()
+	if user.TeamID != "" {
+		teamID = user.TeamID
+	}
+	if teamID != "" {
+		extra["teamId"] = teamID
+	}
+	if user.Enterprise.EnterpriseID != "" {
+		extra["enterpriseId"] = user.Enterprise.EnterpriseID
+	}
+	if p.isExternalUser(user) {
+		extra["external"] = true
+	}
+	return extra
+}
+
+// channelTeamExtra returns the LinkedAccount.Extra of a channel: its team, and the teams it
+// is shared with when it is externally shared.
+func channelTeamExtra(channel slack.Channel, teamID string) string {
+	extra := make(map[string]interface{})
+	if channel.ContextTeamID != "" {
+		teamID = channel.ContextTeamID
+	}
+	if teamID != "" {
+		extra["teamId"] = teamID
+	}
+	if channel.IsExtShared {
+		extra["externallyShared"] = true
+		if len(channel.ConnectedTeamIDs) > 0 {
+			extra["connectedTeamIds"] = channel.ConnectedTeamIDs
+		}
+	}
+	if len(extra) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(extra)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,517 @@
+package slack
+
+import (
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	// emojiRefreshInterval is how often the full emoji list is fetched again from Slack.
+	// In between, emoji_changed events keep the stored list up to date.
+	emojiRefreshInterval = 24 * time.Hour
+	// emojiDownloadWorkers is the number of emoji images downloaded in parallel.
+	emojiDownloadWorkers = 4
+	// maxEmojiAliasDepth bounds the resolution of alias chains (alias -> alias -> emoji).
+	maxEmojiAliasDepth = 10
+	// maxEmojiImageSize is the maximum size of a downloaded emoji image (Slack limits them to 128 KB).
+	maxEmojiImageSize = 1024 * 1024
+)
+
+// emojiEntry is a custom emoji with its alias resolved.
+type emojiEntry struct {
+	URL     string // Image URL ("" for an alias of a standard emoji)
+	AliasOf string // Name of the aliased emoji
+}
+
+// emojiDownloads guards against concurrent runs of cacheEmojiImages for the same instance.
+var emojiDownloads sync.Map
+
+// loadEmojis loads the stored custom emojis, then refreshes them from Slack when they were
+// never fetched (synchronously) or are older than emojiRefreshInterval (in the background).
+// The last refresh is recorded on the configuration of the instance, so that a workspace
+// without custom emojis is not fetched again on every start.
+func (p *SlackProvider) loadEmojis(client *slack.Client) {
+	if client == nil {
+		return
+	}
+
+	stored := p.storedEmojis()
+	lastRefresh := p.emojisRefreshedAt()
+	if lastRefresh.IsZero() && len(stored) == 0 {
+		p.log("SlackProvider.loadEmojis: emojis never fetched, fetching them from Slack\n")
+		p.refreshEmojis(client)
+		return
+	}
+
+	p.setEmojiCache(stored)
+	p.log("SlackProvider.loadEmojis: loaded %d stored emojis (last refresh %s)\n", len(stored), lastRefresh.Format("2006-01-02 15:04:05"))
+	if time.Since(lastRefresh) > emojiRefreshInterval {
+		go p.refreshEmojis(client)
+	} else {
+		go p.cacheEmojiImages()
+	}
+}
+
+// storedEmojis returns the stored custom emojis of this instance.
+func (p *SlackProvider) storedEmojis() []models.CustomEmoji {
+	if db.DB == nil {
+		return nil
+	}
+	var emojis []models.CustomEmoji
+	if err := db.DB.Where("provider_instance_id = ?", p.instanceID).Find(&emojis).Error; err != nil {
+		p.log("SlackProvider.storedEmojis: WARNING - failed to load emojis: %v\n", err)
+		return nil
+	}
+	return emojis
+}
+
+// emojisRefreshedAt returns when the emoji list of this instance was last fetched (zero if never).
+func (p *SlackProvider) emojisRefreshedAt() time.Time {
+	if db.DB == nil {
+		return time.Time{}
+	}
+	var config models.ProviderConfiguration
+	if err := db.DB.Where("instance_id = ?", p.instanceID).First(&config).Error; err != nil || config.EmojisRefreshedAt == nil {
+		return time.Time{}
+	}
+	return *config.EmojisRefreshedAt
+}
+
+// refreshEmojis fetches the full emoji list from Slack, stores it and downloads the new images.
+func (p *SlackProvider) refreshEmojis(client *slack.Client) {
+	p.log("SlackProvider.refreshEmojis: fetching emojis from Slack API\n")
+	var raw map[string]string
+	err := p.callAPI("emoji.list", priorityBackground, func() error {
+		var err error
+		raw, err = client.GetEmoji()
+		return err
+	})
+	if err != nil {
+		p.log("SlackProvider.refreshEmojis: WARNING - failed to get emojis: %v\n", err)
+		return
+	}
+	entries := resolveEmojiAliases(raw)
+	p.log("SlackProvider.refreshEmojis: received %d emojis from API\n", len(entries))
+
+	if db.DB == nil {
+		emojis := make([]models.CustomEmoji, 0, len(entries))
+		for name, entry := range entries {
+			emojis = append(emojis, models.CustomEmoji{Name: name, URL: entry.URL, AliasOf: entry.AliasOf})
+		}
+		p.setEmojiCache(emojis)
+		return
+	}
+
+	var existing []models.CustomEmoji
+	db.DB.Where("provider_instance_id = ?", p.instanceID).Find(&existing)
+	byName := make(map[string]models.CustomEmoji, len(existing))
+	for _, emoji := range existing {
+		byName[emoji.Name] = emoji
+	}
+
+	created, updated, removed := 0, 0, 0
+	for name, entry := range entries {
+		emoji, ok := byName[name]
+		if !ok {
+			if err := db.DB.Create(&models.CustomEmoji{
+				ProviderInstanceID: p.instanceID,
+				Name:               name,
+				URL:                entry.URL,
+				AliasOf:            entry.AliasOf,
+			}).Error; err != nil {
+				p.log("SlackProvider.refreshEmojis: failed to store emoji %s: %v\n", name, err)
+			}
+			created++
+			continue
+		}
+		if emoji.URL != entry.URL || emoji.AliasOf != entry.AliasOf {
+			db.DB.Model(&models.CustomEmoji{}).Where("id = ?", emoji.ID).Updates(map[string]interface{}{
+				"url":        entry.URL,
+				"alias_of":   entry.AliasOf,
+				"local_path": "",
+			})
+			updated++
+		}
+	}
+	var removedNames []string
+	for name := range byName {
+		if _, ok := entries[name]; !ok {
+			removedNames = append(removedNames, name)
+		}
+	}
+	if len(removedNames) > 0 {
+		removed = len(removedNames)
+		p.removeStoredEmojis(removedNames)
+	}
+	// Record the refresh on the configuration of the instance
+	if err := db.DB.Model(&models.ProviderConfiguration{}).Where("instance_id = ?", p.instanceID).Update("emojis_refreshed_at", time.Now()).Error; err != nil {
+		p.log("SlackProvider.refreshEmojis: WARNING - failed to record the refresh: %v\n", err)
+	}
+	p.log("SlackProvider.refreshEmojis: %d emojis added, %d changed, %d removed\n", created, updated, removed)
+
+	stored := p.storedEmojis()
+	p.setEmojiCache(stored)
+	go p.cacheEmojiImages()
+}
+
+// resolveEmojiAliases resolves the "alias:name" values of emoji.list to the URL of their target,
+// following chains of aliases. Aliases of standard emojis keep an empty URL.
+func resolveEmojiAliases(raw map[string]string) map[string]emojiEntry {
+	entries := make(map[string]emojiEntry, len(raw))
+	for name, value := range raw {
+		entry := emojiEntry{}
+		target := value
+		for depth := 0; depth < maxEmojiAliasDepth && strings.HasPrefix(target, "alias:"); depth++ {
+			targetName := strings.TrimPrefix(target, "alias:")
+			if entry.AliasOf == "" {
+				entry.AliasOf = targetName
+			}
+			target = raw[targetName]
+		}
+		if !strings.HasPrefix(target, "alias:") {
+			entry.URL = target
+		}
+		entries[name] = entry
+	}
+	return entries
+}
+
+// setEmojiCache replaces the in-memory emoji maps.
+func (p *SlackProvider) setEmojiCache(emojis []models.CustomEmoji) {
+	urls := make(map[string]string, len(emojis))
+	paths := make(map[string]string)
+	for _, emoji := range emojis {
+		if emoji.URL != "" {
+			urls[emoji.Name] = emoji.URL
+		}
+		if emoji.LocalPath != "" {
+			paths[emoji.Name] = emoji.LocalPath
+		}
+	}
+
+	p.emojiCacheMu.Lock()
+	p.emojiCache = urls
+	p.emojiPaths = paths
+	p.emojiCacheMu.Unlock()
+}
+
+// emojiCacheDir returns the directory of the cached emoji images of an instance, creating it if needed.
+func emojiCacheDir(instanceID string) (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get config directory: %w", err)
+	}
+	dir := filepath.Join(configDir, "Loom", "slack", "emoji", instanceID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create emoji cache directory: %w", err)
+	}
+	return dir, nil
+}
+
+// emojiImagePath returns the cache path of an emoji image. Paths derive from the URL,
+// so aliases share the file of their target.
+func emojiImagePath(dir, imageURL string) string {
+	hash := sha256.Sum256([]byte(imageURL))
+	ext := path.Ext(imageURL)
+	if idx := strings.IndexAny(ext, "?#"); idx != -1 {
+		ext = ext[:idx]
+	}
+	if ext == "" || len(ext) > 5 {
+		ext = ".png"
+	}
+	return filepath.Join(dir, hex.EncodeToString(hash[:16])+ext)
+}
+
+// cacheEmojiImages downloads the emoji images that are not cached yet and records their paths.
+func (p *SlackProvider) cacheEmojiImages() {
+	if _, running := emojiDownloads.LoadOrStore(p.instanceID, true); running {
+		return
+	}
+	defer emojiDownloads.Delete(p.instanceID)
+
+	dir, err := emojiCacheDir(p.instanceID)
+	if err != nil {
+		p.log("SlackProvider.cacheEmojiImages: WARNING - %v\n", err)
+		return
+	}
+
+	// Group the emojis by image, aliases share their target's image
+	p.emojiCacheMu.RLock()
+	namesByURL := make(map[string][]string)
+	for name, imageURL := range p.emojiCache {
+		if cachedPath := p.emojiPaths[name]; cachedPath != "" {
+			if _, err := os.Stat(cachedPath); err == nil {
+				continue
+			}
+		}
+		namesByURL[imageURL] = append(namesByURL[imageURL], name)
+	}
+	p.emojiCacheMu.RUnlock()
+	if len(namesByURL) == 0 {
+		return
+	}
+	p.log("SlackProvider.cacheEmojiImages: caching %d emoji images\n", len(namesByURL))
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	downloaded, failed := 0, 0
+	for i := 0; i < emojiDownloadWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for imageURL := range jobs {
+				imagePath := emojiImagePath(dir, imageURL)
+				if err := p.downloadEmojiImage(imageURL, imagePath); err != nil {
+					p.log("SlackProvider.cacheEmojiImages: WARNING - failed to download %s: %v\n", imageURL, err)
+					mu.Lock()
+					failed++
+					mu.Unlock()
+					continue
+				}
+				p.recordEmojiImage(namesByURL[imageURL], imageURL, imagePath)
+				mu.Lock()
+				downloaded++
+				mu.Unlock()
+			}
+		}()
+	}
+	for imageURL := range namesByURL {
+		jobs <- imageURL
+	}
+	close(jobs)
+	wg.Wait()
+	p.log("SlackProvider.cacheEmojiImages: cached %d emoji images (%d failed)\n", downloaded, failed)
+}
+
+// recordEmojiImage stores the cache path of an image for the emojis using it.
+func (p *SlackProvider) recordEmojiImage(names []string, imageURL, imagePath string) {
+	p.emojiCacheMu.Lock()
+	for _, name := range names {
+		p.emojiPaths[name] = imagePath
+	}
+	p.emojiCacheMu.Unlock()
+
+	if db.DB != nil {
+		db.DB.Model(&models.CustomEmoji{}).
+			Where("provider_instance_id = ? AND url = ?", p.instanceID, imageURL).
+			Update("local_path", imagePath)
+	}
+}
+
+// downloadEmojiImage downloads an emoji image to imagePath, unless it is already there.
+func (p *SlackProvider) downloadEmojiImage(imageURL, imagePath string) error {
+	if _, err := os.Stat(imagePath); err == nil {
+		return nil
+	}
+
+	p.mu.RLock()
+	httpClient := p.httpClient
+	p.mu.RUnlock()
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Get(imageURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download returned %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxEmojiImageSize))
+	if err != nil {
+		return err
+	}
+
+	tmpPath := imagePath + ".part"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, imagePath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// removeStoredEmojis deletes emojis, and their images when no other emoji uses them.
+func (p *SlackProvider) removeStoredEmojis(names []string) {
+	if db.DB == nil {
+		return
+	}
+	var removed []models.CustomEmoji
+	db.DB.Where("provider_instance_id = ? AND name IN ?", p.instanceID, names).Find(&removed)
+	if err := db.DB.Where("provider_instance_id = ? AND name IN ?", p.instanceID, names).Delete(&models.CustomEmoji{}).Error; err != nil {
+		p.log("SlackProvider.removeStoredEmojis: failed to delete emojis: %v\n", err)
+		return
+	}
+	for _, emoji := range removed {
+		if emoji.LocalPath == "" {
+			continue
+		}
+		var users int64
+		db.DB.Model(&models.CustomEmoji{}).Where("provider_instance_id = ? AND local_path = ?", p.instanceID, emoji.LocalPath).Count(&users)
+		if users == 0 {
+			os.Remove(emoji.LocalPath)
+		}
+	}
+}
+
+// handleEmojiChanged applies an emoji_changed event: "add", "remove" or "rename".
+// Renames without the old and new names (RTM) trigger a full refresh.
+func (p *SlackProvider) handleEmojiChanged(subType, name string, names []string, value, oldName, newName string) {
+	switch subType {
+	case "add":
+		if name == "" {
+			return
+		}
+		entry := emojiEntry{URL: value}
+		if strings.HasPrefix(value, "alias:") {
+			entry.AliasOf = strings.TrimPrefix(value, "alias:")
+			p.emojiCacheMu.RLock()
+			entry.URL = p.emojiCache[entry.AliasOf]
+			p.emojiCacheMu.RUnlock()
+		}
+		p.storeEmoji(name, entry)
+		p.log("SlackProvider.handleEmojiChanged: emoji %s added\n", name)
+		go p.cacheEmojiImages()
+
+	case "remove":
+		if len(names) == 0 && name != "" {
+			names = []string{name}
+		}
+		p.emojiCacheMu.Lock()
+		for _, removed := range names {
+			delete(p.emojiCache, removed)
+			delete(p.emojiPaths, removed)
+		}
+		p.emojiCacheMu.Unlock()
+		p.removeStoredEmojis(names)
+		p.log("SlackProvider.handleEmojiChanged: %d emojis removed\n", len(names))
+
+	case "rename":
+		if oldName == "" || newName == "" {
+			p.log("SlackProvider.handleEmojiChanged: emoji renamed, refreshing the emoji list\n")
+			p.mu.RLock()
+			client := p.client
+			p.mu.RUnlock()
+			if client != nil {
+				go p.refreshEmojis(client)
+			}
+			return
+		}
+		p.emojiCacheMu.Lock()
+		if imageURL, ok := p.emojiCache[oldName]; ok {
+			p.emojiCache[newName] = imageURL
+			delete(p.emojiCache, oldName)
+		}
+		if imagePath, ok := p.emojiPaths[oldName]; ok {
+			p.emojiPaths[newName] = imagePath
+			delete(p.emojiPaths, oldName)
+		}
+		p.emojiCacheMu.Unlock()
+		if db.DB != nil {
+			db.DB.Model(&models.CustomEmoji{}).Where("provider_instance_id = ? AND name = ?", p.instanceID, oldName).Update("name", newName)
+		}
+		p.log("SlackProvider.handleEmojiChanged: emoji %s renamed to %s\n", oldName, newName)
+	}
+}
+
+// storeEmoji adds or updates one emoji in memory and in the database.
+func (p *SlackProvider) storeEmoji(name string, entry emojiEntry) {
+	p.emojiCacheMu.Lock()
+	if entry.URL != "" {
+		p.emojiCache[name] = entry.URL
+	} else {
+		delete(p.emojiCache, name)
+	}
+	delete(p.emojiPaths, name)
+	p.emojiCacheMu.Unlock()
+
+	if db.DB == nil {
+		return
+	}
+	var emoji models.CustomEmoji
+	if err := db.DB.Where("provider_instance_id = ? AND name = ?", p.instanceID, name).First(&emoji).Error; err == nil {
+		db.DB.Model(&models.CustomEmoji{}).Where("id = ?", emoji.ID).Updates(map[string]interface{}{
+			"url":        entry.URL,
+			"alias_of":   entry.AliasOf,
+			"local_path": "",
+		})
+		return
+	}
+	if err := db.DB.Create(&models.CustomEmoji{
+		ProviderInstanceID: p.instanceID,
+		Name:               name,
+		URL:                entry.URL,
+		AliasOf:            entry.AliasOf,
+	}).Error; err != nil {
+		p.log("SlackProvider.storeEmoji: failed to store emoji %s: %v\n", name, err)
+	}
+}
+
+// GetEmojiPath returns the cached image of a custom emoji, or "" if it is not cached.
+// emojiName can be with or without colons.
+func (p *SlackProvider) GetEmojiPath(emojiName string) string {
+	name := strings.TrimPrefix(strings.TrimSuffix(emojiName, ":"), ":")
+
+	p.emojiCacheMu.RLock()
+	imagePath := p.emojiPaths[name]
+	p.emojiCacheMu.RUnlock()
+	if imagePath == "" {
+		return ""
+	}
+	if _, err := os.Stat(imagePath); err != nil {
+		return ""
+	}
+	return imagePath
+}
+
+// emojiImage returns the image of a custom emoji for events: its cached file, else its Slack URL.
+// It returns "" for standard emojis.
+func (p *SlackProvider) emojiImage(emojiName string) string {
+	if emojiName == "" {
+		return ""
+	}
+	if imagePath := p.GetEmojiPath(emojiName); imagePath != "" {
+		return imagePath
+	}
+	name := strings.TrimPrefix(strings.TrimSuffix(emojiName, ":"), ":")
+	p.emojiCacheMu.RLock()
+	defer p.emojiCacheMu.RUnlock()
+	return p.emojiCache[name]
+}
+
+// GetEmojiURL returns the URL for a Slack emoji, or empty string if not found
+// emojiName should be without colons (e.g., "calendar" not ":calendar:")
+// Aliases are resolved to the URL of their target when the emojis are loaded.
+func (p *SlackProvider) GetEmojiURL(emojiName string) string {
+	// Remove colons if present
+	name := strings.TrimPrefix(strings.TrimSuffix(emojiName, ":"), ":")
+
+	p.emojiCacheMu.RLock()
+	defer p.emojiCacheMu.RUnlock()
+
+	url := p.emojiCache[name]
+	if url == "" {
+		p.log("SlackProvider.GetEmojiURL: emoji '%s' not found in cache (cache size: %d)\n", name, len(p.emojiCache))
+	} else {
+		p.log("SlackProvider.GetEmojiURL: found emoji '%s' -> %s\n", name, url)
+	}
+	return url
+}
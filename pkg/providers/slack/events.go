@@ -74,6 +74,10 @@ func (p *SlackProvider) SyncHistory(since time.Time) error {
 		return fmt.Errorf("slack client not initialized")
 	}
 
+	// Throttling reports leave the sync status to the sync while it runs
+	p.syncing.Store(true)
+	defer p.syncing.Store(false)
+
 	p.log("SlackProvider.SyncHistory: Starting sync for messages since %s\n", since.Format("2006-01-02 15:04:05"))
 	fmt.Printf("SlackProvider.SyncHistory: Starting sync for messages since %s\n", since.Format("2006-01-02 15:04:05"))
 
@@ -98,6 +102,10 @@ func (p *SlackProvider) SyncHistory(since time.Time) error {
 	// Sync messages for each conversation
 	for idx, contact := range contacts {
 		conversationID := contact.UserID
+		if teamID, ok := p.cachedChannelTeam(conversationID); ok && p.isOtherTeam(teamID) {
+			p.log("SlackProvider.SyncHistory: Skipping conversation %s of workspace %s\n", conversationID, teamID)
+			continue
+		}
 
 		// Find the last message in DB for this conversation to sync from that point
 		var lastMessage models.Message
@@ -120,7 +128,7 @@ func (p *SlackProvider) SyncHistory(since time.Time) error {
 		// Fetch recent messages directly from Slack API (not from DB)
 		// This ensures we always get the latest messages, even if DB has old messages
 		// Pass conversationSince as oldest to only fetch messages after this timestamp
-		messages, err := p.getRecentMessages(conversationID, 100, &conversationSince)
+		messages, err := p.getRecentMessages(conversationID, 100, &conversationSince, priorityBackground)
 		if err != nil {
 			p.log("SlackProvider.SyncHistory: WARNING - failed to get history for conversation %s: %v\n", conversationID, err)
 			// Update progress even on error
@@ -172,6 +180,13 @@ func (p *SlackProvider) SyncHistory(since time.Time) error {
 		}
 	}
 
+	// Bring the unread state in line with the Slack clients
+	conversationIDs := make([]string, 0, len(contacts))
+	for _, contact := range contacts {
+		conversationIDs = append(conversationIDs, contact.UserID)
+	}
+	p.syncReadMarkers(conversationIDs)
+
 	// Emit sync status: completed
 	fmt.Printf("SlackProvider.SyncHistory: Emitting completed status (%d conversations synced)\n", totalSynced)
 	p.emitSyncStatus(core.SyncStatusCompleted, fmt.Sprintf("Sync completed - %d conversations synced", totalSynced), 100)
@@ -181,8 +196,11 @@ func (p *SlackProvider) SyncHistory(since time.Time) error {
 	return nil
 }
 
-// SendStatusMessage sends a status message (broadcast).
+// SendStatusMessage sets the custom status of the account to text. Slack has no broadcast
+// status messages: no message is created and attachments are not supported.
 func (p *SlackProvider) SendStatusMessage(text string, file *core.Attachment) (*models.Message, error) {
-	// Slack status is user status, not a broadcast message usually.
-	return nil, nil
+	if file != nil {
+		return nil, fmt.Errorf("slack statuses cannot have attachments")
+	}
+	return nil, p.SetMyStatusText(text, "", nil)
 }
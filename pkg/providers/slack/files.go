@@ -0,0 +1,653 @@
+package slack
+
+import (
+	"Loom/pkg/core"
+	"Loom/pkg/db"
+	"Loom/pkg/media"
+	"Loom/pkg/models"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	// maxAutoDownloadSize is the size above which files are not downloaded with their message:
+	// only their thumbnail is cached and the attachment links to Slack.
+	maxAutoDownloadSize = 50 * 1024 * 1024
+	// fileInfoURL is the files.info endpoint, called directly for the fields slack-go does not decode.
+	fileInfoURL = "https://slack.com/api/files.info"
+	// uploadProgressStep is the minimum progress (in percent) between two UploadProgressEvents.
+	uploadProgressStep = 5
+	// shareLookupAttempts and shareLookupDelay bound the wait for the message of an uploaded file.
+	shareLookupAttempts = 5
+	shareLookupDelay    = 500 * time.Millisecond
+	// fileDownloadWorkers is the number of goroutines downloading the files of received messages.
+	fileDownloadWorkers = 2
+	// fileDownloadQueueSize is the number of messages whose files can wait for a download worker.
+	fileDownloadQueueSize = 500
+	// fileHeaderSize is the number of bytes of a cached file read to classify its content.
+	fileHeaderSize = 512
+)
+
+// fileDownload is the download of the files of a message.
+type fileDownload struct {
+	conversationID string
+	messageID      string
+	files          []slack.File
+}
+
+// fileInfoResponse is the part of the files.info response used to read media durations.
+type fileInfoResponse struct {
+	OK   bool `json:"ok"`
+	File struct {
+		DurationMs int64 `json:"duration_ms"`
+	} `json:"file"`
+}
+
+// attachmentCacheDir returns the directory where Slack files are cached, creating it if needed.
+func attachmentCacheDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get config directory: %w", err)
+	}
+	cacheDir := filepath.Join(configDir, "Loom", "slack", "attachments")
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create attachment cache directory: %w", err)
+	}
+	return cacheDir, nil
+}
+
+// attachmentCachePath returns the cache path of a Slack file (kind is "file", "thumb" or "duration").
+func attachmentCachePath(cacheDir string, file slack.File, kind string, ext string) string {
+	hash := sha256.Sum256([]byte(file.ID + kind))
+	return filepath.Join(cacheDir, hex.EncodeToString(hash[:])+ext)
+}
+
+// convertSlackFiles maps the files of a message to attachments from their metadata and the
+// attachment cache, without downloading anything. pending is true when a file, thumbnail or
+// duration is not cached yet (see queueFileDownloads); until then attachments link to Slack.
+func (p *SlackProvider) convertSlackFiles(files []slack.File) (attachments []models.Attachment, pending bool) {
+	if len(files) == 0 {
+		return nil, false
+	}
+	cacheDir, err := attachmentCacheDir()
+	if err != nil {
+		p.log("SlackProvider.convertSlackFiles: WARNING - %v\n", err)
+	}
+
+	for _, file := range files {
+		if !isDownloadableFile(file) {
+			continue
+		}
+		att, filePending := convertSlackFile(cacheDir, file)
+		attachments = append(attachments, att)
+		pending = pending || filePending
+	}
+	return attachments, pending
+}
+
+// isDownloadableFile reports whether a file of a message can be shown: deleted files and files
+// hidden by the free plan history limit cannot.
+func isDownloadableFile(file slack.File) bool {
+	return file.ID != "" && file.Mode != "tombstone" && file.Mode != "hidden_by_limit"
+}
+
+// convertSlackFile maps one Slack file to an attachment, using its cached file, thumbnail and
+// duration when present. pending is true when one of them remains to be downloaded.
+func convertSlackFile(cacheDir string, file slack.File) (att models.Attachment, pending bool) {
+	fileName := file.Name
+	if fileName == "" {
+		fileName = file.Title
+	}
+	mimeType := file.Mimetype
+	if mimeType == "" {
+		mimeType = media.MIMEFromExtension(fileName)
+	}
+
+	att = models.Attachment{
+		Type:     string(media.Classify(mimeType, nil)),
+		URL:      file.Permalink,
+		FileName: fileName,
+		FileSize: int64(file.Size),
+		MimeType: mimeType,
+	}
+	if cacheDir == "" {
+		return att, false
+	}
+
+	// The file itself
+	if slackDownloadURL(file) != "" {
+		cachePath := attachmentCachePath(cacheDir, file, "file", slackFileExtension(file))
+		if header, err := readFileHeader(cachePath); err == nil {
+			att.URL = cachePath
+			// Content-based classification (voice notes, stickers)
+			att.Type = string(media.Classify(mimeType, header))
+		} else {
+			pending = true
+		}
+	}
+
+	// The thumbnail
+	if slackThumbnailURL(file) != "" {
+		thumbPath := attachmentCachePath(cacheDir, file, "thumb", ".jpg")
+		if _, err := os.Stat(thumbPath); err == nil {
+			att.Thumbnail = thumbPath
+		} else {
+			pending = true
+		}
+	}
+
+	// Duration of audio and video clips
+	if att.Type == string(media.TypeAudio) || att.Type == string(media.TypeVoice) || att.Type == string(media.TypeVideo) {
+		if seconds, ok := cachedFileDuration(cacheDir, file); ok {
+			att.Duration = seconds
+		} else {
+			pending = true
+		}
+	}
+	return att, pending
+}
+
+// slackFileExtension returns the extension of the cached copy of a file.
+func slackFileExtension(file slack.File) string {
+	fileName := file.Name
+	if fileName == "" {
+		fileName = file.Title
+	}
+	if ext := filepath.Ext(fileName); ext != "" {
+		return ext
+	}
+	mimeType := file.Mimetype
+	if mimeType == "" {
+		mimeType = media.MIMEFromExtension(fileName)
+	}
+	return media.ExtensionFromMIME(mimeType)
+}
+
+// slackDownloadURL returns the private URL a file is downloaded from, "" if it is not
+// downloaded automatically (no URL, or larger than maxAutoDownloadSize).
+func slackDownloadURL(file slack.File) string {
+	if file.Size > maxAutoDownloadSize {
+		return ""
+	}
+	if file.URLPrivateDownload != "" {
+		return file.URLPrivateDownload
+	}
+	return file.URLPrivate
+}
+
+// readFileHeader returns the first bytes of a file, enough to classify its content.
+func readFileHeader(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := make([]byte, fileHeaderSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return header[:n], nil
+}
+
+// slackThumbnailURL returns the largest thumbnail suited for the message list, "" if the file has none.
+func slackThumbnailURL(file slack.File) string {
+	for _, thumb := range []string{file.Thumb480, file.Thumb360, file.Thumb720, file.Thumb160, file.Thumb80, file.Thumb64} {
+		if thumb != "" {
+			return thumb
+		}
+	}
+	return ""
+}
+
+// queueFileDownloads schedules the download of the files of a message on the download workers.
+// Once done, the stored attachments of the message are updated and an AttachmentUpdateEvent is emitted.
+func (p *SlackProvider) queueFileDownloads(conversationID, messageID string, files []slack.File) {
+	key := conversationID + "/" + messageID
+	p.pendingDownloadsMu.Lock()
+	if p.pendingDownloads[key] {
+		p.pendingDownloadsMu.Unlock()
+		return
+	}
+	p.pendingDownloads[key] = true
+	p.pendingDownloadsMu.Unlock()
+
+	select {
+	case p.fileDownloads <- fileDownload{conversationID: conversationID, messageID: messageID, files: files}:
+	default:
+		// The files are queued again the next time the message is converted
+		p.log("SlackProvider.queueFileDownloads: WARNING - download queue full, skipping files of message %s\n", messageID)
+		p.finishFileDownload(key)
+	}
+}
+
+// startFileDownloads starts the download workers of a connection. The files queued while
+// disconnected are downloaded once connected again.
+func (p *SlackProvider) startFileDownloads() {
+	p.stopFileDownloads()
+
+	p.downloadsMu.Lock()
+	defer p.downloadsMu.Unlock()
+	stop := make(chan struct{})
+	p.downloadsStop = stop
+	for i := 0; i < fileDownloadWorkers; i++ {
+		p.downloadsWG.Add(1)
+		go p.runFileDownloads(stop)
+	}
+}
+
+// stopFileDownloads stops the download workers and waits for the downloads in progress.
+func (p *SlackProvider) stopFileDownloads() {
+	p.downloadsMu.Lock()
+	stop := p.downloadsStop
+	p.downloadsStop = nil
+	p.downloadsMu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	p.downloadsWG.Wait()
+}
+
+// runFileDownloads downloads queued files until stop is closed.
+func (p *SlackProvider) runFileDownloads(stop <-chan struct{}) {
+	defer p.downloadsWG.Done()
+	for {
+		select {
+		case <-stop:
+			return
+		case job := <-p.fileDownloads:
+			p.downloadMessageFiles(job)
+			p.finishFileDownload(job.conversationID + "/" + job.messageID)
+		}
+	}
+}
+
+// finishFileDownload allows the files of a message to be queued again.
+func (p *SlackProvider) finishFileDownload(key string) {
+	p.pendingDownloadsMu.Lock()
+	delete(p.pendingDownloads, key)
+	p.pendingDownloadsMu.Unlock()
+}
+
+// downloadMessageFiles caches the files, thumbnails and durations of a message, then stores
+// its updated attachments and emits them.
+func (p *SlackProvider) downloadMessageFiles(job fileDownload) {
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+	if client == nil {
+		return
+	}
+	cacheDir, err := attachmentCacheDir()
+	if err != nil {
+		p.log("SlackProvider.downloadMessageFiles: WARNING - %v\n", err)
+		return
+	}
+
+	for _, file := range job.files {
+		if !isDownloadableFile(file) {
+			continue
+		}
+		if downloadURL := slackDownloadURL(file); downloadURL != "" {
+			if _, err := p.downloadToCache(client, downloadURL, attachmentCachePath(cacheDir, file, "file", slackFileExtension(file))); err != nil {
+				p.log("SlackProvider.downloadMessageFiles: WARNING - failed to download file %s: %v\n", file.ID, err)
+			}
+		}
+		if thumbURL := slackThumbnailURL(file); thumbURL != "" {
+			if _, err := p.downloadToCache(client, thumbURL, attachmentCachePath(cacheDir, file, "thumb", ".jpg")); err != nil {
+				p.log("SlackProvider.downloadMessageFiles: WARNING - failed to download thumbnail of %s: %v\n", file.ID, err)
+			}
+		}
+		if att, _ := convertSlackFile(cacheDir, file); att.Type == string(media.TypeAudio) || att.Type == string(media.TypeVoice) || att.Type == string(media.TypeVideo) {
+			p.fileDuration(cacheDir, file)
+		}
+	}
+
+	attachments, _ := p.convertSlackFiles(job.files)
+	encoded := encodeAttachments(attachments)
+	conversationID := job.conversationID
+	if db.DB != nil {
+		var stored models.Message
+		if err := db.DB.Where("protocol_msg_id = ?", job.messageID).First(&stored).Error; err == nil {
+			conversationID = stored.ProtocolConvID
+			if stored.Attachments != encoded {
+				if err := db.DB.Model(&models.Message{}).Where("id = ?", stored.ID).Update("attachments", encoded).Error; err != nil {
+					p.log("SlackProvider.downloadMessageFiles: failed to update attachments of message %s: %v\n", job.messageID, err)
+				}
+			}
+		}
+	}
+
+	p.log("SlackProvider.downloadMessageFiles: cached %d file(s) of message %s in %s\n", len(attachments), job.messageID, conversationID)
+	p.emitEvent(core.AttachmentUpdateEvent{
+		ConversationID: conversationID,
+		MessageID:      job.messageID,
+		Attachments:    attachments,
+	})
+}
+
+// downloadToCache downloads a private Slack URL to cachePath with client, which sends the token
+// (and the d cookie of client tokens). It returns nil data when the file was already cached.
+func (p *SlackProvider) downloadToCache(client *slack.Client, downloadURL, cachePath string) ([]byte, error) {
+	if _, err := os.Stat(cachePath); err == nil {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	if err := client.GetFile(downloadURL, &buf); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+	if len(data) > 0 && media.BaseMIME(media.DetectMIME(data, "")) == "text/html" && !strings.HasSuffix(cachePath, ".html") {
+		// Slack answers with its login page when the credentials are refused
+		return nil, fmt.Errorf("slack returned a login page instead of the file")
+	}
+
+	// Write to a temporary file first so that interrupted downloads are not cached
+	tmpPath := cachePath + ".part"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write attachment file: %w", err)
+	}
+	if err := os.Rename(tmpPath, cachePath); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to save attachment file: %w", err)
+	}
+	p.log("SlackProvider.downloadToCache: cached %d bytes to %s\n", len(data), cachePath)
+	return data, nil
+}
+
+// cachedFileDuration returns the cached duration in seconds of an audio or video file.
+func cachedFileDuration(cacheDir string, file slack.File) (uint32, bool) {
+	data, err := os.ReadFile(attachmentCachePath(cacheDir, file, "duration", ".txt"))
+	if err != nil {
+		return 0, false
+	}
+	seconds, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32)
+	return uint32(seconds), true
+}
+
+// fileDuration returns the duration in seconds of an audio or video file. slack-go does not
+// decode duration_ms, so files.info is called once per file and the result kept next to the file.
+func (p *SlackProvider) fileDuration(cacheDir string, file slack.File) uint32 {
+	if seconds, ok := cachedFileDuration(cacheDir, file); ok {
+		return seconds
+	}
+
+	durationMs, err := p.fetchFileDurationMs(file.ID)
+	if err != nil {
+		p.log("SlackProvider.fileDuration: WARNING - failed to get the duration of %s: %v\n", file.ID, err)
+		return 0
+	}
+	seconds := uint32((durationMs + 500) / 1000)
+	durationPath := attachmentCachePath(cacheDir, file, "duration", ".txt")
+	if err := os.WriteFile(durationPath, []byte(strconv.FormatUint(uint64(seconds), 10)), 0644); err != nil {
+		p.log("SlackProvider.fileDuration: WARNING - failed to cache the duration of %s: %v\n", file.ID, err)
+	}
+	return seconds
+}
+
+// fetchFileDurationMs calls files.info and returns the duration_ms of a file (0 if it has none).
+func (p *SlackProvider) fetchFileDurationMs(fileID string) (int64, error) {
+	p.mu.RLock()
+	token, _ := p.config.GetString("token")
+	httpClient := p.httpClient
+	p.mu.RUnlock()
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("file", fileID)
+	var info fileInfoResponse
+	err := p.callAPI("files.info", priorityBackground, func() error {
+		resp, err := httpClient.PostForm(fileInfoURL, form)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if err := rateLimitError(resp); err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("files.info returned %s", resp.Status)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+			return fmt.Errorf("failed to decode files.info response: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !info.OK {
+		return 0, fmt.Errorf("files.info failed")
+	}
+	return info.File.DurationMs, nil
+}
+
+// encodeAttachments stores attachments as the JSON array of Message.Attachments ("" when empty).
+func encodeAttachments(attachments []models.Attachment) string {
+	if len(attachments) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(attachments)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// progressReader reports the progress of an upload as the file is read.
+type progressReader struct {
+	reader   io.Reader
+	total    int64
+	sent     int64
+	lastStep int
+	report   func(sent, total int64, progress int)
+}
+
+// Read reads from the underlying reader and reports every uploadProgressStep percent.
+func (r *progressReader) Read(buf []byte) (int, error) {
+	n, err := r.reader.Read(buf)
+	r.sent += int64(n)
+	if r.total > 0 {
+		progress := int(r.sent * 100 / r.total)
+		if progress >= r.lastStep+uploadProgressStep || (progress == 100 && r.lastStep < 100) {
+			r.lastStep = progress
+			r.report(r.sent, r.total, progress)
+		}
+	}
+	return n, err
+}
+
+// SendFile uploads a file to a conversation with the external upload flow
+// (files.getUploadURLExternal, upload, files.completeUploadExternal), reporting the
+// progress with UploadProgressEvents.
+func (p *SlackProvider) SendFile(conversationID string, file *core.Attachment, threadID *string) (*models.Message, error) {
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("slack client not initialized")
+	}
+	if file == nil || len(file.Data) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+
+	channelID, err := p.channelIDForConversation(client, conversationID, priorityInteractive)
+	if err != nil {
+		return nil, err
+	}
+
+	total := int64(len(file.Data))
+	progress := core.UploadProgressEvent{
+		ConversationID: conversationID,
+		FileName:       file.FileName,
+		TotalBytes:     total,
+	}
+	fail := func(err error) (*models.Message, error) {
+		progress.Error = err.Error()
+		p.emitEvent(progress)
+		return nil, err
+	}
+
+	ctx := context.Background()
+	var upload *slack.GetUploadURLExternalResponse
+	err = p.limiter.do(ctx, "files.getUploadURLExternal", priorityInteractive, func() error {
+		var err error
+		upload, err = client.GetUploadURLExternalContext(ctx, slack.GetUploadURLExternalParameters{
+			FileName: file.FileName,
+			FileSize: len(file.Data),
+		})
+		return err
+	})
+	if err != nil {
+		return fail(fmt.Errorf("failed to get an upload URL: %w", err))
+	}
+	p.log("SlackProvider.SendFile: uploading %s (%d bytes) as %s\n", file.FileName, total, upload.FileID)
+
+	reader := &progressReader{
+		reader: bytes.NewReader(file.Data),
+		total:  total,
+		report: func(sent, total int64, percent int) {
+			progress.BytesSent = sent
+			progress.Progress = percent
+			p.emitEvent(progress)
+		},
+	}
+	if err := client.UploadToURL(ctx, slack.UploadToURLParameters{
+		UploadURL: upload.UploadURL,
+		Reader:    reader,
+		Filename:  file.FileName,
+	}); err != nil {
+		return fail(fmt.Errorf("failed to upload %s: %w", file.FileName, err))
+	}
+
+	complete := slack.CompleteUploadExternalParameters{
+		Files:   []slack.FileSummary{{ID: upload.FileID, Title: file.FileName}},
+		Channel: channelID,
+	}
+	if threadID != nil {
+		root := p.threadRoot(conversationID, *threadID)
+		threadID = &root
+		complete.ThreadTimestamp = root
+	}
+	err = p.limiter.do(ctx, "files.completeUploadExternal", priorityInteractive, func() error {
+		_, err := client.CompleteUploadExternalContext(ctx, complete)
+		return err
+	})
+	if err != nil {
+		return fail(fmt.Errorf("failed to share %s: %w", file.FileName, err))
+	}
+
+	progress.BytesSent = total
+	progress.Progress = 100
+	progress.Done = true
+	p.emitEvent(progress)
+
+	// Sharing is asynchronous: the timestamp of the message is known once the file is shared
+	timestamp := p.waitForFileShare(client, upload.FileID, channelID)
+
+	// Keep the sent file in the attachment cache
+	mimeType := file.MimeType
+	if mimeType == "" {
+		mimeType = media.DetectMIME(file.Data, file.FileName)
+	}
+	attachment := models.Attachment{
+		Type:     string(media.Classify(mimeType, file.Data)),
+		FileName: file.FileName,
+		FileSize: total,
+		MimeType: mimeType,
+	}
+	if cacheDir, err := attachmentCacheDir(); err == nil {
+		ext := filepath.Ext(file.FileName)
+		if ext == "" {
+			ext = media.ExtensionFromMIME(mimeType)
+		}
+		cachePath := attachmentCachePath(cacheDir, slack.File{ID: upload.FileID}, "file", ext)
+		if err := os.WriteFile(cachePath, file.Data, 0644); err == nil {
+			attachment.URL = cachePath
+		}
+	}
+
+	// Get current user info for sender details
+	currentUserID, currentUserName, currentAvatarURL, err := p.getCurrentUserInfo()
+	if err != nil {
+		p.log("SlackProvider.SendFile: WARNING - failed to get current user info: %v\n", err)
+		// Continue without user info - IsFromMe will still be true
+	}
+
+	sentMessage := &models.Message{
+		ProtocolMsgID:   upload.FileID,
+		ProtocolConvID:  conversationID,
+		SenderID:        currentUserID,
+		SenderName:      currentUserName,
+		SenderAvatarURL: currentAvatarURL,
+		Timestamp:       time.Now(),
+		IsFromMe:        true,
+		ThreadID:        threadID,
+		Attachments:     encodeAttachments([]models.Attachment{attachment}),
+	}
+	if timestamp == "" {
+		// Without the message timestamp, the real-time echo of the message will store it
+		p.log("SlackProvider.SendFile: WARNING - file %s shared but its message was not found yet\n", upload.FileID)
+		return sentMessage, nil
+	}
+	sentMessage.ProtocolMsgID = timestamp
+	sentMessage.Timestamp = parseSlackTimestamp(timestamp)
+
+	stored := false
+	if db.DB != nil && !p.isMessageStored(conversationID, timestamp) {
+		if err := db.DB.Create(sentMessage).Error; err != nil {
+			p.log("SlackProvider.SendFile: Failed to store sent message %s: %v\n", timestamp, err)
+		} else {
+			stored = true
+		}
+	}
+	p.emitEvent(core.MessageEvent{Message: *sentMessage})
+	if threadID != nil && stored {
+		p.applyThreadReply(conversationID, *sentMessage)
+	}
+	return sentMessage, nil
+}
+
+// waitForFileShare polls files.info until the file is shared in the channel and returns the
+// timestamp of its message ("" if it is not shared in time).
+func (p *SlackProvider) waitForFileShare(client *slack.Client, fileID, channelID string) string {
+	for attempt := 0; attempt < shareLookupAttempts; attempt++ {
+		var info *slack.File
+		err := p.callAPI("files.info", priorityInteractive, func() error {
+			var err error
+			info, _, _, err = client.GetFileInfo(fileID, 0, 0)
+			return err
+		})
+		if err == nil && info != nil {
+			for _, shares := range []map[string][]slack.ShareFileInfo{info.Shares.Public, info.Shares.Private} {
+				if list := shares[channelID]; len(list) > 0 && list[0].Ts != "" {
+					return list[0].Ts
+				}
+			}
+		}
+		time.Sleep(shareLookupDelay)
+	}
+	return ""
+}
@@ -16,16 +16,24 @@ func (p *SlackProvider) CreateGroup(groupName string, participantIDs []string) (
 		return nil, fmt.Errorf("slack client not initialized")
 	}
 
-	channel, err := p.client.CreateConversation(slack.CreateConversationParams{
-		ChannelName: groupName,
-		IsPrivate:   false,
+	var channel *slack.Channel
+	err := p.callAPI("conversations.create", priorityInteractive, func() error {
+		var err error
+		channel, err = p.client.CreateConversation(slack.CreateConversationParams{
+			ChannelName: groupName,
+			IsPrivate:   false,
+		})
+		return err
 	})
 	if err != nil {
 		return nil, err
 	}
 
 	if len(participantIDs) > 0 {
-		_, err = p.client.InviteUsersToConversation(channel.ID, participantIDs...)
+		err = p.callAPI("conversations.invite", priorityInteractive, func() error {
+			_, err := p.client.InviteUsersToConversation(channel.ID, participantIDs...)
+			return err
+		})
 		if err != nil {
 			// Created but failed to invite
 		}
@@ -47,8 +55,10 @@ func (p *SlackProvider) UpdateGroupName(conversationID string, newName string) e
 		return fmt.Errorf("slack client not initialized")
 	}
 
-	_, err := p.client.RenameConversation(conversationID, newName)
-	return err
+	return p.callAPI("conversations.rename", priorityInteractive, func() error {
+		_, err := p.client.RenameConversation(conversationID, newName)
+		return err
+	})
 }
 
 // AddGroupParticipants adds users to a channel.
@@ -60,8 +70,10 @@ func (p *SlackProvider) AddGroupParticipants(conversationID string, participantI
 		return fmt.Errorf("slack client not initialized")
 	}
 
-	_, err := p.client.InviteUsersToConversation(conversationID, participantIDs...)
-	return err
+	return p.callAPI("conversations.invite", priorityInteractive, func() error {
+		_, err := p.client.InviteUsersToConversation(conversationID, participantIDs...)
+		return err
+	})
 }
 
 // RemoveGroupParticipants kicks users from a channel.
@@ -74,7 +86,9 @@ func (p *SlackProvider) RemoveGroupParticipants(conversationID string, participa
 	}
 
 	for _, user := range participantIDs {
-		err := p.client.KickUserFromConversation(conversationID, user)
+		err := p.callAPI("conversations.kick", priorityInteractive, func() error {
+			return p.client.KickUserFromConversation(conversationID, user)
+		})
 		if err != nil {
 			return err
 		}
@@ -91,8 +105,10 @@ func (p *SlackProvider) LeaveGroup(conversationID string) error {
 		return fmt.Errorf("slack client not initialized")
 	}
 
-	_, err := p.client.LeaveConversation(conversationID)
-	return err
+	return p.callAPI("conversations.leave", priorityInteractive, func() error {
+		_, err := p.client.LeaveConversation(conversationID)
+		return err
+	})
 }
 
 // PromoteGroupAdmins - Not supported on Slack
@@ -109,10 +125,12 @@ func (p *SlackProvider) DemoteGroupAdmins(conversationID string, participantIDs
 // For DMs (conversationID starting with "D"), it extracts participants from conversation info.
 // For channels/groups, it uses GetUsersInConversation.
 func (p *SlackProvider) GetGroupParticipants(conversationID string) ([]models.GroupParticipant, error) {
+	// The client is copied rather than locked: the calls may wait for the rate limits
 	p.mu.RLock()
-	defer p.mu.RUnlock()
+	client := p.client
+	p.mu.RUnlock()
 
-	if p.client == nil {
+	if client == nil {
 		return nil, fmt.Errorf("slack client not initialized")
 	}
 
@@ -120,10 +138,15 @@ func (p *SlackProvider) GetGroupParticipants(conversationID string) ([]models.Gr
 	if len(conversationID) > 0 && conversationID[0] == 'D' {
 		// For DMs, use conversations.info to get the channel info
 		// This includes the user IDs in the conversation
-		channelInfo, err := p.client.GetConversationInfo(&slack.GetConversationInfoInput{
-			ChannelID:         conversationID,
-			IncludeLocale:     false,
-			IncludeNumMembers: false,
+		var channelInfo *slack.Channel
+		err := p.callAPI("conversations.info", priorityInteractive, func() error {
+			var err error
+			channelInfo, err = client.GetConversationInfo(&slack.GetConversationInfoInput{
+				ChannelID:         conversationID,
+				IncludeLocale:     false,
+				IncludeNumMembers: false,
+			})
+			return err
 		})
 		if err != nil {
 			// If we can't get conversation info, return empty list
@@ -137,10 +160,9 @@ func (p *SlackProvider) GetGroupParticipants(conversationID string) ([]models.Gr
 		var participants []models.GroupParticipant
 
 		// Get current user ID
-		authTest, err := p.client.AuthTest()
-		if err == nil && authTest != nil {
+		if selfID := p.GetSelfUserID(); selfID != "" {
 			participants = append(participants, models.GroupParticipant{
-				UserID:  authTest.UserID,
+				UserID:  selfID,
 				IsAdmin: false,
 			})
 		}
@@ -156,12 +178,29 @@ func (p *SlackProvider) GetGroupParticipants(conversationID string) ([]models.Gr
 		return participants, nil
 	}
 
-	// For channels/groups, use GetUsersInConversation
-	userIDs, _, err := p.client.GetUsersInConversation(&slack.GetUsersInConversationParameters{
-		ChannelID: conversationID,
-	})
-	if err != nil {
-		return nil, err
+	// For channels/groups, use GetUsersInConversation, following the pagination cursor
+	var userIDs []string
+	cursor := ""
+	for {
+		var page []string
+		var nextCursor string
+		err := p.callAPI("conversations.members", priorityInteractive, func() error {
+			var err error
+			page, nextCursor, err = client.GetUsersInConversation(&slack.GetUsersInConversationParameters{
+				ChannelID: conversationID,
+				Cursor:    cursor,
+				Limit:     1000,
+			})
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, page...)
+		if nextCursor == "" {
+			break
+		}
+		cursor = nextCursor
 	}
 
 	var participants []models.GroupParticipant
@@ -188,6 +227,6 @@ func (p *SlackProvider) JoinGroupByInviteLink(inviteLink string) (*models.Conver
 	return nil, fmt.Errorf("not supported on this provider")
 }
 
-func (p *SlackProvider) JoinGroupByInviteMessage(inviteMessageID string) (*models.Conversation, error) {
+func (p *SlackProvider) JoinGroupByInviteMessage(conversationID string, inviteMessageID string) (*models.Conversation, error) {
 	return nil, fmt.Errorf("not supported on this provider")
 }
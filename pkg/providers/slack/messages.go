@@ -5,7 +5,6 @@ import (
 	"Loom/pkg/core"
 	"Loom/pkg/db"
 	"Loom/pkg/models"
-	"bytes"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -81,7 +80,12 @@ func (p *SlackProvider) getCurrentUserInfo() (userID string, userName string, av
 		return "", "", "", fmt.Errorf("slack client not initialized")
 	}
 
-	authTest, err := client.AuthTest()
+	var authTest *slack.AuthTestResponse
+	err = p.callAPI("auth.test", priorityInteractive, func() error {
+		var err error
+		authTest, err = client.AuthTest()
+		return err
+	})
 	if err != nil || authTest == nil {
 		return "", "", "", fmt.Errorf("failed to get current user: %w", err)
 	}
@@ -93,7 +97,12 @@ func (p *SlackProvider) getCurrentUserInfo() (userID string, userName string, av
 	p.currentUserIDMu.Unlock()
 
 	// Get user info and cache it
-	user, err := client.GetUserInfo(userID)
+	var user *slack.User
+	err = p.callAPI("users.info", priorityInteractive, func() error {
+		var err error
+		user, err = client.GetUserInfo(userID)
+		return err
+	})
 	if err == nil && user != nil {
 		// Cache the user info
 		p.userCacheMu.Lock()
@@ -124,8 +133,35 @@ func (p *SlackProvider) getCurrentUserInfo() (userID string, userName string, av
 	return userID, userName, avatarURL, nil
 }
 
+// GetSelfUserID returns the Slack user ID of the authenticated user, set by Connect.
+// It returns "" before the first connection and never calls the Slack API.
+func (p *SlackProvider) GetSelfUserID() string {
+	p.currentUserIDMu.RLock()
+	defer p.currentUserIDMu.RUnlock()
+	return p.currentUserID
+}
+
+// GetSelfDisplayName returns the name of the authenticated user.
+func (p *SlackProvider) GetSelfDisplayName() string {
+	_, userName, _, err := p.getCurrentUserInfo()
+	if err != nil {
+		p.log("SlackProvider.GetSelfDisplayName: WARNING - failed to get current user: %v\n", err)
+		return ""
+	}
+	return userName
+}
+
 // SendMessage sends a text message to a given conversation.
 func (p *SlackProvider) SendMessage(conversationID string, text string, file *core.Attachment, threadID *string) (*models.Message, error) {
+	if file != nil {
+		return p.SendFile(conversationID, file, threadID)
+	}
+	return p.sendText(conversationID, text, threadID, false)
+}
+
+// sendText posts a text message, in a thread if threadID is not nil.
+// alsoSendToChannel also shows a thread reply in the conversation.
+func (p *SlackProvider) sendText(conversationID string, text string, threadID *string, alsoSendToChannel bool) (*models.Message, error) {
 	p.mu.RLock()
 	client := p.client
 	p.mu.RUnlock()
@@ -134,18 +170,26 @@ func (p *SlackProvider) SendMessage(conversationID string, text string, file *co
 		return nil, fmt.Errorf("slack client not initialized")
 	}
 
-	if file != nil {
-		return p.SendFile(conversationID, file, threadID)
-	}
-
+	formatted := p.formatOutgoingText(text)
 	opts := []slack.MsgOption{
-		slack.MsgOptionText(text, false),
+		slack.MsgOptionText(formatted, false),
 	}
 	if threadID != nil {
-		opts = append(opts, slack.MsgOptionTS(*threadID))
+		// Replies to a reply go to the thread of its parent
+		root := p.threadRoot(conversationID, *threadID)
+		threadID = &root
+		opts = append(opts, slack.MsgOptionTS(root))
+		if alsoSendToChannel {
+			opts = append(opts, slack.MsgOptionBroadcast())
+		}
 	}
 
-	_, timestamp, err := client.PostMessage(conversationID, opts...)
+	var timestamp string
+	err := p.callAPI("chat.postMessage", priorityInteractive, func() error {
+		var err error
+		_, timestamp, err = client.PostMessage(conversationID, opts...)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -162,19 +206,23 @@ func (p *SlackProvider) SendMessage(conversationID string, text string, file *co
 	sentMessage := &models.Message{
 		ProtocolMsgID:   timestamp,
 		ProtocolConvID:  conversationID,
-		Body:            text,
 		SenderID:        currentUserID,
 		SenderName:      currentUserName,
 		SenderAvatarURL: currentAvatarURL,
 		Timestamp:       ts,
 		IsFromMe:        true,
+		ThreadID:        threadID,
+		ThreadBroadcast: threadID != nil && alsoSendToChannel,
 	}
+	p.applyRichText(sentMessage, slack.Msg{Text: formatted})
 
-	// Store message in database
-	if db.DB != nil {
+	// Store message in database, unless its real-time echo already stored (and counted) it
+	stored := false
+	if db.DB != nil && !p.isMessageStored(conversationID, timestamp) {
 		if err := db.DB.Create(sentMessage).Error; err != nil {
 			p.log("SlackProvider.SendMessage: Failed to store sent message %s: %v\n", timestamp, err)
 		} else {
+			stored = true
 			p.log("SlackProvider.SendMessage: Stored sent message %s to database\n", timestamp)
 		}
 	}
@@ -187,61 +235,18 @@ func (p *SlackProvider) SendMessage(conversationID string, text string, file *co
 		p.log("SlackProvider.SendMessage: WARNING - Failed to emit MessageEvent (channel full) for sent message %s\n", timestamp)
 	}
 
+	// Count the reply on its thread (the real-time echo of the message is then already stored)
+	if threadID != nil && stored {
+		p.applyThreadReply(conversationID, *sentMessage)
+	}
+
 	return sentMessage, nil
 }
 
 // SendReply sends a text message as a reply to another message.
+// Slack has no quoted replies: the reply is posted in the thread of the quoted message.
 func (p *SlackProvider) SendReply(conversationID string, text string, quotedMessageID string) (*models.Message, error) {
-	return p.SendMessage(conversationID, text, nil, &quotedMessageID)
-}
-
-// SendFile sends a file to a given conversation without text.
-func (p *SlackProvider) SendFile(conversationID string, file *core.Attachment, threadID *string) (*models.Message, error) {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-
-	if p.client == nil {
-		return nil, fmt.Errorf("slack client not initialized")
-	}
-
-	params := slack.UploadFileV2Parameters{
-		Channel:  conversationID,
-		File:     file.FileName,
-		Reader:   bytes.NewReader(file.Data),
-		FileSize: file.FileSize,
-		Filename: file.FileName,
-	}
-	if threadID != nil {
-		params.ThreadTimestamp = *threadID
-	}
-
-	fileUpload, err := p.client.UploadFileV2(params)
-	if err != nil {
-		return nil, err
-	}
-
-	// UploadFileV2 returns a generic File object, not a message ts.
-	// This is a known limitation of V2 upload helper not returning the message ts immediately easily
-	// without extra call or if we assume it creates one.
-	// For now we use the file ID as a placeholder if we must return something unique.
-
-	// Get current user info for sender details
-	currentUserID, currentUserName, currentAvatarURL, err := p.getCurrentUserInfo()
-	if err != nil {
-		p.log("SlackProvider.SendFile: WARNING - failed to get current user info: %v\n", err)
-		// Continue without user info - IsFromMe will still be true
-	}
-
-	return &models.Message{
-		ProtocolMsgID:   fileUpload.ID, // Warning based on above limitation
-		ProtocolConvID:  conversationID,
-		Body:            fmt.Sprintf("Sent file: %s", file.FileName),
-		SenderID:        currentUserID,
-		SenderName:      currentUserName,
-		SenderAvatarURL: currentAvatarURL,
-		Timestamp:       time.Now(),
-		IsFromMe:        true,
-	}, nil
+	return p.sendText(conversationID, text, &quotedMessageID, false)
 }
 
 // GetConversationHistory retrieves the message history for a specific conversation.
@@ -339,8 +344,13 @@ func (p *SlackProvider) GetConversationHistory(conversationID string, limit int,
 	// to get the actual channel ID (which starts with "D")
 	if len(conversationID) > 0 && conversationID[0] == 'U' {
 		// Open the DM conversation with this user to get the channel ID
-		channel, _, _, err := p.client.OpenConversation(&slack.OpenConversationParameters{
-			Users: []string{conversationID},
+		var channel *slack.Channel
+		err := p.callAPI("conversations.open", priorityInteractive, func() error {
+			var err error
+			channel, _, _, err = p.client.OpenConversation(&slack.OpenConversationParameters{
+				Users: []string{conversationID},
+			})
+			return err
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to open DM conversation with user %s: %w", conversationID, err)
@@ -353,8 +363,11 @@ func (p *SlackProvider) GetConversationHistory(conversationID string, limit int,
 	} else if len(conversationID) > 0 && conversationID[0] == 'D' {
 		// For DM channel IDs, ensure the conversation is open
 		// This is required before we can retrieve message history
-		_, _, _, err := p.client.OpenConversation(&slack.OpenConversationParameters{
-			ChannelID: conversationID,
+		err := p.callAPI("conversations.open", priorityInteractive, func() error {
+			_, _, _, err := p.client.OpenConversation(&slack.OpenConversationParameters{
+				ChannelID: conversationID,
+			})
+			return err
 		})
 		if err != nil {
 			// Log but don't fail - the conversation might already be open
@@ -375,7 +388,12 @@ func (p *SlackProvider) GetConversationHistory(conversationID string, limit int,
 	// But GetConversationHistory is designed for pagination (beforeTimestamp)
 	// For SyncHistory, we'll fetch recent messages and filter client-side
 
-	history, err := p.client.GetConversationHistory(params)
+	var history *slack.GetConversationHistoryResponse
+	err := p.callAPI("conversations.history", priorityInteractive, func() error {
+		var err error
+		history, err = p.client.GetConversationHistory(params)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -468,14 +486,21 @@ func (p *SlackProvider) storeMessagesForConversation(convID string, messages []m
 				batch := toUpdate[i:end]
 				for j := range batch {
 					if err := db.DB.Model(&models.Message{}).Where("id = ?", batch[j].ID).Updates(map[string]interface{}{
-						"body":              batch[j].Body,
-						"timestamp":         batch[j].Timestamp,
-						"is_from_me":        batch[j].IsFromMe,
-						"attachments":       batch[j].Attachments,
-						"is_status_message": batch[j].IsStatusMessage,
-						"is_deleted":        batch[j].IsDeleted,
-						"is_edited":         batch[j].IsEdited,
-						"edited_timestamp":  batch[j].EditedTimestamp,
+						"body":               batch[j].Body,
+						"rich_text":          batch[j].RichText,
+						"rich_content":       batch[j].RichContent,
+						"timestamp":          batch[j].Timestamp,
+						"is_from_me":         batch[j].IsFromMe,
+						"attachments":        batch[j].Attachments,
+						"is_status_message":  batch[j].IsStatusMessage,
+						"is_deleted":         batch[j].IsDeleted,
+						"is_edited":          batch[j].IsEdited,
+						"edited_timestamp":   batch[j].EditedTimestamp,
+						"thread_id":          batch[j].ThreadID,
+						"thread_reply_count": batch[j].ThreadReplyCount,
+						"thread_latest_at":   batch[j].ThreadLatestAt,
+						"thread_users":       batch[j].ThreadUsers,
+						"thread_broadcast":   batch[j].ThreadBroadcast,
 					}).Error; err != nil {
 						p.log("SlackProvider.storeMessagesForConversation: Failed to update message %s: %v\n", batch[j].ProtocolMsgID, err)
 					}
@@ -490,11 +515,6 @@ func (p *SlackProvider) storeMessagesForConversation(convID string, messages []m
 	return len(messages)
 }
 
-// GetThreads loads all messages in a discussion thread.
-func (p *SlackProvider) GetThreads(parentMessageID string) ([]models.Message, error) {
-	return nil, fmt.Errorf("not implemented: requires conversationID")
-}
-
 // EditMessage edits an existing message.
 func (p *SlackProvider) EditMessage(conversationID string, messageID string, newText string) (*models.Message, error) {
 	p.mu.RLock()
@@ -504,18 +524,23 @@ func (p *SlackProvider) EditMessage(conversationID string, messageID string, new
 		return nil, fmt.Errorf("slack client not initialized")
 	}
 
-	_, _, _, err := p.client.UpdateMessage(conversationID, messageID, slack.MsgOptionText(newText, false))
+	formatted := p.formatOutgoingText(newText)
+	err := p.callAPI("chat.update", priorityInteractive, func() error {
+		_, _, _, err := p.client.UpdateMessage(conversationID, messageID, slack.MsgOptionText(formatted, false))
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &models.Message{
+	edited := &models.Message{
 		ProtocolMsgID:  messageID,
 		ProtocolConvID: conversationID,
-		Body:           newText,
 		Timestamp:      time.Now(), // rough estimate
 		IsFromMe:       true,
-	}, nil
+	}
+	p.applyRichText(edited, slack.Msg{Text: formatted})
+	return edited, nil
 }
 
 // DeleteMessage deletes a message.
@@ -527,11 +552,20 @@ func (p *SlackProvider) DeleteMessage(conversationID string, messageID string) e
 		return fmt.Errorf("slack client not initialized")
 	}
 
-	_, _, err := p.client.DeleteMessage(conversationID, messageID)
-	return err
+	return p.callAPI("chat.delete", priorityInteractive, func() error {
+		_, _, err := p.client.DeleteMessage(conversationID, messageID)
+		return err
+	})
 }
 
+// convertSlackMessage converts a Slack message, queuing the download of its files that are not cached yet.
 func (p *SlackProvider) convertSlackMessage(msg slack.Message, conversationID string) models.Message {
+	return p.convertMessage(msg, conversationID, true)
+}
+
+// convertMessage converts a Slack message. Files not cached yet link to Slack; their download
+// is queued if downloadFiles is set.
+func (p *SlackProvider) convertMessage(msg slack.Message, conversationID string, downloadFiles bool) models.Message {
 	ts := parseSlackTimestamp(msg.Timestamp)
 
 	// Get sender name and avatar
@@ -544,15 +578,10 @@ func (p *SlackProvider) convertSlackMessage(msg slack.Message, conversationID st
 		p.userCacheMu.RUnlock()
 
 		if !cached {
-			// Try to get user info from Slack API
+			// Try to get user info from Slack API (users of shared channels may belong to other orgs)
 			var err error
-			user, err = p.client.GetUserInfo(msg.User)
-			if err == nil && user != nil {
-				// Cache the user info
-				p.userCacheMu.Lock()
-				p.userCache[msg.User] = user
-				p.userCacheMu.Unlock()
-			} else {
+			user, err = p.lookupUser(p.client, msg.User, priorityBackground)
+			if err != nil || user == nil {
 				// Fallback: use user ID if we can't get user info
 				senderName = msg.User
 				p.log("SlackProvider.convertSlackMessage: WARNING - failed to get user info for %s: %v\n", msg.User, err)
@@ -597,7 +626,12 @@ func (p *SlackProvider) convertSlackMessage(msg slack.Message, conversationID st
 			isFromMe = (cachedUserID == msg.User)
 		} else if p.client != nil {
 			// Not cached, get from API and cache it
-			authTest, err := p.client.AuthTest()
+			var authTest *slack.AuthTestResponse
+			err := p.callAPI("auth.test", priorityBackground, func() error {
+				var err error
+				authTest, err = p.client.AuthTest()
+				return err
+			})
 			if err == nil && authTest != nil {
 				// Cache the user ID
 				p.currentUserIDMu.Lock()
@@ -631,17 +665,25 @@ func (p *SlackProvider) convertSlackMessage(msg slack.Message, conversationID st
 		}
 	}
 
-	return models.Message{
+	attachments, pending := p.convertSlackFiles(msg.Files)
+	if pending && downloadFiles {
+		p.queueFileDownloads(conversationID, msg.Timestamp, msg.Files)
+	}
+
+	converted := models.Message{
 		ProtocolMsgID:   msg.Timestamp,
 		ProtocolConvID:  conversationID,
-		Body:            msg.Text,
 		SenderID:        msg.User,
 		SenderName:      senderName,
 		SenderAvatarURL: senderAvatarURL,
 		Timestamp:       ts,
 		IsFromMe:        isFromMe,
 		Reactions:       reactions,
+		Attachments:     encodeAttachments(attachments),
 	}
+	p.applyRichText(&converted, msg.Msg)
+	applyThreadMetadata(&converted, msg.Msg)
+	return converted
 }
 
 func parseSlackTimestamp(tsStr string) time.Time {
@@ -694,16 +736,14 @@ func (p *SlackProvider) enrichMessagesWithSenderInfo(messages []models.Message)
 			// Try to get user info from Slack API
 			var err error
 			p.mu.RLock()
-			if p.client != nil {
-				user, err = p.client.GetUserInfo(msg.SenderID)
-			}
+			client := p.client
 			p.mu.RUnlock()
-
-			if err == nil && user != nil {
-				// Cache the user info
-				p.userCacheMu.Lock()
-				p.userCache[msg.SenderID] = user
-				p.userCacheMu.Unlock()
+			if client != nil {
+				// Cached by lookupUser
+				user, err = p.lookupUser(client, msg.SenderID, priorityBackground)
+			}
+			if err != nil {
+				user = nil
 			}
 		}
 
@@ -757,7 +797,9 @@ func (p *SlackProvider) AddReaction(conversationID string, messageID string, emo
 		Channel:   conversationID,
 		Timestamp: messageID,
 	}
-	err := p.client.AddReaction(emoji, item)
+	err := p.callAPI("reactions.add", priorityInteractive, func() error {
+		return p.client.AddReaction(emoji, item)
+	})
 	// Ignore "already_reacted" error as it's not really an error for our use case
 	if err != nil && strings.Contains(err.Error(), "already_reacted") {
 		return nil
@@ -778,7 +820,9 @@ func (p *SlackProvider) RemoveReaction(conversationID string, messageID string,
 		Channel:   conversationID,
 		Timestamp: messageID,
 	}
-	err := p.client.RemoveReaction(emoji, item)
+	err := p.callAPI("reactions.remove", priorityInteractive, func() error {
+		return p.client.RemoveReaction(emoji, item)
+	})
 	// Ignore "no_reaction" error as it's not really an error for our use case
 	if err != nil && strings.Contains(err.Error(), "no_reaction") {
 		return nil
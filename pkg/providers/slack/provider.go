@@ -6,10 +6,12 @@ import (
 	"Loom/pkg/db"
 	"Loom/pkg/logging"
 	"Loom/pkg/models"
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/slack-go/slack"
@@ -17,22 +19,45 @@ import (
 
 // SlackProvider implements the core.Provider interface for Slack.
 type SlackProvider struct {
-	config            core.ProviderConfig
-	client            *slack.Client
-	mu                sync.RWMutex
-	logger            *logging.ProviderLogger
-	userCache         map[string]*slack.User // Cache for user info to avoid repeated API calls
-	userCacheMu       sync.RWMutex
-	emojiCache        map[string]string // Cache for emoji names to URLs (e.g., "calendar" -> "https://...")
-	emojiCacheMu      sync.RWMutex
-	eventChan         chan core.ProviderEvent // Channel for emitting events
-	stopChan          chan struct{}           // Channel to signal polling goroutine to stop
-	statusCache       map[string]userStatus   // Cache of last known status for each user
-	statusCacheMu     sync.RWMutex            // Mutex for status cache
-	lastPollTimestamp time.Time               // Last timestamp when we polled for new messages
-	lastPollMu        sync.RWMutex            // Mutex for lastPollTimestamp
-	currentUserID     string                  // Cached current user ID
-	currentUserIDMu   sync.RWMutex            // Mutex for currentUserID
+	config          core.ProviderConfig
+	instanceID      string // Provider instance ID (e.g. "slack-1"), keys the data stored per instance
+	client          *slack.Client
+	mu              sync.RWMutex
+	logger          *logging.ProviderLogger
+	userCache       map[string]*slack.User // Cache for user info to avoid repeated API calls
+	userCacheMu     sync.RWMutex
+	emojiCache      map[string]string // Cache for emoji names to URLs (e.g., "calendar" -> "https://...")
+	emojiPaths      map[string]string // Cached emoji images by emoji name (see emoji.go)
+	emojiCacheMu    sync.RWMutex
+	channelNames    map[string]string // Cache of channel names by channel ID, for #channel mentions
+	channelNamesMu  sync.RWMutex
+	channelTeams    map[string]string // Cache of the workspace (context_team_id) of the channels, for Enterprise Grid
+	channelTeamsMu  sync.RWMutex
+	eventChan       chan core.ProviderEvent // Channel for emitting events
+	stopChan        chan struct{}           // Channel to signal polling goroutine to stop
+	statusCache     map[string]userStatus   // Cache of last known status for each user
+	statusCacheMu   sync.RWMutex            // Mutex for status cache
+	currentUserID   string                  // Cached current user ID
+	currentUserIDMu sync.RWMutex            // Mutex for currentUserID
+	httpClient      *http.Client            // HTTP client of the Slack client (sends the d cookie)
+	dCookie         string                  // d cookie of client tokens, from the configuration or its shared session
+	teamID          string                  // Workspace of the instance: the "team_id" of the configuration, else that of the token
+	enterpriseID    string                  // Enterprise Grid org of the workspace ("" outside Enterprise Grid)
+	teamMu          sync.RWMutex            // Mutex for teamID and enterpriseID
+	rtm             *slack.RTM              // RTM connection (user and client tokens)
+	realtimeCancel  context.CancelFunc      // Stops the real-time connection
+	dmUsers         map[string]string       // DM channel ID -> user ID of the other person
+	disconnectedAt  time.Time               // When the real-time connection was lost (zero while connected)
+	realtimeMu      sync.Mutex              // Mutex for the real-time fields
+	limiter         *rateLimiter            // Schedules the API calls within the Slack rate limits
+	syncing         atomic.Bool             // Set while SyncHistory runs
+
+	fileDownloads      chan fileDownload // Messages whose files are downloaded in the background (see files.go)
+	downloadsStop      chan struct{}     // Stops the download workers of the connection (nil while stopped)
+	downloadsWG        sync.WaitGroup    // Running download workers
+	downloadsMu        sync.Mutex        // Mutex for downloadsStop
+	pendingDownloads   map[string]bool   // Queued messages, by conversation ID + "/" + message ID
+	pendingDownloadsMu sync.Mutex        // Mutex for pendingDownloads
 }
 
 // userStatus represents the cached status information for a user
@@ -49,28 +74,55 @@ type cookieTransport struct {
 }
 
 func (t *cookieTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	// Log the cookie being sent (first 20 chars only for security)
-	cookiePreview := t.Cookie
-	if len(cookiePreview) > 20 {
-		cookiePreview = cookiePreview[:20] + "..."
+	// The d cookie signs in the whole Slack session: it is only sent to Slack itself,
+	// not to the other hosts of downloads, redirects and link previews
+	if !isSlackHost(req.URL.Hostname()) {
+		return t.Transport.RoundTrip(req)
 	}
+	req = req.Clone(req.Context())
 	req.Header.Add("Cookie", t.Cookie)
 	// Some xoxc endpoints also check for d-s cookie, but usually d is the main auth one.
 	return t.Transport.RoundTrip(req)
 }
 
+// isSlackHost reports whether host is slack.com or one of its subdomains.
+func isSlackHost(host string) bool {
+	host = strings.ToLower(host)
+	return host == "slack.com" || strings.HasSuffix(host, ".slack.com")
+}
+
+// newCookieClient returns an HTTP client sending the d cookie of a client token to Slack.
+// The cookie may be given with or without its "d=" prefix.
+func newCookieClient(dCookie string) *http.Client {
+	return &http.Client{
+		Transport: &cookieTransport{
+			Transport: http.DefaultTransport,
+			Cookie:    "d=" + strings.TrimPrefix(dCookie, "d="),
+		},
+	}
+}
+
 // Ensure interface compliance
 var _ core.Provider = (*SlackProvider)(nil)
 
 // NewSlackProvider creates a new instance of the SlackProvider.
 func NewSlackProvider() *SlackProvider {
-	return &SlackProvider{
-		userCache:   make(map[string]*slack.User),
-		emojiCache:  make(map[string]string),
-		eventChan:   make(chan core.ProviderEvent, 100), // Buffered channel to avoid blocking
-		stopChan:    make(chan struct{}),
-		statusCache: make(map[string]userStatus),
-	}
+	p := &SlackProvider{
+		userCache:    make(map[string]*slack.User),
+		emojiCache:   make(map[string]string),
+		emojiPaths:   make(map[string]string),
+		channelNames: make(map[string]string),
+		channelTeams: make(map[string]string),
+		eventChan:    make(chan core.ProviderEvent, 100), // Buffered channel to avoid blocking
+		stopChan:     make(chan struct{}),
+		statusCache:  make(map[string]userStatus),
+		dmUsers:      make(map[string]string),
+
+		fileDownloads:    make(chan fileDownload, fileDownloadQueueSize),
+		pendingDownloads: make(map[string]bool),
+	}
+	p.limiter = newRateLimiter(p.reportThrottling)
+	return p
 }
 
 // Init initializes the provider with its configuration.
@@ -83,6 +135,7 @@ func (p *SlackProvider) Init(config core.ProviderConfig) error {
 		instanceID = "slack-1" // Default instance ID
 	}
 	fmt.Printf("SlackProvider.Init: instanceID=%s\n", instanceID)
+	p.instanceID = instanceID
 
 	// Initialize logger
 	logger, err := logging.GetLogger("slack", instanceID)
@@ -136,7 +189,21 @@ func (p *SlackProvider) SetConfig(config core.ProviderConfig) error {
 
 	token, _ := config.GetString("token")
 	dCookie, _ := config.GetString("d_cookie")
-	fmt.Printf("SlackProvider.SetConfig: token present=%v, dCookie present=%v\n", token != "", dCookie != "")
+	if sessionID, _ := config.GetString("session_id"); dCookie == "" && sessionID != "" {
+		// Workspaces attached from a client session share the cookie of the session
+		cookie, err := sessionCookie(sessionID)
+		if err != nil {
+			fmt.Printf("SlackProvider.SetConfig: ERROR - failed to load the d cookie of session %s: %v\n", sessionID, err)
+			return fmt.Errorf("failed to load the d cookie of session %s: %w", sessionID, err)
+		}
+		dCookie = cookie
+	}
+	p.dCookie = dCookie
+	teamID, _ := config.GetString("team_id")
+	p.teamMu.Lock()
+	p.teamID = teamID
+	p.teamMu.Unlock()
+	fmt.Printf("SlackProvider.SetConfig: token present=%v, dCookie present=%v, teamID=%q\n", token != "", dCookie != "", teamID)
 	if token != "" {
 		tokenPreview := token
 		if len(tokenPreview) > 10 {
@@ -157,24 +224,19 @@ func (p *SlackProvider) SetConfig(config core.ProviderConfig) error {
 		opts := []slack.Option{}
 
 		if dCookie != "" {
-			fmt.Printf("SlackProvider.SetConfig: setting up cookie transport with d cookie\n")
-			// Create custom HTTP client that sends the d cookie
-			// Format: "d=xoxd-..." (the user should provide just the value, we add "d=")
-			cookieValue := dCookie
-			// If the user provided "d=xoxd-...", extract just the value
-			if strings.HasPrefix(cookieValue, "d=") {
-				cookieValue = cookieValue[2:]
-			}
-			cookieHeader := fmt.Sprintf("d=%s", cookieValue)
-			fmt.Printf("SlackProvider.SetConfig: cookie header format: d=... (length=%d)\n", len(cookieValue))
-
-			client := &http.Client{
-				Transport: &cookieTransport{
-					Transport: http.DefaultTransport,
-					Cookie:    cookieHeader,
-				},
-			}
+			fmt.Printf("SlackProvider.SetConfig: setting up cookie transport with d cookie (length=%d)\n", len(strings.TrimPrefix(dCookie, "d=")))
+			// Client tokens (xoxc) are only accepted with the d cookie
+			client := newCookieClient(dCookie)
 			opts = append(opts, slack.OptionHTTPClient(client))
+			p.httpClient = client
+		} else {
+			p.httpClient = http.DefaultClient
+		}
+
+		// An app-level token (xapp-) enables Socket Mode for real-time events
+		if appToken, _ := config.GetString("app_token"); appToken != "" {
+			fmt.Printf("SlackProvider.SetConfig: app-level token present, Socket Mode enabled\n")
+			opts = append(opts, slack.OptionAppLevelToken(appToken))
 		}
 
 		p.client = slack.New(token, opts...)
@@ -198,6 +260,17 @@ func (p *SlackProvider) IsAuthenticated() bool {
 	return p.client != nil
 }
 
+// IsConnected returns true if the provider has a client and, while the real-time connection
+// runs, that connection is up.
+func (p *SlackProvider) IsConnected() bool {
+	if !p.IsAuthenticated() {
+		return false
+	}
+	p.realtimeMu.Lock()
+	defer p.realtimeMu.Unlock()
+	return p.realtimeCancel == nil || p.disconnectedAt.IsZero()
+}
+
 // Connect establishes the connection with the remote service.
 func (p *SlackProvider) Connect() error {
 	p.mu.RLock()
@@ -209,15 +282,39 @@ func (p *SlackProvider) Connect() error {
 	}
 
 	p.log("SlackProvider.Connect: performing auth test\n")
-	authInfo, err := p.client.AuthTest()
+	var authInfo *slack.AuthTestResponse
+	err := p.callAPI("auth.test", priorityInteractive, func() error {
+		var err error
+		authInfo, err = p.client.AuthTest()
+		return err
+	})
 	if err != nil {
 		p.log("SlackProvider.Connect: ERROR - auth test failed: %v\n", err)
 		return err
 	}
 	p.log("SlackProvider.Connect: auth test successful, user=%s, team=%s\n", authInfo.User, authInfo.Team)
 
-	// Load emojis after successful connection
-	p.loadEmojis()
+	// Enterprise Grid tokens are valid in all the workspaces of the org, others in their own
+	p.teamMu.Lock()
+	if p.teamID != "" && p.teamID != authInfo.TeamID && authInfo.EnterpriseID == "" {
+		teamID := p.teamID
+		p.teamMu.Unlock()
+		p.log("SlackProvider.Connect: ERROR - the token belongs to team %s, not %s\n", authInfo.TeamID, teamID)
+		return fmt.Errorf("the token belongs to the workspace %s (%s), not to %s", authInfo.Team, authInfo.TeamID, teamID)
+	}
+	if p.teamID == "" {
+		p.teamID = authInfo.TeamID
+	}
+	p.enterpriseID = authInfo.EnterpriseID
+	p.teamMu.Unlock()
+
+	// The user of the token does not change while connected; GetSelfUserID only reads it
+	p.currentUserIDMu.Lock()
+	p.currentUserID = authInfo.UserID
+	p.currentUserIDMu.Unlock()
+
+	// Load the stored emojis, refreshed from Slack when they are stale
+	p.loadEmojis(p.client)
 
 	// Initialize status cache with current statuses
 	p.initializeStatusCache()
@@ -225,151 +322,15 @@ func (p *SlackProvider) Connect() error {
 	// Start polling goroutine for status updates
 	go p.pollStatusUpdates()
 
-	// Initialize last poll timestamp to now
-	p.lastPollMu.Lock()
-	p.lastPollTimestamp = time.Now()
-	p.lastPollMu.Unlock()
+	// Download the files of the messages in the background
+	p.startFileDownloads()
 
-	// Start polling goroutine for messages and reactions
-	go p.pollMessagesAndReactions()
+	// Receive messages, reactions, typing and presence in real time
+	p.startRealtime(p.client, p.config)
 
 	return nil
 }
 
-// loadEmojis fetches and caches Slack emojis (both standard and custom)
-func (p *SlackProvider) loadEmojis() {
-	p.mu.RLock()
-	client := p.client
-	p.mu.RUnlock()
-
-	if client == nil {
-		return
-	}
-
-	p.log("SlackProvider.loadEmojis: fetching emojis from Slack API\n")
-	emojis, err := client.GetEmoji()
-	if err != nil {
-		p.log("SlackProvider.loadEmojis: WARNING - failed to get emojis: %v\n", err)
-		return
-	}
-
-	p.log("SlackProvider.loadEmojis: received %d emojis from API\n", len(emojis))
-
-	p.emojiCacheMu.Lock()
-	defer p.emojiCacheMu.Unlock()
-
-	// Clear existing cache
-	p.emojiCache = make(map[string]string)
-
-	// First pass: populate cache with direct emoji URLs
-	directCount := 0
-	aliasCount := 0
-	for name, url := range emojis {
-		// Skip aliases for now, we'll handle them in the second pass
-		if !strings.HasPrefix(url, "alias:") {
-			p.emojiCache[name] = url
-			directCount++
-		} else {
-			aliasCount++
-		}
-	}
-	p.log("SlackProvider.loadEmojis: first pass - %d direct emojis, %d aliases\n", directCount, aliasCount)
-
-	// Second pass: resolve aliases to their target emoji URLs
-	// Handle chains of aliases (alias -> alias -> emoji)
-	maxIterations := 10 // Prevent infinite loops
-	for iteration := 0; iteration < maxIterations; iteration++ {
-		resolved := false
-		for name, url := range emojis {
-			// Skip if already resolved to a direct URL
-			if cachedURL, exists := p.emojiCache[name]; exists && !strings.HasPrefix(cachedURL, "alias:") {
-				continue
-			}
-
-			if strings.HasPrefix(url, "alias:") {
-				// Extract the target emoji name
-				targetName := strings.TrimPrefix(url, "alias:")
-				// Look up the target emoji's URL (might be another alias or direct URL)
-				if targetURL, exists := p.emojiCache[targetName]; exists {
-					if strings.HasPrefix(targetURL, "alias:") {
-						// Target is also an alias, will be resolved in next iteration
-						p.emojiCache[name] = url
-					} else {
-						// Target is a direct URL, resolve the alias
-						p.emojiCache[name] = targetURL
-						p.log("SlackProvider.loadEmojis: resolved alias '%s' -> '%s' (%s)\n", name, targetName, targetURL)
-						resolved = true
-					}
-				} else {
-					// Target not found yet, might be resolved in next iteration
-					p.emojiCache[name] = url
-				}
-			}
-		}
-		// If no more aliases were resolved, we're done
-		if !resolved {
-			break
-		}
-	}
-
-	// Log any remaining unresolved aliases
-	unresolvedCount := 0
-	for name, url := range p.emojiCache {
-		if strings.HasPrefix(url, "alias:") {
-			targetName := strings.TrimPrefix(url, "alias:")
-			p.log("SlackProvider.loadEmojis: WARNING - unresolved alias '%s' -> '%s' (target not found)\n", name, targetName)
-			unresolvedCount++
-		}
-	}
-
-	p.log("SlackProvider.loadEmojis: loaded %d emojis (%d direct, %d aliases resolved, %d unresolved)\n",
-		len(p.emojiCache), len(emojis)-unresolvedCount, len(p.emojiCache)-len(emojis)+unresolvedCount, unresolvedCount)
-}
-
-// GetEmojiURL returns the URL for a Slack emoji, or empty string if not found
-// emojiName should be without colons (e.g., "calendar" not ":calendar:")
-// Handles aliases by resolving them to their target emoji URLs
-func (p *SlackProvider) GetEmojiURL(emojiName string) string {
-	// Remove colons if present
-	name := strings.TrimPrefix(strings.TrimSuffix(emojiName, ":"), ":")
-
-	p.emojiCacheMu.RLock()
-	defer p.emojiCacheMu.RUnlock()
-
-	url := p.emojiCache[name]
-
-	// If found but it's still an alias reference (shouldn't happen after loadEmojis, but handle it anyway)
-	if url != "" && strings.HasPrefix(url, "alias:") {
-		targetName := strings.TrimPrefix(url, "alias:")
-		url = p.emojiCache[targetName]
-		if url != "" {
-			p.log("SlackProvider.GetEmojiURL: resolved alias '%s' -> '%s' -> %s\n", name, targetName, url)
-		}
-	}
-
-	if url == "" {
-		// Log some sample emoji names from cache for debugging
-		sampleCount := 0
-		sampleNames := make([]string, 0, 5)
-		for cachedName := range p.emojiCache {
-			if sampleCount >= 5 {
-				break
-			}
-			sampleNames = append(sampleNames, cachedName)
-			sampleCount++
-		}
-		p.log("SlackProvider.GetEmojiURL: emoji '%s' not found in cache (cache size: %d, samples: %v)\n",
-			name, len(p.emojiCache), sampleNames)
-	} else if strings.HasPrefix(url, "alias:") {
-		// This shouldn't happen after loadEmojis, but handle it anyway
-		targetName := strings.TrimPrefix(url, "alias:")
-		p.log("SlackProvider.GetEmojiURL: WARNING - emoji '%s' still has unresolved alias '%s'\n", name, targetName)
-	} else {
-		p.log("SlackProvider.GetEmojiURL: found emoji '%s' -> %s\n", name, url)
-	}
-	return url
-}
-
 // initializeStatusCache loads current user statuses into the cache
 func (p *SlackProvider) initializeStatusCache() {
 	p.mu.RLock()
@@ -380,7 +341,7 @@ func (p *SlackProvider) initializeStatusCache() {
 		return
 	}
 
-	users, err := client.GetUsers()
+	users, err := p.listUsers(client, priorityBackground)
 	if err != nil {
 		p.log("SlackProvider.initializeStatusCache: WARNING - failed to get users: %v\n", err)
 		return
@@ -447,13 +408,14 @@ func (p *SlackProvider) determineStatus(presence, statusText, statusEmoji string
 
 // pollStatusUpdates periodically checks for status changes and emits events
 func (p *SlackProvider) pollStatusUpdates() {
-	ticker := time.NewTicker(30 * time.Second) // Poll every 30 seconds
-	defer ticker.Stop()
+	timer := time.NewTimer(statusPollInterval)
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
-			p.checkStatusChanges()
+		case <-timer.C:
+			calls := p.checkStatusChanges()
+			timer.Reset(statusPollDelay(calls))
 		case <-p.stopChan:
 			p.log("SlackProvider.pollStatusUpdates: stopping polling goroutine\n")
 			return
@@ -461,20 +423,30 @@ func (p *SlackProvider) pollStatusUpdates() {
 	}
 }
 
-// checkStatusChanges checks for status changes and emits ContactStatusEvent if changed
-func (p *SlackProvider) checkStatusChanges() {
+// statusPollDelay returns the delay before the next status check, given the number of users.list
+// calls of the last one: large workspaces are polled less often, to use at most half of the
+// users.list rate limit.
+func statusPollDelay(calls int) time.Duration {
+	delay := time.Duration(calls) * time.Minute / time.Duration(tier2.perMinute/2)
+	return max(delay, statusPollInterval)
+}
+
+// checkStatusChanges checks for status changes and emits ContactStatusEvent if changed.
+// It returns the number of users.list calls made.
+func (p *SlackProvider) checkStatusChanges() int {
 	p.mu.RLock()
 	client := p.client
 	p.mu.RUnlock()
 
 	if client == nil {
-		return
+		return 0
 	}
 
-	users, err := client.GetUsers()
+	users, err := p.listUsers(client, priorityBackground)
+	calls := len(users)/usersPageSize + 1
 	if err != nil {
 		p.log("SlackProvider.checkStatusChanges: WARNING - failed to get users: %v\n", err)
-		return
+		return calls
 	}
 
 	p.statusCacheMu.Lock()
@@ -495,10 +467,11 @@ func (p *SlackProvider) checkStatusChanges() {
 			// Status changed, emit event
 			select {
 			case p.eventChan <- core.ContactStatusEvent{
-				UserID:      user.ID,
-				Status:      newStatus,
-				StatusEmoji: newStatusEmoji,
-				StatusText:  newStatusText,
+				UserID:         user.ID,
+				Status:         newStatus,
+				StatusEmoji:    newStatusEmoji,
+				StatusEmojiURL: p.emojiImage(newStatusEmoji),
+				StatusText:     newStatusText,
 			}:
 				p.log("SlackProvider.checkStatusChanges: emitted status change for user %s: %s -> %s (emoji: %s, text: %s)\n",
 					user.ID, cached.status, newStatus, newStatusEmoji, newStatusText)
@@ -514,126 +487,12 @@ func (p *SlackProvider) checkStatusChanges() {
 			}
 		}
 	}
-}
-
-// pollMessagesAndReactions periodically checks for new messages and reactions
-func (p *SlackProvider) pollMessagesAndReactions() {
-	// Temporarily disabled to avoid DB deadlocks
-	// TODO: Re-enable with proper DB connection pooling and transaction management
-	// ticker := time.NewTicker(30 * time.Second) // Poll every 30 seconds to reduce DB load
-	// defer ticker.Stop()
-
-	// for {
-	// 	select {
-	// 	case <-ticker.C:
-	// 		p.checkNewMessagesAndReactions()
-	// 	case <-p.stopChan:
-	// 		p.log("SlackProvider.pollMessagesAndReactions: stopping polling goroutine\n")
-	// 		return
-	// 	}
-	// }
-
-	// Wait for stop signal only
-	<-p.stopChan
-	p.log("SlackProvider.pollMessagesAndReactions: stopping polling goroutine\n")
-}
-
-// checkNewMessagesAndReactions checks for new messages and reactions since last poll
-func (p *SlackProvider) checkNewMessagesAndReactions() {
-	p.mu.RLock()
-	client := p.client
-	p.mu.RUnlock()
-
-	if client == nil {
-		return
-	}
-
-	// Get last poll timestamp
-	p.lastPollMu.RLock()
-	lastPoll := p.lastPollTimestamp
-	p.lastPollMu.Unlock()
-
-	// Get only recently active conversations from DB (messages in last 24 hours)
-	// This avoids checking all conversations and reduces DB load significantly
-	var activeConversationIDs []string
-	if db.DB != nil {
-		recentCutoff := time.Now().Add(-24 * time.Hour)
-		var recentMessages []models.Message
-		if err := db.DB.Where("timestamp >= ?", recentCutoff).
-			Select("DISTINCT protocol_conv_id").
-			Find(&recentMessages).Error; err == nil {
-			for _, msg := range recentMessages {
-				if msg.ProtocolConvID != "" {
-					activeConversationIDs = append(activeConversationIDs, msg.ProtocolConvID)
-				}
-			}
-		}
-	}
-
-	// If no active conversations found, skip this poll cycle
-	if len(activeConversationIDs) == 0 {
-		return
-	}
-
-	// Limit to first 10 active conversations to avoid overwhelming the DB
-	maxConversations := 10
-	if len(activeConversationIDs) > maxConversations {
-		activeConversationIDs = activeConversationIDs[:maxConversations]
-	}
-
-	// Check each active conversation for new messages (with rate limiting to avoid DB deadlocks)
-	for i, conversationID := range activeConversationIDs {
-		// Add delay between conversations to reduce DB contention
-		if i > 0 {
-			time.Sleep(100 * time.Millisecond)
-		}
-
-		// Get recent messages (last 10 messages to reduce load)
-		// We'll filter to only those after lastPoll
-		messages, err := p.getRecentMessages(conversationID, 10, nil)
-		if err != nil {
-			p.log("SlackProvider.checkNewMessagesAndReactions: WARNING - failed to get messages for %s: %v\n", conversationID, err)
-			continue
-		}
-
-		// Filter to only messages after last poll
-		for _, msg := range messages {
-			if msg.Timestamp.After(lastPoll) {
-				// Check if message already exists in DB (to avoid duplicates)
-				// Use a simple query without Preload to reduce DB load
-				var existingMsg models.Message
-				if db.DB != nil {
-					if err := db.DB.Where("protocol_msg_id = ? AND protocol_conv_id = ?", msg.ProtocolMsgID, conversationID).
-						Select("id").First(&existingMsg).Error; err == nil {
-						// Message already exists, just check for new reactions
-						p.checkNewReactions(&msg, conversationID)
-						continue
-					}
-				}
-
-				// New message found, emit event
-				select {
-				case p.eventChan <- core.MessageEvent{Message: msg}:
-					p.log("SlackProvider.checkNewMessagesAndReactions: emitted new message event: %s in %s\n", msg.ProtocolMsgID, conversationID)
-				default:
-					p.log("SlackProvider.checkNewMessagesAndReactions: WARNING - event channel full, dropping message event\n")
-				}
-
-				// Check for new reactions on this message
-				p.checkNewReactions(&msg, conversationID)
-			}
-		}
-	}
-
-	// Update last poll timestamp
-	p.lastPollMu.Lock()
-	p.lastPollTimestamp = time.Now()
-	p.lastPollMu.Unlock()
+	return calls
 }
 
 // getRecentMessages gets recent messages for a conversation (from API, not DB)
 // If oldest is not nil, only messages after this timestamp will be fetched
-func (p *SlackProvider) getRecentMessages(conversationID string, limit int, oldest *time.Time) ([]models.Message, error) {
+func (p *SlackProvider) getRecentMessages(conversationID string, limit int, oldest *time.Time, priority requestPriority) ([]models.Message, error) {
 	p.mu.RLock()
 	client := p.client
 	p.mu.RUnlock()
@@ -647,8 +506,13 @@ func (p *SlackProvider) getRecentMessages(conversationID string, limit int, olde
 
 	// If conversationID is a user ID (starts with "U"), we need to open the DM conversation
 	if len(conversationID) > 0 && conversationID[0] == 'U' {
-		channel, _, _, err := client.OpenConversation(&slack.OpenConversationParameters{
-			Users: []string{conversationID},
+		var channel *slack.Channel
+		err := p.callAPI("conversations.open", priority, func() error {
+			var err error
+			channel, _, _, err = client.OpenConversation(&slack.OpenConversationParameters{
+				Users: []string{conversationID},
+			})
+			return err
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to open DM conversation with user %s: %w", conversationID, err)
@@ -657,10 +521,18 @@ func (p *SlackProvider) getRecentMessages(conversationID string, limit int, olde
 			return nil, fmt.Errorf("failed to get DM channel ID for user %s", conversationID)
 		}
 		actualChannelID = channel.ID
+
+		// Remember the mapping for real-time events and read markers of this DM
+		p.realtimeMu.Lock()
+		p.dmUsers[channel.ID] = conversationID
+		p.realtimeMu.Unlock()
 	} else if len(conversationID) > 0 && conversationID[0] == 'D' {
 		// For DM channel IDs, ensure the conversation is open
-		_, _, _, err := client.OpenConversation(&slack.OpenConversationParameters{
-			ChannelID: conversationID,
+		err := p.callAPI("conversations.open", priority, func() error {
+			_, _, _, err := client.OpenConversation(&slack.OpenConversationParameters{
+				ChannelID: conversationID,
+			})
+			return err
 		})
 		if err != nil {
 			// Log but don't fail - the conversation might already be open
@@ -683,7 +555,12 @@ func (p *SlackProvider) getRecentMessages(conversationID string, limit int, olde
 			conversationID, oldest.Format("2006-01-02 15:04:05"), oldestStr)
 	}
 
-	history, err := client.GetConversationHistory(params)
+	var history *slack.GetConversationHistoryResponse
+	err := p.callAPI("conversations.history", priority, func() error {
+		var err error
+		history, err = client.GetConversationHistory(params)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -800,6 +677,9 @@ func (p *SlackProvider) checkNewReactions(msg *models.Message, conversationID st
 
 // Disconnect closes the connection and stops all background operations.
 func (p *SlackProvider) Disconnect() error {
+	// Close the real-time connection
+	p.stopRealtime()
+
 	// Signal polling goroutine to stop
 	select {
 	case p.stopChan <- struct{}{}:
@@ -807,6 +687,9 @@ func (p *SlackProvider) Disconnect() error {
 	}
 	close(p.stopChan)
 
+	// Wait for the file downloads in progress
+	p.stopFileDownloads()
+
 	// Clear status cache
 	p.statusCacheMu.Lock()
 	p.statusCache = make(map[string]userStatus)
@@ -0,0 +1,385 @@
+package slack
+
+import (
+	"Loom/pkg/core"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	// maxRateLimitRetries is how many times a call rejected with HTTP 429 is retried.
+	maxRateLimitRetries = 3
+	// throttleReportDelay is how long a call may wait for the rate limits before it is reported.
+	throttleReportDelay = 2 * time.Second
+	// throttleReportInterval limits the throttling reports to one per method in this interval.
+	throttleReportInterval = 10 * time.Second
+	// usersPageSize is the number of users fetched per users.list call.
+	usersPageSize = 200
+	// statusPollInterval is the minimum interval between two status checks (see pollStatusUpdates).
+	statusPollInterval = 30 * time.Second
+)
+
+// requestPriority orders the calls waiting for the rate limits of a method.
+type requestPriority int
+
+const (
+	priorityInteractive requestPriority = iota // Calls made for the user: sending, editing, opening a conversation
+	priorityBackground                         // Synchronization, polling and lookups
+)
+
+// rateTier is a Slack rate limit tier: calls per minute and the burst allowed above that pace.
+type rateTier struct {
+	perMinute int
+	burst     int
+}
+
+var (
+	tier1       = rateTier{perMinute: 1, burst: 1}
+	tier2       = rateTier{perMinute: 20, burst: 3}
+	tier3       = rateTier{perMinute: 50, burst: 5}
+	tier4       = rateTier{perMinute: 100, burst: 10}
+	tierPosting = rateTier{perMinute: 60, burst: 5} // chat.postMessage: about one message per second
+)
+
+// methodTiers are the documented tiers of the Slack Web API methods used by the provider.
+// Methods not listed use tier 3.
+var methodTiers = map[string]rateTier{
+	"auth.test":                    tier4,
+	"chat.delete":                  tier3,
+	"chat.deleteScheduledMessage":  tier3,
+	"chat.postMessage":             tierPosting,
+	"chat.scheduleMessage":         tier3,
+	"chat.scheduledMessages.list":  tier3,
+	"chat.update":                  tier3,
+	"conversations.archive":        tier2,
+	"conversations.create":         tier2,
+	"conversations.history":        tier3,
+	"conversations.info":           tier3,
+	"conversations.invite":         tier3,
+	"conversations.join":           tier3,
+	"conversations.kick":           tier3,
+	"conversations.leave":          tier3,
+	"conversations.list":           tier2,
+	"conversations.mark":           tier3,
+	"conversations.members":        tier4,
+	"conversations.open":           tier3,
+	"conversations.rename":         tier2,
+	"conversations.replies":        tier3,
+	"conversations.unarchive":      tier2,
+	"dnd.endSnooze":                tier2,
+	"dnd.info":                     tier3,
+	"dnd.setSnooze":                tier2,
+	"emoji.list":                   tier2,
+	"files.completeUploadExternal": tier4,
+	"files.getUploadURLExternal":   tier4,
+	"files.info":                   tier4,
+	"reactions.add":                tier3,
+	"reactions.remove":             tier2,
+	"search.files":                 tier2,
+	"search.messages":              tier2,
+	"stars.add":                    tier2,
+	"stars.remove":                 tier2,
+	"users.getPresence":            tier3,
+	"users.info":                   tier4,
+	"users.list":                   tier2,
+	"users.profile.get":            tier4,
+	"users.profile.set":            tier3,
+	"users.setPresence":            tier2,
+}
+
+// methodBucket is the token bucket of one method.
+type methodBucket struct {
+	tier         rateTier
+	tokens       float64
+	refilled     time.Time
+	blockedUntil time.Time // Set from the Retry-After of a rejected call
+}
+
+// refill adds the tokens earned since the last refill.
+func (b *methodBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.refilled).Seconds()
+	b.tokens = math.Min(float64(b.tier.burst), b.tokens+elapsed*float64(b.tier.perMinute)/60)
+	b.refilled = now
+}
+
+// readyIn returns how long a call of the given priority must wait for a token.
+// Background calls leave the last token of the burst to interactive calls.
+func (b *methodBucket) readyIn(now time.Time, priority requestPriority) time.Duration {
+	if now.Before(b.blockedUntil) {
+		return b.blockedUntil.Sub(now)
+	}
+	needed := 1.0
+	if priority == priorityBackground && b.tier.burst > 1 {
+		needed = 2
+	}
+	if b.tokens >= needed {
+		return 0
+	}
+	return time.Duration((needed - b.tokens) * 60 / float64(b.tier.perMinute) * float64(time.Second))
+}
+
+// pendingCall is a call waiting for the rate limits of its method.
+type pendingCall struct {
+	method   string
+	priority requestPriority
+	granted  chan struct{} // Closed when the call may run
+}
+
+// rateLimiter schedules the Slack API calls of a workspace within the per-method rate tiers.
+// Calls wait in a queue ordered by priority, then arrival, so interactive calls go before
+// background calls of the same method.
+type rateLimiter struct {
+	mu         sync.Mutex
+	buckets    map[string]*methodBucket
+	queue      []*pendingCall
+	timer      *time.Timer
+	lastReport map[string]time.Time // Last throttling report per method
+	delayed    int                  // Calls reported as delayed and still waiting
+	onThrottle func(method string, delay time.Duration)
+}
+
+// newRateLimiter creates a rate limiter. onThrottle is called when calls of a method are delayed
+// (with the expected delay), and with a zero delay once the delayed calls resumed.
+func newRateLimiter(onThrottle func(method string, delay time.Duration)) *rateLimiter {
+	return &rateLimiter{
+		buckets:    make(map[string]*methodBucket),
+		lastReport: make(map[string]time.Time),
+		onThrottle: onThrottle,
+	}
+}
+
+// do runs call once the rate limits of method allow it. Calls rejected by Slack with a
+// rate-limited error are retried after the Retry-After delay.
+func (l *rateLimiter) do(ctx context.Context, method string, priority requestPriority, call func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := l.wait(ctx, method, priority); err != nil {
+			return err
+		}
+		err := call()
+		var rateLimited *slack.RateLimitedError
+		if !errors.As(err, &rateLimited) || attempt >= maxRateLimitRetries {
+			return err
+		}
+		l.block(method, rateLimited.RetryAfter)
+	}
+}
+
+// wait blocks until a call of method may run, reporting it when it waits too long.
+func (l *rateLimiter) wait(ctx context.Context, method string, priority requestPriority) error {
+	call := &pendingCall{method: method, priority: priority, granted: make(chan struct{})}
+
+	l.mu.Lock()
+	index := len(l.queue)
+	for i, queued := range l.queue {
+		if queued.priority > priority {
+			index = i
+			break
+		}
+	}
+	l.queue = append(l.queue, nil)
+	copy(l.queue[index+1:], l.queue[index:])
+	l.queue[index] = call
+	l.dispatchLocked()
+	l.mu.Unlock()
+
+	report := time.NewTimer(throttleReportDelay)
+	defer report.Stop()
+	reported := false
+	for {
+		select {
+		case <-call.granted:
+			if reported {
+				l.resumed()
+			}
+			return nil
+		case <-report.C:
+			reported = true
+			l.reportDelay(call)
+		case <-ctx.Done():
+			l.mu.Lock()
+			for i, queued := range l.queue {
+				if queued == call {
+					l.queue = append(l.queue[:i], l.queue[i+1:]...)
+					break
+				}
+			}
+			l.dispatchLocked()
+			l.mu.Unlock()
+			if reported {
+				l.resumed()
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// block stops the calls of method for the Retry-After delay returned by Slack.
+func (l *rateLimiter) block(method string, retryAfter time.Duration) {
+	if retryAfter <= 0 {
+		retryAfter = time.Second
+	}
+	l.mu.Lock()
+	bucket := l.bucketLocked(method)
+	bucket.blockedUntil = time.Now().Add(retryAfter)
+	bucket.tokens = 0
+	l.mu.Unlock()
+}
+
+// dispatch grants the calls whose method has a token available. It is run by the timer.
+func (l *rateLimiter) dispatch() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.dispatchLocked()
+}
+
+// dispatchLocked grants the queued calls that may run and schedules the next dispatch.
+// A waiting call holds back the calls queued after it for the same method.
+func (l *rateLimiter) dispatchLocked() {
+	now := time.Now()
+	nextDispatch := time.Duration(-1)
+	waiting := make(map[string]bool)
+	remaining := l.queue[:0]
+	for _, call := range l.queue {
+		if waiting[call.method] {
+			remaining = append(remaining, call)
+			continue
+		}
+		bucket := l.bucketLocked(call.method)
+		bucket.refill(now)
+		wait := bucket.readyIn(now, call.priority)
+		if wait == 0 {
+			bucket.tokens--
+			close(call.granted)
+			continue
+		}
+		waiting[call.method] = true
+		remaining = append(remaining, call)
+		if nextDispatch < 0 || wait < nextDispatch {
+			nextDispatch = wait
+		}
+	}
+	for i := len(remaining); i < len(l.queue); i++ {
+		l.queue[i] = nil
+	}
+	l.queue = remaining
+
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	if nextDispatch >= 0 {
+		l.timer = time.AfterFunc(nextDispatch, l.dispatch)
+	}
+}
+
+// bucketLocked returns the token bucket of a method, creating it full.
+func (l *rateLimiter) bucketLocked(method string) *methodBucket {
+	bucket, ok := l.buckets[method]
+	if !ok {
+		tier, known := methodTiers[method]
+		if !known {
+			tier = tier3
+		}
+		bucket = &methodBucket{tier: tier, tokens: float64(tier.burst), refilled: time.Now()}
+		l.buckets[method] = bucket
+	}
+	return bucket
+}
+
+// reportDelay reports a call delayed by the rate limits, at most once per method per throttleReportInterval.
+func (l *rateLimiter) reportDelay(call *pendingCall) {
+	l.mu.Lock()
+	l.delayed++
+	now := time.Now()
+	if now.Sub(l.lastReport[call.method]) < throttleReportInterval {
+		l.mu.Unlock()
+		return
+	}
+	l.lastReport[call.method] = now
+	bucket := l.bucketLocked(call.method)
+	bucket.refill(now)
+	delay := bucket.readyIn(now, call.priority)
+	l.mu.Unlock()
+
+	if l.onThrottle != nil {
+		l.onThrottle(call.method, max(delay, time.Second))
+	}
+}
+
+// resumed records the end of a delayed call and reports when no delayed call is left.
+func (l *rateLimiter) resumed() {
+	l.mu.Lock()
+	l.delayed--
+	done := l.delayed == 0
+	if done {
+		clear(l.lastReport)
+	}
+	l.mu.Unlock()
+
+	if done && l.onThrottle != nil {
+		l.onThrottle("", 0)
+	}
+}
+
+// callAPI runs a Slack API call through the rate limiter of the workspace.
+func (p *SlackProvider) callAPI(method string, priority requestPriority, call func() error) error {
+	return p.limiter.do(context.Background(), method, priority, call)
+}
+
+// rateLimitError returns the rate-limited error of an HTTP 429 response to a raw API call, or nil.
+func rateLimitError(resp *http.Response) error {
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+	return &slack.RateLimitedError{RetryAfter: time.Duration(retryAfter) * time.Second}
+}
+
+// listUsers fetches all the users of the workspace, one rate-limited users.list call per page.
+func (p *SlackProvider) listUsers(client *slack.Client, priority requestPriority) ([]slack.User, error) {
+	ctx := context.Background()
+	options := []slack.GetUsersOption{slack.GetUsersOptionLimit(usersPageSize)}
+	if teamID := p.scopeTeamID(); teamID != "" {
+		options = append(options, slack.GetUsersOptionTeamID(teamID))
+	}
+	page := client.GetUsersPaginated(options...)
+	var users []slack.User
+	for {
+		err := p.limiter.do(ctx, "users.list", priority, func() error {
+			next, err := page.Next(ctx)
+			if err == nil {
+				page = next
+			}
+			return err
+		})
+		if page.Done(err) {
+			return users, nil
+		}
+		if err != nil {
+			return users, err
+		}
+		users = append(users, page.Users...)
+	}
+}
+
+// reportThrottling tells the user that the Slack rate limits are delaying requests,
+// and that they resumed (delay 0) outside of a synchronization.
+func (p *SlackProvider) reportThrottling(method string, delay time.Duration) {
+	if delay > 0 {
+		p.log("SlackProvider.reportThrottling: rate limit reached for %s, calls delayed by %s\n", method, delay.Round(time.Second))
+		p.emitSyncStatus(core.SyncStatusThrottled, fmt.Sprintf("Slack rate limit reached (%s), resuming in %s...", method, delay.Round(time.Second)), -1)
+		return
+	}
+	p.log("SlackProvider.reportThrottling: delayed calls resumed\n")
+	if !p.syncing.Load() {
+		p.emitSyncStatus(core.SyncStatusCompleted, "Slack requests resumed", 100)
+	}
+}
@@ -0,0 +1,589 @@
+package slack
+
+import (
+	"Loom/pkg/core"
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+)
+
+const (
+	// presenceSubscriptionBatch is the number of users subscribed per presence_sub message.
+	presenceSubscriptionBatch = 500
+	// socketModeMaxBackoff caps the delay between two Socket Mode reconnection attempts.
+	socketModeMaxBackoff = 5 * time.Minute
+	// reconnectSyncMargin is synced before the disconnection time to cover events in flight.
+	reconnectSyncMargin = time.Minute
+)
+
+// startRealtime opens the real-time connection to Slack.
+// Socket Mode is used when an app-level token (xapp-) is configured; user (xoxp-) and
+// client (xoxc-) tokens use the RTM websocket. Both reconnect automatically.
+// It is called from Connect, which holds p.mu, so the client and config are passed in
+// (and p.dCookie read without locking).
+func (p *SlackProvider) startRealtime(client *slack.Client, config core.ProviderConfig) {
+	if client == nil {
+		return
+	}
+	appToken, _ := config.GetString("app_token")
+	dCookie := p.dCookie
+
+	// Replace any previous connection (Connect called twice)
+	p.stopRealtime()
+	ctx, cancel := context.WithCancel(context.Background())
+	p.realtimeMu.Lock()
+	p.realtimeCancel = cancel
+	p.realtimeMu.Unlock()
+
+	if appToken != "" {
+		p.log("SlackProvider.startRealtime: starting Socket Mode connection\n")
+		go p.runSocketMode(ctx, client)
+		return
+	}
+
+	p.log("SlackProvider.startRealtime: starting RTM connection\n")
+	options := []slack.RTMOption{}
+	if dCookie != "" {
+		// Client tokens (xoxc) are only accepted with the d cookie, on the websocket as well
+		if dialer := cookieDialer(dCookie); dialer != nil {
+			options = append(options, slack.RTMOptionDialer(dialer))
+		}
+	}
+	rtm := client.NewRTM(options...)
+	p.realtimeMu.Lock()
+	p.rtm = rtm
+	p.realtimeMu.Unlock()
+
+	go rtm.ManageConnection()
+	go p.runRTM(ctx, rtm)
+}
+
+// stopRealtime closes the real-time connection.
+func (p *SlackProvider) stopRealtime() {
+	p.realtimeMu.Lock()
+	cancel := p.realtimeCancel
+	rtm := p.rtm
+	p.realtimeCancel = nil
+	p.rtm = nil
+	p.disconnectedAt = time.Time{}
+	p.realtimeMu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	if rtm != nil {
+		if err := rtm.Disconnect(); err != nil {
+			p.log("SlackProvider.stopRealtime: WARNING - failed to disconnect RTM: %v\n", err)
+		}
+	}
+}
+
+// cookieDialer returns a websocket dialer sending the d cookie to slack.com.
+func cookieDialer(dCookie string) *websocket.Dialer {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil
+	}
+	cookieValue := strings.TrimPrefix(dCookie, "d=")
+	slackURL := &url.URL{Scheme: "https", Host: "slack.com", Path: "/"}
+	jar.SetCookies(slackURL, []*http.Cookie{{Name: "d", Value: cookieValue, Domain: ".slack.com", Path: "/", Secure: true}})
+
+	dialer := *websocket.DefaultDialer
+	dialer.Jar = jar
+	return &dialer
+}
+
+// runRTM dispatches the events of the RTM connection until ctx is cancelled.
+func (p *SlackProvider) runRTM(ctx context.Context, rtm *slack.RTM) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case rtmEvent, ok := <-rtm.IncomingEvents:
+			if !ok {
+				return
+			}
+			switch ev := rtmEvent.Data.(type) {
+			case *slack.ConnectedEvent:
+				p.log("SlackProvider.runRTM: connected (connection #%d)\n", ev.ConnectionCount)
+				p.subscribePresence(rtm)
+				p.onRealtimeConnected()
+			case *slack.DisconnectedEvent:
+				p.log("SlackProvider.runRTM: disconnected (intentional=%v, cause=%v)\n", ev.Intentional, ev.Cause)
+				p.onRealtimeDisconnected()
+			case *slack.ConnectionErrorEvent:
+				p.log("SlackProvider.runRTM: connection error (attempt %d, retrying in %s): %v\n", ev.Attempt, ev.Backoff, ev.ErrorObj)
+				p.onRealtimeDisconnected()
+			case *slack.InvalidAuthEvent:
+				p.log("SlackProvider.runRTM: ERROR - invalid authentication, real-time events disabled\n")
+				return
+			case *slack.RTMError:
+				p.log("SlackProvider.runRTM: RTM error: %v\n", ev)
+			case *slack.MessageEvent:
+				message := ev.Msg
+				p.handleRealtimeMessage(ev.Channel, ev.SubType, &message, ev.SubMessage, ev.DeletedTimestamp)
+			case *slack.ReactionAddedEvent:
+				p.handleRealtimeReaction(ev.Item.Channel, ev.Item.Timestamp, ev.User, ev.Reaction, true, ev.EventTimestamp)
+			case *slack.ReactionRemovedEvent:
+				p.handleRealtimeReaction(ev.Item.Channel, ev.Item.Timestamp, ev.User, ev.Reaction, false, ev.EventTimestamp)
+			case *slack.UserTypingEvent:
+				p.handleRealtimeTyping(ev.Channel, ev.User)
+			case *slack.PresenceChangeEvent:
+				users := ev.Users
+				if ev.User != "" {
+					users = append(users, ev.User)
+				}
+				for _, userID := range users {
+					p.handleRealtimePresence(userID, ev.Presence)
+				}
+			case *slack.MemberJoinedChannelEvent:
+				p.handleRealtimeMembership(ev.Channel, ev.User, core.GroupChangeParticipantAdded)
+			case *slack.MemberLeftChannelEvent:
+				p.handleRealtimeMembership(ev.Channel, ev.User, core.GroupChangeParticipantLeft)
+			case *slack.ChannelMarkedEvent:
+				p.handleRealtimeMarked(ev.Channel, ev.Timestamp)
+			case *slack.GroupMarkedEvent:
+				p.handleRealtimeMarked(ev.Channel, ev.Timestamp)
+			case *slack.IMMarkedEvent:
+				p.handleRealtimeMarked(ev.Channel, ev.Timestamp)
+			case *slack.EmojiChangedEvent:
+				p.handleEmojiChanged(ev.SubType, ev.Name, ev.Names, ev.Value, "", "")
+			}
+		}
+	}
+}
+
+// subscribePresence asks RTM for the presence changes of the known users.
+// User tokens only receive presence_change events for subscribed users.
+func (p *SlackProvider) subscribePresence(rtm *slack.RTM) {
+	p.statusCacheMu.RLock()
+	userIDs := make([]string, 0, len(p.statusCache))
+	for userID := range p.statusCache {
+		userIDs = append(userIDs, userID)
+	}
+	p.statusCacheMu.RUnlock()
+
+	for start := 0; start < len(userIDs); start += presenceSubscriptionBatch {
+		end := start + presenceSubscriptionBatch
+		if end > len(userIDs) {
+			end = len(userIDs)
+		}
+		rtm.SendMessage(rtm.NewSubscribeUserPresence(userIDs[start:end]))
+	}
+	p.log("SlackProvider.subscribePresence: subscribed to the presence of %d users\n", len(userIDs))
+}
+
+// runSocketMode runs the Socket Mode connection until ctx is cancelled, reconnecting with
+// exponential backoff when the connection fails.
+// Socket Mode delivers Events API events: typing and presence are not available.
+func (p *SlackProvider) runSocketMode(ctx context.Context, client *slack.Client) {
+	backoff := 2 * time.Second
+	for {
+		// Each attempt gets its own client: stop its event handler once it returns
+		attemptCtx, cancelAttempt := context.WithCancel(ctx)
+		smc := socketmode.New(client)
+		go p.handleSocketModeEvents(attemptCtx, smc)
+
+		err := smc.RunContext(attemptCtx)
+		cancelAttempt()
+		if ctx.Err() != nil {
+			return
+		}
+		p.log("SlackProvider.runSocketMode: connection ended: %v (retrying in %s)\n", err, backoff)
+		p.onRealtimeDisconnected()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > socketModeMaxBackoff {
+			backoff = socketModeMaxBackoff
+		}
+	}
+}
+
+// handleSocketModeEvents acknowledges and dispatches the events of a Socket Mode client.
+func (p *SlackProvider) handleSocketModeEvents(ctx context.Context, smc *socketmode.Client) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt, ok := <-smc.Events:
+			if !ok {
+				return
+			}
+			switch evt.Type {
+			case socketmode.EventTypeConnected:
+				p.log("SlackProvider.handleSocketModeEvents: connected\n")
+				p.onRealtimeConnected()
+			case socketmode.EventTypeConnectionError:
+				p.log("SlackProvider.handleSocketModeEvents: connection error: %v\n", evt.Data)
+			case socketmode.EventTypeInvalidAuth:
+				p.log("SlackProvider.handleSocketModeEvents: ERROR - invalid app token, real-time events disabled\n")
+			case socketmode.EventTypeDisconnect:
+				p.log("SlackProvider.handleSocketModeEvents: Slack requested a reconnection\n")
+			case socketmode.EventTypeEventsAPI:
+				if evt.Request != nil {
+					smc.Ack(*evt.Request)
+				}
+				eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
+				if !ok {
+					continue
+				}
+				p.handleEventsAPIEvent(eventsAPIEvent)
+			}
+		}
+	}
+}
+
+// handleEventsAPIEvent dispatches an Events API event received over Socket Mode.
+func (p *SlackProvider) handleEventsAPIEvent(event slackevents.EventsAPIEvent) {
+	switch ev := event.InnerEvent.Data.(type) {
+	case *slackevents.MessageEvent:
+		message := &slack.Msg{
+			Type:            ev.Type,
+			Channel:         ev.Channel,
+			User:            ev.User,
+			Text:            ev.Text,
+			Timestamp:       ev.TimeStamp,
+			ThreadTimestamp: ev.ThreadTimeStamp,
+			SubType:         ev.SubType,
+			BotID:           ev.BotID,
+			Username:        ev.Username,
+		}
+		var subMessage *slack.Msg
+		if ev.SubType == "message_changed" {
+			subMessage = ev.Message
+		} else if ev.Message != nil {
+			// Regular messages are normalized into Message, which keeps files and blocks
+			message = ev.Message
+			message.Channel = ev.Channel
+			message.SubType = ev.SubType
+		}
+		p.handleRealtimeMessage(ev.Channel, ev.SubType, message, subMessage, ev.DeletedTimeStamp)
+	case *slackevents.ReactionAddedEvent:
+		p.handleRealtimeReaction(ev.Item.Channel, ev.Item.Timestamp, ev.User, ev.Reaction, true, ev.EventTimestamp)
+	case *slackevents.ReactionRemovedEvent:
+		p.handleRealtimeReaction(ev.Item.Channel, ev.Item.Timestamp, ev.User, ev.Reaction, false, ev.EventTimestamp)
+	case *slackevents.MemberJoinedChannelEvent:
+		p.handleRealtimeMembership(ev.Channel, ev.User, core.GroupChangeParticipantAdded)
+	case *slackevents.MemberLeftChannelEvent:
+		p.handleRealtimeMembership(ev.Channel, ev.User, core.GroupChangeParticipantLeft)
+	case *slackevents.EmojiChangedEvent:
+		p.handleEmojiChanged(ev.Subtype, ev.Name, ev.Names, ev.Value, ev.OldName, ev.NewName)
+	}
+}
+
+// onRealtimeConnected catches up on the messages missed while the connection was down.
+// The initial connection needs no catch-up: Connect is followed by a full SyncHistory.
+func (p *SlackProvider) onRealtimeConnected() {
+	p.realtimeMu.Lock()
+	disconnectedAt := p.disconnectedAt
+	p.disconnectedAt = time.Time{}
+	p.realtimeMu.Unlock()
+
+	if disconnectedAt.IsZero() {
+		return
+	}
+	since := disconnectedAt.Add(-reconnectSyncMargin)
+	p.log("SlackProvider.onRealtimeConnected: reconnected, syncing messages since %s\n", since.Format("2006-01-02 15:04:05"))
+	go func() {
+		if err := p.SyncHistory(since); err != nil {
+			p.log("SlackProvider.onRealtimeConnected: WARNING - catch-up sync failed: %v\n", err)
+		}
+	}()
+}
+
+// onRealtimeDisconnected records when the connection was lost (the first time only).
+func (p *SlackProvider) onRealtimeDisconnected() {
+	p.realtimeMu.Lock()
+	defer p.realtimeMu.Unlock()
+	if p.disconnectedAt.IsZero() {
+		p.disconnectedAt = time.Now()
+	}
+}
+
+// handleRealtimeMessage handles new, edited and deleted messages.
+// For message_changed, subMessage holds the new version; for message_deleted, deletedTS the deleted message.
+func (p *SlackProvider) handleRealtimeMessage(channelID, subType string, message *slack.Msg, subMessage *slack.Msg, deletedTS string) {
+	if p.isOtherTeamChannel(channelID) {
+		return
+	}
+	conversationID := p.conversationIDForChannel(channelID)
+	// Topic, purpose and name changes are also regular messages of the channel
+	p.handleRealtimeChannelInfo(conversationID, subType, message)
+
+	switch subType {
+	case "message_changed":
+		if subMessage == nil {
+			return
+		}
+		p.applyRealtimeEdit(conversationID, subMessage)
+	case "message_deleted":
+		if deletedTS == "" && message != nil {
+			deletedTS = message.DeletedTimestamp
+		}
+		p.applyRealtimeDelete(conversationID, deletedTS)
+	case "message_replied":
+		// Thread parent metadata update, the reply itself arrives as its own message
+	default:
+		if message == nil || message.Timestamp == "" {
+			return
+		}
+		converted := p.convertSlackMessage(slack.Message{Msg: *message}, conversationID)
+		converted.ProtocolConvID = conversationID
+		// Our own sent replies are already stored and counted
+		isNewReply := converted.ThreadID != nil && !p.isMessageStored(conversationID, converted.ProtocolMsgID)
+		p.storeMessagesForConversation(conversationID, []models.Message{converted})
+		p.log("SlackProvider.handleRealtimeMessage: new message %s in %s\n", converted.ProtocolMsgID, conversationID)
+		p.emitEvent(core.MessageEvent{Message: converted})
+		if isNewReply {
+			p.applyThreadReply(conversationID, converted)
+		}
+	}
+}
+
+// applyRealtimeEdit stores the new text of an edited message and emits it.
+func (p *SlackProvider) applyRealtimeEdit(conversationID string, edited *slack.Msg) {
+	editedAt := time.Now()
+	if edited.Edited != nil && edited.Edited.Timestamp != "" {
+		editedAt = parseSlackTimestamp(edited.Edited.Timestamp)
+	}
+
+	var stored models.Message
+	if db.DB != nil && db.DB.Where("protocol_msg_id = ? AND protocol_conv_id = ?", edited.Timestamp, conversationID).First(&stored).Error == nil {
+		previousBody := stored.Body
+		p.applyRichText(&stored, *edited)
+		if stored.Body == previousBody {
+			// Unfurls and thread metadata also produce message_changed events
+			return
+		}
+		stored.IsEdited = true
+		stored.EditedTimestamp = &editedAt
+		if err := db.DB.Model(&models.Message{}).Where("id = ?", stored.ID).Updates(map[string]interface{}{
+			"body":             stored.Body,
+			"rich_text":        stored.RichText,
+			"rich_content":     stored.RichContent,
+			"is_edited":        true,
+			"edited_timestamp": editedAt,
+		}).Error; err != nil {
+			p.log("SlackProvider.applyRealtimeEdit: failed to update message %s: %v\n", edited.Timestamp, err)
+		}
+	} else {
+		// Not stored yet: store the edited version
+		stored = p.convertSlackMessage(slack.Message{Msg: *edited}, conversationID)
+		stored.ProtocolConvID = conversationID
+		stored.IsEdited = true
+		stored.EditedTimestamp = &editedAt
+		p.storeMessagesForConversation(conversationID, []models.Message{stored})
+	}
+
+	p.log("SlackProvider.applyRealtimeEdit: message %s edited in %s\n", edited.Timestamp, conversationID)
+	p.emitEvent(core.MessageEvent{Message: stored})
+}
+
+// applyRealtimeDelete flags a deleted message and emits it.
+func (p *SlackProvider) applyRealtimeDelete(conversationID, messageID string) {
+	if messageID == "" {
+		return
+	}
+	deletedAt := time.Now()
+
+	deleted := models.Message{
+		ProtocolMsgID:  messageID,
+		ProtocolConvID: conversationID,
+		Timestamp:      parseSlackTimestamp(messageID),
+	}
+	if db.DB != nil {
+		var stored models.Message
+		if err := db.DB.Where("protocol_msg_id = ? AND protocol_conv_id = ?", messageID, conversationID).First(&stored).Error; err == nil {
+			deleted = stored
+			if err := db.DB.Model(&models.Message{}).Where("id = ?", stored.ID).Updates(map[string]interface{}{
+				"is_deleted":        true,
+				"deleted_reason":    "revoked",
+				"deleted_timestamp": deletedAt,
+			}).Error; err != nil {
+				p.log("SlackProvider.applyRealtimeDelete: failed to update message %s: %v\n", messageID, err)
+			}
+		}
+	}
+	deleted.IsDeleted = true
+	deleted.DeletedReason = "revoked"
+	deleted.DeletedTimestamp = &deletedAt
+
+	p.log("SlackProvider.applyRealtimeDelete: message %s deleted in %s\n", messageID, conversationID)
+	p.emitEvent(core.MessageEvent{Message: deleted})
+}
+
+// handleRealtimeReaction emits a reaction added to or removed from a message.
+func (p *SlackProvider) handleRealtimeReaction(channelID, messageID, userID, reaction string, added bool, eventTS string) {
+	if channelID == "" || messageID == "" {
+		// Reactions to files are not shown
+		return
+	}
+	if p.isOtherTeamChannel(channelID) {
+		return
+	}
+	timestamp := time.Now().Unix()
+	if eventTS != "" {
+		timestamp = parseSlackTimestamp(eventTS).Unix()
+	}
+	p.emitEvent(core.ReactionEvent{
+		ConversationID: p.conversationIDForChannel(channelID),
+		MessageID:      messageID,
+		UserID:         userID,
+		Emoji:          cleanSlackEmoji(reaction),
+		Added:          added,
+		Timestamp:      timestamp,
+	})
+}
+
+// handleRealtimeTyping emits a typing indicator. Slack has no "stopped typing" event.
+func (p *SlackProvider) handleRealtimeTyping(channelID, userID string) {
+	if p.isOtherTeamChannel(channelID) {
+		return
+	}
+	userName := userID
+	p.userCacheMu.RLock()
+	if user, ok := p.userCache[userID]; ok && user != nil {
+		userName = user.RealName
+		if userName == "" {
+			userName = user.Name
+		}
+	}
+	p.userCacheMu.RUnlock()
+
+	p.emitEvent(core.TypingEvent{
+		ConversationID: p.conversationIDForChannel(channelID),
+		UserID:         userID,
+		UserName:       userName,
+		IsTyping:       true,
+	})
+}
+
+// handleRealtimePresence emits a presence change and the resulting contact status.
+func (p *SlackProvider) handleRealtimePresence(userID, presence string) {
+	isOnline := presence == "active"
+	var lastSeen int64
+	if !isOnline {
+		lastSeen = time.Now().Unix()
+	}
+	p.emitEvent(core.PresenceEvent{UserID: userID, IsOnline: isOnline, LastSeen: lastSeen})
+
+	p.statusCacheMu.Lock()
+	cached := p.statusCache[userID]
+	newStatus := p.determineStatus(presence, cached.statusText, cached.statusEmoji)
+	changed := newStatus != cached.status
+	if changed {
+		cached.status = newStatus
+		p.statusCache[userID] = cached
+	}
+	p.statusCacheMu.Unlock()
+
+	if changed {
+		p.emitEvent(core.ContactStatusEvent{
+			UserID:         userID,
+			Status:         newStatus,
+			StatusEmoji:    cached.statusEmoji,
+			StatusEmojiURL: p.emojiImage(cached.statusEmoji),
+			StatusText:     cached.statusText,
+		})
+	}
+}
+
+// handleRealtimeMembership emits a member joining or leaving a channel.
+func (p *SlackProvider) handleRealtimeMembership(channelID, userID string, changeType core.GroupChangeType) {
+	if p.isOtherTeamChannel(channelID) {
+		return
+	}
+	p.emitEvent(core.GroupChangeEvent{
+		ConversationID: channelID,
+		ChangeType:     changeType,
+		ParticipantID:  userID,
+		Timestamp:      time.Now().Unix(),
+	})
+}
+
+// conversationIDForChannel maps a Slack channel ID to the Loom conversation ID.
+// Direct messages are stored under the user ID of the other person (see GetContacts),
+// so DM channel IDs (D...) are resolved to that user; other channels keep their ID.
+func (p *SlackProvider) conversationIDForChannel(channelID string) string {
+	if !strings.HasPrefix(channelID, "D") {
+		return channelID
+	}
+
+	p.realtimeMu.Lock()
+	userID, cached := p.dmUsers[channelID]
+	p.realtimeMu.Unlock()
+	if cached {
+		return userID
+	}
+
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+	if client == nil {
+		return channelID
+	}
+
+	var channel *slack.Channel
+	err := p.callAPI("conversations.info", priorityBackground, func() error {
+		var err error
+		channel, err = client.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: channelID})
+		return err
+	})
+	if err != nil || channel == nil || channel.User == "" {
+		p.log("SlackProvider.conversationIDForChannel: WARNING - failed to resolve DM %s: %v\n", channelID, err)
+		return channelID
+	}
+
+	p.realtimeMu.Lock()
+	p.dmUsers[channelID] = channel.User
+	p.realtimeMu.Unlock()
+	return channel.User
+}
+
+// emitEvent sends an event without blocking when the channel is full.
+func (p *SlackProvider) emitEvent(event core.ProviderEvent) {
+	// Use recover to prevent panic if channel is closed
+	defer func() {
+		if r := recover(); r != nil {
+			p.log("SlackProvider.emitEvent: PANIC (channel may be closed): %v\n", r)
+		}
+	}()
+
+	select {
+	case p.eventChan <- event:
+	default:
+		p.log("SlackProvider.emitEvent: WARNING - event channel full, dropping %s event\n", event.Type())
+	}
+}
+
+// emitEventWait sends an event, waiting up to timeout when the channel is full.
+// It is used for bursts of events, such as the read state of every conversation after a sync.
+func (p *SlackProvider) emitEventWait(event core.ProviderEvent, timeout time.Duration) {
+	// Use recover to prevent panic if channel is closed
+	defer func() {
+		if r := recover(); r != nil {
+			p.log("SlackProvider.emitEventWait: PANIC (channel may be closed): %v\n", r)
+		}
+	}()
+
+	select {
+	case p.eventChan <- event:
+	case <-time.After(timeout):
+		p.log("SlackProvider.emitEventWait: WARNING - timeout, dropping %s event\n", event.Type())
+	}
+}
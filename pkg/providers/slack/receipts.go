@@ -1,23 +1,150 @@
 package slack
 
-// MarkMessageAsRead marks a message as read.
-func (p *SlackProvider) MarkMessageAsRead(conversationID string, messageID string) error {
+import (
+	"Loom/pkg/core"
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	// clientCountsURL is the endpoint the Slack clients use to fetch the read state of all conversations.
+	// It only accepts client tokens (xoxc).
+	clientCountsURL = "https://slack.com/api/client.counts"
+	// readMarkerSyncWindow limits the conversations.info fallback to conversations active recently.
+	readMarkerSyncWindow = 30 * 24 * time.Hour
+	// maxReadMarkerInfoCalls caps the conversations.info calls of a read marker sync.
+	maxReadMarkerInfoCalls = 100
+	// readMarkerEmitTimeout is how long a read marker waits for room in the event channel.
+	readMarkerEmitTimeout = 5 * time.Second
+)
+
+// conversationReadState is the read position of a conversation as reported by Slack.
+type conversationReadState struct {
+	ChannelID   string
+	LastRead    string // Timestamp of the last read message
+	UnreadCount int    // Number of unread messages, -1 if unknown
+	HasUnreads  bool
+}
+
+// clientCountsResponse is the part of the client.counts response used by Loom.
+type clientCountsResponse struct {
+	OK       bool                 `json:"ok"`
+	Error    string               `json:"error,omitempty"`
+	Channels []clientCountsResult `json:"channels"`
+	MPIMs    []clientCountsResult `json:"mpims"`
+	IMs      []clientCountsResult `json:"ims"`
+}
+
+// clientCountsResult is the read state of one conversation in a client.counts response.
+type clientCountsResult struct {
+	ID         string `json:"id"`
+	LastRead   string `json:"last_read"`
+	HasUnreads bool   `json:"has_unreads"`
+}
+
+// SupportsReadMarkers reports whether read positions are synced with Slack.
+// Bot tokens (xoxb) have no read state of their own: conversations.mark is not available to them.
+func (p *SlackProvider) SupportsReadMarkers() bool {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
+	if p.client == nil {
+		return false
+	}
+	return !p.isBotToken()
+}
+
+// isBotToken reports whether the configured token is a bot token. The caller must hold p.mu.
+func (p *SlackProvider) isBotToken() bool {
+	token, _ := p.config.GetString("token")
+	return strings.HasPrefix(token, "xoxb-")
+}
 
-	// Slack "mark as read" is usually creating an implicit read marker.
-	// We can't easily do this with a bot token for a user, usually.
-	// But assuming we might have a user token:
-	// return p.client.SetChannelReadMark(conversationID, messageID)
-	// For now, no-op as it's often not critical for bots.
+// isClientToken reports whether the configured token is a client token. The caller must hold p.mu.
+func (p *SlackProvider) isClientToken() bool {
+	token, _ := p.config.GetString("token")
+	return strings.HasPrefix(token, "xoxc-")
+}
+
+// MarkMessageAsRead moves the read position of the conversation to a message (conversations.mark).
+// It does nothing for bot tokens and for thread replies, which do not move the conversation position.
+func (p *SlackProvider) MarkMessageAsRead(conversationID string, messageID string) error {
+	p.mu.RLock()
+	client := p.client
+	isBot := p.isBotToken()
+	p.mu.RUnlock()
+
+	if client == nil {
+		return fmt.Errorf("slack client not initialized")
+	}
+	if isBot || messageID == "" {
+		return nil
+	}
+
+	if db.DB != nil {
+		var message models.Message
+		if err := db.DB.Where("protocol_msg_id = ? AND protocol_conv_id = ?", messageID, conversationID).First(&message).Error; err == nil {
+			if message.ThreadID != nil && !message.ThreadBroadcast {
+				return nil
+			}
+		}
+	}
+
+	channelID, err := p.channelIDForConversation(client, conversationID, priorityInteractive)
+	if err != nil {
+		return err
+	}
+	err = p.callAPI("conversations.mark", priorityInteractive, func() error {
+		return client.MarkConversation(channelID, messageID)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark %s as read: %w", conversationID, err)
+	}
+	p.log("SlackProvider.MarkMessageAsRead: marked %s as read up to %s\n", conversationID, messageID)
 	return nil
 }
 
-// MarkConversationAsRead marks all messages in a conversation as read.
+// MarkConversationAsRead marks all messages in a conversation as read, up to the latest stored message
+// or, when none is stored, the latest message on Slack.
 func (p *SlackProvider) MarkConversationAsRead(conversationID string) error {
 	p.mu.RLock()
-	defer p.mu.RUnlock()
-	return nil
+	client := p.client
+	isBot := p.isBotToken()
+	p.mu.RUnlock()
+
+	if client == nil {
+		return fmt.Errorf("slack client not initialized")
+	}
+	if isBot {
+		return nil
+	}
+
+	latest := ""
+	if db.DB != nil {
+		var message models.Message
+		if err := db.DB.Where("protocol_conv_id = ? AND (thread_id IS NULL OR thread_broadcast = ?)", conversationID, true).
+			Order("timestamp desc").First(&message).Error; err == nil {
+			latest = message.ProtocolMsgID
+		}
+	}
+	if latest == "" {
+		messages, err := p.getRecentMessages(conversationID, 1, nil, priorityInteractive)
+		if err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+		latest = messages[len(messages)-1].ProtocolMsgID
+	}
+	return p.MarkMessageAsRead(conversationID, latest)
 }
 
 // MarkMessageAsPlayed marks a voice message as played.
@@ -29,3 +156,204 @@ func (p *SlackProvider) MarkMessageAsPlayed(conversationID string, messageID str
 func (p *SlackProvider) SendRetryReceipt(conversationID string, messageID string) error {
 	return nil
 }
+
+// syncReadMarkers fetches the read state of the conversations from Slack and emits it as
+// ReadMarkerEvents. Client tokens use client.counts (one call for all conversations); user
+// tokens fall back to conversations.info for the conversations active recently.
+func (p *SlackProvider) syncReadMarkers(conversationIDs []string) {
+	p.mu.RLock()
+	client := p.client
+	isBot := p.isBotToken()
+	isClient := p.isClientToken()
+	p.mu.RUnlock()
+
+	if client == nil || isBot {
+		return
+	}
+
+	var states []conversationReadState
+	if isClient {
+		counts, err := p.fetchClientCounts()
+		if err != nil {
+			p.log("SlackProvider.syncReadMarkers: WARNING - client.counts failed, using conversations.info: %v\n", err)
+		} else {
+			states = counts
+		}
+	}
+	if states == nil {
+		states = p.fetchConversationsReadState(client, conversationIDs)
+	}
+
+	for _, state := range states {
+		p.emitReadMarker(p.conversationIDForChannel(state.ChannelID), state)
+	}
+	p.log("SlackProvider.syncReadMarkers: synced the read state of %d conversations\n", len(states))
+}
+
+// fetchClientCounts calls client.counts, which returns the read state of every conversation.
+func (p *SlackProvider) fetchClientCounts() ([]conversationReadState, error) {
+	p.mu.RLock()
+	token, _ := p.config.GetString("token")
+	httpClient := p.httpClient
+	p.mu.RUnlock()
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("simple_unreads", "true")
+	var counts clientCountsResponse
+	err := p.callAPI("client.counts", priorityBackground, func() error {
+		resp, err := httpClient.PostForm(clientCountsURL, form)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if err := rateLimitError(resp); err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("client.counts returned %s", resp.Status)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&counts); err != nil {
+			return fmt.Errorf("failed to decode client.counts response: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !counts.OK {
+		return nil, fmt.Errorf("client.counts failed: %s", counts.Error)
+	}
+
+	var states []conversationReadState
+	for _, group := range [][]clientCountsResult{counts.Channels, counts.MPIMs, counts.IMs} {
+		for _, result := range group {
+			states = append(states, conversationReadState{
+				ChannelID:   result.ID,
+				LastRead:    result.LastRead,
+				UnreadCount: -1,
+				HasUnreads:  result.HasUnreads,
+			})
+		}
+	}
+	return states, nil
+}
+
+// fetchConversationsReadState calls conversations.info for the given conversations that have
+// messages stored in the last readMarkerSyncWindow.
+func (p *SlackProvider) fetchConversationsReadState(client *slack.Client, conversationIDs []string) []conversationReadState {
+	if db.DB == nil || len(conversationIDs) == 0 {
+		return nil
+	}
+
+	var activeIDs []string
+	if err := db.DB.Model(&models.Message{}).
+		Where("protocol_conv_id IN ? AND timestamp >= ?", conversationIDs, time.Now().Add(-readMarkerSyncWindow)).
+		Distinct().Pluck("protocol_conv_id", &activeIDs).Error; err != nil {
+		p.log("SlackProvider.fetchConversationsReadState: failed to list active conversations: %v\n", err)
+		return nil
+	}
+	if len(activeIDs) > maxReadMarkerInfoCalls {
+		activeIDs = activeIDs[:maxReadMarkerInfoCalls]
+	}
+
+	var states []conversationReadState
+	for _, conversationID := range activeIDs {
+		channelID, err := p.channelIDForConversation(client, conversationID, priorityBackground)
+		if err != nil {
+			p.log("SlackProvider.fetchConversationsReadState: WARNING - %v\n", err)
+			continue
+		}
+		var channel *slack.Channel
+		err = p.callAPI("conversations.info", priorityBackground, func() error {
+			var err error
+			channel, err = client.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: channelID})
+			return err
+		})
+		if err != nil {
+			p.log("SlackProvider.fetchConversationsReadState: WARNING - conversations.info failed for %s: %v\n", conversationID, err)
+			continue
+		}
+		if channel == nil || channel.LastRead == "" {
+			// Not a member, or the token does not expose the read state
+			continue
+		}
+
+		unreadCount := -1
+		if channel.UnreadCountDisplay > 0 {
+			unreadCount = channel.UnreadCountDisplay
+		}
+		states = append(states, conversationReadState{
+			ChannelID:   channelID,
+			LastRead:    channel.LastRead,
+			UnreadCount: unreadCount,
+			HasUnreads:  unreadCount > 0,
+		})
+	}
+	return states
+}
+
+// emitReadMarker emits the read state of a conversation. When Slack does not report the unread
+// count, it is computed from the stored messages received after the read position.
+func (p *SlackProvider) emitReadMarker(conversationID string, state conversationReadState) {
+	var lastReadAt time.Time
+	var timestamp int64
+	if state.LastRead != "" && state.LastRead != "0000000000.000000" {
+		lastReadAt = parseSlackTimestamp(state.LastRead)
+		timestamp = lastReadAt.Unix()
+	}
+
+	unreadCount := state.UnreadCount
+	if unreadCount < 0 {
+		unreadCount = countStoredUnread(conversationID, lastReadAt)
+		if state.HasUnreads && unreadCount == 0 {
+			// The unread messages are not stored yet
+			unreadCount = 1
+		} else if !state.HasUnreads {
+			unreadCount = 0
+		}
+	}
+
+	p.emitEventWait(core.ReadMarkerEvent{
+		ConversationID:    conversationID,
+		LastReadMessageID: state.LastRead,
+		UnreadCount:       unreadCount,
+		Timestamp:         timestamp,
+	}, readMarkerEmitTimeout)
+}
+
+// handleRealtimeMarked handles channel_marked, group_marked and im_marked events, sent when
+// a conversation is read from any Slack client (including Loom itself).
+func (p *SlackProvider) handleRealtimeMarked(channelID, lastRead string) {
+	if p.isOtherTeamChannel(channelID) {
+		return
+	}
+	conversationID := p.conversationIDForChannel(channelID)
+	lastReadAt := parseSlackTimestamp(lastRead)
+	unreadCount := countStoredUnread(conversationID, lastReadAt)
+	p.emitReadMarker(conversationID, conversationReadState{
+		ChannelID:   channelID,
+		LastRead:    lastRead,
+		UnreadCount: unreadCount,
+		HasUnreads:  unreadCount > 0,
+	})
+}
+
+// countStoredUnread counts the stored incoming messages of a conversation after lastReadAt.
+// Thread replies do not count, unless they were also sent to the conversation.
+func countStoredUnread(conversationID string, lastReadAt time.Time) int {
+	if db.DB == nil {
+		return 0
+	}
+	var count int64
+	db.DB.Model(&models.Message{}).
+		Where("protocol_conv_id = ? AND is_from_me = ? AND is_status_message = ? AND timestamp > ?", conversationID, false, false, lastReadAt).
+		Where("thread_id IS NULL OR thread_broadcast = ?", true).
+		Count(&count)
+	return int(count)
+}
@@ -0,0 +1,579 @@
+package slack
+
+import (
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"Loom/pkg/richtext"
+	"Loom/pkg/templates"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/slack-go/slack"
+)
+
+// mrkdwnUnescaper decodes the three HTML entities Slack escapes in message text.
+var mrkdwnUnescaper = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">")
+
+var (
+	// outgoingEntityPattern matches the Slack entities that outgoing text may already contain
+	// (mentions, channels, links), which must not be escaped again.
+	outgoingEntityPattern = regexp.MustCompile(`<(?:[@#!][^<>\s]+|(?:https?|mailto):[^<>\s]+)>`)
+	// outgoingEscapePattern matches the characters to escape, and already escaped entities to keep.
+	outgoingEscapePattern = regexp.MustCompile(`&(?:amp|lt|gt);|[&<>]`)
+	// outgoingBroadcastPattern matches @here, @channel and @everyone.
+	outgoingBroadcastPattern = regexp.MustCompile(`(^|[\s(])@(here|channel|everyone)\b`)
+	// outgoingChannelPattern matches #channel references.
+	outgoingChannelPattern = regexp.MustCompile(`(^|[\s(])#([a-z0-9][a-z0-9_\-]*)`)
+	// outgoingCodePattern matches code blocks and inline code, which are sent verbatim.
+	outgoingCodePattern = regexp.MustCompile("(?s)```.*?```|`[^`\n]+`")
+)
+
+// messageRichText converts the text of a Slack message to a resolved rich text document.
+// The rich_text blocks of messages written in a Slack client are used when present,
+// otherwise the mrkdwn text is parsed.
+func (p *SlackProvider) messageRichText(msg slack.Msg) richtext.Document {
+	doc, ok := richTextFromBlocks(msg.Blocks)
+	if !ok {
+		doc = parseMrkdwn(msg.Text)
+	}
+	p.resolveEntities(&doc)
+	return doc
+}
+
+// applyRichText sets the Markdown body, the rich text and the rich content of a converted message.
+// Messages with blocks or attachments get their plain-text fallback as body.
+func (p *SlackProvider) applyRichText(message *models.Message, msg slack.Msg) {
+	doc := p.messageRichText(msg)
+	message.Body = doc.Markdown()
+	message.RichText = ""
+	if doc.HasEntities() {
+		message.RichText = doc.Encode()
+	}
+	message.RichContent = ""
+	if content := p.messageContent(msg, doc); !content.IsEmpty() {
+		message.Body = content.PlainText()
+		message.RichContent = content.Encode()
+	}
+}
+
+// resolveEntities fills in the names of the mentioned users and channels.
+func (p *SlackProvider) resolveEntities(doc *richtext.Document) {
+	for i := range doc.Segments {
+		segment := &doc.Segments[i]
+		if segment.Text != "" {
+			continue
+		}
+		switch segment.Type {
+		case richtext.SegmentUser:
+			segment.Text = p.userName(segment.ID)
+		case richtext.SegmentChannel:
+			segment.Text = p.channelName(segment.ID)
+		}
+	}
+}
+
+// userName returns the display name of a user from the user cache, fetching it on a miss.
+// It returns "" when the user cannot be found.
+func (p *SlackProvider) userName(userID string) string {
+	p.userCacheMu.RLock()
+	user, cached := p.userCache[userID]
+	p.userCacheMu.RUnlock()
+
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+
+	if !cached && client != nil {
+		var err error
+		user, err = p.lookupUser(client, userID, priorityBackground)
+		if err != nil || user == nil {
+			p.log("SlackProvider.userName: WARNING - failed to get user info for %s: %v\n", userID, err)
+			return ""
+		}
+	}
+	if user == nil {
+		return ""
+	}
+	return slackUserDisplayName(user)
+}
+
+// slackUserDisplayName returns the name shown for a user: RealName, then DisplayName, then Name.
+func slackUserDisplayName(user *slack.User) string {
+	if user.RealName != "" {
+		return user.RealName
+	}
+	if user.Profile.DisplayName != "" {
+		return user.Profile.DisplayName
+	}
+	return user.Name
+}
+
+// channelName returns the name of a channel from the channel list (see GetContacts), then the
+// stored contacts, then conversations.info. It returns "" when the channel cannot be found.
+func (p *SlackProvider) channelName(channelID string) string {
+	p.channelNamesMu.RLock()
+	name, cached := p.channelNames[channelID]
+	p.channelNamesMu.RUnlock()
+	if cached {
+		return name
+	}
+
+	if db.DB != nil {
+		var account models.LinkedAccount
+		if err := db.DB.Where("protocol = ? AND user_id = ?", "slack", channelID).First(&account).Error; err == nil && account.Username != "" {
+			name = account.Username
+		}
+	}
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+
+	if name == "" && client != nil {
+		var channel *slack.Channel
+		err := p.callAPI("conversations.info", priorityBackground, func() error {
+			var err error
+			channel, err = client.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: channelID})
+			return err
+		})
+		if err != nil || channel == nil {
+			p.log("SlackProvider.channelName: WARNING - failed to get channel info for %s: %v\n", channelID, err)
+		} else if channel.IsIM {
+			name = p.userName(channel.User)
+		} else {
+			name = channel.Name
+		}
+	}
+
+	// Unknown channels are cached too, to avoid calling Slack for each of their mentions
+	p.channelNamesMu.Lock()
+	p.channelNames[channelID] = name
+	p.channelNamesMu.Unlock()
+	return name
+}
+
+// cacheChannelNames remembers the names of the listed channels.
+func (p *SlackProvider) cacheChannelNames(channels []slack.Channel) {
+	p.channelNamesMu.Lock()
+	defer p.channelNamesMu.Unlock()
+	for _, channel := range channels {
+		if channel.Name != "" {
+			p.channelNames[channel.ID] = channel.Name
+		}
+	}
+}
+
+// richTextFromBlocks converts the rich_text blocks of a message. It reports false when the
+// message has none.
+func richTextFromBlocks(blocks slack.Blocks) (richtext.Document, bool) {
+	var doc richtext.Document
+	found := false
+	for _, block := range blocks.BlockSet {
+		richText, ok := block.(*slack.RichTextBlock)
+		if !ok {
+			continue
+		}
+		found = true
+		for i, element := range richText.Elements {
+			if i > 0 {
+				endLine(&doc)
+			}
+			appendRichTextElement(&doc, element)
+		}
+	}
+	return doc, found
+}
+
+// appendRichTextElement appends a section, list, quote or preformatted element.
+func appendRichTextElement(doc *richtext.Document, element slack.RichTextElement) {
+	switch e := element.(type) {
+	case *slack.RichTextSection:
+		appendRichTextSection(doc, e.Elements, richtext.Style{})
+	case *slack.RichTextQuote:
+		appendRichTextSection(doc, e.Elements, richtext.Style{Quote: true})
+	case *slack.RichTextPreformatted:
+		appendRichTextSection(doc, e.Elements, richtext.Style{Pre: true})
+	case *slack.RichTextList:
+		indent := strings.Repeat("  ", e.Indent)
+		for i, item := range e.Elements {
+			if i > 0 {
+				endLine(doc)
+			}
+			marker := "- "
+			if e.Style == slack.RTEListOrdered {
+				marker = fmt.Sprintf("%d. ", e.Offset+i+1)
+			}
+			doc.AppendText(indent+marker, richtext.Style{})
+			if section, ok := item.(*slack.RichTextSection); ok {
+				appendRichTextSection(doc, section.Elements, richtext.Style{})
+			}
+		}
+	}
+}
+
+// endLine starts a new line unless the document is empty or already ends with one.
+func endLine(doc *richtext.Document) {
+	n := len(doc.Segments)
+	if n == 0 || strings.HasSuffix(doc.Segments[n-1].Text, "\n") && doc.Segments[n-1].Type == richtext.SegmentText {
+		return
+	}
+	doc.AppendText("\n", richtext.Style{})
+}
+
+// appendRichTextSection appends the elements of a section with a base block style.
+func appendRichTextSection(doc *richtext.Document, elements []slack.RichTextSectionElement, base richtext.Style) {
+	for _, element := range elements {
+		switch e := element.(type) {
+		case *slack.RichTextSectionTextElement:
+			doc.AppendText(e.Text, withTextStyle(base, e.Style))
+		case *slack.RichTextSectionUserElement:
+			doc.Append(richtext.Segment{Type: richtext.SegmentUser, ID: e.UserID, Style: withTextStyle(base, e.Style)})
+		case *slack.RichTextSectionChannelElement:
+			doc.Append(richtext.Segment{Type: richtext.SegmentChannel, ID: e.ChannelID, Style: withTextStyle(base, e.Style)})
+		case *slack.RichTextSectionUserGroupElement:
+			doc.Append(richtext.Segment{Type: richtext.SegmentUserGroup, ID: e.UsergroupID, Style: base})
+		case *slack.RichTextSectionLinkElement:
+			doc.Append(richtext.Segment{Type: richtext.SegmentLink, URL: e.URL, Text: e.Text, Style: withTextStyle(base, e.Style)})
+		case *slack.RichTextSectionEmojiElement:
+			doc.Append(richtext.Segment{Type: richtext.SegmentEmoji, Text: e.Name, Style: withTextStyle(base, e.Style)})
+		case *slack.RichTextSectionBroadcastElement:
+			doc.Append(richtext.Segment{Type: richtext.SegmentBroadcast, ID: e.Range, Style: base})
+		case *slack.RichTextSectionDateElement:
+			if e.Fallback != nil && *e.Fallback != "" {
+				doc.AppendText(*e.Fallback, base)
+			} else {
+				doc.AppendText(time.Unix(int64(e.Timestamp), 0).Format("2006-01-02 15:04"), base)
+			}
+		case *slack.RichTextSectionTeamElement:
+			doc.AppendText(e.TeamID, withTextStyle(base, e.Style))
+		case *slack.RichTextSectionColorElement:
+			doc.AppendText(e.Value, base)
+		}
+	}
+}
+
+// withTextStyle adds the inline style of a rich text element to a block style.
+func withTextStyle(base richtext.Style, style *slack.RichTextSectionTextStyle) richtext.Style {
+	if style != nil {
+		base.Bold = style.Bold
+		base.Italic = style.Italic
+		base.Strike = style.Strike
+		base.Code = style.Code
+	}
+	return base
+}
+
+// parseMrkdwn parses Slack mrkdwn: <...> entities, &amp; &lt; &gt;, *bold*, _italic_, ~strike~,
+// `code`, ```preformatted``` blocks and > quotes.
+func parseMrkdwn(text string) richtext.Document {
+	var doc richtext.Document
+	for text != "" {
+		start := strings.Index(text, "```")
+		end := -1
+		if start != -1 {
+			if e := strings.Index(text[start+3:], "```"); e != -1 {
+				end = start + 3 + e
+			}
+		}
+		if start == -1 || end == -1 {
+			parseMrkdwnLines(&doc, text)
+			break
+		}
+		parseMrkdwnLines(&doc, text[:start])
+		parseMrkdwnInline(&doc, text[start+3:end], richtext.Style{Pre: true}, false)
+		text = text[end+3:]
+	}
+	return doc
+}
+
+// parseMrkdwnLines parses text outside of code blocks, line by line for quotes.
+// A line starting with ">>>" quotes the rest of the text.
+func parseMrkdwnLines(doc *richtext.Document, text string) {
+	lines := strings.SplitAfter(text, "\n")
+	quoteRest := false
+	for _, line := range lines {
+		style := richtext.Style{}
+		if !quoteRest && strings.HasPrefix(line, "&gt;&gt;&gt;") {
+			quoteRest = true
+			line = strings.TrimPrefix(strings.TrimPrefix(line, "&gt;&gt;&gt;"), " ")
+		}
+		if quoteRest {
+			style.Quote = true
+		} else if strings.HasPrefix(line, "&gt;") {
+			style.Quote = true
+			line = strings.TrimPrefix(strings.TrimPrefix(line, "&gt;"), " ")
+		}
+		parseMrkdwnInline(doc, line, style, true)
+	}
+}
+
+// parseMrkdwnInline parses entities and, when formatting is allowed, inline styles.
+func parseMrkdwnInline(doc *richtext.Document, text string, style richtext.Style, formatting bool) {
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() > 0 {
+			doc.AppendText(mrkdwnUnescaper.Replace(plain.String()), style)
+			plain.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		if c == '<' {
+			if end := strings.IndexByte(text[i:], '>'); end != -1 {
+				flush()
+				doc.Append(parseMrkdwnEntity(text[i+1:i+end], style))
+				i += end + 1
+				continue
+			}
+		}
+		if formatting && (c == '*' || c == '_' || c == '~' || c == '`') {
+			if end := closingMarker(text, i); end != -1 {
+				flush()
+				inner := style
+				switch c {
+				case '*':
+					inner.Bold = true
+				case '_':
+					inner.Italic = true
+				case '~':
+					inner.Strike = true
+				case '`':
+					inner.Code = true
+				}
+				parseMrkdwnInline(doc, text[i+1:end], inner, c != '`')
+				i = end + 1
+				continue
+			}
+		}
+		plain.WriteByte(c)
+		i++
+	}
+	flush()
+}
+
+// closingMarker returns the index of the marker closing the one at start, or -1.
+// Like Slack, a marker opens at a word boundary, is followed by a non-space character,
+// closes on the same line after a non-space character and before a word boundary.
+func closingMarker(text string, start int) int {
+	marker := text[start]
+	if start > 0 {
+		prev, _ := utf8.DecodeLastRuneInString(text[:start])
+		if isWordRune(prev) {
+			return -1
+		}
+	}
+	if start+1 >= len(text) || text[start+1] == ' ' || text[start+1] == '\n' || text[start+1] == marker {
+		return -1
+	}
+	for i := start + 1; i < len(text); i++ {
+		switch text[i] {
+		case '\n':
+			return -1
+		case '<':
+			// Entities may contain markers (URLs with underscores)
+			if end := strings.IndexByte(text[i:], '>'); end != -1 {
+				i += end
+				continue
+			}
+		case marker:
+			if text[i-1] == ' ' {
+				continue
+			}
+			if i+1 < len(text) {
+				next, _ := utf8.DecodeRuneInString(text[i+1:])
+				if isWordRune(next) {
+					continue
+				}
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// isWordRune reports whether r is part of a word, i.e. cannot be next to a style marker.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// parseMrkdwnEntity parses the content of a <...> entity: <@U123>, <#C123|general>, <!here>,
+// <!subteam^S123|@team>, <!date^...|fallback> or <https://example.com|label>.
+func parseMrkdwnEntity(content string, style richtext.Style) richtext.Segment {
+	target, label := content, ""
+	if idx := strings.IndexByte(content, '|'); idx != -1 {
+		target, label = content[:idx], mrkdwnUnescaper.Replace(content[idx+1:])
+	}
+
+	switch {
+	case strings.HasPrefix(target, "@"):
+		return richtext.Segment{Type: richtext.SegmentUser, ID: target[1:], Text: label, Style: style}
+	case strings.HasPrefix(target, "#"):
+		return richtext.Segment{Type: richtext.SegmentChannel, ID: target[1:], Text: label, Style: style}
+	case target == "!here" || target == "!channel" || target == "!everyone":
+		return richtext.Segment{Type: richtext.SegmentBroadcast, ID: target[1:], Style: style}
+	case strings.HasPrefix(target, "!subteam^"):
+		return richtext.Segment{Type: richtext.SegmentUserGroup, ID: strings.TrimPrefix(target, "!subteam^"), Text: label, Style: style}
+	case strings.HasPrefix(target, "!"):
+		// Dates and other special commands: show their fallback text
+		if label == "" {
+			label = target[1:]
+		}
+		return richtext.Segment{Type: richtext.SegmentText, Text: label, Style: style}
+	}
+
+	link := mrkdwnUnescaper.Replace(target)
+	if label == "" && strings.HasPrefix(link, "mailto:") {
+		label = strings.TrimPrefix(link, "mailto:")
+	}
+	return richtext.Segment{Type: richtext.SegmentLink, URL: link, Text: label, Style: style}
+}
+
+// formatOutgoingText converts text written in the canonical Markdown subset to Slack mrkdwn:
+// **bold**, ~~strike~~ and [label](url) are converted, &, < and > are escaped, @name, #channel
+// and @here/@channel/@everyone become Slack mentions. Code is sent verbatim (escaped only),
+// and text already in mrkdwn (e.g. rendered templates) is left unchanged.
+func (p *SlackProvider) formatOutgoingText(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range outgoingCodePattern.FindAllStringIndex(text, -1) {
+		b.WriteString(p.formatOutgoingProse(text[last:loc[0]]))
+		b.WriteString(escapeOutgoing(text[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	b.WriteString(p.formatOutgoingProse(text[last:]))
+	return b.String()
+}
+
+// formatOutgoingProse converts text outside of code.
+func (p *SlackProvider) formatOutgoingProse(text string) string {
+	if text == "" {
+		return ""
+	}
+	text = escapeOutgoing(text)
+	text = templates.Format(text, "slack")
+	text = outgoingBroadcastPattern.ReplaceAllString(text, "$1<!$2>")
+	text = p.resolveOutgoingChannels(text)
+	return p.resolveOutgoingUsers(text)
+}
+
+// escapeOutgoing escapes &, < and >, keeping the entities already escaped and the Slack
+// mentions and links already present.
+func escapeOutgoing(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range outgoingEntityPattern.FindAllStringIndex(text, -1) {
+		b.WriteString(escapeOutgoingChars(text[last:loc[0]]))
+		b.WriteString(text[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(escapeOutgoingChars(text[last:]))
+	return b.String()
+}
+
+// escapeOutgoingChars escapes &, < and > unless they are part of an escaped entity.
+func escapeOutgoingChars(text string) string {
+	return outgoingEscapePattern.ReplaceAllStringFunc(text, func(match string) string {
+		switch match {
+		case "&":
+			return "&amp;"
+		case "<":
+			return "&lt;"
+		case ">":
+			return "&gt;"
+		}
+		return match
+	})
+}
+
+// resolveOutgoingChannels turns #name into a channel mention when the channel is known.
+func (p *SlackProvider) resolveOutgoingChannels(text string) string {
+	p.channelNamesMu.RLock()
+	defer p.channelNamesMu.RUnlock()
+	if len(p.channelNames) == 0 {
+		return text
+	}
+	return outgoingChannelPattern.ReplaceAllStringFunc(text, func(match string) string {
+		idx := strings.IndexByte(match, '#')
+		name := match[idx+1:]
+		for id, channelName := range p.channelNames {
+			if channelName == name && id != "" && id[0] != 'D' {
+				return match[:idx] + "<#" + id + ">"
+			}
+		}
+		return match
+	})
+}
+
+// resolveOutgoingUsers turns @name into a user mention. Names may contain spaces, so the
+// longest known name following the @ wins. Users are known from the user cache.
+func (p *SlackProvider) resolveOutgoingUsers(text string) string {
+	if !strings.Contains(text, "@") {
+		return text
+	}
+
+	type candidate struct {
+		name string
+		id   string
+	}
+	var candidates []candidate
+	p.userCacheMu.RLock()
+	for id, user := range p.userCache {
+		if user == nil || user.Deleted {
+			continue
+		}
+		for _, name := range []string{user.RealName, user.Profile.DisplayName, user.Name} {
+			if name != "" {
+				candidates = append(candidates, candidate{name: name, id: id})
+			}
+		}
+	}
+	p.userCacheMu.RUnlock()
+	if len(candidates) == 0 {
+		return text
+	}
+	sort.Slice(candidates, func(i, j int) bool { return len(candidates[i].name) > len(candidates[j].name) })
+
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		at := strings.IndexByte(text[i:], '@')
+		if at == -1 {
+			b.WriteString(text[i:])
+			break
+		}
+		at += i
+		b.WriteString(text[i:at])
+		i = at + 1
+
+		// Only at the start of a word, and not inside an entity such as <@U123>
+		if at > 0 {
+			prev, _ := utf8.DecodeLastRuneInString(text[:at])
+			if prev == '<' || isWordRune(prev) {
+				b.WriteByte('@')
+				continue
+			}
+		}
+		rest := text[at+1:]
+		matched := false
+		for _, c := range candidates {
+			if len(rest) < len(c.name) || !strings.EqualFold(rest[:len(c.name)], c.name) {
+				continue
+			}
+			if next, _ := utf8.DecodeRuneInString(rest[len(c.name):]); len(rest) > len(c.name) && isWordRune(next) {
+				continue
+			}
+			b.WriteString("<@" + c.id + ">")
+			i = at + 1 + len(c.name)
+			matched = true
+			break
+		}
+		if !matched {
+			b.WriteByte('@')
+		}
+	}
+	return b.String()
+}
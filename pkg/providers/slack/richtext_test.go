@@ -0,0 +1,165 @@
+package slack
+
+import (
+	"Loom/pkg/richtext"
+	"reflect"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestParseMrkdwn(t *testing.T) {
+	bold := richtext.Style{Bold: true}
+	tests := []struct {
+		name string
+		text string
+		want []richtext.Segment
+	}{
+		{"plain text", "hello world", []richtext.Segment{
+			{Type: richtext.SegmentText, Text: "hello world"},
+		}},
+		{"user mention", "hi <@U123>", []richtext.Segment{
+			{Type: richtext.SegmentText, Text: "hi "},
+			{Type: richtext.SegmentUser, ID: "U123"},
+		}},
+		{"channel with label", "see <#C42|general>", []richtext.Segment{
+			{Type: richtext.SegmentText, Text: "see "},
+			{Type: richtext.SegmentChannel, ID: "C42", Text: "general"},
+		}},
+		{"broadcast", "<!here> lunch", []richtext.Segment{
+			{Type: richtext.SegmentBroadcast, ID: "here"},
+			{Type: richtext.SegmentText, Text: " lunch"},
+		}},
+		{"user group", "<!subteam^S1|@devs>", []richtext.Segment{
+			{Type: richtext.SegmentUserGroup, ID: "S1", Text: "@devs"},
+		}},
+		{"date fallback", "<!date^1700000000^{date}|Nov 14>", []richtext.Segment{
+			{Type: richtext.SegmentText, Text: "Nov 14"},
+		}},
+		{"bare link", "<https://example.com/a_b_c>", []richtext.Segment{
+			{Type: richtext.SegmentLink, URL: "https://example.com/a_b_c"},
+		}},
+		{"link with escaped url", "<https://example.com/?a=1&amp;b=2|docs>", []richtext.Segment{
+			{Type: richtext.SegmentLink, URL: "https://example.com/?a=1&b=2", Text: "docs"},
+		}},
+		{"mailto", "<mailto:bob@example.com>", []richtext.Segment{
+			{Type: richtext.SegmentLink, URL: "mailto:bob@example.com", Text: "bob@example.com"},
+		}},
+		{"escaping", "a &lt;b&gt; &amp; c", []richtext.Segment{
+			{Type: richtext.SegmentText, Text: "a <b> & c"},
+		}},
+		{"styles", "*bold* _italic_ ~strike~", []richtext.Segment{
+			{Type: richtext.SegmentText, Text: "bold", Style: bold},
+			{Type: richtext.SegmentText, Text: " "},
+			{Type: richtext.SegmentText, Text: "italic", Style: richtext.Style{Italic: true}},
+			{Type: richtext.SegmentText, Text: " "},
+			{Type: richtext.SegmentText, Text: "strike", Style: richtext.Style{Strike: true}},
+		}},
+		{"nested formatting", "*bold _both_*", []richtext.Segment{
+			{Type: richtext.SegmentText, Text: "bold ", Style: bold},
+			{Type: richtext.SegmentText, Text: "both", Style: richtext.Style{Bold: true, Italic: true}},
+		}},
+		{"mention inside bold", "*<@U1>*", []richtext.Segment{
+			{Type: richtext.SegmentUser, ID: "U1", Style: bold},
+		}},
+		{"marker inside a word", "snake_case_name", []richtext.Segment{
+			{Type: richtext.SegmentText, Text: "snake_case_name"},
+		}},
+		{"unclosed marker", "2 * 3", []richtext.Segment{
+			{Type: richtext.SegmentText, Text: "2 * 3"},
+		}},
+		{"marker across lines", "*a\nb*", []richtext.Segment{
+			{Type: richtext.SegmentText, Text: "*a\nb*"},
+		}},
+		{"code span keeps markers", "`*x* &lt;y&gt;`", []richtext.Segment{
+			{Type: richtext.SegmentText, Text: "*x* <y>", Style: richtext.Style{Code: true}},
+		}},
+		{"code block", "run:```go _build_```", []richtext.Segment{
+			{Type: richtext.SegmentText, Text: "run:"},
+			{Type: richtext.SegmentText, Text: "go _build_", Style: richtext.Style{Pre: true}},
+		}},
+		{"quote", "&gt; quoted\nnot", []richtext.Segment{
+			{Type: richtext.SegmentText, Text: "quoted\n", Style: richtext.Style{Quote: true}},
+			{Type: richtext.SegmentText, Text: "not"},
+		}},
+		{"quote the rest", "&gt;&gt;&gt; a\nb", []richtext.Segment{
+			{Type: richtext.SegmentText, Text: "a\nb", Style: richtext.Style{Quote: true}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseMrkdwn(tt.text).Segments
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMrkdwn(%q) =\n%+v\nwant\n%+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+// newTestProvider returns a provider knowing a few users and channels, without a client.
+func newTestProvider() *SlackProvider {
+	p := NewSlackProvider()
+	p.userCache["U1"] = &slack.User{ID: "U1", Name: "bob", RealName: "Bob Smith"}
+	p.userCache["U2"] = &slack.User{ID: "U2", Name: "bobby"}
+	p.userCache["U3"] = &slack.User{ID: "U3", Name: "gone", Deleted: true}
+	p.channelNames["C1"] = "general"
+	p.channelNames["D1"] = "general"
+	return p
+}
+
+func TestFormatOutgoingText(t *testing.T) {
+	p := newTestProvider()
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain text", "hello", "hello"},
+		{"escaping", "a < b & c > d", "a &lt; b &amp; c &gt; d"},
+		{"already escaped", "a &amp; b &lt;", "a &amp; b &lt;"},
+		{"bold and strike", "**bold** and ~~gone~~", "*bold* and ~gone~"},
+		{"markdown link", "[docs](https://example.com/a?b=1)", "<https://example.com/a?b=1|docs>"},
+		{"existing entities", "<@U9> <#C9> <https://x.y>", "<@U9> <#C9> <https://x.y>"},
+		{"broadcast", "@here ping (@channel)", "<!here> ping (<!channel>)"},
+		{"known channel", "see #general", "see <#C1>"},
+		{"unknown channel", "see #random", "see #random"},
+		{"user mention", "hi @bob", "hi <@U1>"},
+		{"code span", "`**x** <y>` **z**", "`**x** &lt;y&gt;` *z*"},
+		{"code block", "```@bob #general```", "```@bob #general```"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.formatOutgoingText(tt.text); got != tt.want {
+				t.Errorf("formatOutgoingText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveOutgoingUsers(t *testing.T) {
+	p := newTestProvider()
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"no mention", "hello", "hello"},
+		{"user name", "@bob hi", "<@U1> hi"},
+		{"real name with a space", "cc @Bob Smith.", "cc <@U1>."},
+		{"case insensitive", "@BOBBY", "<@U2>"},
+		{"longest name wins", "@bobby", "<@U2>"},
+		{"not a whole name", "@bobcat", "@bobcat"},
+		{"inside a word", "mail@bob", "mail@bob"},
+		{"inside an entity", "<@bob>", "<@bob>"},
+		{"deleted user", "@gone", "@gone"},
+		{"unknown user", "@alice", "@alice"},
+		{"several mentions", "@bob and @bobby", "<@U1> and <@U2>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.resolveOutgoingUsers(tt.text); got != tt.want {
+				t.Errorf("resolveOutgoingUsers(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package slack
+
+import (
+	"Loom/pkg/core"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// maxScheduleAhead is how far in the future chat.scheduleMessage accepts messages.
+const maxScheduleAhead = 120 * 24 * time.Hour
+
+// ScheduleMessage schedules a message with chat.scheduleMessage: Slack posts it at postAt,
+// whether Loom is running or not.
+func (p *SlackProvider) ScheduleMessage(conversationID string, text string, threadID *string, postAt time.Time) (*core.ScheduledMessage, error) {
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("slack client not initialized")
+	}
+	if !postAt.After(time.Now()) {
+		return nil, fmt.Errorf("the scheduled time is in the past")
+	}
+	if time.Until(postAt) > maxScheduleAhead {
+		return nil, fmt.Errorf("slack schedules messages up to 120 days ahead: %w", core.ErrNativeSchedulingUnavailable)
+	}
+
+	channelID, err := p.channelIDForConversation(client, conversationID, priorityInteractive)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []slack.MsgOption{
+		slack.MsgOptionText(p.formatOutgoingText(text), false),
+	}
+	if threadID != nil {
+		root := p.threadRoot(conversationID, *threadID)
+		threadID = &root
+		opts = append(opts, slack.MsgOptionTS(root))
+	}
+
+	var scheduledID string
+	err = p.callAPI("chat.scheduleMessage", priorityInteractive, func() error {
+		var err error
+		_, scheduledID, err = client.ScheduleMessage(channelID, strconv.FormatInt(postAt.Unix(), 10), opts...)
+		return err
+	})
+	if err != nil {
+		p.log("SlackProvider.ScheduleMessage: ERROR - failed to schedule message in %s: %v\n", conversationID, err)
+		if strings.Contains(err.Error(), "time_too_far") {
+			return nil, fmt.Errorf("%v: %w", err, core.ErrNativeSchedulingUnavailable)
+		}
+		return nil, err
+	}
+	p.log("SlackProvider.ScheduleMessage: scheduled %s in %s for %s\n", scheduledID, conversationID, postAt.Format(time.RFC3339))
+
+	return &core.ScheduledMessage{
+		ID:             scheduledID,
+		ConversationID: conversationID,
+		Text:           text,
+		ThreadID:       threadID,
+		PostAt:         time.Unix(postAt.Unix(), 0),
+		Native:         true,
+	}, nil
+}
+
+// GetScheduledMessages lists the messages scheduled on Slack with chat.scheduledMessages.list.
+// Slack does not return the thread of scheduled replies, so their ThreadID is nil.
+func (p *SlackProvider) GetScheduledMessages(conversationID string) ([]core.ScheduledMessage, error) {
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("slack client not initialized")
+	}
+
+	channelID := ""
+	if conversationID != "" {
+		var err error
+		if channelID, err = p.channelIDForConversation(client, conversationID, priorityInteractive); err != nil {
+			return nil, err
+		}
+	}
+
+	var scheduled []core.ScheduledMessage
+	cursor := ""
+	for {
+		var page []slack.ScheduledMessage
+		var nextCursor string
+		err := p.callAPI("chat.scheduledMessages.list", priorityInteractive, func() error {
+			var err error
+			page, nextCursor, err = client.GetScheduledMessages(&slack.GetScheduledMessagesParameters{
+				Channel: channelID,
+				Cursor:  cursor,
+				Limit:   100,
+			})
+			return err
+		})
+		if err != nil {
+			p.log("SlackProvider.GetScheduledMessages: ERROR - failed to list scheduled messages: %v\n", err)
+			return nil, err
+		}
+		for _, message := range page {
+			scheduled = append(scheduled, core.ScheduledMessage{
+				ID:             message.ID,
+				ConversationID: p.conversationIDForChannel(message.Channel),
+				Text:           p.messageRichText(slack.Msg{Text: message.Text}).Markdown(),
+				PostAt:         time.Unix(int64(message.PostAt), 0),
+				Native:         true,
+			})
+		}
+		if nextCursor == "" {
+			break
+		}
+		cursor = nextCursor
+	}
+
+	p.log("SlackProvider.GetScheduledMessages: %d scheduled messages\n", len(scheduled))
+	return scheduled, nil
+}
+
+// CancelScheduledMessage deletes a message scheduled on Slack with chat.deleteScheduledMessage.
+func (p *SlackProvider) CancelScheduledMessage(conversationID string, scheduledID string) error {
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+
+	if client == nil {
+		return fmt.Errorf("slack client not initialized")
+	}
+
+	channelID, err := p.channelIDForConversation(client, conversationID, priorityInteractive)
+	if err != nil {
+		return err
+	}
+
+	err = p.callAPI("chat.deleteScheduledMessage", priorityInteractive, func() error {
+		_, err := client.DeleteScheduledMessage(&slack.DeleteScheduledMessageParameters{
+			Channel:            channelID,
+			ScheduledMessageID: scheduledID,
+		})
+		return err
+	})
+	if err != nil {
+		p.log("SlackProvider.CancelScheduledMessage: ERROR - failed to cancel %s: %v\n", scheduledID, err)
+		if strings.Contains(err.Error(), "invalid_scheduled_message_id") {
+			return fmt.Errorf("the message was already sent or cancelled")
+		}
+		return err
+	}
+	p.log("SlackProvider.CancelScheduledMessage: cancelled %s in %s\n", scheduledID, conversationID)
+	return nil
+}
@@ -0,0 +1,335 @@
+package slack
+
+import (
+	"Loom/pkg/core"
+	"Loom/pkg/models"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	// defaultSearchLimit is the number of hits per page when the query sets no limit.
+	defaultSearchLimit = 20
+	// maxSearchLimit is the largest page search.messages and search.files return.
+	maxSearchLimit = 100
+	// highlightStart and highlightEnd surround the matched terms in highlighted search results.
+	highlightStart = "\ue000"
+	highlightEnd   = "\ue001"
+)
+
+// SearchMessages searches the whole workspace history with search.messages, or search.files
+// when query.Files is set. Searching needs a user token with the search:read scope.
+// Pages are numbered: the cursor is the number of the next page.
+func (p *SlackProvider) SearchMessages(query core.SearchQuery) (*core.SearchResults, error) {
+	p.mu.RLock()
+	client := p.client
+	botToken := p.isBotToken()
+	p.mu.RUnlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("slack client not initialized")
+	}
+	if botToken {
+		return nil, fmt.Errorf("searching Slack needs a user token, bot tokens cannot search")
+	}
+
+	slackQuery := translateSearchQuery(query)
+	if slackQuery == "" {
+		return nil, fmt.Errorf("the search query is empty")
+	}
+	params := slack.NewSearchParameters()
+	params.Sort = "timestamp"
+	params.Highlight = true
+	params.TeamID = p.scopeTeamID()
+	params.Count = query.Limit
+	if params.Count <= 0 {
+		params.Count = defaultSearchLimit
+	}
+	params.Count = min(params.Count, maxSearchLimit)
+	if query.Cursor != "" {
+		page, err := strconv.Atoi(query.Cursor)
+		if err != nil || page < 1 {
+			return nil, fmt.Errorf("invalid search cursor %q", query.Cursor)
+		}
+		params.Page = page
+	}
+
+	results := &core.SearchResults{Hits: []core.SearchHit{}}
+	var paging slack.Paging
+	if query.Files {
+		var files *slack.SearchFiles
+		err := p.callAPI("search.files", priorityInteractive, func() error {
+			var err error
+			files, err = client.SearchFiles(slackQuery, params)
+			return err
+		})
+		if err != nil {
+			p.log("SlackProvider.SearchMessages: ERROR - search.files failed for %q: %v\n", slackQuery, err)
+			return nil, searchError(err)
+		}
+		for _, file := range files.Matches {
+			results.Hits = append(results.Hits, p.fileSearchHit(file))
+		}
+		paging = files.Paging
+	} else {
+		var messages *slack.SearchMessages
+		err := p.callAPI("search.messages", priorityInteractive, func() error {
+			var err error
+			messages, err = client.SearchMessages(slackQuery, params)
+			return err
+		})
+		if err != nil {
+			p.log("SlackProvider.SearchMessages: ERROR - search.messages failed for %q: %v\n", slackQuery, err)
+			return nil, searchError(err)
+		}
+		for _, match := range messages.Matches {
+			results.Hits = append(results.Hits, p.messageSearchHit(match))
+		}
+		paging = messages.Paging
+	}
+
+	// Slack only filters on whole days
+	filtered := results.Hits[:0]
+	for _, hit := range results.Hits {
+		if query.Since != nil && hit.Message.Timestamp.Before(*query.Since) {
+			continue
+		}
+		if query.Until != nil && !hit.Message.Timestamp.Before(*query.Until) {
+			continue
+		}
+		filtered = append(filtered, hit)
+	}
+	results.Hits = filtered
+
+	results.Total = paging.Total
+	if paging.Page < paging.Pages {
+		results.NextCursor = strconv.Itoa(paging.Page + 1)
+	}
+	p.log("SlackProvider.SearchMessages: %d hits for %q (page %d/%d, %d in total)\n", len(results.Hits), slackQuery, paging.Page, paging.Pages, paging.Total)
+	return results, nil
+}
+
+// translateSearchQuery builds the Slack search query: the text followed by the in:, from:,
+// after: and before: modifiers. after: and before: exclude the given day, so the dates are
+// widened by a day and the hits filtered to the exact times afterwards.
+func translateSearchQuery(query core.SearchQuery) string {
+	terms := []string{}
+	if text := strings.TrimSpace(query.Text); text != "" {
+		terms = append(terms, text)
+	}
+	if query.ConversationID != "" {
+		if strings.HasPrefix(query.ConversationID, "U") {
+			// Direct messages are stored under the user ID of the other person
+			terms = append(terms, "in:<@"+query.ConversationID+">")
+		} else {
+			terms = append(terms, "in:<#"+query.ConversationID+">")
+		}
+	}
+	if query.SenderID != "" {
+		terms = append(terms, "from:<@"+query.SenderID+">")
+	}
+	if query.Since != nil {
+		terms = append(terms, "after:"+query.Since.AddDate(0, 0, -1).Format("2006-01-02"))
+	}
+	if query.Until != nil {
+		terms = append(terms, "before:"+query.Until.AddDate(0, 0, 1).Format("2006-01-02"))
+	}
+	return strings.Join(terms, " ")
+}
+
+// messageSearchHit converts a search.messages match.
+func (p *SlackProvider) messageSearchHit(match slack.SearchMessage) core.SearchHit {
+	conversationID := p.conversationIDForChannel(match.Channel.ID)
+	highlights := highlightedTerms(match.Text)
+
+	msg := slack.Msg{
+		Type:        match.Type,
+		Channel:     match.Channel.ID,
+		User:        match.User,
+		Username:    match.Username,
+		Text:        stripHighlights(match.Text),
+		Timestamp:   match.Timestamp,
+		Blocks:      match.Blocks,
+		Attachments: match.Attachments,
+	}
+	if threadTS := permalinkThread(match.Permalink); threadTS != "" && threadTS != match.Timestamp {
+		msg.ThreadTimestamp = threadTS
+	}
+	// Search results are not stored: nothing is downloaded for them
+	message := p.convertMessage(slack.Message{Msg: msg}, conversationID, false)
+
+	hit := core.SearchHit{
+		Message:          message,
+		ConversationName: p.searchConversationName(match.Channel, conversationID),
+		IsGroup:          conversationID == match.Channel.ID,
+		Highlights:       highlights,
+		Permalink:        match.Permalink,
+		Remote:           true,
+	}
+	for _, neighbour := range []slack.CtxMessage{match.Previous2, match.Previous, match.Next, match.Next2} {
+		if neighbour.Timestamp == "" {
+			continue
+		}
+		hit.Context = append(hit.Context, p.contextMessage(neighbour, conversationID))
+	}
+	return hit
+}
+
+// fileSearchHit converts a search.files match to the message that shared the file.
+func (p *SlackProvider) fileSearchHit(file slack.File) core.SearchHit {
+	channelID, share := firstFileShare(file)
+	conversationID := p.conversationIDForChannel(channelID)
+	messageID := share.Ts
+	if messageID == "" {
+		messageID = "file:" + file.ID
+	}
+
+	title := file.Title
+	if title == "" {
+		title = file.Name
+	}
+	message := models.Message{
+		ProtocolMsgID:  messageID,
+		ProtocolConvID: conversationID,
+		SenderID:       file.User,
+		SenderName:     p.userName(file.User),
+		Body:           stripHighlights(title),
+		Timestamp:      file.Timestamp.Time(),
+		Attachments:    encodeAttachments([]models.Attachment{searchAttachment(file)}),
+	}
+	if share.ThreadTs != "" && share.ThreadTs != share.Ts {
+		threadID := share.ThreadTs
+		message.ThreadID = &threadID
+	}
+	if share.Ts != "" {
+		message.Timestamp = parseSlackTimestamp(share.Ts)
+	}
+	p.currentUserIDMu.RLock()
+	message.IsFromMe = file.User != "" && file.User == p.currentUserID
+	p.currentUserIDMu.RUnlock()
+
+	name := share.ChannelName
+	if name == "" && conversationID != "" {
+		name = p.channelName(channelID)
+	}
+	return core.SearchHit{
+		Message:          message,
+		ConversationName: name,
+		IsGroup:          conversationID != "" && conversationID == channelID,
+		Highlights:       highlightedTerms(title),
+		Permalink:        file.Permalink,
+		Remote:           true,
+	}
+}
+
+// searchAttachment maps a file found by search.files to an attachment from its metadata only:
+// it links to the file on Slack and to its thumbnail there, nothing is downloaded.
+func searchAttachment(file slack.File) models.Attachment {
+	att, _ := convertSlackFile("", file)
+	att.Thumbnail = slackThumbnailURL(file)
+	return att
+}
+
+// firstFileShare returns a channel the file was shared in and the share, public channels first.
+func firstFileShare(file slack.File) (string, slack.ShareFileInfo) {
+	for _, shares := range []map[string][]slack.ShareFileInfo{file.Shares.Public, file.Shares.Private} {
+		for channelID, infos := range shares {
+			if len(infos) > 0 {
+				return channelID, infos[0]
+			}
+		}
+	}
+	for _, channels := range [][]string{file.Channels, file.Groups, file.IMs} {
+		if len(channels) > 0 {
+			return channels[0], slack.ShareFileInfo{}
+		}
+	}
+	return "", slack.ShareFileInfo{}
+}
+
+// contextMessage converts a neighbouring message returned with a search match.
+func (p *SlackProvider) contextMessage(neighbour slack.CtxMessage, conversationID string) models.Message {
+	senderName := neighbour.Username
+	if neighbour.User != "" {
+		if name := p.userName(neighbour.User); name != "" {
+			senderName = name
+		}
+	}
+	p.currentUserIDMu.RLock()
+	isFromMe := neighbour.User != "" && neighbour.User == p.currentUserID
+	p.currentUserIDMu.RUnlock()
+
+	return models.Message{
+		ProtocolMsgID:  neighbour.Timestamp,
+		ProtocolConvID: conversationID,
+		SenderID:       neighbour.User,
+		SenderName:     senderName,
+		Body:           p.messageRichText(slack.Msg{Text: stripHighlights(neighbour.Text)}).Markdown(),
+		Timestamp:      parseSlackTimestamp(neighbour.Timestamp),
+		IsFromMe:       isFromMe,
+	}
+}
+
+// searchConversationName returns the name of the conversation of a match: the channel name,
+// or the name of the other person of a direct message.
+func (p *SlackProvider) searchConversationName(channel slack.CtxChannel, conversationID string) string {
+	if conversationID != channel.ID {
+		return p.userName(conversationID)
+	}
+	if channel.Name != "" {
+		return channel.Name
+	}
+	return p.channelName(channel.ID)
+}
+
+// highlightedTerms returns the distinct terms surrounded by highlight markers.
+func highlightedTerms(text string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for {
+		start := strings.Index(text, highlightStart)
+		if start == -1 {
+			return terms
+		}
+		text = text[start+len(highlightStart):]
+		end := strings.Index(text, highlightEnd)
+		if end == -1 {
+			return terms
+		}
+		term := text[:end]
+		text = text[end+len(highlightEnd):]
+		if key := strings.ToLower(term); term != "" && !seen[key] {
+			seen[key] = true
+			terms = append(terms, term)
+		}
+	}
+}
+
+// stripHighlights removes the highlight markers of a search result text.
+func stripHighlights(text string) string {
+	return strings.NewReplacer(highlightStart, "", highlightEnd, "").Replace(text)
+}
+
+// permalinkThread returns the thread_ts parameter of a message permalink (set for thread replies).
+func permalinkThread(permalink string) string {
+	parsed, err := url.Parse(permalink)
+	if err != nil {
+		return ""
+	}
+	return parsed.Query().Get("thread_ts")
+}
+
+// searchError explains the Slack search errors caused by the token.
+func searchError(err error) error {
+	switch code := err.Error(); {
+	case strings.Contains(code, "not_allowed_token_type"):
+		return fmt.Errorf("searching Slack needs a user token, bot tokens cannot search")
+	case strings.Contains(code, "missing_scope"):
+		return fmt.Errorf("the Slack token is missing the search:read permission: %w", err)
+	}
+	return fmt.Errorf("slack search failed: %w", err)
+}
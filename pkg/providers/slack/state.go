@@ -16,7 +16,9 @@ func (p *SlackProvider) PinConversation(conversationID string) error {
 		return fmt.Errorf("slack client not initialized")
 	}
 
-	return p.client.AddStar(conversationID, slack.ItemRef{Channel: conversationID})
+	return p.callAPI("stars.add", priorityInteractive, func() error {
+		return p.client.AddStar(conversationID, slack.ItemRef{Channel: conversationID})
+	})
 }
 
 // UnpinConversation unpins a conversation.
@@ -28,7 +30,9 @@ func (p *SlackProvider) UnpinConversation(conversationID string) error {
 		return fmt.Errorf("slack client not initialized")
 	}
 
-	return p.client.RemoveStar(conversationID, slack.ItemRef{Channel: conversationID})
+	return p.callAPI("stars.remove", priorityInteractive, func() error {
+		return p.client.RemoveStar(conversationID, slack.ItemRef{Channel: conversationID})
+	})
 }
 
 // MuteConversation mutes a conversation.
@@ -0,0 +1,188 @@
+package slack
+
+import (
+	"Loom/pkg/core"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Ensure the provider can manage the status of the account
+var _ core.MyStatusManager = (*SlackProvider)(nil)
+
+// MyStatusFeatures returns the parts of the status supported by Slack: all of them.
+func (p *SlackProvider) MyStatusFeatures() core.MyStatusFeatures {
+	return core.MyStatusFeatures{Emoji: true, Expiration: true, DoNotDisturb: true}
+}
+
+// GetMyStatus returns the custom status (users.profile.get), the presence (users.getPresence)
+// and the do not disturb state (dnd.info) of the account.
+func (p *SlackProvider) GetMyStatus() (*core.MyStatus, error) {
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("slack client not initialized")
+	}
+
+	var profile *slack.UserProfile
+	err := p.callAPI("users.profile.get", priorityInteractive, func() error {
+		var err error
+		profile, err = client.GetUserProfile(&slack.GetUserProfileParameters{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the profile: %w", err)
+	}
+
+	status := &core.MyStatus{
+		Text:  profile.StatusText,
+		Emoji: profile.StatusEmoji,
+	}
+	if profile.StatusExpiration > 0 {
+		expiration := time.Unix(int64(profile.StatusExpiration), 0)
+		status.Expiration = &expiration
+	}
+
+	selfID := p.GetSelfUserID()
+	if selfID != "" {
+		var presence *slack.UserPresence
+		err := p.callAPI("users.getPresence", priorityInteractive, func() error {
+			var err error
+			presence, err = client.GetUserPresence(selfID)
+			return err
+		})
+		if err != nil {
+			p.log("SlackProvider.GetMyStatus: WARNING - failed to get presence: %v\n", err)
+		} else if presence.ManualAway {
+			status.Presence = core.PresenceAway
+		} else {
+			status.Presence = core.PresenceAvailable
+		}
+	}
+
+	var dnd *slack.DNDStatus
+	err = p.callAPI("dnd.info", priorityInteractive, func() error {
+		var err error
+		dnd, err = client.GetDNDInfo(nil)
+		return err
+	})
+	if err != nil {
+		p.log("SlackProvider.GetMyStatus: WARNING - failed to get do not disturb info: %v\n", err)
+	} else if dnd.SnoozeEnabled && dnd.SnoozeEndTime > 0 {
+		until := time.Unix(int64(dnd.SnoozeEndTime), 0)
+		status.DNDUntil = &until
+	}
+
+	return status, nil
+}
+
+// SetMyStatusText sets the custom status of the account (users.profile.set).
+// An empty text and emoji clear the status; a nil expiration keeps it until it is changed.
+func (p *SlackProvider) SetMyStatusText(text string, emoji string, expiration *time.Time) error {
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+
+	if client == nil {
+		return fmt.Errorf("slack client not initialized")
+	}
+
+	if text == "" && emoji == "" {
+		if err := p.callAPI("users.profile.set", priorityInteractive, client.UnsetUserCustomStatus); err != nil {
+			return fmt.Errorf("failed to clear the status: %w", err)
+		}
+		p.log("SlackProvider.SetMyStatusText: status cleared\n")
+		return nil
+	}
+
+	// Slack expects the emoji as a shortcode with colons
+	if emoji != "" && !strings.HasPrefix(emoji, ":") {
+		emoji = ":" + strings.TrimSuffix(emoji, ":") + ":"
+	}
+	var expiresAt int64
+	if expiration != nil {
+		if !expiration.After(time.Now()) {
+			return fmt.Errorf("the status expiration is in the past")
+		}
+		expiresAt = expiration.Unix()
+	}
+
+	err := p.callAPI("users.profile.set", priorityInteractive, func() error {
+		return client.SetUserCustomStatus(text, emoji, expiresAt)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set the status: %w", err)
+	}
+	p.log("SlackProvider.SetMyStatusText: status set to %q %s (expires at %d)\n", text, emoji, expiresAt)
+	return nil
+}
+
+// SetMyPresence sets the presence of the account (users.setPresence): available lets Slack
+// compute it from the activity ("auto"), away forces it.
+func (p *SlackProvider) SetMyPresence(presence core.PresenceState) error {
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+
+	if client == nil {
+		return fmt.Errorf("slack client not initialized")
+	}
+
+	var value string
+	switch presence {
+	case core.PresenceAvailable:
+		value = "auto"
+	case core.PresenceAway:
+		value = "away"
+	default:
+		return fmt.Errorf("unknown presence %q", presence)
+	}
+
+	err := p.callAPI("users.setPresence", priorityInteractive, func() error {
+		return client.SetUserPresence(value)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set the presence: %w", err)
+	}
+	p.log("SlackProvider.SetMyPresence: presence set to %s\n", value)
+	return nil
+}
+
+// SetDoNotDisturb snoozes the notifications of the account for duration (dnd.setSnooze,
+// rounded up to the minute), or ends the snooze when duration is zero (dnd.endSnooze).
+func (p *SlackProvider) SetDoNotDisturb(duration time.Duration) error {
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+
+	if client == nil {
+		return fmt.Errorf("slack client not initialized")
+	}
+
+	if duration <= 0 {
+		err := p.callAPI("dnd.endSnooze", priorityInteractive, func() error {
+			_, err := client.EndSnooze()
+			return err
+		})
+		if err != nil && !strings.Contains(err.Error(), "snooze_not_active") {
+			return fmt.Errorf("failed to end do not disturb: %w", err)
+		}
+		p.log("SlackProvider.SetDoNotDisturb: do not disturb ended\n")
+		return nil
+	}
+
+	minutes := int((duration + time.Minute - 1) / time.Minute)
+	err := p.callAPI("dnd.setSnooze", priorityInteractive, func() error {
+		_, err := client.SetSnooze(minutes)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set do not disturb: %w", err)
+	}
+	p.log("SlackProvider.SetDoNotDisturb: do not disturb for %d minutes\n", minutes)
+	return nil
+}
@@ -0,0 +1,300 @@
+package slack
+
+import (
+	"Loom/pkg/core"
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"encoding/json"
+	"fmt"
+
+	"github.com/slack-go/slack"
+)
+
+// threadRepliesPageSize is the number of messages fetched per conversations.replies call.
+const threadRepliesPageSize = 200
+
+// GetThreads loads all replies of a thread from Slack, stores them and returns them oldest first.
+// Slack needs the channel of the thread, so the parent message must already be stored
+// (i.e. its conversation has been loaded). Stored replies are returned if Slack is unreachable.
+// Message timestamps are only unique within a channel: GetConversationThread should be
+// preferred when the conversation is known.
+func (p *SlackProvider) GetThreads(parentMessageID string) ([]models.Message, error) {
+	return p.GetConversationThread("", parentMessageID)
+}
+
+// GetConversationThread is GetThreads with the parent message looked up in conversationID.
+// An empty conversationID looks the parent up in all the conversations.
+func (p *SlackProvider) GetConversationThread(conversationID string, parentMessageID string) ([]models.Message, error) {
+	if db.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	query := db.DB.Where("protocol_msg_id = ?", parentMessageID)
+	if conversationID != "" {
+		query = query.Where("protocol_conv_id = ?", conversationID)
+	}
+	var parent models.Message
+	if err := query.First(&parent).Error; err != nil {
+		return nil, fmt.Errorf("thread parent %s not found, load its conversation first", parentMessageID)
+	}
+	// The parent may itself be a reply: Slack threads are a single level deep
+	parentTS := parentMessageID
+	if parent.ThreadID != nil && *parent.ThreadID != "" {
+		parentTS = *parent.ThreadID
+	}
+
+	replies, err := p.loadThread(parent.ProtocolConvID, parentTS)
+	if err != nil {
+		p.log("SlackProvider.GetConversationThread: WARNING - failed to load thread %s from Slack: %v, using stored replies\n", parentTS, err)
+		stored, dbErr := storedThreadReplies(parent.ProtocolConvID, parentTS)
+		if dbErr != nil || len(stored) == 0 {
+			return nil, err
+		}
+		p.enrichMessagesWithSenderInfo(stored)
+		return stored, nil
+	}
+	return replies, nil
+}
+
+// loadThread fetches a thread with conversations.replies, following the pagination cursor,
+// stores the parent and its replies and returns the replies oldest first.
+func (p *SlackProvider) loadThread(conversationID, parentTS string) ([]models.Message, error) {
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("slack client not initialized")
+	}
+
+	channelID, err := p.channelIDForConversation(client, conversationID, priorityInteractive)
+	if err != nil {
+		return nil, err
+	}
+
+	var thread []models.Message
+	var replies []models.Message
+	cursor := ""
+	for page := 1; ; page++ {
+		var msgs []slack.Message
+		var hasMore bool
+		var nextCursor string
+		err := p.callAPI("conversations.replies", priorityInteractive, func() error {
+			var err error
+			msgs, hasMore, nextCursor, err = client.GetConversationReplies(&slack.GetConversationRepliesParameters{
+				ChannelID: channelID,
+				Timestamp: parentTS,
+				Cursor:    cursor,
+				Limit:     threadRepliesPageSize,
+			})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load thread %s: %w", parentTS, err)
+		}
+		for _, msg := range msgs {
+			converted := p.convertSlackMessage(msg, conversationID)
+			thread = append(thread, converted)
+			if msg.Timestamp != parentTS {
+				replies = append(replies, converted)
+			}
+		}
+		p.log("SlackProvider.loadThread: page %d of thread %s: %d messages\n", page, parentTS, len(msgs))
+
+		if !hasMore || nextCursor == "" {
+			break
+		}
+		cursor = nextCursor
+	}
+
+	p.storeMessagesForConversation(conversationID, thread)
+	p.log("SlackProvider.loadThread: loaded %d replies of thread %s in %s\n", len(replies), parentTS, conversationID)
+	return replies, nil
+}
+
+// storedThreadReplies returns the stored replies of a thread, oldest first.
+func storedThreadReplies(conversationID, parentTS string) ([]models.Message, error) {
+	var replies []models.Message
+	err := db.DB.Where("protocol_conv_id = ? AND thread_id = ? AND protocol_msg_id <> ?", conversationID, parentTS, parentTS).
+		Order("timestamp asc").
+		Find(&replies).Error
+	return replies, err
+}
+
+// SendThreadReply posts text in a thread. With alsoSendToChannel, the reply is also
+// shown in the conversation ("Also send to channel" in Slack).
+func (p *SlackProvider) SendThreadReply(conversationID string, threadID string, text string, alsoSendToChannel bool) (*models.Message, error) {
+	return p.sendText(conversationID, text, &threadID, alsoSendToChannel)
+}
+
+// threadRoot returns the timestamp of the thread parent for messageID: the message itself,
+// or the parent of its thread when it is already a reply.
+func (p *SlackProvider) threadRoot(conversationID, messageID string) string {
+	if db.DB == nil {
+		return messageID
+	}
+	var message models.Message
+	if err := db.DB.Where("protocol_msg_id = ? AND protocol_conv_id = ?", messageID, conversationID).First(&message).Error; err != nil {
+		return messageID
+	}
+	if message.ThreadID != nil && *message.ThreadID != "" {
+		return *message.ThreadID
+	}
+	return messageID
+}
+
+// isMessageStored reports whether a message is already in the database.
+func (p *SlackProvider) isMessageStored(conversationID, messageID string) bool {
+	if db.DB == nil {
+		return false
+	}
+	var count int64
+	db.DB.Model(&models.Message{}).Where("protocol_msg_id = ? AND protocol_conv_id = ?", messageID, conversationID).Count(&count)
+	return count > 0
+}
+
+// applyThreadReply updates the reply count, latest reply and participants of the parent
+// of a new reply, then emits a ThreadUpdateEvent.
+// It must only be called once per reply, when the reply was not stored yet.
+func (p *SlackProvider) applyThreadReply(conversationID string, reply models.Message) {
+	if reply.ThreadID == nil || *reply.ThreadID == "" {
+		return
+	}
+	parentTS := *reply.ThreadID
+
+	event := core.ThreadUpdateEvent{
+		ConversationID:  conversationID,
+		ParentMessageID: parentTS,
+		LatestReplyAt:   reply.Timestamp.Unix(),
+		LatestReplyID:   reply.ProtocolMsgID,
+	}
+
+	var parent models.Message
+	if db.DB != nil && db.DB.Where("protocol_msg_id = ? AND protocol_conv_id = ?", parentTS, conversationID).First(&parent).Error == nil {
+		users := decodeThreadUsers(parent.ThreadUsers)
+		if reply.SenderID != "" && !containsString(users, reply.SenderID) {
+			users = append(users, reply.SenderID)
+		}
+		latestAt := reply.Timestamp
+		if parent.ThreadLatestAt != nil && parent.ThreadLatestAt.After(latestAt) {
+			latestAt = *parent.ThreadLatestAt
+		}
+		replyCount := parent.ThreadReplyCount + 1
+
+		if err := db.DB.Model(&models.Message{}).Where("id = ?", parent.ID).Updates(map[string]interface{}{
+			"thread_reply_count": replyCount,
+			"thread_latest_at":   latestAt,
+			"thread_users":       encodeThreadUsers(users),
+		}).Error; err != nil {
+			p.log("SlackProvider.applyThreadReply: failed to update thread parent %s: %v\n", parentTS, err)
+		}
+
+		event.ReplyCount = replyCount
+		event.LatestReplyAt = latestAt.Unix()
+		event.ParticipantIDs = users
+	} else {
+		// Parent not stored: report what is known locally
+		var count int64
+		if db.DB != nil {
+			db.DB.Model(&models.Message{}).Where("protocol_conv_id = ? AND thread_id = ? AND protocol_msg_id <> ?", conversationID, parentTS, parentTS).Count(&count)
+		}
+		event.ReplyCount = int(count)
+		if reply.SenderID != "" {
+			event.ParticipantIDs = []string{reply.SenderID}
+		}
+	}
+
+	p.log("SlackProvider.applyThreadReply: thread %s in %s now has %d replies\n", parentTS, conversationID, event.ReplyCount)
+	p.emitEvent(event)
+}
+
+// applyThreadMetadata copies the thread information of a Slack message onto a converted message:
+// the parent of replies, and the reply count, latest reply and participants of thread parents.
+func applyThreadMetadata(message *models.Message, msg slack.Msg) {
+	if msg.ThreadTimestamp != "" && msg.ThreadTimestamp != msg.Timestamp {
+		threadID := msg.ThreadTimestamp
+		message.ThreadID = &threadID
+		message.ThreadBroadcast = msg.SubType == slack.MsgSubTypeThreadBroadcast
+	}
+	if msg.ReplyCount > 0 {
+		message.ThreadReplyCount = msg.ReplyCount
+		if msg.LatestReply != "" {
+			latestAt := parseSlackTimestamp(msg.LatestReply)
+			message.ThreadLatestAt = &latestAt
+		}
+		message.ThreadUsers = encodeThreadUsers(msg.ReplyUsers)
+	}
+}
+
+// channelIDForConversation returns the Slack channel to call the API with: DMs are stored
+// under the user ID of the other person and need their D... channel.
+func (p *SlackProvider) channelIDForConversation(client *slack.Client, conversationID string, priority requestPriority) (string, error) {
+	if len(conversationID) == 0 || conversationID[0] != 'U' {
+		return conversationID, nil
+	}
+
+	// DM channels already opened do not need another conversations.open call
+	p.realtimeMu.Lock()
+	for channelID, userID := range p.dmUsers {
+		if userID == conversationID {
+			p.realtimeMu.Unlock()
+			return channelID, nil
+		}
+	}
+	p.realtimeMu.Unlock()
+
+	var channel *slack.Channel
+	err := p.callAPI("conversations.open", priority, func() error {
+		var err error
+		channel, _, _, err = client.OpenConversation(&slack.OpenConversationParameters{
+			Users: []string{conversationID},
+		})
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to open DM conversation with user %s: %w", conversationID, err)
+	}
+	if channel == nil || channel.ID == "" {
+		return "", fmt.Errorf("failed to get DM channel ID for user %s", conversationID)
+	}
+
+	// Remember the mapping for real-time events of this DM
+	p.realtimeMu.Lock()
+	p.dmUsers[channel.ID] = conversationID
+	p.realtimeMu.Unlock()
+	return channel.ID, nil
+}
+
+// encodeThreadUsers stores the participants of a thread as a JSON array ("" when empty).
+func encodeThreadUsers(users []string) string {
+	if len(users) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(users)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// decodeThreadUsers reads the participants of a thread stored by encodeThreadUsers.
+func decodeThreadUsers(value string) []string {
+	if value == "" {
+		return nil
+	}
+	var users []string
+	if err := json.Unmarshal([]byte(value), &users); err != nil {
+		return nil
+	}
+	return users
+}
+
+// containsString reports whether values contains value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,393 @@
+package slack
+
+import (
+	"Loom/pkg/core"
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	// clientBootURL is the page of the Slack web client listing the workspaces of the browser
+	// session, with their client tokens.
+	clientBootURL = "https://app.slack.com/auth?app=client&teams=&iframe=1"
+	// maxBootPageSize bounds the boot page read when looking for client tokens.
+	maxBootPageSize = 8 << 20
+	// browserUserAgent is sent to the web client pages, which turn away unknown browsers.
+	browserUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"
+)
+
+// clientTokenPattern matches the client tokens of a web client page.
+var clientTokenPattern = regexp.MustCompile(`xoxc-[0-9A-Za-z-]+`)
+
+// Workspace is a Slack workspace (team) reachable from a client session.
+type Workspace struct {
+	TeamID         string `json:"teamId"`
+	Name           string `json:"name"`
+	Domain         string `json:"domain,omitempty"`
+	URL            string `json:"url,omitempty"`
+	EnterpriseID   string `json:"enterpriseId,omitempty"`   // Enterprise Grid org of the workspace
+	EnterpriseName string `json:"enterpriseName,omitempty"` // Name of the Enterprise Grid org
+	UserID         string `json:"userId"`                   // The signed-in user in the workspace
+	UserName       string `json:"userName"`
+	Attached       bool   `json:"attached"` // A Slack instance already uses the workspace
+	Token          string `json:"-"`        // Client token of the workspace, kept in the backend
+}
+
+// DiscoverWorkspaces lists the workspaces a client token (xoxc-) and its d cookie give access to:
+//   - the workspace of the token;
+//   - the other workspaces of its Enterprise Grid org, which the same token can use;
+//   - the other workspaces signed in to in the same browser session, found in the boot page
+//     of the Slack web client, whose tokens are checked with auth.test.
+//
+// The boot page is not a documented API: when it cannot be read, only the workspaces of the
+// token (and its org) are listed.
+func DiscoverWorkspaces(token string, dCookie string) ([]Workspace, error) {
+	token = strings.TrimSpace(token)
+	dCookie = strings.TrimSpace(dCookie)
+	if !strings.HasPrefix(token, "xoxc-") || dCookie == "" {
+		return nil, fmt.Errorf("finding workspaces needs a client token (xoxc-) and its d cookie")
+	}
+	httpClient := newCookieClient(dCookie)
+
+	primary, err := authWorkspace(httpClient, token)
+	if err != nil {
+		return nil, fmt.Errorf("the client token was refused: %w", err)
+	}
+
+	var workspaces []Workspace
+	seen := make(map[string]bool)
+	expanded := make(map[string]bool)
+	add := func(workspace Workspace) {
+		if seen[workspace.TeamID] {
+			return
+		}
+		seen[workspace.TeamID] = true
+		workspaces = append(workspaces, workspace)
+		if workspace.EnterpriseID != "" && !expanded[workspace.EnterpriseID] {
+			expanded[workspace.EnterpriseID] = true
+			for _, orgWorkspace := range gridWorkspaces(httpClient, workspace) {
+				if !seen[orgWorkspace.TeamID] {
+					seen[orgWorkspace.TeamID] = true
+					workspaces = append(workspaces, orgWorkspace)
+				}
+			}
+		}
+	}
+	add(*primary)
+
+	tokens, err := sessionTokens(httpClient)
+	if err != nil {
+		fmt.Printf("Slack.DiscoverWorkspaces: WARNING - failed to read the workspaces of the session: %v\n", err)
+	}
+	for _, sessionToken := range tokens {
+		if sessionToken == token {
+			continue
+		}
+		workspace, err := authWorkspace(httpClient, sessionToken)
+		if err != nil {
+			fmt.Printf("Slack.DiscoverWorkspaces: WARNING - skipping a token of the session: %v\n", err)
+			continue
+		}
+		add(*workspace)
+	}
+
+	attached := attachedWorkspaces()
+	for i := range workspaces {
+		workspaces[i].Attached = attached[workspaces[i].TeamID] || attached[workspaces[i].Token]
+	}
+
+	// The workspace of the token first, then by org and name
+	sort.SliceStable(workspaces[1:], func(i, j int) bool {
+		a, b := workspaces[1+i], workspaces[1+j]
+		if a.EnterpriseName != b.EnterpriseName {
+			return a.EnterpriseName < b.EnterpriseName
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+	fmt.Printf("Slack.DiscoverWorkspaces: found %d workspaces\n", len(workspaces))
+	return workspaces, nil
+}
+
+// authWorkspace returns the workspace of a client token, named after team.info.
+func authWorkspace(httpClient *http.Client, token string) (*Workspace, error) {
+	client := slack.New(token, slack.OptionHTTPClient(httpClient))
+	auth, err := client.AuthTest()
+	if err != nil {
+		return nil, err
+	}
+	workspace := &Workspace{
+		TeamID:       auth.TeamID,
+		Name:         auth.Team,
+		URL:          auth.URL,
+		EnterpriseID: auth.EnterpriseID,
+		UserID:       auth.UserID,
+		UserName:     auth.User,
+		Token:        token,
+	}
+	if team, err := client.GetTeamInfo(); err == nil {
+		workspace.Name = team.Name
+		workspace.Domain = team.Domain
+	}
+	return workspace, nil
+}
+
+// gridWorkspaces returns the other workspaces of the Enterprise Grid org of a workspace that
+// the user is a member of. They are used with the token of the workspace, scoped by team ID.
+func gridWorkspaces(httpClient *http.Client, workspace Workspace) []Workspace {
+	client := slack.New(workspace.Token, slack.OptionHTTPClient(httpClient))
+	user, err := client.GetUserInfo(workspace.UserID)
+	if err != nil {
+		fmt.Printf("Slack.gridWorkspaces: WARNING - failed to get the workspaces of %s: %v\n", workspace.UserID, err)
+		return nil
+	}
+
+	var workspaces []Workspace
+	for _, teamID := range user.Enterprise.Teams {
+		if teamID == workspace.TeamID {
+			continue
+		}
+		orgWorkspace := Workspace{
+			TeamID:         teamID,
+			Name:           teamID,
+			EnterpriseID:   workspace.EnterpriseID,
+			EnterpriseName: user.Enterprise.EnterpriseName,
+			UserID:         workspace.UserID,
+			UserName:       workspace.UserName,
+			Token:          workspace.Token,
+		}
+		if team, err := client.GetOtherTeamInfo(teamID); err == nil {
+			orgWorkspace.Name = team.Name
+			orgWorkspace.Domain = team.Domain
+			orgWorkspace.URL = "https://" + team.Domain + ".slack.com/"
+		}
+		workspaces = append(workspaces, orgWorkspace)
+	}
+	return workspaces
+}
+
+// sessionTokens returns the distinct client tokens of the boot page of the web client.
+func sessionTokens(httpClient *http.Client) ([]string, error) {
+	req, err := http.NewRequest(http.MethodGet, clientBootURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", browserUserAgent)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the web client returned %s", resp.Status)
+	}
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxBootPageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []string
+	seen := make(map[string]bool)
+	for _, token := range clientTokenPattern.FindAllString(string(page), -1) {
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+// attachedWorkspaces returns the team IDs of the configured Slack instances, and the tokens
+// of those configured without a team ID.
+func attachedWorkspaces() map[string]bool {
+	attached := make(map[string]bool)
+	for _, values := range slackInstanceConfigs() {
+		if teamID, _ := values["team_id"].(string); teamID != "" {
+			attached[teamID] = true
+		} else if token, _ := values["token"].(string); token != "" {
+			attached[token] = true
+		}
+	}
+	return attached
+}
+
+// slackInstanceConfigs returns the stored configurations of the Slack instances.
+func slackInstanceConfigs() []map[string]interface{} {
+	if db.DB == nil {
+		return nil
+	}
+	var configs []models.ProviderConfiguration
+	if err := db.DB.Where("provider_id = ?", "slack").Find(&configs).Error; err != nil {
+		fmt.Printf("Slack.slackInstanceConfigs: WARNING - failed to load the Slack instances: %v\n", err)
+		return nil
+	}
+	var values []map[string]interface{}
+	for _, config := range configs {
+		var configValues map[string]interface{}
+		if err := json.Unmarshal([]byte(config.ConfigJSON), &configValues); err == nil {
+			values = append(values, configValues)
+		}
+	}
+	return values
+}
+
+// WorkspaceConfig returns the configuration of the instance of a discovered workspace, using
+// the d cookie of a session saved with SaveSession.
+func WorkspaceConfig(workspace Workspace, sessionID string) core.ProviderConfig {
+	return core.ProviderConfig{
+		"token":      workspace.Token,
+		"team_id":    workspace.TeamID,
+		"session_id": sessionID,
+	}
+}
+
+// SaveSession stores the d cookie of a client session, or finds the session already storing
+// it, and returns the session ID.
+func SaveSession(dCookie string) (string, error) {
+	if db.DB == nil {
+		return "", fmt.Errorf("database not initialized")
+	}
+	cookie := strings.TrimPrefix(strings.TrimSpace(dCookie), "d=")
+	if cookie == "" {
+		return "", fmt.Errorf("the d cookie is empty")
+	}
+	hash := sha256.Sum256([]byte(cookie))
+	session := models.SlackSession{CookieHash: hex.EncodeToString(hash[:])}
+	if err := db.DB.Where("cookie_hash = ?", session.CookieHash).Attrs(models.SlackSession{DCookie: cookie}).FirstOrCreate(&session).Error; err != nil {
+		return "", fmt.Errorf("failed to save the Slack session: %w", err)
+	}
+	return strconv.FormatUint(uint64(session.ID), 10), nil
+}
+
+// sessionCookie returns the d cookie of a saved session.
+func sessionCookie(sessionID string) (string, error) {
+	if db.DB == nil {
+		return "", fmt.Errorf("database not initialized")
+	}
+	var session models.SlackSession
+	if err := db.DB.First(&session, "id = ?", sessionID).Error; err != nil {
+		return "", fmt.Errorf("unknown Slack session %s: %w", sessionID, err)
+	}
+	return session.DCookie, nil
+}
+
+// PruneSessions deletes the sessions no Slack instance uses anymore, so that the cookie of
+// a session does not outlive its workspaces.
+func PruneSessions() {
+	if db.DB == nil {
+		return
+	}
+	used := []string{}
+	for _, values := range slackInstanceConfigs() {
+		if sessionID, _ := values["session_id"].(string); sessionID != "" {
+			used = append(used, sessionID)
+		}
+	}
+
+	query := db.DB.Model(&models.SlackSession{})
+	if len(used) > 0 {
+		query = query.Where("id NOT IN ?", used)
+	} else {
+		query = query.Where("1 = 1")
+	}
+	result := query.Delete(&models.SlackSession{})
+	if result.Error != nil {
+		fmt.Printf("Slack.PruneSessions: WARNING - failed to delete unused sessions: %v\n", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		fmt.Printf("Slack.PruneSessions: deleted %d unused sessions\n", result.RowsAffected)
+	}
+}
+
+// workspaceTeam returns the team and Enterprise Grid org of the instance.
+func (p *SlackProvider) workspaceTeam() (teamID string, enterpriseID string) {
+	p.teamMu.RLock()
+	defer p.teamMu.RUnlock()
+	return p.teamID, p.enterpriseID
+}
+
+// scopeTeamID returns the team_id the workspace-level calls (users.list, conversations.list,
+// search) are scoped to. Only Enterprise Grid tokens, valid in all the workspaces of the org,
+// need it; it is "" otherwise.
+func (p *SlackProvider) scopeTeamID() string {
+	teamID, enterpriseID := p.workspaceTeam()
+	if enterpriseID == "" {
+		return ""
+	}
+	return teamID
+}
+
+// cacheChannelTeams remembers the workspace (context_team_id) of the channels.
+func (p *SlackProvider) cacheChannelTeams(channels []slack.Channel) {
+	p.channelTeamsMu.Lock()
+	defer p.channelTeamsMu.Unlock()
+	for _, channel := range channels {
+		p.channelTeams[channel.ID] = channel.ContextTeamID
+	}
+}
+
+// cachedChannelTeam returns the cached workspace of a channel.
+func (p *SlackProvider) cachedChannelTeam(channelID string) (string, bool) {
+	p.channelTeamsMu.RLock()
+	defer p.channelTeamsMu.RUnlock()
+	teamID, ok := p.channelTeams[channelID]
+	return teamID, ok
+}
+
+// isOtherTeam reports whether a context_team_id belongs to another workspace of the
+// Enterprise Grid org. Channels of the org itself (org-wide channels and DMs) are kept.
+func (p *SlackProvider) isOtherTeam(contextTeamID string) bool {
+	teamID, enterpriseID := p.workspaceTeam()
+	if enterpriseID == "" || contextTeamID == "" || teamID == "" {
+		return false
+	}
+	return contextTeamID != teamID && contextTeamID != enterpriseID
+}
+
+// isOtherTeamChannel reports whether a channel belongs to another workspace of the
+// Enterprise Grid org. The org-wide token of the workspaces delivers the real-time events of
+// all of them; each instance only keeps those of its own workspace. Unknown channels are
+// looked up with conversations.info; they are kept when the lookup fails.
+func (p *SlackProvider) isOtherTeamChannel(channelID string) bool {
+	if channelID == "" {
+		return false
+	}
+	if _, enterpriseID := p.workspaceTeam(); enterpriseID == "" {
+		return false
+	}
+	if teamID, ok := p.cachedChannelTeam(channelID); ok {
+		return p.isOtherTeam(teamID)
+	}
+
+	p.mu.RLock()
+	client := p.client
+	p.mu.RUnlock()
+	if client == nil {
+		return false
+	}
+
+	var channel *slack.Channel
+	err := p.callAPI("conversations.info", priorityBackground, func() error {
+		var err error
+		channel, err = client.GetConversationInfo(&slack.GetConversationInfoInput{ChannelID: channelID})
+		return err
+	})
+	if err != nil || channel == nil {
+		p.log("SlackProvider.isOtherTeamChannel: WARNING - failed to get the workspace of %s: %v\n", channelID, err)
+		return false
+	}
+	p.cacheChannelTeams([]slack.Channel{*channel})
+	return p.isOtherTeam(channel.ContextTeamID)
+}
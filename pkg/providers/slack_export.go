@@ -10,3 +10,31 @@ import (
 func NewSlackProvider() core.Provider {
 	return slack.NewSlackProvider()
 }
+
+// SlackWorkspace is a Slack workspace reachable from a client session.
+// This is a re-export from the slack subpackage.
+type SlackWorkspace = slack.Workspace
+
+// DiscoverSlackWorkspaces lists the workspaces a Slack client token and its d cookie give access to.
+// This is a re-export from the slack subpackage.
+func DiscoverSlackWorkspaces(token string, dCookie string) ([]SlackWorkspace, error) {
+	return slack.DiscoverWorkspaces(token, dCookie)
+}
+
+// SaveSlackSession stores the d cookie shared by the workspaces of a client session.
+// This is a re-export from the slack subpackage.
+func SaveSlackSession(dCookie string) (string, error) {
+	return slack.SaveSession(dCookie)
+}
+
+// SlackWorkspaceConfig returns the configuration of the instance of a discovered workspace.
+// This is a re-export from the slack subpackage.
+func SlackWorkspaceConfig(workspace SlackWorkspace, sessionID string) core.ProviderConfig {
+	return slack.WorkspaceConfig(workspace, sessionID)
+}
+
+// PruneSlackSessions deletes the client sessions no Slack instance uses anymore.
+// This is a re-export from the slack subpackage.
+func PruneSlackSessions() {
+	slack.PruneSessions()
+}
@@ -542,9 +542,16 @@ func (w *WhatsAppProvider) eventHandler(evt interface{}) {
 			// IMPORTANT: Mark client as available to receive typing indicators
 			// Without this, WhatsApp will not send ChatPresence events (typing notifications)
 			// Reference: https://github.com/tulir/whatsmeow/discussions/681
-			err := w.client.SendPresence(w.ctx, types.PresenceAvailable)
+			// Keep the presence set from Loom (SetMyPresence) across reconnections
+			presence := types.PresenceAvailable
+			w.mu.RLock()
+			if w.selfPresence != "" {
+				presence = w.selfPresence
+			}
+			w.mu.RUnlock()
+			err := w.client.SendPresence(w.ctx, presence)
 			if err != nil {
-				fmt.Printf("WhatsApp: Warning - Failed to send presence available: %v\n", err)
+				fmt.Printf("WhatsApp: Warning - Failed to send presence %s: %v\n", presence, err)
 			} else {
 				fmt.Println("WhatsApp: Marked self as available - will now receive typing indicators and presence updates")
 			}
@@ -584,6 +591,26 @@ func (w *WhatsAppProvider) eventHandler(evt interface{}) {
 		fmt.Println("WhatsApp: Logged out event received")
 	case *events.StreamError:
 		fmt.Printf("WhatsApp: Stream error: %v\n", v)
+	case *events.MarkChatAsRead:
+		// The chat was marked as read or unread from another device
+		if !v.Action.GetRead() {
+			fmt.Printf("WhatsApp: Chat %s marked as unread from another device, ignoring\n", v.JID.String())
+			break
+		}
+		readEvent := core.ReadMarkerEvent{
+			ConversationID: v.JID.String(),
+			UnreadCount:    0,
+			Timestamp:      v.Action.GetMessageRange().GetLastMessageTimestamp(),
+		}
+		if readEvent.Timestamp == 0 {
+			readEvent.Timestamp = v.Timestamp.Unix()
+		}
+		select {
+		case w.eventChan <- readEvent:
+			fmt.Printf("WhatsApp: ReadMarkerEvent emitted for chat %s\n", v.JID.String())
+		default:
+			fmt.Printf("WhatsApp: WARNING - Failed to emit ReadMarkerEvent for chat %s (channel full)\n", v.JID.String())
+		}
 	case *events.Receipt:
 		// Handle read receipts (message read confirmations)
 		// Convert to ReceiptEvent and emit
@@ -620,6 +647,7 @@ func (w *WhatsAppProvider) eventHandler(evt interface{}) {
 			fmt.Printf("WhatsApp: ===== HISTORY SYNC STARTED =====\n")
 			w.cacheConversationsFromHistory(v.Data)
 			w.cacheMessagesFromHistory(v.Data)
+			w.emitReadMarkersFromHistory(v.Data)
 			// Process call log records to enrich call messages with summary information
 			fmt.Printf("WhatsApp: ===== PROCESSING CALL LOG RECORDS =====\n")
 			w.processCallLogRecords(v.Data)
@@ -1380,6 +1408,31 @@ func (w *WhatsAppProvider) processCallLogRecords(history *waHistorySync.HistoryS
 	}
 }
 
+// emitReadMarkersFromHistory reports the unread count of each conversation in a history sync
+// so that the local read markers match the phone.
+func (w *WhatsAppProvider) emitReadMarkersFromHistory(history *waHistorySync.HistorySync) {
+	if history == nil {
+		return
+	}
+
+	emitted := 0
+	for _, conv := range history.GetConversations() {
+		if conv == nil || conv.GetID() == "" {
+			continue
+		}
+		select {
+		case w.eventChan <- core.ReadMarkerEvent{
+			ConversationID: conv.GetID(),
+			UnreadCount:    int(conv.GetUnreadCount()),
+		}:
+			emitted++
+		default:
+			fmt.Printf("WhatsApp: WARNING - Failed to emit ReadMarkerEvent for chat %s (channel full)\n", conv.GetID())
+		}
+	}
+	fmt.Printf("WhatsApp: Emitted %d read markers from history sync\n", emitted)
+}
+
 func (w *WhatsAppProvider) cacheConversationsFromHistory(history *waHistorySync.HistorySync) {
 	if history == nil {
 		return
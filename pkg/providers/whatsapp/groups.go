@@ -1,12 +1,19 @@
 package whatsapp
 
 import (
+	"Loom/pkg/core"
+	"Loom/pkg/db"
 	"Loom/pkg/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"go.mau.fi/whatsmeow"
+	waE2E "go.mau.fi/whatsmeow/proto/waE2E"
 	"go.mau.fi/whatsmeow/types"
 )
 
@@ -54,20 +61,9 @@ func (w *WhatsAppProvider) CreateGroup(groupName string, participantIDs []string
 	// Parse participant IDs to JIDs
 	participants := make([]types.JID, 0, len(participantIDs))
 	for _, id := range participantIDs {
-		// Clean up ID if needed (remove prefixes etc)
-		cleanID := strings.TrimPrefix(id, "whatsapp-")
-
-		// Parse JID
-		jid, err := types.ParseJID(cleanID)
+		jid, err := parseParticipantJID(id)
 		if err != nil {
-			// Try adding suffix if missing (assuming phone number)
-			if !strings.Contains(cleanID, "@") {
-				jid, err = types.ParseJID(cleanID + "@s.whatsapp.net")
-			}
-
-			if err != nil {
-				return nil, fmt.Errorf("invalid participant ID %s: %w", id, err)
-			}
+			return nil, err
 		}
 		participants = append(participants, jid)
 	}
@@ -115,61 +111,383 @@ func (w *WhatsAppProvider) CreateGroup(groupName string, participantIDs []string
 	return conversation, nil
 }
 
+// UpdateGroupName renames a group (its subject) and the local conversation.
 func (w *WhatsAppProvider) UpdateGroupName(conversationID string, newName string) error {
-	// TODO: Implement group name update
-	markUnused(conversationID, newName)
-	return fmt.Errorf("group name update not yet implemented")
+	w.mu.RLock()
+	client := w.client
+	ctx := w.ctx
+	w.mu.RUnlock()
+
+	if client == nil {
+		return fmt.Errorf("client not initialized")
+	}
+	name := strings.TrimSpace(newName)
+	if name == "" {
+		return fmt.Errorf("the group name is empty")
+	}
+	groupJID, err := parseGroupJID(conversationID)
+	if err != nil {
+		return err
+	}
+
+	if err := client.SetGroupName(ctx, groupJID, name); err != nil {
+		fmt.Printf("WhatsApp: Failed to rename group %s: %v\n", conversationID, err)
+		return fmt.Errorf("failed to rename group: %w", err)
+	}
+	fmt.Printf("WhatsApp: Renamed group %s to %q\n", conversationID, name)
+
+	w.mu.Lock()
+	w.knownGroups[conversationID] = name
+	w.mu.Unlock()
+	if db.DB != nil {
+		if err := db.DB.Model(&models.Conversation{}).Where("protocol_conv_id = ?", conversationID).Update("group_name", name).Error; err != nil {
+			fmt.Printf("WhatsApp: WARNING - Failed to rename conversation %s: %v\n", conversationID, err)
+		}
+		if err := db.DB.Model(&models.LinkedAccount{}).Where("protocol = ? AND user_id = ?", "whatsapp", conversationID).Update("username", name).Error; err != nil {
+			fmt.Printf("WhatsApp: WARNING - Failed to rename group contact %s: %v\n", conversationID, err)
+		}
+	}
+
+	w.emitGroupChange(core.GroupChangeEvent{
+		ConversationID: conversationID,
+		ChangeType:     core.GroupChangeUpdated,
+		GroupName:      name,
+		Timestamp:      time.Now().Unix(),
+	})
+	return nil
 }
 
+// AddGroupParticipants adds participants to a group. Participants whose privacy settings
+// only allow invitations, or who are not on WhatsApp, are reported in a
+// *core.ParticipantChangeError; the others are added.
 func (w *WhatsAppProvider) AddGroupParticipants(conversationID string, participantIDs []string) error {
-	// TODO: Implement adding participants
-	markUnused(conversationID, participantIDs)
-	return fmt.Errorf("adding participants not yet implemented")
+	return w.updateGroupParticipants(conversationID, participantIDs, whatsmeow.ParticipantChangeAdd)
 }
 
+// RemoveGroupParticipants removes participants from a group (admins only).
 func (w *WhatsAppProvider) RemoveGroupParticipants(conversationID string, participantIDs []string) error {
-	// TODO: Implement removing participants
-	markUnused(conversationID, participantIDs)
-	return fmt.Errorf("removing participants not yet implemented")
+	return w.updateGroupParticipants(conversationID, participantIDs, whatsmeow.ParticipantChangeRemove)
 }
 
+// LeaveGroup leaves a group. The conversation and its history are kept.
 func (w *WhatsAppProvider) LeaveGroup(conversationID string) error {
-	// TODO: Implement leaving group
-	markUnused(conversationID)
-	return fmt.Errorf("leaving group not yet implemented")
+	w.mu.RLock()
+	client := w.client
+	ctx := w.ctx
+	w.mu.RUnlock()
+
+	if client == nil {
+		return fmt.Errorf("client not initialized")
+	}
+	groupJID, err := parseGroupJID(conversationID)
+	if err != nil {
+		return err
+	}
+
+	if err := client.LeaveGroup(ctx, groupJID); err != nil {
+		fmt.Printf("WhatsApp: Failed to leave group %s: %v\n", conversationID, err)
+		return fmt.Errorf("failed to leave group: %w", err)
+	}
+	fmt.Printf("WhatsApp: Left group %s\n", conversationID)
+
+	// The group is no longer listed in the contacts
+	w.mu.Lock()
+	delete(w.knownGroups, conversationID)
+	delete(w.groupParticipants, conversationID)
+	w.groupsCacheTimestamp = nil
+	w.mu.Unlock()
+
+	selfIDs := []string{}
+	if client.Store.ID != nil {
+		selfIDs = append(selfIDs, client.Store.ID.ToNonAD().String())
+	}
+	if !client.Store.LID.IsEmpty() {
+		selfIDs = append(selfIDs, client.Store.LID.ToNonAD().String())
+	}
+	if len(selfIDs) > 0 {
+		self := participantForms{userID: selfIDs[0], aliases: selfIDs[1:]}
+		w.applyParticipantChange(conversationID, []participantForms{self}, whatsmeow.ParticipantChangeRemove)
+	}
+
+	selfID := ""
+	if len(selfIDs) > 0 {
+		selfID = selfIDs[0]
+	}
+	w.emitGroupChange(core.GroupChangeEvent{
+		ConversationID: conversationID,
+		ChangeType:     core.GroupChangeParticipantLeft,
+		ParticipantID:  selfID,
+		Timestamp:      time.Now().Unix(),
+	})
+	return nil
 }
 
+// PromoteGroupAdmins makes participants admins of a group (admins only).
 func (w *WhatsAppProvider) PromoteGroupAdmins(conversationID string, participantIDs []string) error {
-	// TODO: Implement promoting admins
-	markUnused(conversationID, participantIDs)
-	return fmt.Errorf("promoting admins not yet implemented")
+	return w.updateGroupParticipants(conversationID, participantIDs, whatsmeow.ParticipantChangePromote)
 }
 
+// DemoteGroupAdmins makes admins regular participants of a group (admins only).
 func (w *WhatsAppProvider) DemoteGroupAdmins(conversationID string, participantIDs []string) error {
-	// TODO: Implement demoting admins
-	markUnused(conversationID, participantIDs)
-	return fmt.Errorf("demoting admins not yet implemented")
+	return w.updateGroupParticipants(conversationID, participantIDs, whatsmeow.ParticipantChangeDemote)
 }
 
-func (w *WhatsAppProvider) GetGroupParticipants(conversationID string) ([]models.GroupParticipant, error) {
+// updateGroupParticipants applies a participant change with UpdateGroupParticipants, which
+// reports a result per participant. The participants it succeeded for are updated in the
+// local group and announced with GroupChangeEvents; the others are returned in a
+// *core.ParticipantChangeError.
+func (w *WhatsAppProvider) updateGroupParticipants(conversationID string, participantIDs []string, action whatsmeow.ParticipantChange) error {
 	w.mu.RLock()
 	client := w.client
 	ctx := w.ctx
 	w.mu.RUnlock()
 
 	if client == nil {
-		return nil, fmt.Errorf("client not initialized")
+		return fmt.Errorf("client not initialized")
+	}
+	if len(participantIDs) == 0 {
+		return fmt.Errorf("no participant given")
+	}
+	groupJID, err := parseGroupJID(conversationID)
+	if err != nil {
+		return err
 	}
 
-	// Parse conversation ID (JID)
-	groupJID, err := types.ParseJID(conversationID)
+	// Keep the requested IDs to report the failures with them
+	jids := make([]types.JID, 0, len(participantIDs))
+	requested := make(map[string]string, len(participantIDs))
+	for _, id := range participantIDs {
+		jid, err := parseParticipantJID(id)
+		if err != nil {
+			return err
+		}
+		jids = append(jids, jid)
+		requested[jid.String()] = id
+	}
+
+	results, err := client.UpdateGroupParticipants(ctx, groupJID, jids, action)
+	if err != nil {
+		fmt.Printf("WhatsApp: Failed to %s participants of group %s: %v\n", action, conversationID, err)
+		return fmt.Errorf("failed to %s participants: %w", action, err)
+	}
+
+	var changed []participantForms
+	var failures []core.ParticipantFailure
+	answered := make(map[string]bool, len(results))
+	for _, result := range results {
+		participantID := requestedParticipantID(result, requested)
+		answered[participantID] = true
+		if result.Error != 0 {
+			failures = append(failures, core.ParticipantFailure{
+				ParticipantID: participantID,
+				Code:          result.Error,
+				Reason:        participantFailureReason(result, action),
+			})
+			continue
+		}
+
+		// Participants are stored by phone number when WhatsApp gives it, as in GetGroupParticipants
+		userID := result.JID.String()
+		if !result.PhoneNumber.IsEmpty() {
+			userID = result.PhoneNumber.String()
+			if result.JID.Server == types.HiddenUserServer {
+				w.storeContactMapping(result.JID.String(), userID)
+			}
+		}
+		changed = append(changed, participantForms{userID: userID, aliases: participantAliases(result, userID)})
+	}
+	// WhatsApp may leave out participants: their change cannot be assumed to have been made
+	for _, id := range participantIDs {
+		if !answered[id] {
+			failures = append(failures, core.ParticipantFailure{ParticipantID: id, Reason: "no result from WhatsApp"})
+		}
+	}
+	fmt.Printf("WhatsApp: %s in group %s: %d succeeded, %d failed\n", action, conversationID, len(changed), len(failures))
+
+	w.applyParticipantChange(conversationID, changed, action)
+	changeType := map[whatsmeow.ParticipantChange]core.GroupChangeType{
+		whatsmeow.ParticipantChangeAdd:     core.GroupChangeParticipantAdded,
+		whatsmeow.ParticipantChangeRemove:  core.GroupChangeParticipantRemoved,
+		whatsmeow.ParticipantChangePromote: core.GroupChangeParticipantPromoted,
+		whatsmeow.ParticipantChangeDemote:  core.GroupChangeParticipantDemoted,
+	}[action]
+	for _, participant := range changed {
+		w.emitGroupChange(core.GroupChangeEvent{
+			ConversationID: conversationID,
+			ChangeType:     changeType,
+			ParticipantID:  participant.userID,
+			Timestamp:      time.Now().Unix(),
+		})
+	}
+
+	if len(failures) > 0 {
+		return &core.ParticipantChangeError{Action: string(action), Failures: failures}
+	}
+	return nil
+}
+
+// requestedParticipantID returns the ID a participant result was requested with. WhatsApp may
+// answer with the LID of a participant given by phone number, or the other way around.
+func requestedParticipantID(result types.GroupParticipant, requested map[string]string) string {
+	for _, jid := range []types.JID{result.JID, result.PhoneNumber, result.LID} {
+		if id, ok := requested[jid.String()]; ok && !jid.IsEmpty() {
+			return id
+		}
+	}
+	return result.JID.String()
+}
+
+// participantForms identifies a participant of a group change by all the forms of its JID.
+type participantForms struct {
+	userID  string   // ID the participant is stored with: the phone number when known
+	aliases []string // Other IDs of the participant (its LID)
+}
+
+// all returns the ID of the participant followed by its aliases.
+func (p participantForms) all() []string {
+	return append([]string{p.userID}, p.aliases...)
+}
+
+// participantAliases returns the JIDs of a participant result other than userID.
+func participantAliases(result types.GroupParticipant, userID string) []string {
+	var aliases []string
+	for _, jid := range []types.JID{result.JID, result.PhoneNumber, result.LID} {
+		if id := jid.String(); !jid.IsEmpty() && id != userID && !slices.Contains(aliases, id) {
+			aliases = append(aliases, id)
+		}
+	}
+	return aliases
+}
+
+// participantFailureReason explains the error code of a participant change.
+func participantFailureReason(result types.GroupParticipant, action whatsmeow.ParticipantChange) string {
+	switch result.Error {
+	case 401:
+		return "not authorized, the contact may have been blocked"
+	case 403:
+		if result.AddRequest != nil {
+			return "their privacy settings only allow them to be invited to groups"
+		}
+		return "not allowed"
+	case 404:
+		if action == whatsmeow.ParticipantChangeAdd {
+			return "not on WhatsApp"
+		}
+		return "not a participant of the group"
+	case 408:
+		return "they left the group recently and cannot be added back yet"
+	case 409:
+		if action == whatsmeow.ParticipantChangeAdd {
+			return "already a participant of the group"
+		}
+		return "the participant is already in that role"
+	case 500:
+		return "the group is full"
+	}
+	return fmt.Sprintf("refused by WhatsApp (error %d)", result.Error)
+}
+
+// applyParticipantChange updates the stored participants of a group after a change.
+// Stored participants are matched on all the forms of their JID (phone number and LID).
+func (w *WhatsAppProvider) applyParticipantChange(conversationID string, changed []participantForms, action whatsmeow.ParticipantChange) {
+	if db.DB == nil || len(changed) == 0 {
+		return
+	}
+	var conversation models.Conversation
+	if err := db.DB.Where("protocol_conv_id = ?", conversationID).First(&conversation).Error; err != nil {
+		// The group is not stored yet: its participants are loaded with it
+		return
+	}
+
+	var userIDs []string
+	for _, participant := range changed {
+		userIDs = append(userIDs, participant.all()...)
+	}
+
+	var err error
+	participants := db.DB.Model(&models.GroupParticipant{}).Where("conversation_id = ? AND user_id IN ?", conversation.ID, userIDs)
+	switch action {
+	case whatsmeow.ParticipantChangeAdd:
+		now := time.Now()
+		for _, participant := range changed {
+			var count int64
+			if err = db.DB.Model(&models.GroupParticipant{}).Where("conversation_id = ? AND user_id IN ?", conversation.ID, participant.all()).Count(&count).Error; err != nil {
+				break
+			}
+			if count > 0 {
+				continue
+			}
+			if err = db.DB.Create(&models.GroupParticipant{ConversationID: conversation.ID, UserID: participant.userID, JoinedAt: now}).Error; err != nil {
+				break
+			}
+		}
+	case whatsmeow.ParticipantChangeRemove:
+		err = participants.Delete(&models.GroupParticipant{}).Error
+	case whatsmeow.ParticipantChangePromote:
+		err = participants.Update("is_admin", true).Error
+	case whatsmeow.ParticipantChangeDemote:
+		err = participants.Update("is_admin", false).Error
+	}
 	if err != nil {
-		return nil, fmt.Errorf("invalid conversation ID: %w", err)
+		fmt.Printf("WhatsApp: WARNING - Failed to update the participants of %s after %s: %v\n", conversationID, action, err)
 	}
+}
 
-	// Verify it's a group
+// emitGroupChange sends a group change event without blocking.
+func (w *WhatsAppProvider) emitGroupChange(event core.GroupChangeEvent) {
+	select {
+	case w.eventChan <- event:
+	default:
+		fmt.Printf("WhatsApp: WARNING - Failed to emit GroupChangeEvent (channel full) for %s\n", event.ConversationID)
+	}
+}
+
+// parseGroupJID parses the JID of a group conversation.
+func parseGroupJID(conversationID string) (types.JID, error) {
+	groupJID, err := types.ParseJID(conversationID)
+	if err != nil {
+		return types.JID{}, fmt.Errorf("invalid conversation ID: %w", err)
+	}
 	if groupJID.Server != types.GroupServer {
-		return nil, fmt.Errorf("conversation is not a group: %s", conversationID)
+		return types.JID{}, fmt.Errorf("conversation is not a group: %s", conversationID)
+	}
+	return groupJID, nil
+}
+
+// parseParticipantJID parses a participant ID: a JID, or a phone number.
+func parseParticipantJID(id string) (types.JID, error) {
+	// Clean up ID if needed (remove prefixes etc)
+	cleanID := strings.TrimPrefix(id, "whatsapp-")
+
+	// A bare phone number is a user JID (ParseJID would accept it as a server-only JID)
+	if !strings.Contains(cleanID, "@") {
+		cleanID = strings.TrimPrefix(cleanID, "+") + "@" + types.DefaultUserServer
+	}
+
+	jid, err := types.ParseJID(cleanID)
+	if err != nil {
+		return types.JID{}, fmt.Errorf("invalid participant ID %s: %w", id, err)
+	}
+	if jid.User == "" {
+		return types.JID{}, fmt.Errorf("invalid participant ID %s: no user", id)
+	}
+	return jid, nil
+}
+
+func (w *WhatsAppProvider) GetGroupParticipants(conversationID string) ([]models.GroupParticipant, error) {
+	w.mu.RLock()
+	client := w.client
+	ctx := w.ctx
+	w.mu.RUnlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("client not initialized")
+	}
+
+	// Parse conversation ID (JID)
+	groupJID, err := parseGroupJID(conversationID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get group info to obtain participants
@@ -232,26 +550,319 @@ func (w *WhatsAppProvider) GetGroupParticipants(conversationID string) ([]models
 	return participants, nil
 }
 
+// CreateGroupInviteLink returns the current invite link of a group. Only group admins can get it.
 func (w *WhatsAppProvider) CreateGroupInviteLink(conversationID string) (string, error) {
-	// TODO: Implement invite link creation
-	markUnused(conversationID)
-	return "", fmt.Errorf("invite links not yet implemented")
+	return w.groupInviteLink(conversationID, false)
 }
 
+// RevokeGroupInviteLink revokes the invite link of a group; a new link replaces it.
 func (w *WhatsAppProvider) RevokeGroupInviteLink(conversationID string) error {
-	// TODO: Implement invite link revocation
-	markUnused(conversationID)
-	return fmt.Errorf("invite links not yet implemented")
+	_, err := w.groupInviteLink(conversationID, true)
+	return err
 }
 
+// groupInviteLink gets the invite link of a group, replacing it with a new one if reset is true.
+func (w *WhatsAppProvider) groupInviteLink(conversationID string, reset bool) (string, error) {
+	w.mu.RLock()
+	client := w.client
+	ctx := w.ctx
+	w.mu.RUnlock()
+
+	if client == nil {
+		return "", fmt.Errorf("client not initialized")
+	}
+	groupJID, err := parseGroupJID(conversationID)
+	if err != nil {
+		return "", err
+	}
+
+	link, err := client.GetGroupInviteLink(ctx, groupJID, reset)
+	if err != nil {
+		fmt.Printf("WhatsApp: Failed to get the invite link of group %s (reset: %v): %v\n", conversationID, reset, err)
+		if errors.Is(err, whatsmeow.ErrGroupInviteLinkUnauthorized) {
+			return "", fmt.Errorf("only group admins can manage the invite link")
+		}
+		return "", fmt.Errorf("failed to get the invite link: %w", err)
+	}
+	if reset {
+		fmt.Printf("WhatsApp: Revoked the invite link of group %s\n", conversationID)
+	}
+	return link, nil
+}
+
+// PreviewGroupInviteLink returns the group of an invite link without joining it.
+func (w *WhatsAppProvider) PreviewGroupInviteLink(inviteLink string) (*core.GroupInvitePreview, error) {
+	w.mu.RLock()
+	client := w.client
+	ctx := w.ctx
+	w.mu.RUnlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("client not initialized")
+	}
+	code, err := inviteLinkCode(inviteLink)
+	if err != nil {
+		return nil, err
+	}
+
+	groupInfo, err := client.GetGroupInfoFromLink(ctx, code)
+	if err != nil {
+		fmt.Printf("WhatsApp: Failed to get the group of invite link %s: %v\n", code, err)
+		return nil, inviteError(err)
+	}
+	return w.groupInvitePreview(groupInfo, code, ""), nil
+}
+
+// PreviewGroupInviteMessage returns the group of an invitation message received in a
+// conversation without joining it.
+func (w *WhatsAppProvider) PreviewGroupInviteMessage(conversationID string, inviteMessageID string) (*core.GroupInvitePreview, error) {
+	w.mu.RLock()
+	client := w.client
+	ctx := w.ctx
+	w.mu.RUnlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("client not initialized")
+	}
+	invite, groupJID, inviterJID, err := w.groupInviteOfMessage(conversationID, inviteMessageID)
+	if err != nil {
+		return nil, err
+	}
+
+	groupInfo, err := client.GetGroupInfoFromInvite(ctx, groupJID, inviterJID, invite.Code, invite.Expiration)
+	if err != nil {
+		fmt.Printf("WhatsApp: Failed to get the group of invitation %s: %v\n", inviteMessageID, err)
+		return nil, inviteError(err)
+	}
+	return w.groupInvitePreview(groupInfo, invite.Code, invite.Thumbnail), nil
+}
+
+// JoinGroupByInviteLink joins the group of an invite link. When the group admins approve new
+// members, a join request is sent and an error wrapping core.ErrJoinRequestPending is returned.
 func (w *WhatsAppProvider) JoinGroupByInviteLink(inviteLink string) (*models.Conversation, error) {
-	// TODO: Implement joining via invite link
-	markUnused(inviteLink)
-	return nil, fmt.Errorf("invite links not yet implemented")
+	w.mu.RLock()
+	client := w.client
+	ctx := w.ctx
+	w.mu.RUnlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("client not initialized")
+	}
+	code, err := inviteLinkCode(inviteLink)
+	if err != nil {
+		return nil, err
+	}
+
+	groupJID, err := client.JoinGroupWithLink(ctx, code)
+	if err != nil {
+		fmt.Printf("WhatsApp: Failed to join the group of invite link %s: %v\n", code, err)
+		return nil, inviteError(err)
+	}
+	fmt.Printf("WhatsApp: Joined group %s with an invite link\n", groupJID)
+	return w.joinedGroupConversation(groupJID)
 }
 
-func (w *WhatsAppProvider) JoinGroupByInviteMessage(inviteMessageID string) (*models.Conversation, error) {
-	// TODO: Implement joining via invite message
-	markUnused(inviteMessageID)
-	return nil, fmt.Errorf("invite messages not yet implemented")
+// JoinGroupByInviteMessage joins the group of an invitation message received in a conversation.
+func (w *WhatsAppProvider) JoinGroupByInviteMessage(conversationID string, inviteMessageID string) (*models.Conversation, error) {
+	w.mu.RLock()
+	client := w.client
+	ctx := w.ctx
+	w.mu.RUnlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("client not initialized")
+	}
+	invite, groupJID, inviterJID, err := w.groupInviteOfMessage(conversationID, inviteMessageID)
+	if err != nil {
+		return nil, err
+	}
+	if invite.Expiration > 0 && time.Now().Unix() > invite.Expiration {
+		return nil, fmt.Errorf("the invitation to %s has expired", invite.GroupName)
+	}
+
+	if err := client.JoinGroupWithInvite(ctx, groupJID, inviterJID, invite.Code, invite.Expiration); err != nil {
+		fmt.Printf("WhatsApp: Failed to join group %s with invitation %s: %v\n", groupJID, inviteMessageID, err)
+		return nil, inviteError(err)
+	}
+	fmt.Printf("WhatsApp: Joined group %s with invitation %s\n", groupJID, inviteMessageID)
+	return w.joinedGroupConversation(groupJID)
+}
+
+// joinedGroupConversation returns the conversation of a group just joined and lists it in the
+// contacts. A group whose info cannot be read yet is waiting for the approval of an admin.
+func (w *WhatsAppProvider) joinedGroupConversation(groupJID types.JID) (*models.Conversation, error) {
+	w.mu.RLock()
+	client := w.client
+	ctx := w.ctx
+	w.mu.RUnlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("client not initialized")
+	}
+	groupInfo, err := client.GetGroupInfo(ctx, groupJID)
+	if err != nil {
+		if errors.Is(err, whatsmeow.ErrNotInGroup) {
+			return nil, fmt.Errorf("joining %s: %w", groupJID, core.ErrJoinRequestPending)
+		}
+		return nil, fmt.Errorf("failed to get group info: %w", err)
+	}
+
+	conversationID := groupJID.String()
+	w.mu.Lock()
+	w.knownGroups[conversationID] = groupInfo.Name
+	w.groupsCacheTimestamp = nil
+	w.mu.Unlock()
+	go w.cacheGroupParticipants(groupJID)
+
+	conversation := &models.Conversation{
+		ProtocolConvID:    conversationID,
+		GroupName:         groupInfo.Name,
+		IsGroup:           true,
+		GroupParticipants: make([]models.GroupParticipant, 0, len(groupInfo.Participants)),
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+	}
+	for _, participant := range groupInfo.Participants {
+		userID := participant.JID.String()
+		if !participant.PhoneNumber.IsEmpty() {
+			userID = participant.PhoneNumber.String()
+		}
+		conversation.GroupParticipants = append(conversation.GroupParticipants, models.GroupParticipant{
+			UserID:   userID,
+			IsAdmin:  participant.IsAdmin || participant.IsSuperAdmin,
+			JoinedAt: time.Now(),
+		})
+	}
+
+	w.emitGroupChange(core.GroupChangeEvent{
+		ConversationID: conversationID,
+		ChangeType:     core.GroupChangeCreated,
+		GroupName:      groupInfo.Name,
+		Timestamp:      time.Now().Unix(),
+	})
+	return conversation, nil
+}
+
+// groupInvitePreview describes the group of an invitation. The picture is fetched with the
+// invitation code, the thumbnail of the invitation being used when it cannot be.
+func (w *WhatsAppProvider) groupInvitePreview(groupInfo *types.GroupInfo, code string, thumbnail string) *core.GroupInvitePreview {
+	conversationID := groupInfo.JID.String()
+	preview := &core.GroupInvitePreview{
+		GroupID:          conversationID,
+		Name:             groupInfo.Name,
+		Topic:            groupInfo.Topic,
+		ParticipantCount: len(groupInfo.Participants),
+		PictureURL:       thumbnail,
+		ApprovalRequired: groupInfo.IsJoinApprovalRequired,
+	}
+
+	w.mu.RLock()
+	client := w.client
+	ctx := w.ctx
+	_, preview.IsMember = w.knownGroups[conversationID]
+	w.mu.RUnlock()
+
+	if client != nil {
+		picture, err := client.GetProfilePictureInfo(ctx, groupInfo.JID, &whatsmeow.GetProfilePictureParams{
+			Preview:    true,
+			InviteCode: code,
+		})
+		if err == nil && picture != nil && picture.URL != "" {
+			preview.PictureURL = picture.URL
+		} else if err != nil {
+			fmt.Printf("WhatsApp: No picture for the invitation to %s: %v\n", conversationID, err)
+		}
+	}
+	return preview
+}
+
+// groupInviteOfMessage returns the invitation carried by a message of a conversation, with its
+// group and inviter.
+func (w *WhatsAppProvider) groupInviteOfMessage(conversationID, messageID string) (*models.GroupInvite, types.JID, types.JID, error) {
+	inviteJSON := ""
+	w.mu.RLock()
+	for _, msg := range w.conversationMessages[conversationID] {
+		if msg.ProtocolMsgID == messageID {
+			inviteJSON = msg.GroupInvite
+			break
+		}
+	}
+	w.mu.RUnlock()
+	if inviteJSON == "" && db.DB != nil {
+		var dbMsg models.Message
+		// Message IDs are only unique within a conversation
+		if err := db.DB.Where("protocol_msg_id = ? AND protocol_conv_id = ?", messageID, conversationID).First(&dbMsg).Error; err == nil {
+			inviteJSON = dbMsg.GroupInvite
+		}
+	}
+	if inviteJSON == "" {
+		return nil, types.JID{}, types.JID{}, fmt.Errorf("message %s is not a group invitation", messageID)
+	}
+
+	var invite models.GroupInvite
+	if err := json.Unmarshal([]byte(inviteJSON), &invite); err != nil {
+		return nil, types.JID{}, types.JID{}, fmt.Errorf("invalid group invitation in message %s: %w", messageID, err)
+	}
+	groupJID, err := parseGroupJID(invite.GroupID)
+	if err != nil {
+		return nil, types.JID{}, types.JID{}, err
+	}
+	inviterJID, err := types.ParseJID(invite.InviterID)
+	if err != nil {
+		return nil, types.JID{}, types.JID{}, fmt.Errorf("invalid inviter %s: %w", invite.InviterID, err)
+	}
+	return &invite, groupJID, inviterJID, nil
+}
+
+// groupInviteFromMessage converts the invitation of a GroupInviteMessage sent by sender.
+func groupInviteFromMessage(inviteMsg *waE2E.GroupInviteMessage, sender types.JID) models.GroupInvite {
+	invite := models.GroupInvite{
+		GroupID:    inviteMsg.GetGroupJID(),
+		GroupName:  inviteMsg.GetGroupName(),
+		InviterID:  sender.ToNonAD().String(),
+		Code:       inviteMsg.GetInviteCode(),
+		Expiration: inviteMsg.GetInviteExpiration(),
+		Caption:    inviteMsg.GetCaption(),
+	}
+	if invite.GroupName == "" {
+		invite.GroupName = invite.GroupID
+	}
+	if thumbnail := inviteMsg.GetJPEGThumbnail(); len(thumbnail) > 0 {
+		invite.Thumbnail = "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(thumbnail)
+	}
+	return invite
+}
+
+// inviteLinkCode returns the code of an invite link: https://chat.whatsapp.com/CODE, the same
+// without the scheme, or the bare code.
+func inviteLinkCode(inviteLink string) (string, error) {
+	link := strings.TrimSpace(inviteLink)
+	link = strings.TrimPrefix(strings.TrimPrefix(link, "https://"), "http://")
+	if strings.HasPrefix(link, "chat.whatsapp.com/") {
+		link = strings.TrimPrefix(link, "chat.whatsapp.com/")
+	} else if strings.Contains(link, "/") {
+		return "", fmt.Errorf("not a WhatsApp invite link: %s", inviteLink)
+	}
+	if end := strings.IndexAny(link, "?#/"); end != -1 {
+		link = link[:end]
+	}
+	if link == "" || strings.IndexFunc(link, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) != -1 {
+		return "", fmt.Errorf("not a WhatsApp invite link: %s", inviteLink)
+	}
+	return link, nil
+}
+
+// inviteError explains the errors of the invitations.
+func inviteError(err error) error {
+	switch {
+	case errors.Is(err, whatsmeow.ErrInviteLinkRevoked):
+		return fmt.Errorf("the invite link was revoked")
+	case errors.Is(err, whatsmeow.ErrInviteLinkInvalid):
+		return fmt.Errorf("the invite link is not valid")
+	case errors.Is(err, whatsmeow.ErrGroupNotFound):
+		return fmt.Errorf("the group no longer exists")
+	}
+	return fmt.Errorf("failed to use the invitation: %w", err)
 }
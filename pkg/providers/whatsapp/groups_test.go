@@ -0,0 +1,68 @@
+package whatsapp
+
+import (
+	"testing"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestInviteLinkCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		link    string
+		want    string
+		wantErr bool
+	}{
+		{"https link", "https://chat.whatsapp.com/AbC123xyz", "AbC123xyz", false},
+		{"http link", "http://chat.whatsapp.com/AbC123xyz", "AbC123xyz", false},
+		{"without scheme", "chat.whatsapp.com/AbC123xyz", "AbC123xyz", false},
+		{"bare code", "AbC123xyz", "AbC123xyz", false},
+		{"surrounding spaces", "  https://chat.whatsapp.com/AbC123xyz\n", "AbC123xyz", false},
+		{"query string", "https://chat.whatsapp.com/AbC123xyz?utm=share", "AbC123xyz", false},
+		{"fragment", "https://chat.whatsapp.com/AbC123xyz#top", "AbC123xyz", false},
+		{"trailing slash", "https://chat.whatsapp.com/AbC123xyz/", "AbC123xyz", false},
+		{"other host", "https://example.com/AbC123xyz", "", true},
+		{"no code", "https://chat.whatsapp.com/", "", true},
+		{"empty", "", "", true},
+		{"invalid characters", "AbC-123_xyz", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := inviteLinkCode(tt.link)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("inviteLinkCode(%q) error = %v, wantErr %v", tt.link, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("inviteLinkCode(%q) = %q, want %q", tt.link, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseParticipantJID(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		want    types.JID
+		wantErr bool
+	}{
+		{"phone number", "33612345678", types.NewJID("33612345678", types.DefaultUserServer), false},
+		{"phone number with plus", "+33612345678", types.NewJID("33612345678", types.DefaultUserServer), false},
+		{"user JID", "33612345678@s.whatsapp.net", types.NewJID("33612345678", types.DefaultUserServer), false},
+		{"LID", "123456789012345@lid", types.NewJID("123456789012345", types.HiddenUserServer), false},
+		{"prefixed ID", "whatsapp-33612345678@s.whatsapp.net", types.NewJID("33612345678", types.DefaultUserServer), false},
+		{"no user", "@s.whatsapp.net", types.JID{}, true},
+		{"empty", "", types.JID{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseParticipantJID(tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseParticipantJID(%q) error = %v, wantErr %v", tt.id, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseParticipantJID(%q) = %v, want %v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package whatsapp
 import (
 	"Loom/pkg/core"
 	"Loom/pkg/db"
+	"Loom/pkg/media"
 	"Loom/pkg/models"
 	"crypto/sha256"
 	"encoding/hex"
@@ -28,7 +29,7 @@ func (w *WhatsAppProvider) downloadAndCacheAttachment(evt *events.Message, media
 		return nil
 	}
 
-	var media *waE2E.Message
+	var mediaMsg *waE2E.Message
 	msg := evt.Message
 	if msg == nil {
 		return nil
@@ -43,7 +44,7 @@ func (w *WhatsAppProvider) downloadAndCacheAttachment(evt *events.Message, media
 	switch mediaType {
 	case "image":
 		if img := msg.GetImageMessage(); img != nil {
-			media = &waE2E.Message{ImageMessage: img}
+			mediaMsg = &waE2E.Message{ImageMessage: img}
 			mimeType = img.GetMimetype()
 			fileSize = int64(img.GetFileLength())
 			// Generate filename from mime type
@@ -64,7 +65,7 @@ func (w *WhatsAppProvider) downloadAndCacheAttachment(evt *events.Message, media
 		}
 	case "video":
 		if vid := msg.GetVideoMessage(); vid != nil {
-			media = &waE2E.Message{VideoMessage: vid}
+			mediaMsg = &waE2E.Message{VideoMessage: vid}
 			mimeType = vid.GetMimetype()
 			fileSize = int64(vid.GetFileLength())
 			// Generate filename from mime type
@@ -82,7 +83,7 @@ func (w *WhatsAppProvider) downloadAndCacheAttachment(evt *events.Message, media
 		}
 	case "audio":
 		if aud := msg.GetAudioMessage(); aud != nil {
-			media = &waE2E.Message{AudioMessage: aud}
+			mediaMsg = &waE2E.Message{AudioMessage: aud}
 			mimeType = aud.GetMimetype()
 			fileSize = int64(aud.GetFileLength())
 			// Generate filename from mime type
@@ -97,7 +98,7 @@ func (w *WhatsAppProvider) downloadAndCacheAttachment(evt *events.Message, media
 		}
 	case "document":
 		if doc := msg.GetDocumentMessage(); doc != nil {
-			media = &waE2E.Message{DocumentMessage: doc}
+			mediaMsg = &waE2E.Message{DocumentMessage: doc}
 			fileName = doc.GetFileName()
 			mimeType = doc.GetMimetype()
 			fileSize = int64(doc.GetFileLength())
@@ -106,7 +107,7 @@ func (w *WhatsAppProvider) downloadAndCacheAttachment(evt *events.Message, media
 		}
 	case "sticker":
 		if stk := msg.GetStickerMessage(); stk != nil {
-			media = &waE2E.Message{StickerMessage: stk}
+			mediaMsg = &waE2E.Message{StickerMessage: stk}
 			fileName = "sticker.webp"
 			mimeType = "image/webp"
 			fileSize = int64(stk.GetFileLength())
@@ -117,7 +118,7 @@ func (w *WhatsAppProvider) downloadAndCacheAttachment(evt *events.Message, media
 		return nil
 	}
 
-	if media == nil {
+	if mediaMsg == nil {
 		return nil
 	}
 
@@ -137,23 +138,7 @@ func (w *WhatsAppProvider) downloadAndCacheAttachment(evt *events.Message, media
 	hash := sha256.Sum256([]byte(evt.Info.ID + mediaType))
 	ext := filepath.Ext(fileName)
 	if ext == "" {
-		// Determine extension from mime type
-		switch {
-		case strings.HasPrefix(mimeType, "image/"):
-			ext = ".jpg"
-		case strings.HasPrefix(mimeType, "video/"):
-			ext = ".mp4"
-		case strings.HasPrefix(mimeType, "audio/ogg"):
-			ext = ".ogg"
-		case strings.HasPrefix(mimeType, "audio/"):
-			ext = ".mp3"
-		case mimeType == "application/pdf":
-			ext = ".pdf"
-		case strings.HasPrefix(mimeType, "application/vnd.ms-excel") || strings.HasPrefix(mimeType, "application/vnd.openxmlformats-officedocument.spreadsheetml"):
-			ext = ".xls"
-		default:
-			ext = ".bin"
-		}
+		ext = media.ExtensionFromMIME(mimeType)
 	}
 	filename := hex.EncodeToString(hash[:]) + ext
 	cachePath := filepath.Join(cacheDir, filename)
@@ -184,15 +169,15 @@ func (w *WhatsAppProvider) downloadAndCacheAttachment(evt *events.Message, media
 	var downloadable whatsmeow.DownloadableMessage
 	switch mediaType {
 	case "image":
-		downloadable = media.GetImageMessage()
+		downloadable = mediaMsg.GetImageMessage()
 	case "video":
-		downloadable = media.GetVideoMessage()
+		downloadable = mediaMsg.GetVideoMessage()
 	case "audio":
-		downloadable = media.GetAudioMessage()
+		downloadable = mediaMsg.GetAudioMessage()
 	case "document":
-		downloadable = media.GetDocumentMessage()
+		downloadable = mediaMsg.GetDocumentMessage()
 	case "sticker":
-		downloadable = media.GetStickerMessage()
+		downloadable = mediaMsg.GetStickerMessage()
 	default:
 		return nil
 	}
@@ -790,6 +775,20 @@ func (w *WhatsAppProvider) convertWhatsAppMessage(evt *events.Message) *models.M
 		body = msg.GetExtendedTextMessage().GetText()
 	}
 
+	// Group invitations are offered to be joined
+	groupInviteJSON := ""
+	if inviteMsg := msg.GetGroupInviteMessage(); inviteMsg != nil {
+		invite := groupInviteFromMessage(inviteMsg, evt.Info.Sender)
+		if data, err := json.Marshal(invite); err == nil {
+			groupInviteJSON = string(data)
+		}
+		body = invite.Caption
+		if body == "" {
+			body = fmt.Sprintf("Invitation to join %s", invite.GroupName)
+		}
+		fmt.Printf("WhatsApp: Received invitation to group %s in message %s\n", invite.GroupID, evt.Info.ID)
+	}
+
 	// Extract quoted message information from ContextInfo
 	// ContextInfo can be present in ExtendedTextMessage, ImageMessage, VideoMessage, etc.
 	var quotedMessageID *string
@@ -811,6 +810,8 @@ func (w *WhatsAppProvider) convertWhatsAppMessage(evt *events.Message) *models.M
 		contextInfo = msg.GetDocumentMessage().GetContextInfo()
 	} else if msg.GetStickerMessage() != nil && msg.GetStickerMessage().GetContextInfo() != nil {
 		contextInfo = msg.GetStickerMessage().GetContextInfo()
+	} else if msg.GetGroupInviteMessage() != nil && msg.GetGroupInviteMessage().GetContextInfo() != nil {
+		contextInfo = msg.GetGroupInviteMessage().GetContextInfo()
 	}
 
 	if contextInfo != nil {
@@ -1017,6 +1018,7 @@ func (w *WhatsAppProvider) convertWhatsAppMessage(evt *events.Message) *models.M
 		QuotedSenderID:  quotedSenderID,
 		QuotedBody:      quotedBody,
 		CallType:        callType,
+		GroupInvite:     groupInviteJSON,
 	}
 }
 
@@ -2137,23 +2139,25 @@ func (w *WhatsAppProvider) SendFile(conversationID string, file *core.Attachment
 		return nil, fmt.Errorf("invalid conversation ID: %w", err)
 	}
 
-	// Determine media type and upload with correct type
-	var attachmentType string
-	var uploadType whatsmeow.MediaType
+	// Classify the file and pick the WhatsApp message type that can carry it
+	mimeType := file.MimeType
+	if mimeType == "" || mimeType == media.DefaultMIMEType {
+		mimeType = media.DetectMIME(file.Data, file.FileName)
+	}
+	attachmentType := whatsAppMediaType(mimeType, file.Data)
 
-	mimeType := strings.ToLower(file.MimeType)
-	if strings.HasPrefix(mimeType, "image/") {
+	var uploadType whatsmeow.MediaType
+	switch attachmentType {
+	case media.TypeImage:
 		uploadType = whatsmeow.MediaImage
-		attachmentType = "image"
-	} else if strings.HasPrefix(mimeType, "video/") {
+	case media.TypeVideo:
 		uploadType = whatsmeow.MediaVideo
-		attachmentType = "video"
-	} else if strings.HasPrefix(mimeType, "audio/") {
+	case media.TypeAudio, media.TypeVoice:
 		uploadType = whatsmeow.MediaAudio
-		attachmentType = "audio"
-	} else {
+	case media.TypeSticker:
+		uploadType = whatsmeow.MediaImage
+	default:
 		uploadType = whatsmeow.MediaDocument
-		attachmentType = "document"
 	}
 
 	// Upload the file
@@ -2164,12 +2168,13 @@ func (w *WhatsAppProvider) SendFile(conversationID string, file *core.Attachment
 
 	// Create message based on media type
 	var msg *waE2E.Message
-	if attachmentType == "image" {
+	switch attachmentType {
+	case media.TypeImage:
 		msg = &waE2E.Message{
 			ImageMessage: &waE2E.ImageMessage{
 				URL:           &uploadResp.URL,
 				DirectPath:    &uploadResp.DirectPath,
-				Mimetype:      &file.MimeType,
+				Mimetype:      &mimeType,
 				Caption:       nil,
 				FileSHA256:    uploadResp.FileSHA256,
 				FileLength:    &uploadResp.FileLength,
@@ -2177,12 +2182,12 @@ func (w *WhatsAppProvider) SendFile(conversationID string, file *core.Attachment
 				FileEncSHA256: uploadResp.FileEncSHA256,
 			},
 		}
-	} else if attachmentType == "video" {
+	case media.TypeVideo:
 		msg = &waE2E.Message{
 			VideoMessage: &waE2E.VideoMessage{
 				URL:           &uploadResp.URL,
 				DirectPath:    &uploadResp.DirectPath,
-				Mimetype:      &file.MimeType,
+				Mimetype:      &mimeType,
 				Caption:       nil,
 				FileSHA256:    uploadResp.FileSHA256,
 				FileLength:    &uploadResp.FileLength,
@@ -2190,29 +2195,47 @@ func (w *WhatsAppProvider) SendFile(conversationID string, file *core.Attachment
 				FileEncSHA256: uploadResp.FileEncSHA256,
 			},
 		}
-	} else if attachmentType == "audio" {
+	case media.TypeAudio, media.TypeVoice:
+		isVoice := attachmentType == media.TypeVoice
+		if isVoice {
+			// WhatsApp clients only play voice notes announced with the opus codec
+			mimeType = "audio/ogg; codecs=opus"
+		}
 		msg = &waE2E.Message{
 			AudioMessage: &waE2E.AudioMessage{
 				URL:           &uploadResp.URL,
 				DirectPath:    &uploadResp.DirectPath,
-				Mimetype:      &file.MimeType,
+				Mimetype:      &mimeType,
 				FileSHA256:    uploadResp.FileSHA256,
 				FileLength:    &uploadResp.FileLength,
 				MediaKey:      uploadResp.MediaKey,
 				FileEncSHA256: uploadResp.FileEncSHA256,
+				PTT:           &isVoice,
 			},
 		}
-	} else {
+	case media.TypeSticker:
+		msg = &waE2E.Message{
+			StickerMessage: &waE2E.StickerMessage{
+				URL:           &uploadResp.URL,
+				DirectPath:    &uploadResp.DirectPath,
+				Mimetype:      proto.String("image/webp"),
+				FileSHA256:    uploadResp.FileSHA256,
+				FileLength:    &uploadResp.FileLength,
+				MediaKey:      uploadResp.MediaKey,
+				FileEncSHA256: uploadResp.FileEncSHA256,
+			},
+		}
+	default:
 		// Send as document
 		fileName := file.FileName
 		if fileName == "" {
-			fileName = "file"
+			fileName = "file" + media.ExtensionFromMIME(mimeType)
 		}
 		msg = &waE2E.Message{
 			DocumentMessage: &waE2E.DocumentMessage{
 				URL:           &uploadResp.URL,
 				DirectPath:    &uploadResp.DirectPath,
-				Mimetype:      &file.MimeType,
+				Mimetype:      &mimeType,
 				FileName:      &fileName,
 				FileSHA256:    uploadResp.FileSHA256,
 				FileLength:    &uploadResp.FileLength,
@@ -2234,22 +2257,10 @@ func (w *WhatsAppProvider) SendFile(conversationID string, file *core.Attachment
 		cacheDir := filepath.Join(configDir, "Loom", "whatsapp", "attachments")
 		os.MkdirAll(cacheDir, 0700)
 
-		hash := sha256.Sum256([]byte(resp.ID + attachmentType))
+		hash := sha256.Sum256([]byte(resp.ID + string(attachmentType)))
 		ext := filepath.Ext(file.FileName)
 		if ext == "" {
-			// Determine extension from mime type
-			switch {
-			case strings.HasPrefix(mimeType, "image/"):
-				ext = ".jpg"
-			case strings.HasPrefix(mimeType, "video/"):
-				ext = ".mp4"
-			case strings.HasPrefix(mimeType, "audio/"):
-				ext = ".mp3"
-			case mimeType == "application/pdf":
-				ext = ".pdf"
-			default:
-				ext = ".bin"
-			}
+			ext = media.ExtensionFromMIME(mimeType)
 		}
 		filename := hex.EncodeToString(hash[:]) + ext
 		cachePath := filepath.Join(cacheDir, filename)
@@ -2258,11 +2269,11 @@ func (w *WhatsAppProvider) SendFile(conversationID string, file *core.Attachment
 		if err := os.WriteFile(cachePath, file.Data, 0644); err == nil {
 			// Create attachment info
 			attachment := models.Attachment{
-				Type:     attachmentType,
+				Type:     string(attachmentType),
 				URL:      cachePath,
 				FileName: file.FileName,
 				FileSize: int64(file.FileSize),
-				MimeType: file.MimeType,
+				MimeType: mimeType,
 			}
 
 			// Convert to JSON for storage
@@ -52,6 +52,7 @@ type WhatsAppProvider struct {
 	lidToJIDMap          map[string]string               // Map of LID to standard JID for conversation resolution
 	lidToJIDMu           sync.RWMutex                    // Mutex for LID to JID map
 	logger               *logging.ProviderLogger         // Logger for this provider instance
+	selfPresence         types.Presence                  // Presence set from Loom, sent on each connection ("" = available)
 }
 
 func (w *WhatsAppProvider) log(format string, args ...interface{}) {
@@ -318,6 +319,35 @@ func (w *WhatsAppProvider) GetQRCode() (string, error) {
 	return w.latestQRCode, nil
 }
 
+// GetSelfUserID returns the JID of the logged-in account (without device part).
+func (w *WhatsAppProvider) GetSelfUserID() string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.client == nil || w.client.Store == nil || w.client.Store.ID == nil {
+		return ""
+	}
+	return w.client.Store.ID.ToNonAD().String()
+}
+
+// GetSelfDisplayName returns the push name of the logged-in account.
+func (w *WhatsAppProvider) GetSelfDisplayName() string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.client == nil || w.client.Store == nil {
+		return ""
+	}
+	return w.client.Store.PushName
+}
+
+// IsConnected returns true if the WhatsApp websocket is connected.
+func (w *WhatsAppProvider) IsConnected() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.client != nil && w.client.IsConnected()
+}
+
 func (w *WhatsAppProvider) IsAuthenticated() bool {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
@@ -125,10 +125,21 @@ func (w *WhatsAppProvider) MarkMessageAsPlayed(conversationID string, messageID
 	return nil
 }
 
+// MarkConversationAsRead sends a read receipt for the latest incoming message of a conversation,
+// which marks the whole conversation as read on the other devices.
 func (w *WhatsAppProvider) MarkConversationAsRead(conversationID string) error {
-	// TODO: Implement marking conversation as read
-	markUnused(conversationID)
-	return fmt.Errorf("marking conversation as read not yet implemented")
+	if db.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	var lastMessage models.Message
+	err := db.DB.Where("protocol_conv_id = ? AND is_from_me = ? AND is_status_message = ?", conversationID, false, false).
+		Order("timestamp desc").First(&lastMessage).Error
+	if err != nil {
+		// Nothing received in the conversation: nothing to mark
+		return nil
+	}
+	return w.MarkMessageAsRead(conversationID, lastMessage.ProtocolMsgID)
 }
 
 func (w *WhatsAppProvider) SendRetryReceipt(conversationID string, messageID string) error {
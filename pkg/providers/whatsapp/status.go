@@ -4,6 +4,9 @@ import (
 	"Loom/pkg/core"
 	"Loom/pkg/models"
 	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
 )
 
 func (w *WhatsAppProvider) SendStatusMessage(text string, file *core.Attachment) (*models.Message, error) {
@@ -11,3 +14,92 @@ func (w *WhatsAppProvider) SendStatusMessage(text string, file *core.Attachment)
 	markUnused(text, file)
 	return nil, fmt.Errorf("status messages not yet implemented")
 }
+
+// Ensure the provider can manage the status of the account
+var _ core.MyStatusManager = (*WhatsAppProvider)(nil)
+
+// MyStatusFeatures returns the parts of the status supported by WhatsApp: the about text
+// and the presence only.
+func (w *WhatsAppProvider) MyStatusFeatures() core.MyStatusFeatures {
+	return core.MyStatusFeatures{}
+}
+
+// GetMyStatus returns the about text of the account and the presence set from Loom.
+func (w *WhatsAppProvider) GetMyStatus() (*core.MyStatus, error) {
+	w.mu.RLock()
+	client := w.client
+	presence := w.selfPresence
+	w.mu.RUnlock()
+
+	if client == nil || client.Store == nil || client.Store.ID == nil {
+		return nil, fmt.Errorf("not logged in")
+	}
+
+	self := client.Store.ID.ToNonAD()
+	info, err := client.GetUserInfo(w.ctx, []types.JID{self})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the about text: %w", err)
+	}
+
+	status := &core.MyStatus{
+		Text:     info[self].Status,
+		Presence: core.PresenceAvailable,
+	}
+	if presence == types.PresenceUnavailable {
+		status.Presence = core.PresenceAway
+	}
+	return status, nil
+}
+
+// SetMyStatusText sets the about text of the account. WhatsApp has no status emoji or
+// expiration: they are ignored.
+func (w *WhatsAppProvider) SetMyStatusText(text string, emoji string, expiration *time.Time) error {
+	w.mu.RLock()
+	client := w.client
+	w.mu.RUnlock()
+
+	if client == nil || !client.IsLoggedIn() {
+		return fmt.Errorf("not logged in")
+	}
+	markUnused(emoji, expiration)
+
+	if err := client.SetStatusMessage(w.ctx, text); err != nil {
+		return fmt.Errorf("failed to set the about text: %w", err)
+	}
+	w.log("WhatsApp.SetMyStatusText: about text set to %q\n", text)
+	return nil
+}
+
+// SetMyPresence sets the presence of the account. It is sent again on each connection.
+// While unavailable, WhatsApp does not send the typing indicators of the contacts.
+func (w *WhatsAppProvider) SetMyPresence(presence core.PresenceState) error {
+	var state types.Presence
+	switch presence {
+	case core.PresenceAvailable:
+		state = types.PresenceAvailable
+	case core.PresenceAway:
+		state = types.PresenceUnavailable
+	default:
+		return fmt.Errorf("unknown presence %q", presence)
+	}
+
+	w.mu.Lock()
+	client := w.client
+	w.selfPresence = state
+	w.mu.Unlock()
+
+	if client == nil || !client.IsLoggedIn() {
+		return fmt.Errorf("not logged in")
+	}
+	if err := client.SendPresence(w.ctx, state); err != nil {
+		return fmt.Errorf("failed to set the presence: %w", err)
+	}
+	w.log("WhatsApp.SetMyPresence: presence set to %s\n", state)
+	return nil
+}
+
+// SetDoNotDisturb is not supported on WhatsApp.
+func (w *WhatsAppProvider) SetDoNotDisturb(duration time.Duration) error {
+	markUnused(duration)
+	return fmt.Errorf("do not disturb is not supported on WhatsApp")
+}
@@ -1,6 +1,7 @@
 package whatsapp
 
 import (
+	"Loom/pkg/media"
 	"strings"
 )
 
@@ -77,6 +78,31 @@ func isPhoneNumber(str string) bool {
 	return digitCount >= 8 && float64(digitCount)/float64(len(cleaned)) > 0.7
 }
 
+// whatsAppMediaType classifies an outgoing file and downgrades it to a document
+// when WhatsApp cannot render it inline (e.g. a GIF, a HEIC photo or an MKV video).
+func whatsAppMediaType(mimeType string, data []byte) media.Type {
+	base := media.BaseMIME(mimeType)
+	kind := media.Classify(mimeType, data)
+
+	switch kind {
+	case media.TypeImage:
+		if base != "image/jpeg" && base != "image/png" {
+			return media.TypeDocument
+		}
+	case media.TypeVideo:
+		if base != "video/mp4" && base != "video/3gpp" {
+			return media.TypeDocument
+		}
+	case media.TypeAudio:
+		switch base {
+		case "audio/mpeg", "audio/mp4", "audio/aac", "audio/amr", "audio/ogg":
+		default:
+			return media.TypeDocument
+		}
+	}
+	return kind
+}
+
 // markUnused is a helper to silence static analysis warnings for stub implementations.
 func markUnused(values ...interface{}) {
 	for _, v := range values {
@@ -0,0 +1,245 @@
+// Package readstate persists the read position and unread counters of each conversation.
+// Markers are updated when messages arrive, when the user reads a conversation in Loom,
+// and when a provider reports that a conversation was read elsewhere.
+package readstate
+
+import (
+	"Loom/pkg/core"
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Totals aggregates the unread counters across conversations.
+type Totals struct {
+	UnreadMessages      int `json:"unreadMessages"`      // Unread messages in conversations that are not muted
+	UnreadConversations int `json:"unreadConversations"` // Conversations with at least one unread message (not muted)
+	Mentions            int `json:"mentions"`            // Unread mentions, including muted conversations
+}
+
+// Get returns the marker of a conversation, or an empty marker if none is stored yet.
+func Get(instanceID, conversationID string) (*models.ReadMarker, error) {
+	if db.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	var marker models.ReadMarker
+	err := db.DB.Where("provider_instance_id = ? AND protocol_conv_id = ?", instanceID, conversationID).First(&marker).Error
+	if err == gorm.ErrRecordNotFound {
+		return &models.ReadMarker{ProviderInstanceID: instanceID, ProtocolConvID: conversationID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &marker, nil
+}
+
+// List returns the markers of an instance ("" = all instances).
+func List(instanceID string) ([]models.ReadMarker, error) {
+	if db.DB == nil {
+		return []models.ReadMarker{}, nil
+	}
+
+	query := db.DB.Model(&models.ReadMarker{})
+	if instanceID != "" {
+		query = query.Where("provider_instance_id = ?", instanceID)
+	}
+	var markers []models.ReadMarker
+	if err := query.Find(&markers).Error; err != nil {
+		return nil, err
+	}
+	return markers, nil
+}
+
+// RecordIncoming updates the marker of a conversation for a newly received message.
+// Incoming messages newer than the read position increment the counters; a message sent
+// by the user from another device means the conversation has been read up to that point.
+// It returns the marker and whether it changed.
+func RecordIncoming(instanceID string, msg models.Message, mentioned bool) (*models.ReadMarker, bool, error) {
+	if msg.IsStatusMessage || msg.IsEdited || msg.IsDeleted {
+		return nil, false, nil
+	}
+
+	marker, err := Get(instanceID, msg.ProtocolConvID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if msg.IsFromMe {
+		if !msg.Timestamp.After(marker.LastReadAt) && marker.UnreadCount == 0 {
+			return marker, false, nil
+		}
+		marker.LastReadMessageID = msg.ProtocolMsgID
+		marker.LastReadAt = msg.Timestamp
+		marker.UnreadCount = 0
+		marker.MentionCount = 0
+		return marker, true, save(marker)
+	}
+
+	if !msg.Timestamp.After(marker.LastReadAt) {
+		// Already read (e.g. history replayed after a reconnection)
+		return marker, false, nil
+	}
+
+	marker.UnreadCount++
+	if mentioned {
+		marker.MentionCount++
+	}
+	return marker, true, save(marker)
+}
+
+// MarkRead moves the read position of a conversation to the given message.
+// Counters are recomputed from the stored messages received after it.
+func MarkRead(instanceID, conversationID, messageID string) (*models.ReadMarker, bool, error) {
+	marker, err := Get(instanceID, conversationID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	readAt := time.Now()
+	var msg models.Message
+	if err := db.DB.Where("protocol_msg_id = ?", messageID).First(&msg).Error; err == nil {
+		readAt = msg.Timestamp
+	}
+	if readAt.Before(marker.LastReadAt) {
+		// Older than the current read position: nothing to do
+		return marker, false, nil
+	}
+
+	var remaining int64
+	if err := db.DB.Model(&models.Message{}).
+		Where("protocol_conv_id = ? AND is_from_me = ? AND is_status_message = ? AND timestamp > ?", conversationID, false, false, readAt).
+		Count(&remaining).Error; err != nil {
+		return nil, false, err
+	}
+
+	marker.LastReadMessageID = messageID
+	marker.LastReadAt = readAt
+	if int(remaining) < marker.UnreadCount {
+		marker.UnreadCount = int(remaining)
+	}
+	if marker.MentionCount > marker.UnreadCount {
+		marker.MentionCount = marker.UnreadCount
+	}
+	return marker, true, save(marker)
+}
+
+// MarkConversationRead clears the counters of a conversation.
+// lastMessageID and lastMessageAt describe the latest message, when known.
+func MarkConversationRead(instanceID, conversationID, lastMessageID string, lastMessageAt time.Time) (*models.ReadMarker, error) {
+	marker, err := Get(instanceID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastMessageAt.IsZero() {
+		lastMessageAt = time.Now()
+	}
+	if lastMessageAt.After(marker.LastReadAt) {
+		marker.LastReadMessageID = lastMessageID
+		marker.LastReadAt = lastMessageAt
+	}
+	marker.UnreadCount = 0
+	marker.MentionCount = 0
+	return marker, save(marker)
+}
+
+// MarkConversationAsRead marks a whole conversation as read on the provider (e.g. conversations.mark
+// on Slack), then clears the counters of the conversation up to the latest incoming message known locally.
+func MarkConversationAsRead(provider core.Provider, instanceID, conversationID string) (*models.ReadMarker, error) {
+	if db.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	var lastMessage models.Message
+	err := db.DB.Where("protocol_conv_id = ? AND is_from_me = ? AND is_status_message = ?", conversationID, false, false).
+		Order("timestamp desc").First(&lastMessage).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	if err := provider.MarkConversationAsRead(conversationID); err != nil {
+		return nil, fmt.Errorf("failed to mark conversation %s as read: %w", conversationID, err)
+	}
+
+	return MarkConversationRead(instanceID, conversationID, lastMessage.ProtocolMsgID, lastMessage.Timestamp)
+}
+
+// Reconcile applies the read state reported by a provider.
+// unreadCount is ignored when negative (unknown); the provider is authoritative otherwise.
+func Reconcile(instanceID, conversationID, lastReadMessageID string, lastReadAt time.Time, unreadCount int) (*models.ReadMarker, bool, error) {
+	marker, err := Get(instanceID, conversationID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	changed := false
+	if !lastReadAt.IsZero() && lastReadAt.After(marker.LastReadAt) {
+		marker.LastReadAt = lastReadAt
+		marker.LastReadMessageID = lastReadMessageID
+		changed = true
+	}
+	if unreadCount >= 0 && unreadCount != marker.UnreadCount {
+		marker.UnreadCount = unreadCount
+		changed = true
+	}
+	if marker.MentionCount > marker.UnreadCount {
+		marker.MentionCount = marker.UnreadCount
+		changed = true
+	}
+
+	if !changed {
+		return marker, false, nil
+	}
+	return marker, true, save(marker)
+}
+
+// GetTotals sums the counters of an instance ("" = all instances).
+// Muted conversations do not count towards unread messages, only towards mentions.
+func GetTotals(instanceID string) (Totals, error) {
+	totals := Totals{}
+	if db.DB == nil {
+		return totals, nil
+	}
+
+	query := db.DB.Where("unread_count > 0")
+	if instanceID != "" {
+		query = query.Where("provider_instance_id = ?", instanceID)
+	}
+	var markers []models.ReadMarker
+	if err := query.Find(&markers).Error; err != nil {
+		return totals, err
+	}
+	if len(markers) == 0 {
+		return totals, nil
+	}
+
+	var mutedIDs []string
+	if err := db.DB.Model(&models.Conversation{}).Where("is_muted = ?", true).Pluck("protocol_conv_id", &mutedIDs).Error; err != nil {
+		return totals, err
+	}
+	muted := make(map[string]bool, len(mutedIDs))
+	for _, id := range mutedIDs {
+		muted[id] = true
+	}
+
+	for _, marker := range markers {
+		totals.Mentions += marker.MentionCount
+		if muted[marker.ProtocolConvID] {
+			continue
+		}
+		totals.UnreadMessages += marker.UnreadCount
+		totals.UnreadConversations++
+	}
+	return totals, nil
+}
+
+// save creates or updates a marker.
+func save(marker *models.ReadMarker) error {
+	if db.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	return db.DB.Save(marker).Error
+}
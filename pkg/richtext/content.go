@@ -0,0 +1,167 @@
+package richtext
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// BlockType is the kind of a content block.
+type BlockType string
+
+const (
+	BlockHeader  BlockType = "header"  // Title line (Text)
+	BlockSection BlockType = "section" // Text and Fields, with an optional image (Images) or button (Actions) beside them
+	BlockContext BlockType = "context" // Small print mixing Text and Images
+	BlockDivider BlockType = "divider" // Horizontal rule
+	BlockImage   BlockType = "image"   // Image (Images) with an optional title (Text)
+	BlockActions BlockType = "actions" // Row of buttons and menus (Actions)
+)
+
+// ActionType is the kind of an interactive element.
+type ActionType string
+
+const (
+	ActionButton ActionType = "button" // Button, a link when URL is set
+	ActionMenu   ActionType = "menu"   // Select menu, date picker or overflow menu (Text = placeholder)
+)
+
+// Image is an image shown in a block or an attachment.
+type Image struct {
+	URL     string `json:"url"`
+	AltText string `json:"altText,omitempty"`
+}
+
+// Field is a labelled value, shown in columns when Short.
+type Field struct {
+	Title string   `json:"title,omitempty"`
+	Value Document `json:"value"`
+	Short bool     `json:"short,omitempty"`
+}
+
+// Action is a button or menu of an integration message. Loom shows them but only link
+// buttons can be used: the other actions call back the integration.
+type Action struct {
+	Type  ActionType `json:"type"`
+	Text  string     `json:"text"`
+	URL   string     `json:"url,omitempty"`
+	Value string     `json:"value,omitempty"`
+	Style string     `json:"style,omitempty"` // "primary", "danger" or "" (default)
+}
+
+// Block is a layout block of a message (Slack Block Kit).
+type Block struct {
+	Type    BlockType `json:"type"`
+	Text    Document  `json:"text,omitzero"`
+	Fields  []Field   `json:"fields,omitempty"`
+	Images  []Image   `json:"images,omitempty"`
+	Actions []Action  `json:"actions,omitempty"`
+}
+
+// Attachment is a legacy message attachment: a card with a colored border, used by
+// integrations before Block Kit.
+type Attachment struct {
+	Color      string   `json:"color,omitempty"` // CSS color of the border, e.g. "#2eb886"
+	Pretext    Document `json:"pretext,omitzero"`
+	AuthorName string   `json:"authorName,omitempty"`
+	AuthorLink string   `json:"authorLink,omitempty"`
+	AuthorIcon string   `json:"authorIcon,omitempty"`
+	Title      string   `json:"title,omitempty"`
+	TitleLink  string   `json:"titleLink,omitempty"`
+	Text       Document `json:"text,omitzero"`
+	Fields     []Field  `json:"fields,omitempty"`
+	ImageURL   string   `json:"imageUrl,omitempty"`
+	ThumbURL   string   `json:"thumbUrl,omitempty"`
+	Blocks     []Block  `json:"blocks,omitempty"`
+	Actions    []Action `json:"actions,omitempty"`
+	Footer     string   `json:"footer,omitempty"`
+	FooterIcon string   `json:"footerIcon,omitempty"`
+	Timestamp  int64    `json:"timestamp,omitempty"` // Unix timestamp shown in the footer
+}
+
+// Content is the structured content of an integration message (CI, ticketing, alerting bots):
+// its layout blocks and legacy attachments. When a message has content, it is the complete
+// rendering of the message and its body holds the PlainText fallback.
+type Content struct {
+	Blocks      []Block      `json:"blocks,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// IsEmpty reports whether the content has no blocks and no attachments.
+func (c Content) IsEmpty() bool {
+	return len(c.Blocks) == 0 && len(c.Attachments) == 0
+}
+
+// Encode serializes the content to JSON ("" when it is empty).
+func (c Content) Encode() string {
+	if c.IsEmpty() {
+		return ""
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// DecodeContent reads content serialized by Encode.
+func DecodeContent(value string) (Content, error) {
+	var c Content
+	if value == "" {
+		return c, nil
+	}
+	err := json.Unmarshal([]byte(value), &c)
+	return c, err
+}
+
+// PlainText renders the content as plain text, one line per text element, for search and
+// notifications. Dividers, images without text and interactive elements are left out.
+func (c Content) PlainText() string {
+	var lines []string
+	add := func(text string) {
+		if text = strings.TrimSpace(text); text != "" {
+			lines = append(lines, text)
+		}
+	}
+	for _, block := range c.Blocks {
+		appendBlockText(add, block)
+	}
+	for _, attachment := range c.Attachments {
+		add(attachment.Pretext.PlainText())
+		add(attachment.AuthorName)
+		add(attachment.Title)
+		add(attachment.Text.PlainText())
+		appendFieldsText(add, attachment.Fields)
+		for _, block := range attachment.Blocks {
+			appendBlockText(add, block)
+		}
+		footer := attachment.Footer
+		if attachment.Timestamp != 0 {
+			footer = strings.TrimSpace(footer + " " + time.Unix(attachment.Timestamp, 0).Format("2006-01-02 15:04"))
+		}
+		add(footer)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// appendBlockText adds the text lines of a block.
+func appendBlockText(add func(string), block Block) {
+	if block.Type == BlockImage && block.Text.IsEmpty() && len(block.Images) > 0 {
+		add(block.Images[0].AltText)
+		return
+	}
+	add(block.Text.PlainText())
+	appendFieldsText(add, block.Fields)
+}
+
+// appendFieldsText adds one "Title: value" line per field.
+func appendFieldsText(add func(string), fields []Field) {
+	for _, field := range fields {
+		value := strings.TrimSpace(field.Value.PlainText())
+		if field.Title != "" && value != "" {
+			add(field.Title + ": " + value)
+		} else {
+			add(field.Title + value)
+		}
+	}
+}
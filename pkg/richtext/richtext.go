@@ -0,0 +1,270 @@
+// Package richtext is the provider-neutral representation of formatted message text.
+// Providers parse their own markup (Slack mrkdwn and rich_text blocks, ...) into a Document
+// whose mentions, channels and links are resolved entities, then store it alongside a
+// Markdown rendering in the message body. Integration messages also carry a Content made of
+// layout blocks and attachments (see content.go).
+package richtext
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// SegmentType is the kind of a segment.
+type SegmentType string
+
+const (
+	SegmentText      SegmentType = "text"      // Plain text
+	SegmentUser      SegmentType = "user"      // Mention of a user (ID, Text = display name)
+	SegmentUserGroup SegmentType = "usergroup" // Mention of a user group (ID, Text = handle)
+	SegmentChannel   SegmentType = "channel"   // Reference to a channel (ID, Text = channel name)
+	SegmentLink      SegmentType = "link"      // Link (URL, Text = label, empty for a bare URL)
+	SegmentBroadcast SegmentType = "broadcast" // @here, @channel or @everyone (ID = "here", "channel", "everyone")
+	SegmentEmoji     SegmentType = "emoji"     // Emoji shortcode (Text = name without colons)
+)
+
+// Style is the formatting of a segment.
+type Style struct {
+	Bold   bool `json:"bold,omitempty"`
+	Italic bool `json:"italic,omitempty"`
+	Strike bool `json:"strike,omitempty"`
+	Code   bool `json:"code,omitempty"`  // Inline code
+	Pre    bool `json:"pre,omitempty"`   // Preformatted block
+	Quote  bool `json:"quote,omitempty"` // Block quote
+}
+
+// Segment is a run of text or an entity sharing the same style.
+type Segment struct {
+	Type  SegmentType `json:"type"`
+	Text  string      `json:"text,omitempty"`
+	ID    string      `json:"id,omitempty"`
+	URL   string      `json:"url,omitempty"`
+	Style Style       `json:"style,omitzero"`
+}
+
+// Document is a formatted message text.
+type Document struct {
+	Segments []Segment `json:"segments"`
+}
+
+// Append adds a segment, merging it into the previous one when both are text with the same style.
+func (d *Document) Append(segment Segment) {
+	if segment.Type == SegmentText && segment.Text == "" {
+		return
+	}
+	if n := len(d.Segments); n > 0 && segment.Type == SegmentText {
+		last := &d.Segments[n-1]
+		if last.Type == SegmentText && last.Style == segment.Style {
+			last.Text += segment.Text
+			return
+		}
+	}
+	d.Segments = append(d.Segments, segment)
+}
+
+// AppendText adds plain text with a style.
+func (d *Document) AppendText(text string, style Style) {
+	d.Append(Segment{Type: SegmentText, Text: text, Style: style})
+}
+
+// IsEmpty reports whether the document has no content.
+func (d Document) IsEmpty() bool {
+	return len(d.Segments) == 0
+}
+
+// HasEntities reports whether the document contains anything other than plain text.
+func (d Document) HasEntities() bool {
+	for _, segment := range d.Segments {
+		if segment.Type != SegmentText || segment.Style != (Style{}) {
+			return true
+		}
+	}
+	return false
+}
+
+// MentionsUser reports whether the document mentions the user.
+func (d Document) MentionsUser(userID string) bool {
+	for _, segment := range d.Segments {
+		if segment.Type == SegmentUser && segment.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// MentionsEveryone reports whether the document contains @here, @channel or @everyone.
+func (d Document) MentionsEveryone() bool {
+	for _, segment := range d.Segments {
+		if segment.Type == SegmentBroadcast {
+			return true
+		}
+	}
+	return false
+}
+
+// Encode serializes the document to JSON ("" when it is empty).
+func (d Document) Encode() string {
+	if d.IsEmpty() {
+		return ""
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// Decode reads a document serialized by Encode.
+func Decode(value string) (Document, error) {
+	var d Document
+	if value == "" {
+		return d, nil
+	}
+	err := json.Unmarshal([]byte(value), &d)
+	return d, err
+}
+
+// PlainText renders the document without formatting, entities shown by their names.
+func (d Document) PlainText() string {
+	var b strings.Builder
+	for _, segment := range d.Segments {
+		b.WriteString(segmentLabel(segment))
+	}
+	return b.String()
+}
+
+// Markdown renders the document in the Markdown subset displayed by the frontend:
+// **bold**, _italic_, ~~strike~~, `code`, fenced code blocks, > quotes and [label](url).
+// Mentions are rendered as @name and channels as #name.
+func (d Document) Markdown() string {
+	var b strings.Builder
+	segments := d.Segments
+	for i := 0; i < len(segments); {
+		// Group consecutive segments of the same block kind
+		j := i + 1
+		for j < len(segments) && segments[j].Style.Pre == segments[i].Style.Pre && segments[j].Style.Quote == segments[i].Style.Quote {
+			j++
+		}
+		block := segments[i:j]
+		switch {
+		case block[0].Style.Pre:
+			var code strings.Builder
+			for _, segment := range block {
+				code.WriteString(segmentLabel(segment))
+			}
+			ensureNewline(&b)
+			b.WriteString("```\n")
+			b.WriteString(strings.Trim(code.String(), "\n"))
+			b.WriteString("\n```")
+			if j < len(segments) && !strings.HasPrefix(segments[j].Text, "\n") {
+				b.WriteString("\n")
+			}
+		case block[0].Style.Quote:
+			ensureNewline(&b)
+			quoted := strings.TrimRight(renderInline(block), "\n")
+			b.WriteString("> " + strings.ReplaceAll(quoted, "\n", "\n> "))
+			if j < len(segments) && !strings.HasPrefix(segments[j].Text, "\n") {
+				b.WriteString("\n")
+			}
+		default:
+			b.WriteString(renderInline(block))
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// ensureNewline starts a new line unless the builder is empty or already at the start of a line.
+func ensureNewline(b *strings.Builder) {
+	if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+		b.WriteString("\n")
+	}
+}
+
+// renderInline renders segments with their inline styles.
+func renderInline(segments []Segment) string {
+	var b strings.Builder
+	for _, segment := range segments {
+		var text string
+		switch segment.Type {
+		case SegmentLink:
+			if segment.Text == "" || segment.Text == segment.URL {
+				text = segment.URL
+			} else {
+				text = "[" + segment.Text + "](" + segment.URL + ")"
+			}
+		default:
+			text = segmentLabel(segment)
+		}
+		b.WriteString(applyStyle(text, segment.Style))
+	}
+	return b.String()
+}
+
+// applyStyle wraps each line of text in the Markdown markers of a style.
+// Markers cannot span lines, and surrounding spaces stay outside of them.
+func applyStyle(text string, style Style) string {
+	open, closing := "", ""
+	if style.Code {
+		open, closing = "`", "`"
+	} else {
+		if style.Bold {
+			open, closing = open+"**", "**"+closing
+		}
+		if style.Italic {
+			open, closing = open+"_", "_"+closing
+		}
+		if style.Strike {
+			open, closing = open+"~~", "~~"+closing
+		}
+	}
+	if open == "" {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		start := strings.Index(line, trimmed)
+		lines[i] = line[:start] + open + trimmed + closing + line[start+len(trimmed):]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// segmentLabel returns the text shown for a segment.
+func segmentLabel(segment Segment) string {
+	switch segment.Type {
+	case SegmentUser:
+		if segment.Text != "" {
+			return "@" + segment.Text
+		}
+		return "@" + segment.ID
+	case SegmentUserGroup:
+		name := segment.Text
+		if name == "" {
+			name = segment.ID
+		}
+		if strings.HasPrefix(name, "@") {
+			return name
+		}
+		return "@" + name
+	case SegmentChannel:
+		if segment.Text != "" {
+			return "#" + segment.Text
+		}
+		return "#" + segment.ID
+	case SegmentBroadcast:
+		return "@" + segment.ID
+	case SegmentEmoji:
+		return ":" + segment.Text + ":"
+	case SegmentLink:
+		if segment.Text != "" {
+			return segment.Text
+		}
+		return segment.URL
+	}
+	return segment.Text
+}
@@ -0,0 +1,262 @@
+// Package scheduler sends messages at a chosen time. Providers that can schedule messages on
+// their service (core.MessageScheduler) are used natively, so the messages go out even when
+// Loom is closed. The messages of the other providers, and those the service refuses to
+// schedule, are stored and sent by the local scheduler while Loom is running.
+package scheduler
+
+import (
+	"Loom/pkg/core"
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Local message statuses.
+const (
+	StatusPending = "pending"
+	StatusSending = "sending" // Claimed by a scheduler (the app or loomd) that is sending it
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+)
+
+// pollInterval is how often the local scheduler looks for due messages.
+const pollInterval = 15 * time.Second
+
+// maxSendAttempts is how many times a due message is tried before it is marked as failed.
+// Only attempts made while the provider is connected count: it may still be connecting when Loom starts.
+const maxSendAttempts = 10
+
+// sendingTimeout is how long a message may stay claimed before another scheduler takes it over
+// (the process sending it stopped before recording the result).
+const sendingTimeout = 10 * time.Minute
+
+// localIDPrefix prefixes the IDs of the messages of the local scheduler.
+const localIDPrefix = "local-"
+
+// Scheduler schedules messages natively or locally and sends the local ones when they are due.
+type Scheduler struct {
+	providerManager *core.ProviderManager
+}
+
+// NewScheduler creates a scheduler sending through the providers of providerManager.
+func NewScheduler(providerManager *core.ProviderManager) *Scheduler {
+	return &Scheduler{
+		providerManager: providerManager,
+	}
+}
+
+// Run sends the due local messages until ctx is cancelled. Messages that fell due while
+// Loom was closed are sent when it starts.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	fmt.Printf("Scheduler: Sending scheduled messages\n")
+	for {
+		s.sendDue(time.Now())
+		select {
+		case <-ctx.Done():
+			fmt.Printf("Scheduler: Stopped\n")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Schedule schedules text to be posted in a conversation of a provider instance at postAt,
+// natively when the provider supports it, otherwise with the local scheduler.
+func (s *Scheduler) Schedule(instanceID string, conversationID string, text string, threadID *string, postAt time.Time) (*core.ScheduledMessage, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("the message is empty")
+	}
+	if !postAt.After(time.Now()) {
+		return nil, fmt.Errorf("the scheduled time is in the past")
+	}
+	provider, err := s.providerManager.GetProvider(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if scheduler, ok := provider.(core.MessageScheduler); ok {
+		scheduled, err := scheduler.ScheduleMessage(conversationID, text, threadID, postAt)
+		if err == nil {
+			return scheduled, nil
+		}
+		if !errors.Is(err, core.ErrNativeSchedulingUnavailable) {
+			return nil, err
+		}
+		fmt.Printf("Scheduler: Native scheduling unavailable (%v), using the local scheduler\n", err)
+	}
+
+	if db.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	local := models.ScheduledMessage{
+		ProviderInstanceID: instanceID,
+		ProtocolConvID:     conversationID,
+		Text:               text,
+		ThreadID:           threadID,
+		PostAt:             postAt,
+		Status:             StatusPending,
+	}
+	if err := db.DB.Create(&local).Error; err != nil {
+		return nil, fmt.Errorf("failed to save scheduled message: %w", err)
+	}
+	fmt.Printf("Scheduler: Scheduled local message %d in %s for %s\n", local.ID, conversationID, postAt.Format(time.RFC3339))
+
+	scheduled := fromLocal(local)
+	return &scheduled, nil
+}
+
+// List returns the pending scheduled messages of a provider instance, native and local,
+// in a conversation or in all conversations if conversationID is empty, soonest first.
+// Native messages are left out when the service cannot list them.
+func (s *Scheduler) List(instanceID string, conversationID string) ([]core.ScheduledMessage, error) {
+	scheduled := []core.ScheduledMessage{}
+	if provider, err := s.providerManager.GetProvider(instanceID); err == nil {
+		if scheduler, ok := provider.(core.MessageScheduler); ok {
+			native, err := scheduler.GetScheduledMessages(conversationID)
+			if err != nil {
+				fmt.Printf("Scheduler: WARNING - Failed to list the scheduled messages of %s: %v\n", instanceID, err)
+			} else {
+				scheduled = append(scheduled, native...)
+			}
+		}
+	}
+
+	if db.DB != nil {
+		query := db.DB.Where("provider_instance_id = ? AND status = ?", instanceID, StatusPending)
+		if conversationID != "" {
+			query = query.Where("protocol_conv_id = ?", conversationID)
+		}
+		var locals []models.ScheduledMessage
+		if err := query.Find(&locals).Error; err != nil {
+			return nil, fmt.Errorf("failed to load scheduled messages: %w", err)
+		}
+		for _, local := range locals {
+			scheduled = append(scheduled, fromLocal(local))
+		}
+	}
+
+	sort.SliceStable(scheduled, func(i, j int) bool {
+		return scheduled[i].PostAt.Before(scheduled[j].PostAt)
+	})
+	return scheduled, nil
+}
+
+// Cancel deletes a scheduled message before it is sent.
+func (s *Scheduler) Cancel(instanceID string, conversationID string, scheduledID string) error {
+	if !strings.HasPrefix(scheduledID, localIDPrefix) {
+		provider, err := s.providerManager.GetProvider(instanceID)
+		if err != nil {
+			return err
+		}
+		scheduler, ok := provider.(core.MessageScheduler)
+		if !ok {
+			return fmt.Errorf("unknown scheduled message %s", scheduledID)
+		}
+		return scheduler.CancelScheduledMessage(conversationID, scheduledID)
+	}
+
+	id, err := strconv.ParseUint(strings.TrimPrefix(scheduledID, localIDPrefix), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid scheduled message ID %s", scheduledID)
+	}
+	if db.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	result := db.DB.Where("id = ? AND provider_instance_id = ? AND status = ?", id, instanceID, StatusPending).Delete(&models.ScheduledMessage{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to cancel scheduled message: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("the message was already sent or cancelled")
+	}
+	fmt.Printf("Scheduler: Cancelled local message %d\n", id)
+	return nil
+}
+
+// sendDue sends the local messages due at now. Each message is claimed before it is sent, so
+// that the app and loomd running together never both send it.
+func (s *Scheduler) sendDue(now time.Time) {
+	if db.DB == nil {
+		return
+	}
+
+	// Release the messages of a scheduler that stopped while sending them
+	released := db.DB.Model(&models.ScheduledMessage{}).Where("status = ? AND updated_at < ?", StatusSending, now.Add(-sendingTimeout)).Update("status", StatusPending)
+	if released.Error != nil {
+		fmt.Printf("Scheduler: WARNING - Failed to release stale messages: %v\n", released.Error)
+	} else if released.RowsAffected > 0 {
+		fmt.Printf("Scheduler: Released %d message(s) left in sending state\n", released.RowsAffected)
+	}
+
+	var due []models.ScheduledMessage
+	if err := db.DB.Where("status = ? AND post_at <= ?", StatusPending, now).Order("post_at").Find(&due).Error; err != nil {
+		fmt.Printf("Scheduler: ERROR - Failed to load due messages: %v\n", err)
+		return
+	}
+
+	for _, message := range due {
+		provider, err := s.providerManager.GetProvider(message.ProviderInstanceID)
+		if err != nil || !core.IsConnected(provider) {
+			// Not an attempt: the message is sent once the provider is connected
+			fmt.Printf("Scheduler: Message %d is due but %s is not connected, waiting\n", message.ID, message.ProviderInstanceID)
+			continue
+		}
+
+		claim := db.DB.Model(&models.ScheduledMessage{}).Where("id = ? AND status = ?", message.ID, StatusPending).Update("status", StatusSending)
+		if claim.Error != nil {
+			fmt.Printf("Scheduler: WARNING - Failed to claim message %d: %v\n", message.ID, claim.Error)
+			continue
+		}
+		if claim.RowsAffected == 0 {
+			// Cancelled, or claimed by another scheduler
+			continue
+		}
+
+		sent, err := provider.SendMessage(message.ProtocolConvID, message.Text, nil, message.ThreadID)
+		updates := map[string]interface{}{}
+		if err != nil {
+			message.Attempts++
+			updates["attempts"] = message.Attempts
+			updates["error"] = err.Error()
+			if message.Attempts >= maxSendAttempts {
+				updates["status"] = StatusFailed
+				fmt.Printf("Scheduler: ERROR - Failed to send message %d after %d attempts: %v\n", message.ID, message.Attempts, err)
+			} else {
+				updates["status"] = StatusPending
+				fmt.Printf("Scheduler: WARNING - Failed to send message %d (attempt %d): %v\n", message.ID, message.Attempts, err)
+			}
+		} else {
+			sentAt := time.Now()
+			updates["status"] = StatusSent
+			updates["error"] = ""
+			updates["sent_at"] = &sentAt
+			if sent != nil {
+				updates["sent_message_id"] = sent.ProtocolMsgID
+			}
+			fmt.Printf("Scheduler: Sent scheduled message %d in %s\n", message.ID, message.ProtocolConvID)
+		}
+		if err := db.DB.Model(&models.ScheduledMessage{}).Where("id = ?", message.ID).Updates(updates).Error; err != nil {
+			fmt.Printf("Scheduler: WARNING - Failed to update scheduled message %d: %v\n", message.ID, err)
+		}
+	}
+}
+
+// fromLocal converts a message of the local scheduler.
+func fromLocal(local models.ScheduledMessage) core.ScheduledMessage {
+	return core.ScheduledMessage{
+		ID:             localIDPrefix + strconv.FormatUint(uint64(local.ID), 10),
+		ConversationID: local.ProtocolConvID,
+		Text:           local.Text,
+		ThreadID:       local.ThreadID,
+		PostAt:         local.PostAt,
+	}
+}
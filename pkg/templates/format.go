@@ -0,0 +1,29 @@
+package templates
+
+import "regexp"
+
+// Template bodies use a small Markdown subset that is converted to the markup of each provider:
+// **bold**, _italic_, ~~strike~~, `code` and [text](url).
+var (
+	boldPattern   = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	strikePattern = regexp.MustCompile(`~~(.+?)~~`)
+	linkPattern   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+)
+
+// Format converts the Markdown subset used by templates to the markup of a provider.
+// Unknown providers receive the text unchanged.
+func Format(text string, providerID string) string {
+	switch providerID {
+	case "slack":
+		// Slack mrkdwn: *bold*, _italic_, ~strike~, <url|text>
+		text = boldPattern.ReplaceAllString(text, "*$1*")
+		text = strikePattern.ReplaceAllString(text, "~$1~")
+		text = linkPattern.ReplaceAllString(text, "<$2|$1>")
+	case "whatsapp":
+		// WhatsApp: *bold*, _italic_, ~strike~; links cannot have a label
+		text = boldPattern.ReplaceAllString(text, "*$1*")
+		text = strikePattern.ReplaceAllString(text, "~$1~")
+		text = linkPattern.ReplaceAllString(text, "$1 ($2)")
+	}
+	return text
+}
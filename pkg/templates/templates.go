@@ -0,0 +1,139 @@
+// Package templates renders reusable message snippets.
+// Template bodies contain placeholders such as {{contact.firstName}}, {{me.name}} or {{date}},
+// and custom prompts written {{prompt:Order number}} whose values are asked to the user.
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// promptPrefix marks a placeholder whose value is supplied by the user when rendering.
+const promptPrefix = "prompt:"
+
+// placeholderPattern matches "{{ name }}" placeholders.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// Placeholder describes a placeholder found in a template body.
+type Placeholder struct {
+	Name   string `json:"name"`             // Full placeholder name (e.g. "contact.firstName", "prompt:Order number")
+	Prompt string `json:"prompt,omitempty"` // Label to show to the user for custom prompts
+}
+
+// Context holds the values placeholders resolve to.
+type Context struct {
+	ContactName      string            // Display name of the contact (empty for groups)
+	MeName           string            // Display name of the logged-in account
+	ConversationName string            // Contact name or group name
+	ProviderName     string            // Display name of the provider instance (e.g. "WhatsApp Work")
+	Now              time.Time         // Time used for {{date}} and {{time}}
+	Values           map[string]string // Answers to custom prompts, keyed by prompt label
+}
+
+// Placeholders returns the distinct placeholders used in body, in order of appearance.
+func Placeholders(body string) []Placeholder {
+	seen := make(map[string]bool)
+	placeholders := []Placeholder{}
+	for _, match := range placeholderPattern.FindAllStringSubmatch(body, -1) {
+		name := match[1]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		placeholder := Placeholder{Name: name}
+		if strings.HasPrefix(name, promptPrefix) {
+			placeholder.Prompt = strings.TrimSpace(strings.TrimPrefix(name, promptPrefix))
+		}
+		placeholders = append(placeholders, placeholder)
+	}
+	return placeholders
+}
+
+// Render replaces the placeholders of body with the values from ctx.
+// It fails if a placeholder is unknown or has no value, listing all of them.
+func Render(body string, ctx Context) (string, error) {
+	if ctx.Now.IsZero() {
+		ctx.Now = time.Now()
+	}
+
+	missing := make(map[string]bool)
+	rendered := placeholderPattern.ReplaceAllStringFunc(body, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		value, ok := ctx.resolve(name)
+		if !ok {
+			missing[name] = true
+			return match
+		}
+		return value
+	})
+
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("unresolved placeholders: %s", strings.Join(names, ", "))
+	}
+	return rendered, nil
+}
+
+// resolve returns the value of a placeholder, and false if it is unknown or empty.
+func (ctx Context) resolve(name string) (string, bool) {
+	if strings.HasPrefix(name, promptPrefix) {
+		value, ok := ctx.Values[strings.TrimSpace(strings.TrimPrefix(name, promptPrefix))]
+		return value, ok
+	}
+
+	var value string
+	switch name {
+	case "contact.name":
+		value = ctx.ContactName
+	case "contact.firstName":
+		value = firstName(ctx.ContactName)
+	case "contact.lastName":
+		value = lastName(ctx.ContactName)
+	case "me.name":
+		value = ctx.MeName
+	case "me.firstName":
+		value = firstName(ctx.MeName)
+	case "conversation.name":
+		value = ctx.ConversationName
+	case "provider.name":
+		value = ctx.ProviderName
+	case "date":
+		value = ctx.Now.Format("2006-01-02")
+	case "time":
+		value = ctx.Now.Format("15:04")
+	case "datetime":
+		value = ctx.Now.Format("2006-01-02 15:04")
+	case "weekday":
+		value = ctx.Now.Weekday().String()
+	default:
+		// Custom values can also be referenced without the prompt prefix
+		value = ctx.Values[name]
+	}
+	return value, value != ""
+}
+
+// firstName returns the first word of a display name.
+func firstName(name string) string {
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// lastName returns everything after the first word of a display name.
+func lastName(name string) string {
+	fields := strings.Fields(name)
+	if len(fields) < 2 {
+		return ""
+	}
+	return strings.Join(fields[1:], " ")
+}
@@ -0,0 +1,460 @@
+// Package webhooks forwards provider events to external HTTP endpoints.
+// Each event matching a webhook's filters is POSTed as JSON, signed with HMAC-SHA256
+// when the webhook has a secret, retried with exponential backoff, and stored in the
+// dead-letter table when every attempt failed. Retries wait on timers rather than in the
+// workers, so failing endpoints do not delay the deliveries to the others.
+package webhooks
+
+import (
+	"Loom/pkg/core"
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts is the number of deliveries tried when a webhook does not set one.
+	DefaultMaxAttempts = 5
+	// initialBackoff is the delay before the first retry; it doubles after each attempt.
+	initialBackoff = 2 * time.Second
+	// maxBackoff caps the delay between two attempts.
+	maxBackoff = 5 * time.Minute
+	// requestTimeout bounds a single HTTP delivery.
+	requestTimeout = 15 * time.Second
+	// workerCount is the number of concurrent deliveries.
+	workerCount = 4
+	// queueSize is the number of deliveries waiting for a worker.
+	queueSize = 1000
+)
+
+// Headers sent with each delivery.
+const (
+	HeaderEvent     = "X-Loom-Event"
+	HeaderDelivery  = "X-Loom-Delivery"
+	HeaderTimestamp = "X-Loom-Timestamp"
+	// HeaderSignature is "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>".
+	HeaderSignature = "X-Loom-Signature"
+)
+
+// defaultEventTypes are the events delivered to webhooks without an event type filter.
+var defaultEventTypes = []core.EventType{
+	core.EventTypeMessage,
+	core.EventTypeReaction,
+	core.EventTypeReceipt,
+	core.EventTypeGroupChange,
+}
+
+// Payload is the JSON body POSTed to webhooks.
+type Payload struct {
+	DeliveryID string             `json:"deliveryId"`
+	Type       core.EventType     `json:"type"`
+	InstanceID string             `json:"instanceId"`
+	Timestamp  time.Time          `json:"timestamp"`
+	Data       core.ProviderEvent `json:"data"`
+}
+
+// delivery is a payload waiting to be sent to a webhook.
+type delivery struct {
+	webhook    models.Webhook
+	deliveryID string
+	eventType  string
+	body       []byte
+	attempts   int           // Attempts made so far
+	backoff    time.Duration // Delay before the next retry
+	statusCode int           // HTTP status of the last attempt (0 = network error)
+	lastError  string        // Error of the last attempt
+}
+
+// Dispatcher delivers provider events to the configured webhooks.
+type Dispatcher struct {
+	providerManager *core.ProviderManager
+	client          *http.Client
+	queue           chan delivery
+	mu              sync.RWMutex
+	webhooks        []models.Webhook
+	initialBackoff  time.Duration
+	retryMu         sync.Mutex
+	retries         map[*time.Timer]delivery // Deliveries waiting for their next attempt
+}
+
+// NewDispatcher creates a dispatcher for the events of providerManager.
+// Webhooks are loaded from the database immediately.
+func NewDispatcher(providerManager *core.ProviderManager) *Dispatcher {
+	d := &Dispatcher{
+		providerManager: providerManager,
+		client:          &http.Client{Timeout: requestTimeout},
+		queue:           make(chan delivery, queueSize),
+		initialBackoff:  initialBackoff,
+		retries:         make(map[*time.Timer]delivery),
+	}
+	if err := d.Reload(); err != nil {
+		fmt.Printf("Webhooks: WARNING - failed to load webhooks: %v\n", err)
+	}
+	return d
+}
+
+// Reload re-reads the enabled webhooks from the database. Call it after webhooks are changed.
+func (d *Dispatcher) Reload() error {
+	if db.DB == nil {
+		return nil
+	}
+
+	var webhooks []models.Webhook
+	if err := db.DB.Where("enabled = ?", true).Find(&webhooks).Error; err != nil {
+		return fmt.Errorf("failed to load webhooks: %w", err)
+	}
+
+	d.mu.Lock()
+	d.webhooks = webhooks
+	d.mu.Unlock()
+	fmt.Printf("Webhooks: Loaded %d enabled webhook(s)\n", len(webhooks))
+	return nil
+}
+
+// Run dispatches the events of all provider instances until ctx is cancelled.
+// Once the workers have finished, the deliveries still waiting for a retry are dead-lettered.
+func (d *Dispatcher) Run(ctx context.Context) {
+	defer d.stopRetries()
+	var workers sync.WaitGroup
+	defer workers.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for i := 0; i < workerCount; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			d.worker(ctx)
+		}()
+	}
+
+	events, unsubscribe := d.providerManager.SubscribeEvents()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case instanceEvent, ok := <-events:
+			if !ok {
+				return
+			}
+			d.Dispatch(instanceEvent.InstanceID, instanceEvent.Event)
+		}
+	}
+}
+
+// Dispatch queues an event for every webhook whose filters match it.
+func (d *Dispatcher) Dispatch(instanceID string, event core.ProviderEvent) {
+	d.mu.RLock()
+	webhooks := d.webhooks
+	d.mu.RUnlock()
+
+	var body []byte
+	deliveryID := ""
+	for _, webhook := range webhooks {
+		if !Matches(webhook, instanceID, event) {
+			continue
+		}
+
+		// Serialize once per event: all webhooks receive the same delivery
+		if body == nil {
+			deliveryID = newDeliveryID()
+			var err error
+			body, err = json.Marshal(Payload{
+				DeliveryID: deliveryID,
+				Type:       event.Type(),
+				InstanceID: instanceID,
+				Timestamp:  time.Now(),
+				Data:       event,
+			})
+			if err != nil {
+				fmt.Printf("Webhooks: ERROR - failed to marshal %s event: %v\n", event.Type(), err)
+				return
+			}
+		}
+
+		d.enqueue(delivery{webhook: webhook, deliveryID: deliveryID, eventType: string(event.Type()), body: body})
+	}
+}
+
+// Redeliver queues a dead letter again and removes it from the dead-letter table.
+func (d *Dispatcher) Redeliver(deadLetterID uint) error {
+	if db.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	var letter models.WebhookDeadLetter
+	if err := db.DB.First(&letter, deadLetterID).Error; err != nil {
+		return fmt.Errorf("dead letter %d not found: %w", deadLetterID, err)
+	}
+	var webhook models.Webhook
+	if err := db.DB.First(&webhook, letter.WebhookID).Error; err != nil {
+		return fmt.Errorf("webhook %d not found: %w", letter.WebhookID, err)
+	}
+
+	if err := db.DB.Delete(&letter).Error; err != nil {
+		return err
+	}
+	d.enqueue(delivery{webhook: webhook, deliveryID: letter.DeliveryID, eventType: letter.EventType, body: []byte(letter.Payload)})
+	return nil
+}
+
+// Matches reports whether an event passes the instance, conversation and event type filters of a webhook.
+func Matches(webhook models.Webhook, instanceID string, event core.ProviderEvent) bool {
+	if !webhook.Enabled {
+		return false
+	}
+
+	eventTypes := splitFilter(webhook.EventTypes)
+	if len(eventTypes) == 0 {
+		for _, t := range defaultEventTypes {
+			eventTypes = append(eventTypes, string(t))
+		}
+	}
+	if !contains(eventTypes, string(event.Type())) {
+		return false
+	}
+
+	if instances := splitFilter(webhook.InstanceFilter); len(instances) > 0 && !contains(instances, instanceID) {
+		return false
+	}
+
+	if conversations := splitFilter(webhook.ConversationFilter); len(conversations) > 0 {
+		conversationID := eventConversationID(event)
+		if conversationID == "" || !contains(conversations, conversationID) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateWebhook checks that a webhook is well-formed before it is saved.
+func ValidateWebhook(webhook models.Webhook) error {
+	parsed, err := url.Parse(webhook.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("invalid webhook URL: %q", webhook.URL)
+	}
+	if webhook.MaxAttempts < 0 {
+		return fmt.Errorf("maxAttempts must be positive")
+	}
+	return nil
+}
+
+// Sign returns the signature of a payload for the HeaderSignature header.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// enqueue adds a delivery to the queue, dead-lettering it if the queue is full.
+func (d *Dispatcher) enqueue(job delivery) {
+	select {
+	case d.queue <- job:
+	default:
+		fmt.Printf("Webhooks: WARNING - delivery queue is full, dead-lettering %s for webhook %d\n", job.deliveryID, job.webhook.ID)
+		saveDeadLetter(job, job.attempts, job.statusCode, "delivery queue full")
+	}
+}
+
+// worker sends queued deliveries until ctx is cancelled.
+func (d *Dispatcher) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-d.queue:
+			d.deliver(ctx, job)
+		}
+	}
+}
+
+// deliver makes one attempt at a delivery. A failure worth retrying is queued again after
+// the backoff delay; the last failure is dead-lettered.
+func (d *Dispatcher) deliver(ctx context.Context, job delivery) {
+	maxAttempts := job.webhook.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	statusCode, retryable, err := d.post(ctx, job)
+	if err == nil {
+		return
+	}
+	job.attempts++
+	job.statusCode = statusCode
+	job.lastError = err.Error()
+	fmt.Printf("Webhooks: Delivery %s to webhook %d failed (attempt %d/%d): %v\n", job.deliveryID, job.webhook.ID, job.attempts, maxAttempts, err)
+	if !retryable || job.attempts >= maxAttempts {
+		saveDeadLetter(job, job.attempts, job.statusCode, job.lastError)
+		return
+	}
+	if ctx.Err() != nil {
+		saveDeadLetter(job, job.attempts, job.statusCode, "dispatcher stopped: "+job.lastError)
+		return
+	}
+
+	if job.backoff == 0 {
+		job.backoff = d.initialBackoff
+	} else {
+		job.backoff = min(job.backoff*2, maxBackoff)
+	}
+	d.scheduleRetry(job)
+}
+
+// scheduleRetry queues a delivery again once its backoff delay has passed.
+func (d *Dispatcher) scheduleRetry(job delivery) {
+	d.retryMu.Lock()
+	defer d.retryMu.Unlock()
+
+	var timer *time.Timer
+	timer = time.AfterFunc(job.backoff, func() {
+		d.retryMu.Lock()
+		_, pending := d.retries[timer]
+		delete(d.retries, timer)
+		d.retryMu.Unlock()
+		if pending {
+			d.enqueue(job)
+		}
+	})
+	d.retries[timer] = job
+}
+
+// stopRetries cancels the pending retries and dead-letters their deliveries.
+func (d *Dispatcher) stopRetries() {
+	d.retryMu.Lock()
+	retries := d.retries
+	d.retries = make(map[*time.Timer]delivery)
+	d.retryMu.Unlock()
+
+	for timer, job := range retries {
+		timer.Stop()
+		saveDeadLetter(job, job.attempts, job.statusCode, "dispatcher stopped: "+job.lastError)
+	}
+}
+
+// post performs one HTTP delivery. It returns the status code, whether a failure is worth retrying, and the error.
+func (d *Dispatcher) post(ctx context.Context, job delivery) (int, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.webhook.URL, bytes.NewReader(job.body))
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Loom-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, job.eventType)
+	req.Header.Set(HeaderDelivery, job.deliveryID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if job.webhook.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(job.webhook.Secret, timestamp, job.body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, false, nil
+	}
+	// Server errors and rate limiting are transient; other client errors will not get better
+	retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout
+	return resp.StatusCode, retryable, fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+// saveDeadLetter stores a failed delivery.
+func saveDeadLetter(job delivery, attempts int, statusCode int, lastError string) {
+	if db.DB == nil {
+		return
+	}
+	letter := models.WebhookDeadLetter{
+		WebhookID:      job.webhook.ID,
+		DeliveryID:     job.deliveryID,
+		EventType:      job.eventType,
+		Payload:        string(job.body),
+		Attempts:       attempts,
+		LastStatusCode: statusCode,
+		LastError:      lastError,
+	}
+	if err := db.DB.Create(&letter).Error; err != nil {
+		fmt.Printf("Webhooks: ERROR - failed to save dead letter for delivery %s: %v\n", job.deliveryID, err)
+	}
+}
+
+// eventConversationID returns the protocol conversation ID an event belongs to, if any.
+func eventConversationID(event core.ProviderEvent) string {
+	switch e := event.(type) {
+	case core.MessageEvent:
+		return e.Message.ProtocolConvID
+	case core.ReactionEvent:
+		return e.ConversationID
+	case core.TypingEvent:
+		return e.ConversationID
+	case core.GroupChangeEvent:
+		return e.ConversationID
+	case core.ReceiptEvent:
+		return e.ConversationID
+	case core.RetryReceiptEvent:
+		return e.ConversationID
+	case core.ReadMarkerEvent:
+		return e.ConversationID
+	case core.ThreadUpdateEvent:
+		return e.ConversationID
+	case core.UploadProgressEvent:
+		return e.ConversationID
+	case core.AttachmentUpdateEvent:
+		return e.ConversationID
+	case core.SyncStatusEvent:
+		return e.ConversationID
+	}
+	return ""
+}
+
+// newDeliveryID returns a random identifier for a delivery.
+func newDeliveryID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// splitFilter parses a comma-separated filter.
+func splitFilter(value string) []string {
+	var values []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// contains reports whether values contains value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
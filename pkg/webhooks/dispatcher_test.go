@@ -0,0 +1,275 @@
+package webhooks
+
+import (
+	"Loom/pkg/core"
+	"Loom/pkg/db"
+	"Loom/pkg/models"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// receivedRequest is a delivery seen by the test receiver.
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is an httptest webhook endpoint answering with a scripted list of status codes
+// (the last one is repeated).
+type receiver struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedRequest
+	received chan struct{}
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{statuses: statuses, received: make(chan struct{}, 100)}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		status := r.statuses[min(len(r.requests), len(r.statuses)-1)]
+		r.requests = append(r.requests, receivedRequest{header: req.Header.Clone(), body: body})
+		r.mu.Unlock()
+		w.WriteHeader(status)
+		r.received <- struct{}{}
+	}))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+// wait blocks until n deliveries were received.
+func (r *receiver) wait(t *testing.T, n int) []receivedRequest {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-r.received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d deliveries, want %d", i, n)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedRequest(nil), r.requests...)
+}
+
+// setupDatabase replaces the database with an in-memory one holding the given webhooks.
+func setupDatabase(t *testing.T, webhooks ...models.Webhook) {
+	t.Helper()
+	database, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.AutoMigrate(&models.Webhook{}, &models.WebhookDeadLetter{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range webhooks {
+		if err := database.Create(&webhooks[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	previous := db.DB
+	db.DB = database
+	t.Cleanup(func() { db.DB = previous })
+}
+
+// startDispatcher runs a dispatcher with fast retries until the test ends.
+func startDispatcher(t *testing.T) *Dispatcher {
+	t.Helper()
+	d := NewDispatcher(core.NewProviderManager())
+	d.initialBackoff = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		d.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+	return d
+}
+
+// waitDeadLetter waits for the first dead letter to be stored.
+func waitDeadLetter(t *testing.T) models.WebhookDeadLetter {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var letters []models.WebhookDeadLetter
+		if err := db.DB.Limit(1).Find(&letters).Error; err == nil && len(letters) > 0 {
+			return letters[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no dead letter was stored")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func messageEvent(conversationID string) core.MessageEvent {
+	return core.MessageEvent{Message: models.Message{ProtocolConvID: conversationID, ProtocolMsgID: "msg-1", Body: "hello"}}
+}
+
+func TestDeliverySignedJSON(t *testing.T) {
+	r := newReceiver(t, http.StatusOK)
+	setupDatabase(t, models.Webhook{URL: r.server.URL, Secret: "s3cret", Enabled: true})
+	d := startDispatcher(t)
+
+	d.Dispatch("whatsapp-1", messageEvent("chat-1"))
+	request := r.wait(t, 1)[0]
+
+	var payload struct {
+		DeliveryID string         `json:"deliveryId"`
+		Type       core.EventType `json:"type"`
+		InstanceID string         `json:"instanceId"`
+		Data       struct {
+			Message models.Message
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(request.body, &payload); err != nil {
+		t.Fatalf("invalid JSON body %s: %v", request.body, err)
+	}
+	if payload.Type != core.EventTypeMessage || payload.InstanceID != "whatsapp-1" || payload.Data.Message.Body != "hello" {
+		t.Errorf("unexpected payload %s", request.body)
+	}
+	if got := request.header.Get(HeaderDelivery); got == "" || got != payload.DeliveryID {
+		t.Errorf("%s = %q, want %q", HeaderDelivery, got, payload.DeliveryID)
+	}
+	if got := request.header.Get(HeaderEvent); got != "message" {
+		t.Errorf("%s = %q, want message", HeaderEvent, got)
+	}
+	want := Sign("s3cret", request.header.Get(HeaderTimestamp), request.body)
+	if got := request.header.Get(HeaderSignature); got != want {
+		t.Errorf("%s = %q, want %q", HeaderSignature, got, want)
+	}
+}
+
+func TestDeliveryFilters(t *testing.T) {
+	r := newReceiver(t, http.StatusOK)
+	setupDatabase(t, models.Webhook{
+		URL:                r.server.URL,
+		Enabled:            true,
+		InstanceFilter:     "slack-1",
+		ConversationFilter: "C1, C2",
+		EventTypes:         "message,typing",
+	})
+	d := startDispatcher(t)
+
+	d.Dispatch("whatsapp-1", messageEvent("C1"))                                   // other instance
+	d.Dispatch("slack-1", messageEvent("C3"))                                      // other conversation
+	d.Dispatch("slack-1", core.ReactionEvent{ConversationID: "C1", Emoji: "+1"})   // other event type
+	d.Dispatch("slack-1", core.TypingEvent{ConversationID: "C2", UserID: "U1"})    // delivered
+	d.Dispatch("slack-1", core.ContactStatusEvent{UserID: "U1", Status: "online"}) // no conversation
+	d.Dispatch("slack-1", messageEvent("C1"))                                      // delivered
+	requests := r.wait(t, 2)
+
+	// Let any unexpected delivery arrive
+	time.Sleep(100 * time.Millisecond)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.requests) != 2 {
+		t.Fatalf("received %d deliveries, want 2", len(r.requests))
+	}
+	events := map[string]bool{}
+	for _, request := range requests {
+		events[request.header.Get(HeaderEvent)] = true
+	}
+	if !events["typing"] || !events["message"] {
+		t.Errorf("delivered events %v, want typing and message", events)
+	}
+}
+
+func TestMatchesDefaultEventTypes(t *testing.T) {
+	webhook := models.Webhook{Enabled: true}
+	tests := []struct {
+		event core.ProviderEvent
+		want  bool
+	}{
+		{messageEvent("C1"), true},
+		{core.ReactionEvent{ConversationID: "C1"}, true},
+		{core.ReceiptEvent{ConversationID: "C1"}, true},
+		{core.GroupChangeEvent{ConversationID: "C1"}, true},
+		{core.TypingEvent{ConversationID: "C1"}, false},
+		{core.PresenceEvent{UserID: "U1"}, false},
+	}
+	for _, tt := range tests {
+		if got := Matches(webhook, "slack-1", tt.event); got != tt.want {
+			t.Errorf("Matches(%s) = %v, want %v", tt.event.Type(), got, tt.want)
+		}
+	}
+	webhook.Enabled = false
+	if Matches(webhook, "slack-1", messageEvent("C1")) {
+		t.Error("a disabled webhook matched")
+	}
+}
+
+func TestRetryOnServerError(t *testing.T) {
+	r := newReceiver(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK)
+	setupDatabase(t, models.Webhook{URL: r.server.URL, Enabled: true, MaxAttempts: 5})
+	d := startDispatcher(t)
+
+	d.Dispatch("whatsapp-1", messageEvent("chat-1"))
+	requests := r.wait(t, 3)
+
+	deliveryID := requests[0].header.Get(HeaderDelivery)
+	for _, request := range requests {
+		if got := request.header.Get(HeaderDelivery); got != deliveryID {
+			t.Errorf("retry has delivery ID %q, want %q", got, deliveryID)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	var count int64
+	db.DB.Model(&models.WebhookDeadLetter{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d dead letters after a successful retry, want 0", count)
+	}
+}
+
+func TestDeadLetterAfterLastAttempt(t *testing.T) {
+	r := newReceiver(t, http.StatusServiceUnavailable)
+	setupDatabase(t, models.Webhook{URL: r.server.URL, Enabled: true, MaxAttempts: 3})
+	d := startDispatcher(t)
+
+	d.Dispatch("whatsapp-1", messageEvent("chat-1"))
+	requests := r.wait(t, 3)
+
+	letter := waitDeadLetter(t)
+	if letter.Attempts != 3 || letter.LastStatusCode != http.StatusServiceUnavailable || letter.EventType != "message" {
+		t.Errorf("dead letter %+v, want 3 attempts ending with 503", letter)
+	}
+	if letter.DeliveryID != requests[0].header.Get(HeaderDelivery) || letter.Payload != string(requests[0].body) {
+		t.Errorf("dead letter does not hold the delivery %s", letter.DeliveryID)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.requests) != 3 {
+		t.Errorf("received %d attempts, want 3", len(r.requests))
+	}
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	r := newReceiver(t, http.StatusBadRequest)
+	setupDatabase(t, models.Webhook{URL: r.server.URL, Enabled: true, MaxAttempts: 3})
+	d := startDispatcher(t)
+
+	d.Dispatch("whatsapp-1", messageEvent("chat-1"))
+	r.wait(t, 1)
+
+	letter := waitDeadLetter(t)
+	if letter.Attempts != 1 || letter.LastStatusCode != http.StatusBadRequest {
+		t.Errorf("dead letter %+v, want 1 attempt ending with 400", letter)
+	}
+}